@@ -110,6 +110,18 @@ func (a *AbnormalTask) adminGetTaskForm(ctx *gin.Context) {
 	resp.Format(a.abnormalTask.AdminGetTaskForm(pkg.CTXTransfer(ctx), adminTaskReq)).Context(ctx)
 }
 
+func (a *AbnormalTask) adminContinueNode(ctx *gin.Context) {
+	resp.Format(a.abnormalTask.AdminContinueNode(pkg.CTXTransfer(ctx), &flow.AdminNodeReq{
+		ID: ctx.Param("id"),
+	})).Context(ctx)
+}
+
+func (a *AbnormalTask) adminAbandonNode(ctx *gin.Context) {
+	resp.Format(a.abnormalTask.AdminAbandonNode(pkg.CTXTransfer(ctx), &flow.AdminNodeReq{
+		ID: ctx.Param("id"),
+	})).Context(ctx)
+}
+
 func (a *AbnormalTask) getURIParams(ctx *gin.Context) (*flow.AdminTaskReq, bool) {
 	processInstanceID, ok1 := ctx.Params.Get("processInstanceID")
 	taskID, ok2 := ctx.Params.Get("taskID")
@@ -124,3 +136,21 @@ func (a *AbnormalTask) getURIParams(ctx *gin.Context) (*flow.AdminTaskReq, bool)
 		TaskID:            taskID,
 	}, ok
 }
+
+func (a *AbnormalTask) batchHandle(ctx *gin.Context) {
+	req := &flow.BatchAbnormalTaskReq{}
+	if err := ctx.ShouldBind(req); err != nil {
+		ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	resp.Format(a.abnormalTask.BatchHandle(pkg.CTXTransfer(ctx), req)).Context(ctx)
+}
+
+func (a *AbnormalTask) batchJob(ctx *gin.Context) {
+	req := &flow.AbnormalTaskJobReq{}
+	if err := ctx.ShouldBind(req); err != nil {
+		ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	resp.Format(a.abnormalTask.BatchJob(pkg.CTXTransfer(ctx), req)).Context(ctx)
+}
@@ -0,0 +1,80 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package restful
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/quanxiang-cloud/flow/internal/flow"
+	"github.com/quanxiang-cloud/flow/internal/server/options"
+	"github.com/quanxiang-cloud/flow/pkg"
+	"github.com/quanxiang-cloud/flow/pkg/config"
+	"github.com/quanxiang-cloud/flow/pkg/misc/logger"
+	"github.com/quanxiang-cloud/flow/pkg/misc/resp"
+)
+
+// AppSecret info
+type AppSecret struct {
+	appSecret flow.AppSecret
+}
+
+// NewAppSecret new
+func NewAppSecret(c *config.Configs, opts ...options.Options) (*AppSecret, error) {
+	s, err := flow.NewAppSecret(c, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &AppSecret{
+		appSecret: s,
+	}, nil
+}
+
+func (s *AppSecret) save(ctx *gin.Context) {
+	req := &flow.SaveAppSecretReq{}
+	if err := ctx.ShouldBind(req); err != nil {
+		logger.Logger.Error(err)
+		resp.Format(nil, err).Context(ctx)
+		return
+	}
+	resp.Format(s.appSecret.Save(pkg.CTXTransfer(ctx), req)).Context(ctx)
+}
+
+func (s *AppSecret) rotate(ctx *gin.Context) {
+	req := &flow.AppSecretReq{}
+	if err := ctx.ShouldBind(req); err != nil {
+		logger.Logger.Error(err)
+		resp.Format(nil, err).Context(ctx)
+		return
+	}
+	resp.Format(s.appSecret.Rotate(pkg.CTXTransfer(ctx), req)).Context(ctx)
+}
+
+func (s *AppSecret) revoke(ctx *gin.Context) {
+	req := &flow.AppSecretReq{}
+	if err := ctx.ShouldBind(req); err != nil {
+		logger.Logger.Error(err)
+		resp.Format(nil, err).Context(ctx)
+		return
+	}
+	resp.Format(s.appSecret.Revoke(pkg.CTXTransfer(ctx), req)).Context(ctx)
+}
+
+func (s *AppSecret) info(ctx *gin.Context) {
+	req := &flow.AppSecretReq{}
+	if err := ctx.ShouldBind(req); err != nil {
+		logger.Logger.Error(err)
+		resp.Format(nil, err).Context(ctx)
+		return
+	}
+	resp.Format(s.appSecret.Info(pkg.CTXTransfer(ctx), req)).Context(ctx)
+}
@@ -0,0 +1,70 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package restful
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/quanxiang-cloud/flow/internal/flow"
+	"github.com/quanxiang-cloud/flow/internal/server/options"
+	"github.com/quanxiang-cloud/flow/pkg"
+	"github.com/quanxiang-cloud/flow/pkg/config"
+	"github.com/quanxiang-cloud/flow/pkg/misc/logger"
+	"github.com/quanxiang-cloud/flow/pkg/misc/resp"
+)
+
+// Delegation info
+type Delegation struct {
+	delegation flow.Delegation
+}
+
+// NewDelegation new
+func NewDelegation(c *config.Configs, opts ...options.Options) (*Delegation, error) {
+	d, err := flow.NewDelegation(c, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &Delegation{
+		delegation: d,
+	}, nil
+}
+
+func (d *Delegation) save(ctx *gin.Context) {
+	req := &flow.SaveDelegationReq{}
+	if err := ctx.ShouldBind(req); err != nil {
+		logger.Logger.Error(err)
+		resp.Format(nil, err).Context(ctx)
+		return
+	}
+	resp.Format(d.delegation.Save(pkg.CTXTransfer(ctx), req)).Context(ctx)
+}
+
+func (d *Delegation) cancel(ctx *gin.Context) {
+	req := &flow.DelegationReq{}
+	if err := ctx.ShouldBind(req); err != nil {
+		logger.Logger.Error(err)
+		resp.Format(nil, err).Context(ctx)
+		return
+	}
+	resp.Format(d.delegation.Cancel(pkg.CTXTransfer(ctx), req)).Context(ctx)
+}
+
+func (d *Delegation) list(ctx *gin.Context) {
+	req := &flow.ListDelegationReq{}
+	if err := ctx.ShouldBind(req); err != nil {
+		logger.Logger.Error(err)
+		resp.Format(nil, err).Context(ctx)
+		return
+	}
+	resp.Format(d.delegation.List(pkg.CTXTransfer(ctx), req)).Context(ctx)
+}
@@ -238,3 +238,67 @@ func (f *Flow) triggerFlow(ctx *gin.Context) {
 	}
 	resp.Format(nil, f.trigger.MessageTrigger(req)).Context(ctx)
 }
+
+func (f *Flow) versionList(ctx *gin.Context) {
+	req := &flow.VersionListReq{}
+	if err := ctx.ShouldBind(req); err != nil {
+		ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	resp.Format(f.flow.VersionList(pkg.CTXTransfer(ctx), req)).Context(ctx)
+}
+
+func (f *Flow) versionInfo(ctx *gin.Context) {
+	ID := ctx.Param("ID")
+	resp.Format(f.flow.VersionInfo(pkg.CTXTransfer(ctx), ID)).Context(ctx)
+}
+
+func (f *Flow) rollbackVersion(ctx *gin.Context) {
+	req := &flow.RollbackVersionReq{}
+	if err := ctx.ShouldBind(req); err != nil {
+		ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	profile := header2.GetProfile(ctx)
+	resp.Format(f.flow.RollbackVersion(pkg.CTXTransfer(ctx), req, profile.UserID)).Context(ctx)
+}
+
+func (f *Flow) validate(ctx *gin.Context) {
+	req := &flow.ValidateReq{}
+	if err := ctx.ShouldBind(req); err != nil {
+		ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	resp.Format(f.flow.Validate(pkg.CTXTransfer(ctx), req)).Context(ctx)
+}
+
+func (f *Flow) simulate(ctx *gin.Context) {
+	req := &flow.SimulateReq{}
+	if err := ctx.ShouldBind(req); err != nil {
+		ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	profile := header2.GetProfile(ctx)
+	resp.Format(f.flow.Simulate(pkg.CTXTransfer(ctx), req, profile.UserID)).Context(ctx)
+}
+
+func (f *Flow) exportBPMN(ctx *gin.Context) {
+	ID := ctx.Param("ID")
+	data, err := f.flow.ExportBPMN(pkg.CTXTransfer(ctx), ID)
+	if err != nil {
+		resp.Format(nil, err).Context(ctx)
+		return
+	}
+	ctx.Header("Content-Disposition", "attachment; filename="+ID+".bpmn")
+	ctx.Data(http.StatusOK, "application/xml; charset=utf-8", data)
+}
+
+func (f *Flow) importBPMN(ctx *gin.Context) {
+	req := &flow.ImportBPMNReq{}
+	if err := ctx.ShouldBind(req); err != nil {
+		ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	profile := header2.GetProfile(ctx)
+	resp.Format(f.flow.ImportBPMN(pkg.CTXTransfer(ctx), req, profile.UserID)).Context(ctx)
+}
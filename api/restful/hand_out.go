@@ -74,6 +74,13 @@ func (h *HandOut) handOut(ctx *gin.Context) {
 		H = h
 		// 兼容之前
 		// TODO 如果添加了其他类型，这里需要增加条件判断
+	} else if len(s) == 2 && s[0] == convert.CallbackOfWebhook {
+		h, err := callback_tasks.GetCallback(s[0])
+		if err != nil {
+			resp.Format(nil, err).Context(ctx)
+			return
+		}
+		H = h
 	} else if len(s) == 2 && s[0] == convert.CallbackOfCron {
 		// 启动流程
 
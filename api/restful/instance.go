@@ -417,6 +417,42 @@ func (i *Instance) reviewTask(ctx *gin.Context) {
 	resp.Format(r, nil).Context(ctx)
 }
 
+// BatchReviewTask rest
+func (i *Instance) batchReviewTask(ctx *gin.Context) {
+	req := &flow.BatchReviewReq{}
+	err := ctx.ShouldBind(req)
+	if err != nil {
+		logger.Logger.Error(err)
+		resp.Format(nil, err).Context(ctx)
+		return
+	}
+	resp.Format(i.instance.BatchReviewTask(pkg.CTXTransfer(ctx), req)).Context(ctx)
+}
+
+// Suspend rest
+func (i *Instance) suspend(ctx *gin.Context) {
+	req := &flow.InstanceSuspendReq{}
+	err := ctx.ShouldBind(req)
+	if err != nil {
+		logger.Logger.Error(err)
+		resp.Format(nil, err).Context(ctx)
+		return
+	}
+	resp.Format(i.instance.Suspend(pkg.CTXTransfer(ctx), req)).Context(ctx)
+}
+
+// Resume rest
+func (i *Instance) resume(ctx *gin.Context) {
+	req := &flow.InstanceSuspendReq{}
+	err := ctx.ShouldBind(req)
+	if err != nil {
+		logger.Logger.Error(err)
+		resp.Format(nil, err).Context(ctx)
+		return
+	}
+	resp.Format(i.instance.Resume(pkg.CTXTransfer(ctx), req)).Context(ctx)
+}
+
 // GetFlowInstanceForm rest
 func (i *Instance) getFlowInstanceForm(ctx *gin.Context) {
 	processInstanceID := ctx.Param("processInstanceID")
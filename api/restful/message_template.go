@@ -0,0 +1,80 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package restful
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/quanxiang-cloud/flow/internal/flow"
+	"github.com/quanxiang-cloud/flow/internal/server/options"
+	"github.com/quanxiang-cloud/flow/pkg"
+	"github.com/quanxiang-cloud/flow/pkg/config"
+	"github.com/quanxiang-cloud/flow/pkg/misc/logger"
+	"github.com/quanxiang-cloud/flow/pkg/misc/resp"
+)
+
+// MessageTemplate info
+type MessageTemplate struct {
+	messageTemplate flow.MessageTemplate
+}
+
+// NewMessageTemplate new
+func NewMessageTemplate(c *config.Configs, opts ...options.Options) (*MessageTemplate, error) {
+	m, err := flow.NewMessageTemplate(c, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &MessageTemplate{
+		messageTemplate: m,
+	}, nil
+}
+
+func (m *MessageTemplate) save(ctx *gin.Context) {
+	req := &flow.SaveMessageTemplateReq{}
+	if err := ctx.ShouldBind(req); err != nil {
+		logger.Logger.Error(err)
+		resp.Format(nil, err).Context(ctx)
+		return
+	}
+	resp.Format(m.messageTemplate.Save(pkg.CTXTransfer(ctx), req)).Context(ctx)
+}
+
+func (m *MessageTemplate) delete(ctx *gin.Context) {
+	req := &flow.MessageTemplateReq{}
+	if err := ctx.ShouldBind(req); err != nil {
+		logger.Logger.Error(err)
+		resp.Format(nil, err).Context(ctx)
+		return
+	}
+	resp.Format(m.messageTemplate.Delete(pkg.CTXTransfer(ctx), req)).Context(ctx)
+}
+
+func (m *MessageTemplate) list(ctx *gin.Context) {
+	req := &flow.ListMessageTemplateReq{}
+	if err := ctx.ShouldBind(req); err != nil {
+		logger.Logger.Error(err)
+		resp.Format(nil, err).Context(ctx)
+		return
+	}
+	resp.Format(m.messageTemplate.List(pkg.CTXTransfer(ctx), req)).Context(ctx)
+}
+
+func (m *MessageTemplate) preview(ctx *gin.Context) {
+	req := &flow.PreviewMessageTemplateReq{}
+	if err := ctx.ShouldBind(req); err != nil {
+		logger.Logger.Error(err)
+		resp.Format(nil, err).Context(ctx)
+		return
+	}
+	resp.Format(m.messageTemplate.Preview(pkg.CTXTransfer(ctx), req)).Context(ctx)
+}
@@ -82,6 +82,14 @@ func NewRouter(c *config.Configs) (*Router, error) {
 		v1.POST("/appReplicationImport", flow.appReplicationImport)
 
 		v1.POST("/triggerFlow", flow.triggerFlow)
+
+		v1.POST("/version/list", flow.versionList)
+		v1.POST("/version/info/:ID", flow.versionInfo)
+		v1.POST("/version/rollback", flow.rollbackVersion)
+		v1.POST("/validate", flow.validate)
+		v1.POST("/simulate", flow.simulate)
+		v1.POST("/bpmn/export/:ID", flow.exportBPMN)
+		v1.POST("/bpmn/import", flow.importBPMN)
 	}
 
 	// Instance router
@@ -111,12 +119,15 @@ func NewRouter(c *config.Configs) (*Router, error) {
 		v2.POST("/deliverTask/:processInstanceID/:taskID", instance.deliverTask)
 		v2.POST("/getFlowInstanceCount", instance.flowInstanceCount)
 		v2.POST("/reviewTask/:processInstanceID/:taskID", instance.reviewTask)
+		v2.POST("/batchReviewTask", instance.batchReviewTask)
 		v2.POST("/getFlowInstanceForm/:processInstanceID", instance.getFlowInstanceForm)
 		v2.POST("/getFormData/:processInstanceID/:taskID", instance.getFormData)
 		v2.POST("/processHistories/:processInstanceID", instance.processHistories)
 
 		v2.POST("/list", instance.list)
 		v2.POST("/node/list", instance.nodeList)
+		v2.POST("/suspend", instance.suspend)
+		v2.POST("/resume", instance.resume)
 	}
 
 	// comment router
@@ -143,6 +154,10 @@ func NewRouter(c *config.Configs) (*Router, error) {
 		v4.POST("/adminAbandon/:processInstanceID/:taskID", abnormal.adminAbandon)
 		v4.POST("/adminDeliverTask/:processInstanceID/:taskID", abnormal.adminDeliverTask)
 		v4.POST("/adminGetTaskForm/:processInstanceID/:taskID", abnormal.adminGetTaskForm)
+		v4.POST("/adminContinueNode/:id", abnormal.adminContinueNode)
+		v4.POST("/adminAbandonNode/:id", abnormal.adminAbandonNode)
+		v4.POST("/batchHandle", abnormal.batchHandle)
+		v4.POST("/batchJob", abnormal.batchJob)
 	}
 
 	// handout router
@@ -174,6 +189,76 @@ func NewRouter(c *config.Configs) (*Router, error) {
 		v7.POST("/calculation", formula.Calculation)
 	}
 
+	// migration router
+	migration, err := NewMigration(c, optDB)
+	if err != nil {
+		return nil, err
+	}
+	v8 := engine.Group(ServerPath + "/migration")
+	{
+		v8.POST("/instances", migration.migrateInstances)
+	}
+
+	// app secret router
+	appSecret, err := NewAppSecret(c, optDB)
+	if err != nil {
+		return nil, err
+	}
+	v9 := engine.Group(ServerPath + "/appSecret")
+	{
+		v9.POST("/save", appSecret.save)
+		v9.POST("/rotate", appSecret.rotate)
+		v9.POST("/revoke", appSecret.revoke)
+		v9.POST("/info", appSecret.info)
+	}
+
+	// webhook callback router
+	webhook, err := NewWebhook(c, optDB)
+	if err != nil {
+		return nil, err
+	}
+	v10 := engine.Group(ServerPath)
+	{
+		v10.POST("/callback/:token", webhook.callback)
+	}
+
+	// delegation router
+	delegation, err := NewDelegation(c, optDB)
+	if err != nil {
+		return nil, err
+	}
+	v11 := engine.Group(ServerPath + "/delegation")
+	{
+		v11.POST("/save", delegation.save)
+		v11.POST("/cancel", delegation.cancel)
+		v11.POST("/list", delegation.list)
+	}
+
+	// work calendar router
+	workCalendar, err := NewWorkCalendar(c, optDB)
+	if err != nil {
+		return nil, err
+	}
+	v12 := engine.Group(ServerPath + "/workCalendar")
+	{
+		v12.POST("/save", workCalendar.save)
+		v12.POST("/get", workCalendar.get)
+		v12.POST("/delete", workCalendar.delete)
+	}
+
+	// message template router
+	messageTemplate, err := NewMessageTemplate(c, optDB)
+	if err != nil {
+		return nil, err
+	}
+	v13 := engine.Group(ServerPath + "/messageTemplate")
+	{
+		v13.POST("/save", messageTemplate.save)
+		v13.POST("/delete", messageTemplate.delete)
+		v13.POST("/list", messageTemplate.list)
+		v13.POST("/preview", messageTemplate.preview)
+	}
+
 	return &Router{
 		c:      c,
 		engine: engine,
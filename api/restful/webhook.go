@@ -0,0 +1,53 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package restful
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/quanxiang-cloud/flow/internal/flow"
+	"github.com/quanxiang-cloud/flow/internal/server/options"
+	"github.com/quanxiang-cloud/flow/pkg"
+	"github.com/quanxiang-cloud/flow/pkg/config"
+	"github.com/quanxiang-cloud/flow/pkg/misc/logger"
+	"github.com/quanxiang-cloud/flow/pkg/misc/resp"
+)
+
+// Webhook info
+type Webhook struct {
+	webhook flow.Webhook
+}
+
+// NewWebhook new
+func NewWebhook(c *config.Configs, opts ...options.Options) (*Webhook, error) {
+	w, err := flow.NewWebhook(c, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &Webhook{
+		webhook: w,
+	}, nil
+}
+
+// callback result posted by the external system of a webhook node waiting for callback
+func (w *Webhook) callback(ctx *gin.Context) {
+	payload := make(map[string]interface{})
+	if ctx.Request.ContentLength != 0 {
+		if err := ctx.ShouldBindJSON(&payload); err != nil {
+			logger.Logger.Error(err)
+			resp.Format(nil, err).Context(ctx)
+			return
+		}
+	}
+	resp.Format(w.webhook.Callback(pkg.CTXTransfer(ctx), ctx.Param("token"), payload)).Context(ctx)
+}
@@ -0,0 +1,73 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package restful
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/quanxiang-cloud/flow/internal/flow"
+	"github.com/quanxiang-cloud/flow/internal/server/options"
+	"github.com/quanxiang-cloud/flow/pkg"
+	"github.com/quanxiang-cloud/flow/pkg/config"
+	"github.com/quanxiang-cloud/flow/pkg/misc/header2"
+	"github.com/quanxiang-cloud/flow/pkg/misc/logger"
+	"github.com/quanxiang-cloud/flow/pkg/misc/resp"
+)
+
+// WorkCalendar info
+type WorkCalendar struct {
+	workCalendar flow.WorkCalendar
+}
+
+// NewWorkCalendar new
+func NewWorkCalendar(c *config.Configs, opts ...options.Options) (*WorkCalendar, error) {
+	w, err := flow.NewWorkCalendar(c, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &WorkCalendar{
+		workCalendar: w,
+	}, nil
+}
+
+func (w *WorkCalendar) save(ctx *gin.Context) {
+	req := &flow.SaveWorkCalendarReq{}
+	if err := ctx.ShouldBind(req); err != nil {
+		logger.Logger.Error(err)
+		resp.Format(nil, err).Context(ctx)
+		return
+	}
+	req.Super = header2.GetRole(ctx).IsSuper()
+	resp.Format(w.workCalendar.Save(pkg.CTXTransfer(ctx), req)).Context(ctx)
+}
+
+func (w *WorkCalendar) get(ctx *gin.Context) {
+	req := &flow.WorkCalendarReq{}
+	if err := ctx.ShouldBind(req); err != nil {
+		logger.Logger.Error(err)
+		resp.Format(nil, err).Context(ctx)
+		return
+	}
+	resp.Format(w.workCalendar.Get(pkg.CTXTransfer(ctx), req)).Context(ctx)
+}
+
+func (w *WorkCalendar) delete(ctx *gin.Context) {
+	req := &flow.WorkCalendarReq{}
+	if err := ctx.ShouldBind(req); err != nil {
+		logger.Logger.Error(err)
+		resp.Format(nil, err).Context(ctx)
+		return
+	}
+	req.Super = header2.GetRole(ctx).IsSuper()
+	resp.Format(w.workCalendar.Delete(pkg.CTXTransfer(ctx), req)).Context(ctx)
+}
@@ -0,0 +1,784 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package convert
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"strings"
+
+	"github.com/quanxiang-cloud/flow/pkg/utils"
+)
+
+// BPMN 2.0 namespaces
+const (
+	bpmnFlowNS = "https://github.com/quanxiang-cloud/flow/bpmn"
+
+	bpmnExporter        = "quanxiang-flow"
+	defaultChartVersion = "0.2"
+)
+
+// BPMN element kinds
+const (
+	bpmnStartEvent        = "startEvent"
+	bpmnEndEvent          = "endEvent"
+	bpmnUserTask          = "userTask"
+	bpmnServiceTask       = "serviceTask"
+	bpmnCatchEvent        = "intermediateCatchEvent"
+	bpmnBoundaryEvent     = "boundaryEvent"
+	bpmnExclusiveGateway  = "exclusiveGateway"
+	bpmnInclusiveGateway  = "inclusiveGateway"
+	bpmnParallelGateway   = "parallelGateway"
+	bpmnDiverging         = "Diverging"
+	bpmnConverging        = "Converging"
+	bpmnFormalExpression  = "tFormalExpression"
+	bpmnTimeoutSuffix     = "_timeout"
+	bpmnTimeoutJump       = "jump"
+	bpmnTriggerFormData   = "FORM_DATA"
+	bpmnTriggerFormTime   = "FORM_TIME"
+	bpmnDefaultNodeWidth  = 200
+	bpmnDefaultNodeHeight = 72
+)
+
+type bpmnDefinitions struct {
+	XMLName         xml.Name     `xml:"http://www.omg.org/spec/BPMN/20100524/MODEL definitions"`
+	ID              string       `xml:"id,attr"`
+	TargetNamespace string       `xml:"targetNamespace,attr"`
+	Exporter        string       `xml:"exporter,attr,omitempty"`
+	ExporterVersion string       `xml:"exporterVersion,attr,omitempty"`
+	Process         bpmnProcess  `xml:"process"`
+	Diagram         *bpmnDiagram `xml:"http://www.omg.org/spec/BPMN/20100524/DI BPMNDiagram"`
+}
+
+type bpmnProcess struct {
+	ID                string              `xml:"id,attr"`
+	Name              string              `xml:"name,attr,omitempty"`
+	IsExecutable      bool                `xml:"isExecutable,attr"`
+	StartEvents       []*bpmnNode         `xml:"startEvent"`
+	UserTasks         []*bpmnNode         `xml:"userTask"`
+	ServiceTasks      []*bpmnNode         `xml:"serviceTask"`
+	CatchEvents       []*bpmnNode         `xml:"intermediateCatchEvent"`
+	BoundaryEvents    []*bpmnNode         `xml:"boundaryEvent"`
+	ExclusiveGateways []*bpmnNode         `xml:"exclusiveGateway"`
+	InclusiveGateways []*bpmnNode         `xml:"inclusiveGateway"`
+	ParallelGateways  []*bpmnNode         `xml:"parallelGateway"`
+	EndEvents         []*bpmnNode         `xml:"endEvent"`
+	SequenceFlows     []*bpmnSequenceFlow `xml:"sequenceFlow"`
+}
+
+type bpmnNode struct {
+	ID               string         `xml:"id,attr"`
+	Name             string         `xml:"name,attr,omitempty"`
+	Default          string         `xml:"default,attr,omitempty"`
+	GatewayDirection string         `xml:"gatewayDirection,attr,omitempty"`
+	AttachedToRef    string         `xml:"attachedToRef,attr,omitempty"`
+	CancelActivity   string         `xml:"cancelActivity,attr,omitempty"`
+	Extension        *bpmnExtension `xml:"extensionElements"`
+	Incoming         []string       `xml:"incoming"`
+	Outgoing         []string       `xml:"outgoing"`
+	Timer            *bpmnTimer     `xml:"timerEventDefinition"`
+}
+
+type bpmnSequenceFlow struct {
+	ID                  string          `xml:"id,attr"`
+	Name                string          `xml:"name,attr,omitempty"`
+	SourceRef           string          `xml:"sourceRef,attr"`
+	TargetRef           string          `xml:"targetRef,attr"`
+	Extension           *bpmnExtension  `xml:"extensionElements"`
+	ConditionExpression *bpmnExpression `xml:"conditionExpression"`
+}
+
+// bpmnExtension keep the shape type and businessData of flow model
+type bpmnExtension struct {
+	Shape *bpmnShapeData `xml:"https://github.com/quanxiang-cloud/flow/bpmn shape"`
+}
+
+type bpmnShapeData struct {
+	Type         string `xml:"type,attr"`
+	BusinessData string `xml:",chardata"`
+}
+
+type bpmnTimer struct {
+	TimeDuration *bpmnExpression `xml:"timeDuration"`
+	TimeCycle    *bpmnExpression `xml:"timeCycle"`
+}
+
+type bpmnExpression struct {
+	Type  string `xml:"http://www.w3.org/2001/XMLSchema-instance type,attr,omitempty"`
+	Value string `xml:",chardata"`
+}
+
+type bpmnDiagram struct {
+	ID    string    `xml:"id,attr"`
+	Plane bpmnPlane `xml:"http://www.omg.org/spec/BPMN/20100524/DI BPMNPlane"`
+}
+
+type bpmnPlane struct {
+	ID          string         `xml:"id,attr"`
+	BpmnElement string         `xml:"bpmnElement,attr"`
+	Shapes      []*bpmnDIShape `xml:"http://www.omg.org/spec/BPMN/20100524/DI BPMNShape"`
+	Edges       []*bpmnDIEdge  `xml:"http://www.omg.org/spec/BPMN/20100524/DI BPMNEdge"`
+}
+
+type bpmnDIShape struct {
+	ID          string     `xml:"id,attr"`
+	BpmnElement string     `xml:"bpmnElement,attr"`
+	Bounds      bpmnBounds `xml:"http://www.omg.org/spec/DD/20100524/DC Bounds"`
+}
+
+type bpmnDIEdge struct {
+	ID          string         `xml:"id,attr"`
+	BpmnElement string         `xml:"bpmnElement,attr"`
+	Waypoints   []bpmnWaypoint `xml:"http://www.omg.org/spec/DD/20100524/DI waypoint"`
+	Label       *bpmnDILabel   `xml:"http://www.omg.org/spec/BPMN/20100524/DI BPMNLabel"`
+}
+
+type bpmnDILabel struct {
+	Bounds bpmnBounds `xml:"http://www.omg.org/spec/DD/20100524/DC Bounds"`
+}
+
+type bpmnBounds struct {
+	X      float64 `xml:"x,attr"`
+	Y      float64 `xml:"y,attr"`
+	Width  float64 `xml:"width,attr"`
+	Height float64 `xml:"height,attr"`
+}
+
+type bpmnWaypoint struct {
+	X float64 `xml:"x,attr"`
+	Y float64 `xml:"y,attr"`
+}
+
+// shapeLayout position and size of shape in flow model json
+type shapeLayout struct {
+	ID       string `json:"id"`
+	Position struct {
+		X float64 `json:"x"`
+		Y float64 `json:"y"`
+	} `json:"position"`
+	Data struct {
+		NodeData struct {
+			Width  float64 `json:"width"`
+			Height float64 `json:"height"`
+		} `json:"nodeData"`
+	} `json:"data"`
+}
+
+func (l *shapeLayout) bounds() bpmnBounds {
+	b := bpmnBounds{
+		X:      l.Position.X,
+		Y:      l.Position.Y,
+		Width:  l.Data.NodeData.Width,
+		Height: l.Data.NodeData.Height,
+	}
+	if b.Width == 0 || b.Height == 0 {
+		b.Width, b.Height = bpmnDefaultNodeWidth, bpmnDefaultNodeHeight
+	}
+	return b
+}
+
+// BPMNModel flow model converted from BPMN 2.0 xml
+type BPMNModel struct {
+	Name        string
+	TriggerMode string
+	Cron        string
+	BpmnText    string
+}
+
+// ToBPMN convert flow model json to BPMN 2.0 xml, businessData of shapes is kept in extension elements
+func ToBPMN(chartJSON string, processID string, name string, cron string) ([]byte, error) {
+	p, err := ToProcessModel(chartJSON)
+	if err != nil {
+		return nil, err
+	}
+	layouts := struct {
+		Shapes []*shapeLayout `json:"shapes"`
+	}{}
+	if err := json.Unmarshal([]byte(chartJSON), &layouts); err != nil {
+		return nil, err
+	}
+	layoutMap := make(map[string]*shapeLayout, len(layouts.Shapes))
+	for _, l := range layouts.Shapes {
+		layoutMap[l.ID] = l
+	}
+	shapeMap := make(map[string]ShapeModel, len(p.Shapes))
+	for _, s := range p.Shapes {
+		shapeMap[s.ID] = s
+	}
+
+	// 有条件的分流导出为inclusiveGateway，否则为parallelGateway
+	gatewayKinds := make(map[string]string)
+	for _, s := range p.Shapes {
+		if s.Type != processBranchSource {
+			continue
+		}
+		kind := bpmnParallelGateway
+		for _, childID := range s.Data.NodeData.ChildrenID {
+			if utils.Strval(shapeMap[childID].Data.BusinessData["rule"]) != "" {
+				kind = bpmnInclusiveGateway
+			}
+		}
+		gatewayKinds[s.ID] = kind
+		gatewayKinds[s.Data.NodeData.BranchTargetElementID] = kind
+	}
+
+	defs := &bpmnDefinitions{
+		ID:              "definitions_" + processID,
+		TargetNamespace: bpmnFlowNS,
+		Exporter:        bpmnExporter,
+		ExporterVersion: p.Version,
+		Process: bpmnProcess{
+			ID:           processID,
+			Name:         name,
+			IsExecutable: true,
+		},
+	}
+	defs.Diagram = &bpmnDiagram{
+		ID: "diagram_" + processID,
+		Plane: bpmnPlane{
+			ID:          "plane_" + processID,
+			BpmnElement: processID,
+		},
+	}
+	plane := &defs.Diagram.Plane
+	process := &defs.Process
+
+	nodes := make(map[string]*bpmnNode)
+	for _, s := range p.Shapes {
+		if s.Type == step || s.Type == plus || s.Type == processBranch {
+			continue
+		}
+		node := &bpmnNode{
+			ID:        s.ID,
+			Name:      s.Data.NodeData.Name,
+			Extension: toBPMNExtension(s),
+		}
+		nodes[s.ID] = node
+
+		switch s.Type {
+		case FormData:
+			process.StartEvents = append(process.StartEvents, node)
+		case FormTime:
+			node.Timer = &bpmnTimer{TimeCycle: &bpmnExpression{Type: bpmnFormalExpression, Value: cron}}
+			process.StartEvents = append(process.StartEvents, node)
+		case end:
+			process.EndEvents = append(process.EndEvents, node)
+		case Approve, FillIn:
+			process.UserTasks = append(process.UserTasks, node)
+			if boundary, flow := toBPMNTimeout(s); boundary != nil {
+				process.BoundaryEvents = append(process.BoundaryEvents, boundary)
+				if l, ok := layoutMap[s.ID]; ok {
+					b := l.bounds()
+					plane.Shapes = append(plane.Shapes, &bpmnDIShape{
+						ID:          "di_" + boundary.ID,
+						BpmnElement: boundary.ID,
+						Bounds:      bpmnBounds{X: b.X + b.Width - 18, Y: b.Y + b.Height - 18, Width: 36, Height: 36},
+					})
+				}
+				if flow != nil {
+					process.SequenceFlows = append(process.SequenceFlows, flow)
+				}
+			}
+		case Delayed:
+			node.Timer = &bpmnTimer{}
+			process.CatchEvents = append(process.CatchEvents, node)
+		case processBranchSource, processBranchTarget:
+			node.GatewayDirection = bpmnDiverging
+			if s.Type == processBranchTarget {
+				node.GatewayDirection = bpmnConverging
+			}
+			if gatewayKinds[s.ID] == bpmnInclusiveGateway {
+				process.InclusiveGateways = append(process.InclusiveGateways, node)
+			} else {
+				process.ParallelGateways = append(process.ParallelGateways, node)
+			}
+		default:
+			process.ServiceTasks = append(process.ServiceTasks, node)
+		}
+		if l, ok := layoutMap[s.ID]; ok {
+			plane.Shapes = append(plane.Shapes, &bpmnDIShape{
+				ID:          "di_" + s.ID,
+				BpmnElement: s.ID,
+				Bounds:      l.bounds(),
+			})
+		}
+	}
+
+	for _, s := range p.Shapes {
+		node, ok := nodes[s.ID]
+		if !ok {
+			continue
+		}
+		for _, childID := range s.Data.NodeData.ChildrenID {
+			flow := &bpmnSequenceFlow{
+				ID:        "e" + s.ID + "-" + childID,
+				SourceRef: s.ID,
+				TargetRef: childID,
+			}
+			var label *bpmnDILabel
+			if s.Type == processBranchSource {
+				branch, ok := shapeMap[childID]
+				if !ok {
+					return nil, fmt.Errorf("branch %s not exists", childID)
+				}
+				flow.ID = branch.ID
+				flow.Name = branch.Data.NodeData.Name
+				flow.Extension = toBPMNExtension(branch)
+				flow.TargetRef = s.Data.NodeData.BranchTargetElementID
+				if len(branch.Data.NodeData.ChildrenID) > 0 {
+					flow.TargetRef = branch.Data.NodeData.ChildrenID[0]
+				}
+				if rule := utils.Strval(branch.Data.BusinessData["rule"]); rule != "" {
+					flow.ConditionExpression = &bpmnExpression{Type: bpmnFormalExpression, Value: rule}
+				} else if utils.Strval(branch.Data.BusinessData["ignore"]) == "true" {
+					node.Default = branch.ID
+				}
+				if l, ok := layoutMap[branch.ID]; ok {
+					label = &bpmnDILabel{Bounds: l.bounds()}
+				}
+			}
+			target, ok := nodes[flow.TargetRef]
+			if !ok {
+				return nil, fmt.Errorf("node %s not exists", flow.TargetRef)
+			}
+			node.Outgoing = append(node.Outgoing, flow.ID)
+			target.Incoming = append(target.Incoming, flow.ID)
+			process.SequenceFlows = append(process.SequenceFlows, flow)
+
+			sourceLayout, targetLayout := layoutMap[flow.SourceRef], layoutMap[flow.TargetRef]
+			if sourceLayout == nil || targetLayout == nil {
+				continue
+			}
+			sb, tb := sourceLayout.bounds(), targetLayout.bounds()
+			plane.Edges = append(plane.Edges, &bpmnDIEdge{
+				ID:          "di_" + flow.ID,
+				BpmnElement: flow.ID,
+				Waypoints: []bpmnWaypoint{
+					{X: sb.X + sb.Width/2, Y: sb.Y + sb.Height},
+					{X: tb.X + tb.Width/2, Y: tb.Y},
+				},
+				Label: label,
+			})
+		}
+	}
+
+	data, err := xml.MarshalIndent(defs, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), data...), nil
+}
+
+func toBPMNExtension(s ShapeModel) *bpmnExtension {
+	ext := &bpmnExtension{
+		Shape: &bpmnShapeData{Type: s.Type},
+	}
+	if s.Data.BusinessData != nil {
+		data, err := json.Marshal(s.Data.BusinessData)
+		if err == nil {
+			ext.Shape.BusinessData = string(data)
+		}
+	}
+	return ext
+}
+
+// toBPMNTimeout convert time rule of task to a timer boundary event
+func toBPMNTimeout(s ShapeModel) (*bpmnNode, *bpmnSequenceFlow) {
+	basicConfig := GetTaskBasicConfigModel(&s)
+	if basicConfig == nil || !basicConfig.TimeRule.Enabled {
+		return nil, nil
+	}
+	deadLine := basicConfig.TimeRule.DeadLine
+	timeout := basicConfig.TimeRule.WhenTimeout
+	boundary := &bpmnNode{
+		ID:             s.ID + bpmnTimeoutSuffix,
+		AttachedToRef:  s.ID,
+		CancelActivity: "false",
+		Timer: &bpmnTimer{
+			TimeDuration: &bpmnExpression{
+				Type:  bpmnFormalExpression,
+				Value: fmt.Sprintf("P%dDT%dH%dM", deadLine.Day, deadLine.Hours, deadLine.Minutes),
+			},
+		},
+	}
+	if timeout.Type != bpmnTimeoutJump || timeout.Value == "" {
+		return boundary, nil
+	}
+	boundary.CancelActivity = "true"
+	flow := &bpmnSequenceFlow{
+		ID:        "e" + boundary.ID + "-" + timeout.Value,
+		SourceRef: boundary.ID,
+		TargetRef: timeout.Value,
+	}
+	boundary.Outgoing = []string{flow.ID}
+	return boundary, flow
+}
+
+type bpmnImporter struct {
+	kinds     map[string]string
+	nodes     map[string]*bpmnNode
+	order     []string
+	types     map[string]string
+	outgoing  map[string][]*bpmnSequenceFlow
+	joins     map[string]string
+	nodeDatas map[string]map[string]interface{}
+}
+
+// FromBPMN convert BPMN 2.0 xml to flow model json, the supported subset is
+// start/end events, user/service tasks, timer catch events and exclusive/inclusive/parallel gateways,
+// branches are inclusive in flow model, so an exclusive gateway may have only one conditional branch besides the default
+func FromBPMN(data []byte) (*BPMNModel, error) {
+	defs := &bpmnDefinitions{}
+	if err := xml.Unmarshal(data, defs); err != nil {
+		return nil, err
+	}
+	process := defs.Process
+	im := &bpmnImporter{
+		kinds:     make(map[string]string),
+		nodes:     make(map[string]*bpmnNode),
+		types:     make(map[string]string),
+		outgoing:  make(map[string][]*bpmnSequenceFlow),
+		joins:     make(map[string]string),
+		nodeDatas: make(map[string]map[string]interface{}),
+	}
+	for kind, nodes := range map[string][]*bpmnNode{
+		bpmnStartEvent:       process.StartEvents,
+		bpmnEndEvent:         process.EndEvents,
+		bpmnUserTask:         process.UserTasks,
+		bpmnServiceTask:      process.ServiceTasks,
+		bpmnCatchEvent:       process.CatchEvents,
+		bpmnExclusiveGateway: process.ExclusiveGateways,
+		bpmnInclusiveGateway: process.InclusiveGateways,
+		bpmnParallelGateway:  process.ParallelGateways,
+	} {
+		for _, node := range nodes {
+			im.kinds[node.ID] = kind
+			im.nodes[node.ID] = node
+		}
+	}
+	// 保持文档中的节点顺序，开始节点在前
+	for _, nodes := range [][]*bpmnNode{
+		process.StartEvents, process.UserTasks, process.ServiceTasks, process.CatchEvents,
+		process.ExclusiveGateways, process.InclusiveGateways, process.ParallelGateways, process.EndEvents,
+	} {
+		for _, node := range nodes {
+			im.order = append(im.order, node.ID)
+		}
+	}
+
+	boundaries := make(map[string]bool)
+	for _, node := range process.BoundaryEvents {
+		boundaries[node.ID] = true
+	}
+	for _, flow := range process.SequenceFlows {
+		// 超时边界事件由节点的timeRule还原
+		if boundaries[flow.SourceRef] {
+			continue
+		}
+		if im.nodes[flow.SourceRef] == nil || im.nodes[flow.TargetRef] == nil {
+			return nil, fmt.Errorf("sequenceFlow %s references unsupported element", flow.ID)
+		}
+		im.outgoing[flow.SourceRef] = append(im.outgoing[flow.SourceRef], flow)
+	}
+
+	model := &BPMNModel{
+		Name:        process.Name,
+		TriggerMode: bpmnTriggerFormData,
+	}
+	for _, id := range im.order {
+		t, err := im.shapeType(im.nodes[id])
+		if err != nil {
+			return nil, err
+		}
+		im.types[id] = t
+		if t == FormTime {
+			model.TriggerMode = bpmnTriggerFormTime
+			if timer := im.nodes[id].Timer; timer != nil && timer.TimeCycle != nil {
+				model.Cron = strings.TrimSpace(timer.TimeCycle.Value)
+			}
+		}
+	}
+
+	for _, id := range im.order {
+		if im.types[id] != processBranchSource {
+			continue
+		}
+		join, err := im.findJoin(id, 0)
+		if err != nil {
+			return nil, err
+		}
+		im.joins[id] = join
+	}
+
+	shapes, err := im.shapes(defs.Diagram)
+	if err != nil {
+		return nil, err
+	}
+	version := defaultChartVersion
+	if defs.Exporter == bpmnExporter && defs.ExporterVersion != "" {
+		version = defs.ExporterVersion
+	}
+	text, err := json.Marshal(map[string]interface{}{
+		"version": version,
+		"shapes":  shapes,
+	})
+	if err != nil {
+		return nil, err
+	}
+	model.BpmnText = string(text)
+	return model, nil
+}
+
+// shapeType resolve flow model shape type of BPMN element
+func (im *bpmnImporter) shapeType(node *bpmnNode) (string, error) {
+	if node.Extension != nil && node.Extension.Shape != nil && node.Extension.Shape.Type != "" {
+		return node.Extension.Shape.Type, nil
+	}
+	switch im.kinds[node.ID] {
+	case bpmnStartEvent:
+		if node.Timer != nil {
+			return FormTime, nil
+		}
+		return FormData, nil
+	case bpmnEndEvent:
+		return end, nil
+	case bpmnUserTask:
+		return Approve, nil
+	case bpmnCatchEvent:
+		if node.Timer != nil {
+			return Delayed, nil
+		}
+	case bpmnExclusiveGateway, bpmnInclusiveGateway, bpmnParallelGateway:
+		if len(im.outgoing[node.ID]) > 1 || node.GatewayDirection == bpmnDiverging {
+			if im.kinds[node.ID] == bpmnExclusiveGateway && !im.exclusive(node.ID) {
+				return "", fmt.Errorf("%s %s has more than one conditional sequenceFlow, "+
+					"branches of the flow are inclusive and the exclusive semantics can not be preserved", bpmnExclusiveGateway, node.ID)
+			}
+			return processBranchSource, nil
+		}
+		return processBranchTarget, nil
+	}
+	return "", fmt.Errorf("%s %s is not supported", im.kinds[node.ID], node.ID)
+}
+
+// exclusive whether the diverging gateway keeps exclusive semantics as a branch source,
+// the default branch is only taken when none matched, so at most one conditional branch is allowed
+func (im *bpmnImporter) exclusive(id string) bool {
+	conditions := 0
+	for _, flow := range im.outgoing[id] {
+		if flow.ConditionExpression != nil && im.nodes[id].Default != flow.ID {
+			conditions++
+		}
+	}
+	return conditions <= 1
+}
+
+func (im *bpmnImporter) next(id string) (string, error) {
+	flows := im.outgoing[id]
+	if len(flows) == 0 {
+		return "", fmt.Errorf("node %s has no outgoing sequenceFlow", id)
+	}
+	return flows[0].TargetRef, nil
+}
+
+// findJoin find the converging gateway which closes the diverging gateway
+func (im *bpmnImporter) findJoin(sourceID string, depth int) (string, error) {
+	if depth > len(im.nodes) {
+		return "", fmt.Errorf("gateway %s is not closed", sourceID)
+	}
+	cur, err := im.next(sourceID)
+	if err != nil {
+		return "", err
+	}
+	for i := 0; i <= len(im.nodes); i++ {
+		switch im.types[cur] {
+		case processBranchTarget:
+			return cur, nil
+		case processBranchSource:
+			join, err := im.findJoin(cur, depth+1)
+			if err != nil {
+				return "", err
+			}
+			cur = join
+		case end:
+			return "", fmt.Errorf("gateway %s is not closed", sourceID)
+		}
+		if cur, err = im.next(cur); err != nil {
+			return "", err
+		}
+	}
+	return "", fmt.Errorf("gateway %s is not closed", sourceID)
+}
+
+// shapes build shapes of flow model json, including branches and edges
+func (im *bpmnImporter) shapes(diagram *bpmnDiagram) ([]map[string]interface{}, error) {
+	bounds := make(map[string]bpmnBounds)
+	if diagram != nil {
+		for _, s := range diagram.Plane.Shapes {
+			bounds[s.BpmnElement] = s.Bounds
+		}
+		for _, e := range diagram.Plane.Edges {
+			if e.Label != nil {
+				bounds[e.BpmnElement] = e.Label.Bounds
+			}
+		}
+	}
+
+	shapes := make([]map[string]interface{}, 0)
+	children := make(map[string][]string)
+	parents := make(map[string][]string)
+	link := func(source, target string) {
+		children[source] = append(children[source], target)
+		parents[target] = append(parents[target], source)
+	}
+	newShape := func(id, t, name string, businessData map[string]interface{}) map[string]interface{} {
+		b, ok := bounds[id]
+		if !ok {
+			b = bpmnBounds{Width: bpmnDefaultNodeWidth, Height: bpmnDefaultNodeHeight}
+		}
+		nodeData := map[string]interface{}{
+			"width":  b.Width,
+			"height": b.Height,
+			"name":   name,
+		}
+		im.nodeDatas[id] = nodeData
+		shape := map[string]interface{}{
+			"id":       id,
+			"type":     t,
+			"position": map[string]interface{}{"x": b.X, "y": b.Y},
+			"data": map[string]interface{}{
+				"nodeData":     nodeData,
+				"businessData": businessData,
+			},
+		}
+		shapes = append(shapes, shape)
+		return shape
+	}
+
+	for _, id := range im.order {
+		node := im.nodes[id]
+		t := im.types[id]
+		businessData, err := fromBPMNExtension(node.Extension)
+		if err != nil {
+			return nil, err
+		}
+		if businessData == nil {
+			businessData = defaultBusinessData(t)
+		}
+		newShape(id, t, node.Name, businessData)
+		if t == processBranchSource {
+			im.nodeDatas[id]["branchTargetElementID"] = im.joins[id]
+		}
+	}
+
+	for _, id := range im.order {
+		for _, flow := range im.outgoing[id] {
+			if im.types[id] != processBranchSource {
+				link(id, flow.TargetRef)
+				continue
+			}
+			businessData, err := fromBPMNExtension(flow.Extension)
+			if err != nil {
+				return nil, err
+			}
+			if businessData == nil {
+				businessData = make(map[string]interface{})
+				if flow.ConditionExpression != nil {
+					businessData["rule"] = strings.TrimSpace(flow.ConditionExpression.Value)
+				} else if im.nodes[id].Default == flow.ID || len(im.outgoing[id]) == 1 {
+					businessData["ignore"] = true
+				}
+			}
+			newShape(flow.ID, processBranch, flow.Name, businessData)
+			im.nodeDatas[flow.ID]["branchTargetElementID"] = im.joins[id]
+			link(id, flow.ID)
+			link(flow.ID, flow.TargetRef)
+		}
+	}
+
+	// 分支内的节点记录所属分支及合流节点
+	for _, id := range im.order {
+		join, ok := im.joins[id]
+		if !ok {
+			continue
+		}
+		for _, branchID := range children[id] {
+			cur := children[branchID][0]
+			for i := 0; cur != join && i <= len(im.nodes); i++ {
+				im.nodeDatas[cur]["branchID"] = branchID
+				im.nodeDatas[cur]["branchTargetElementID"] = join
+				if nested, ok := im.joins[cur]; ok {
+					cur = nested
+					continue
+				}
+				if len(children[cur]) == 0 {
+					break
+				}
+				cur = children[cur][0]
+			}
+		}
+	}
+
+	for id, nodeData := range im.nodeDatas {
+		nodeData["childrenID"] = append(make([]string, 0), children[id]...)
+		nodeData["parentID"] = append(make([]string, 0), parents[id]...)
+	}
+
+	for _, shape := range append(make([]map[string]interface{}, 0, len(shapes)), shapes...) {
+		source := shape["id"].(string)
+		for _, target := range children[source] {
+			edgeType, label := plus, "+"
+			if shape["type"] == processBranchSource {
+				edgeType, label = step, ""
+			}
+			shapes = append(shapes, map[string]interface{}{
+				"id":            "e" + source + "-" + target,
+				"type":          edgeType,
+				"source":        source,
+				"target":        target,
+				"label":         label,
+				"arrowHeadType": "arrowclosed",
+			})
+		}
+	}
+	return shapes, nil
+}
+
+func fromBPMNExtension(ext *bpmnExtension) (map[string]interface{}, error) {
+	if ext == nil || ext.Shape == nil || strings.TrimSpace(ext.Shape.BusinessData) == "" {
+		return nil, nil
+	}
+	businessData := make(map[string]interface{})
+	if err := json.Unmarshal([]byte(ext.Shape.BusinessData), &businessData); err != nil {
+		return nil, err
+	}
+	return businessData, nil
+}
+
+// defaultBusinessData businessData required by the engine for elements without extension
+func defaultBusinessData(t string) map[string]interface{} {
+	switch t {
+	case Approve, FillIn:
+		return map[string]interface{}{
+			"basicConfig": map[string]interface{}{
+				"approvePersons":    map[string]interface{}{"type": Person},
+				"multiplePersonWay": "or",
+			},
+		}
+	case processBranchTarget:
+		return map[string]interface{}{"processBranchEndStrategy": "all"}
+	}
+	return make(map[string]interface{})
+}
@@ -0,0 +1,156 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package convert
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBPMNRoundTrip(t *testing.T) {
+	chart := `{"version":"0.2","shapes":[
+		{"id":"form","type":"formData","position":{"x":250,"y":36},"data":{"nodeData":{"name":"开始","width":200,"height":72,"childrenID":["src"]},"businessData":{"form":{"name":"表单","value":"tb1"}}}},
+		{"id":"src","type":"processBranchSource","position":{"x":325,"y":150},"data":{"nodeData":{"name":"分流","width":50,"height":25,"childrenID":["b1","b2"],"branchTargetElementID":"tgt"}}},
+		{"id":"b1","type":"processBranch","position":{"x":100,"y":250},"data":{"nodeData":{"name":"分支1","childrenID":["a1"],"branchTargetElementID":"tgt"},"businessData":{"rule":"$form.field_a > 10"}}},
+		{"id":"b2","type":"processBranch","position":{"x":400,"y":250},"data":{"nodeData":{"name":"分支2","childrenID":["tgt"],"branchTargetElementID":"tgt"},"businessData":{"ignore":true}}},
+		{"id":"a1","type":"approve","position":{"x":100,"y":400},"data":{"nodeData":{"name":"审批","childrenID":["tgt"],"branchID":"b1","branchTargetElementID":"tgt"},"businessData":{"basicConfig":{"multiplePersonWay":"or","approvePersons":{"type":"person","users":[{"id":"u1"}]},"timeRule":{"enabled":true,"deadLine":{"day":1},"whenTimeout":{"type":"jump","value":"end"}}}}}},
+		{"id":"tgt","type":"processBranchTarget","position":{"x":325,"y":550},"data":{"nodeData":{"name":"合流","width":50,"height":25,"childrenID":["w1"]},"businessData":{"processBranchEndStrategy":"all"}}},
+		{"id":"w1","type":"webhook","position":{"x":250,"y":650},"data":{"nodeData":{"name":"推送","childrenID":["end"]},"businessData":{"type":"request","config":{"api":{"value":"a/b"}}}}},
+		{"id":"end","type":"end","position":{"x":300,"y":800},"data":{"nodeData":{"name":"结束","width":100,"height":28,"childrenID":[]}}},
+		{"id":"eform-src","type":"plus","source":"form","target":"src"}
+	]}`
+
+	data, err := ToBPMN(chart, "p1", "流程", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"<inclusiveGateway", `default="b2"`, "<userTask", "<serviceTask", "<boundaryEvent", "P1DT0H0M", "<conditionExpression"} {
+		if !strings.Contains(string(data), s) {
+			t.Errorf("exported xml missing %s", s)
+		}
+	}
+
+	model, err := FromBPMN(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if model.Name != "流程" || model.TriggerMode != "FORM_DATA" {
+		t.Errorf("unexpected model %+v", model)
+	}
+	want, _ := ToProcessModel(chart)
+	got, err := ToProcessModel(model.BpmnText)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gotShapes := make(map[string]ShapeModel)
+	for _, s := range got.Shapes {
+		gotShapes[s.ID] = s
+	}
+	for _, s := range want.Shapes {
+		if s.Type == plus || s.Type == step {
+			continue
+		}
+		g, ok := gotShapes[s.ID]
+		if !ok {
+			t.Errorf("shape %s lost", s.ID)
+			continue
+		}
+		if g.Type != s.Type || g.Data.NodeData.Name != s.Data.NodeData.Name ||
+			g.Data.NodeData.BranchTargetElementID != s.Data.NodeData.BranchTargetElementID ||
+			!reflect.DeepEqual(g.Data.NodeData.ChildrenID, s.Data.NodeData.ChildrenID) {
+			t.Errorf("shape %s changed: got %+v, want %+v", s.ID, g, s)
+		}
+		if s.Data.BusinessData != nil && !reflect.DeepEqual(g.Data.BusinessData, s.Data.BusinessData) {
+			t.Errorf("businessData of %s changed: got %v, want %v", s.ID, g.Data.BusinessData, s.Data.BusinessData)
+		}
+	}
+	if gotShapes["eform-src"].Type != plus || gotShapes["esrc-b1"].Type != step {
+		t.Errorf("edges not rebuilt")
+	}
+}
+
+func TestFromBPMNWithoutExtension(t *testing.T) {
+	data := `<?xml version="1.0" encoding="UTF-8"?>
+<bpmn:definitions xmlns:bpmn="http://www.omg.org/spec/BPMN/20100524/MODEL" id="d1" targetNamespace="x">
+  <bpmn:process id="p1" name="请假">
+    <bpmn:startEvent id="start"/>
+    <bpmn:userTask id="t1" name="审批"/>
+    <bpmn:exclusiveGateway id="g1" default="f3"/>
+    <bpmn:exclusiveGateway id="g2"/>
+    <bpmn:endEvent id="end"/>
+    <bpmn:sequenceFlow id="f1" sourceRef="start" targetRef="g1"/>
+    <bpmn:sequenceFlow id="f2" name="大于3天" sourceRef="g1" targetRef="t1">
+      <bpmn:conditionExpression>days &gt; 3</bpmn:conditionExpression>
+    </bpmn:sequenceFlow>
+    <bpmn:sequenceFlow id="f3" sourceRef="g1" targetRef="g2"/>
+    <bpmn:sequenceFlow id="f4" sourceRef="t1" targetRef="g2"/>
+    <bpmn:sequenceFlow id="f5" sourceRef="g2" targetRef="end"/>
+  </bpmn:process>
+</bpmn:definitions>`
+	model, err := FromBPMN([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := ToProcessModel(model.BpmnText)
+	if err != nil {
+		t.Fatal(err)
+	}
+	shapes := make(map[string]ShapeModel)
+	for _, s := range p.Shapes {
+		shapes[s.ID] = s
+	}
+	if shapes["g1"].Type != processBranchSource || shapes["g1"].Data.NodeData.BranchTargetElementID != "g2" {
+		t.Errorf("unexpected gateway %+v", shapes["g1"])
+	}
+	if shapes["f2"].Data.BusinessData["rule"] != "days > 3" || shapes["f3"].Data.BusinessData["ignore"] != true {
+		t.Errorf("unexpected branches %+v %+v", shapes["f2"], shapes["f3"])
+	}
+	if shapes["t1"].Type != Approve {
+		t.Errorf("unexpected task %+v", shapes["t1"])
+	}
+	// 导入的审批节点需要补充审批人
+	diagnostics := Validate(p, nil)
+	if len(diagnostics) != 1 || diagnostics[0].Code != DiagNoApprovePersons {
+		t.Errorf("unexpected diagnostics %v", diagnostics)
+	}
+}
+
+func TestFromBPMNExclusiveGateway(t *testing.T) {
+	data := `<?xml version="1.0" encoding="UTF-8"?>
+<bpmn:definitions xmlns:bpmn="http://www.omg.org/spec/BPMN/20100524/MODEL" id="d1" targetNamespace="x">
+  <bpmn:process id="p1" name="请假">
+    <bpmn:startEvent id="start"/>
+    <bpmn:userTask id="t1" name="审批"/>
+    <bpmn:userTask id="t2" name="复核"/>
+    <bpmn:exclusiveGateway id="g1"/>
+    <bpmn:exclusiveGateway id="g2"/>
+    <bpmn:endEvent id="end"/>
+    <bpmn:sequenceFlow id="f1" sourceRef="start" targetRef="g1"/>
+    <bpmn:sequenceFlow id="f2" sourceRef="g1" targetRef="t1">
+      <bpmn:conditionExpression>days &gt; 3</bpmn:conditionExpression>
+    </bpmn:sequenceFlow>
+    <bpmn:sequenceFlow id="f3" sourceRef="g1" targetRef="t2">
+      <bpmn:conditionExpression>days &gt; 1</bpmn:conditionExpression>
+    </bpmn:sequenceFlow>
+    <bpmn:sequenceFlow id="f4" sourceRef="t1" targetRef="g2"/>
+    <bpmn:sequenceFlow id="f5" sourceRef="t2" targetRef="g2"/>
+    <bpmn:sequenceFlow id="f6" sourceRef="g2" targetRef="end"/>
+  </bpmn:process>
+</bpmn:definitions>`
+	// 两个条件可能同时成立，分流节点无法保持排他语义
+	if _, err := FromBPMN([]byte(data)); err == nil {
+		t.Errorf("exclusive gateway with several conditions should be rejected")
+	}
+}
@@ -32,6 +32,10 @@ const (
 	OrFillIn = "OR_FILLIN" // 任填
 	// AndFillIn type
 	AndFillIn = "AND_FILLIN" // 全填
+	// SeqApproval type
+	SeqApproval = "SEQ_APPROVAL" // 依次审批
+	// SeqFillIn type
+	SeqFillIn = "SEQ_FILLIN" // 依次填写
 	// START type
 	START = "START" // 开始
 	// END type
@@ -39,6 +43,9 @@ const (
 
 	// AssigneeList dynamic user list key
 	AssigneeList = "assigneeList"
+
+	// Sequential multiplePersonWay, assignees are processed one after another
+	Sequential = "sequential"
 )
 
 // Process Node Type
@@ -61,6 +68,24 @@ const (
 	DelayTypeOfSpecTime    = "specTime"
 )
 
+// 超时逐级升级的处理方式
+const (
+	// EscalateNotifySuperior 通知处理人的上级领导
+	EscalateNotifySuperior = "notifySuperior"
+	// EscalateReassignLead 转交处理人的部门负责人
+	EscalateReassignLead = "reassignLead"
+	// EscalateAbnormal 转为异常任务，由应用管理员处理
+	EscalateAbnormal = "abnormal"
+)
+
+// 时限的计时方式
+const (
+	// TimeTypeNatural 自然时间
+	TimeTypeNatural = "natural"
+	// TimeTypeWorking 工作时间，按工作日历计算
+	TimeTypeWorking = "working"
+)
+
 // 邮件节点类型
 const (
 	// EmailTypeOfField 人员选择字段(1.1.1版本前默认)
@@ -103,6 +128,8 @@ const (
 	CallbackOfUrge = "urge"
 	// CallbackOfCron 定时
 	CallbackOfCron = "cron"
+	// CallbackOfWebhook webhook等待回调超时
+	CallbackOfWebhook = "webhookCallback"
 )
 
 // LowCode flow Chart Type
@@ -126,10 +153,18 @@ const (
 	TableDataCreate = "tableDataCreate"
 	// TableDataUpdate service task
 	TableDataUpdate = "tableDataUpdate"
+	// TableDataDelete service task
+	TableDataDelete = "tableDataDelete"
+	// TableDataQuery service task, load data of a table into variables
+	TableDataQuery = "tableDataQuery"
 	// WebHook service task
 	WebHook = "webhook"
 	// Delayed 延时节点
 	Delayed = "delayed"
+	// SubFlow 子流程节点，发起另一个流程并等待其结束
+	SubFlow = "subProcess"
+	// ScriptTask 脚本节点，运行多语句表达式并写入流程变量
+	ScriptTask = "script"
 
 	end                 = "end"
 	processBranchSource = "processBranchSource"
@@ -303,7 +338,7 @@ func switchToNodeType(s ShapeModel) string {
 		fallthrough
 	case FillIn:
 		rt := GetValueFromBusinessData(s, "basicConfig.multiplePersonWay").(string)
-		if rt == "or" {
+		if rt == "or" || rt == Sequential { // 依次处理同一时间只有一个处理人
 			return User
 		}
 		return MultiUser
@@ -319,8 +354,16 @@ func switchToNodeType(s ShapeModel) string {
 		fallthrough
 	case TableDataUpdate:
 		fallthrough
+	case TableDataDelete:
+		fallthrough
+	case TableDataQuery:
+		fallthrough
 	case Delayed:
 		fallthrough
+	case SubFlow:
+		fallthrough
+	case ScriptTask:
+		fallthrough
 	case WebHook:
 		return Service
 	}
@@ -472,11 +515,35 @@ func checkChart(s ShapeModel, formID string) error {
 		if updateRule == nil || reflect.ValueOf(updateRule).Len() == 0 {
 			return error2.NewErrorWithString(error2.Internal, "updateRule not set")
 		}
+	} else if TableDataDelete == s.Type {
+		if utils.Strval(GetValueFromBusinessData(s, "targetTableId")) == "" {
+			return error2.NewErrorWithString(error2.Internal, "targetTableId not set")
+		}
+		if !hasDeleteScope(s) {
+			return error2.NewErrorWithString(error2.Internal, "conditions not set")
+		}
+	} else if TableDataQuery == s.Type {
+		if utils.Strval(GetValueFromBusinessData(s, "targetTableId")) == "" {
+			return error2.NewErrorWithString(error2.Internal, "targetTableId not set")
+		}
+		outputMapping := GetValueFromBusinessData(s, "outputMapping")
+		if outputMapping == nil || reflect.ValueOf(outputMapping).Len() == 0 {
+			return error2.NewErrorWithString(error2.Internal, "outputMapping not set")
+		}
 	}
 
 	return nil
 }
 
+// hasDeleteScope data delete node deletes rows matched by filterRule.conditions or ids of idsVariable
+func hasDeleteScope(s ShapeModel) bool {
+	if utils.Strval(GetValueFromBusinessData(s, "idsVariable")) != "" {
+		return true
+	}
+	conditions := GetValueFromBusinessData(s, "filterRule.conditions")
+	return conditions != nil && reflect.ValueOf(conditions).Len() > 0
+}
+
 func getUsers(s ShapeModel) []string {
 	userIDs := make([]string, 0)
 	users := GetValueFromBusinessData(s, "basicConfig.approvePersons.users")
@@ -530,12 +597,16 @@ func GetCurrentNodeType(t string, multiplePersonWay string) string {
 			return OrApproval
 		} else if "and" == multiplePersonWay {
 			return AndApproval
+		} else if Sequential == multiplePersonWay {
+			return SeqApproval
 		}
 	} else if t == FillIn {
 		if "or" == multiplePersonWay {
 			return OrFillIn
 		} else if "and" == multiplePersonWay {
 			return AndFillIn
+		} else if Sequential == multiplePersonWay {
+			return SeqFillIn
 		}
 	}
 
@@ -568,6 +639,8 @@ type TaskBasicConfigModel struct {
 	AutoRules []string `json:"autoRules"`
 	// 审批用时限制
 	TimeRule TaskTimeRuleModel `json:"timeRule"`
+	// 会签投票规则，multiplePersonWay为and时生效
+	VoteRule VoteRuleModel `json:"voteRule"`
 }
 
 // ApprovePersonsModel struct
@@ -587,6 +660,8 @@ type TaskTimeRuleModel struct {
 	Enabled     bool              `json:"enabled"`
 	DeadLine    DeadLineModel     `json:"deadLine"`
 	WhenTimeout TypeAndValueModel `json:"whenTimeout"`
+	// 计时方式：natural自然时间，working工作时间
+	TimeType string `json:"timeType"`
 }
 
 // DeadLineModel struct
@@ -599,9 +674,18 @@ type DeadLineModel struct {
 
 // TypeAndValueModel struct
 type TypeAndValueModel struct {
-	// noDealWith不处理,autoDealWith自动处理,jump跳转至其他节点
+	// noDealWith不处理,autoDealWith自动处理,jump跳转至其他节点,escalate逐级升级
 	Type  string `json:"type"`
 	Value string `json:"value"`
+	// 逐级升级的各级处理，type为escalate时生效
+	Levels []EscalationLevelModel `json:"levels"`
+}
+
+// EscalationLevelModel struct
+type EscalationLevelModel struct {
+	DayHourMinuteModel // 超时后多久执行
+	// notifySuperior通知上级领导,reassignLead转交部门负责人,abnormal转为异常任务由管理员处理
+	Action string `json:"action"`
 }
 
 // UrgeModel struct
@@ -712,3 +796,18 @@ type Input struct {
 	FieldName string      `json:"fieldName"`
 	TableID   string      `json:"tableID"`
 }
+
+// webhook mode
+const (
+	// WebHookModeSync 同步调用，响应即结果
+	WebHookModeSync = "sync"
+	// WebHookModeCallback 发送请求后等待外部系统回调
+	WebHookModeCallback = "callback"
+)
+
+// WebHookOutput webhook response mapping
+type WebHookOutput struct {
+	Selector string `json:"selector"` // $.data.list[0].id
+	Target   string `json:"target"`   // variable, formField
+	Name     string `json:"name"`     // 流程变量code或表单字段
+}
@@ -0,0 +1,99 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package convert
+
+import (
+	"reflect"
+	"strings"
+)
+
+const (
+	diffInitial   = "初始版本"
+	diffUnchanged = "无变更"
+)
+
+// DiffSummary summarize the node changes between two flow model json
+func DiffSummary(oldText, newText string) string {
+	if oldText == "" {
+		return diffInitial
+	}
+	oldModel, err := ToProcessModel(oldText)
+	if err != nil {
+		return ""
+	}
+	newModel, err := ToProcessModel(newText)
+	if err != nil {
+		return ""
+	}
+
+	oldShapes := make(map[string]ShapeModel, len(oldModel.Shapes))
+	for _, s := range oldModel.Shapes {
+		oldShapes[s.ID] = s
+	}
+
+	added, removed, modified := make([]string, 0), make([]string, 0), make([]string, 0)
+	edgeChanged := false
+	for _, s := range newModel.Shapes {
+		o, ok := oldShapes[s.ID]
+		delete(oldShapes, s.ID)
+		if s.Type == step || s.Type == plus {
+			if !ok || o.Source != s.Source || o.Target != s.Target {
+				edgeChanged = true
+			}
+			continue
+		}
+		if !ok {
+			added = append(added, shapeName(s))
+			continue
+		}
+		if o.Type != s.Type || !reflect.DeepEqual(o.Data, s.Data) {
+			modified = append(modified, shapeName(s))
+		}
+	}
+	for _, s := range oldModel.Shapes {
+		if _, ok := oldShapes[s.ID]; !ok {
+			continue
+		}
+		if s.Type == step || s.Type == plus {
+			edgeChanged = true
+			continue
+		}
+		removed = append(removed, shapeName(s))
+	}
+
+	parts := make([]string, 0, 4)
+	if len(added) > 0 {
+		parts = append(parts, "新增节点["+strings.Join(added, ",")+"]")
+	}
+	if len(removed) > 0 {
+		parts = append(parts, "删除节点["+strings.Join(removed, ",")+"]")
+	}
+	if len(modified) > 0 {
+		parts = append(parts, "修改节点["+strings.Join(modified, ",")+"]")
+	}
+	if edgeChanged {
+		parts = append(parts, "连线变更")
+	}
+	if len(parts) == 0 {
+		return diffUnchanged
+	}
+	return strings.Join(parts, ";")
+}
+
+func shapeName(s ShapeModel) string {
+	if s.Data.NodeData.Name != "" {
+		return s.Data.NodeData.Name
+	}
+	return s.ID
+}
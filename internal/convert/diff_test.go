@@ -0,0 +1,47 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package convert
+
+import "testing"
+
+func TestDiffSummary(t *testing.T) {
+	base := `{"shapes":[
+		{"id":"form","type":"formData","data":{"nodeData":{"name":"开始"}}},
+		{"id":"a1","type":"approve","data":{"nodeData":{"name":"审批1"},"businessData":{"basicConfig":{"multiplePersonWay":"or"}}}},
+		{"id":"e1","type":"step","source":"form","target":"a1"}
+	]}`
+	tests := []struct {
+		name    string
+		old     string
+		new     string
+		summary string
+	}{
+		{"initial", "", base, diffInitial},
+		{"unchanged", base, base, diffUnchanged},
+		{"changed", base, `{"shapes":[
+			{"id":"form","type":"formData","data":{"nodeData":{"name":"开始"}}},
+			{"id":"a1","type":"approve","data":{"nodeData":{"name":"审批1"},"businessData":{"basicConfig":{"multiplePersonWay":"and"}}}},
+			{"id":"f1","type":"fillIn","data":{"nodeData":{"name":"填写1"}}},
+			{"id":"e1","type":"step","source":"form","target":"a1"}
+		]}`, "新增节点[填写1];修改节点[审批1]"},
+		{"removed", base, `{"shapes":[
+			{"id":"form","type":"formData","data":{"nodeData":{"name":"开始"}}}
+		]}`, "删除节点[审批1];连线变更"},
+	}
+	for _, tt := range tests {
+		if got := DiffSummary(tt.old, tt.new); got != tt.summary {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.summary)
+		}
+	}
+}
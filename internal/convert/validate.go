@@ -0,0 +1,560 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package convert
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+
+	"github.com/quanxiang-cloud/flow/internal/eval"
+	"github.com/quanxiang-cloud/flow/pkg/utils"
+)
+
+// Diagnostic level
+const (
+	LevelError   = "error"
+	LevelWarning = "warning"
+)
+
+// Diagnostic code
+const (
+	DiagNoStart          = "NO_START"
+	DiagNoEnd            = "NO_END"
+	DiagUnknownChild     = "UNKNOWN_CHILD"
+	DiagUnreachable      = "UNREACHABLE"
+	DiagDanglingBranch   = "DANGLING_BRANCH"
+	DiagConditionNotSet  = "CONDITION_NOT_SET"
+	DiagNoApprovePersons = "NO_APPROVE_PERSONS"
+	DiagJumpTarget       = "JUMP_TARGET_NOT_EXISTS"
+	DiagUnknownField     = "UNKNOWN_FIELD"
+	DiagUnknownVariable  = "UNKNOWN_VARIABLE"
+	DiagFormula          = "INVALID_FORMULA"
+	DiagSubFlowNotSet    = "SUB_FLOW_NOT_SET"
+	DiagSubFlowCycle     = "SUB_FLOW_CYCLE"
+	DiagScript           = "INVALID_SCRIPT"
+	DiagTargetTable      = "TARGET_TABLE_NOT_SET"
+	DiagSubTableNotSet   = "SUB_TABLE_NOT_SET"
+	DiagDeleteScope      = "DELETE_SCOPE_NOT_SET"
+	DiagQueryOutput      = "INVALID_QUERY_OUTPUT"
+	DiagVoteRule         = "INVALID_VOTE_RULE"
+	DiagEscalation       = "INVALID_ESCALATION"
+)
+
+// Diagnostic one problem found in flow chart
+type Diagnostic struct {
+	Level   string `json:"level"`
+	Code    string `json:"code"`
+	ShapeID string `json:"shapeID"`
+	Name    string `json:"name"`
+	Message string `json:"message"`
+}
+
+// ValidateRefs known references of flow, nil map skips the check
+type ValidateRefs struct {
+	FormFields map[string]bool
+	Variables  map[string]bool
+	// FlowID id of the flow being checked, SubFlows child flow ids started by each known flow
+	FlowID   string
+	SubFlows map[string][]string
+}
+
+var refPattern = regexp.MustCompile(`\$([\w-]+)\.(\w+)`)
+
+type validator struct {
+	p           *ProcessModel
+	refs        *ValidateRefs
+	shapes      map[string]ShapeModel
+	formDefKey  string
+	diagnostics []*Diagnostic
+}
+
+// Validate check flow chart and report diagnostics, refs may be nil
+func Validate(p *ProcessModel, refs *ValidateRefs) []*Diagnostic {
+	if refs == nil {
+		refs = &ValidateRefs{}
+	}
+	v := &validator{
+		p:           p,
+		refs:        refs,
+		shapes:      make(map[string]ShapeModel, len(p.Shapes)),
+		diagnostics: make([]*Diagnostic, 0),
+	}
+	for _, s := range p.Shapes {
+		v.shapes[s.ID] = s
+	}
+
+	v.checkGraph()
+	v.checkBranches()
+	for _, s := range p.Shapes {
+		switch s.Type {
+		case Approve, FillIn:
+			v.checkApprovePersons(s)
+			v.checkTimeRule(s)
+			v.checkVoteRule(s)
+		case WebHook:
+			v.checkWebHook(s)
+		case processBranch:
+			v.checkBranchRule(s)
+		case ProcessVariableAssignment:
+			v.checkAssignmentRules(s)
+		case SubFlow:
+			v.checkSubProcess(s)
+		case ScriptTask:
+			v.checkScript(s)
+		case TableDataCreate:
+			v.checkDataCreate(s)
+		case TableDataDelete:
+			v.checkDataDelete(s)
+		case TableDataQuery:
+			v.checkDataQuery(s)
+		}
+	}
+	return v.diagnostics
+}
+
+func (v *validator) add(level, code string, s ShapeModel, message string) {
+	v.diagnostics = append(v.diagnostics, &Diagnostic{
+		Level:   level,
+		Code:    code,
+		ShapeID: s.ID,
+		Name:    s.Data.NodeData.Name,
+		Message: message,
+	})
+}
+
+func isEdge(s ShapeModel) bool {
+	return s.Type == step || s.Type == plus
+}
+
+// checkGraph find shapes which can not be reached from the start shape
+func (v *validator) checkGraph() {
+	var start *ShapeModel
+	hasEnd := false
+	for k := range v.p.Shapes {
+		s := v.p.Shapes[k]
+		if s.Type == FormData || s.Type == FormTime {
+			start = &v.p.Shapes[k]
+			v.formDefKey = s.ID
+		}
+		if s.Type == end {
+			hasEnd = true
+		}
+	}
+	if !hasEnd {
+		v.add(LevelError, DiagNoEnd, ShapeModel{}, "流程缺少结束节点")
+	}
+	if start == nil {
+		v.add(LevelError, DiagNoStart, ShapeModel{}, "流程缺少触发节点")
+		return
+	}
+
+	visited := map[string]bool{start.ID: true}
+	queue := []string{start.ID}
+	for len(queue) > 0 {
+		s := v.shapes[queue[0]]
+		queue = queue[1:]
+		for _, childID := range s.Data.NodeData.ChildrenID {
+			if _, ok := v.shapes[childID]; !ok {
+				v.add(LevelError, DiagUnknownChild, s, "后续节点不存在: "+childID)
+				continue
+			}
+			if !visited[childID] {
+				visited[childID] = true
+				queue = append(queue, childID)
+			}
+		}
+	}
+	for _, s := range v.p.Shapes {
+		if isEdge(s) || visited[s.ID] {
+			continue
+		}
+		v.add(LevelError, DiagUnreachable, s, "节点无法到达")
+	}
+}
+
+// checkBranches every processBranchSource must be closed by a processBranchTarget
+func (v *validator) checkBranches() {
+	closed := make(map[string]bool)
+	for _, s := range v.p.Shapes {
+		if s.Type != processBranchSource {
+			continue
+		}
+		targetID := s.Data.NodeData.BranchTargetElementID
+		target, ok := v.shapes[targetID]
+		if !ok || target.Type != processBranchTarget {
+			v.add(LevelError, DiagDanglingBranch, s, "分流节点缺少对应的合流节点")
+		} else {
+			closed[targetID] = true
+		}
+		if len(s.Data.NodeData.ChildrenID) == 0 {
+			v.add(LevelError, DiagDanglingBranch, s, "分流节点没有分支")
+		}
+		for _, childID := range s.Data.NodeData.ChildrenID {
+			if child, ok := v.shapes[childID]; ok && child.Type != processBranch {
+				v.add(LevelError, DiagDanglingBranch, s, "分流节点的后续节点不是分支: "+childID)
+			}
+		}
+	}
+	for _, s := range v.p.Shapes {
+		if s.Type == processBranchTarget && !closed[s.ID] {
+			v.add(LevelError, DiagDanglingBranch, s, "合流节点缺少对应的分流节点")
+		}
+	}
+}
+
+func (v *validator) checkApprovePersons(s ShapeModel) {
+	basicConfig := GetTaskBasicConfigModel(&s)
+	if basicConfig == nil {
+		v.add(LevelError, DiagNoApprovePersons, s, "审批人未填写")
+		return
+	}
+	persons := basicConfig.ApprovePersons
+	empty := false
+	switch persons.Type {
+	case "":
+		empty = true
+	case Person:
+		empty = len(persons.Users) == 0 && len(persons.Roles) == 0 && len(persons.Departments) == 0
+	case Field:
+		empty = len(persons.Fields) == 0
+		for _, field := range persons.Fields {
+			v.checkFormField(s, field)
+		}
+	case Position:
+		empty = len(persons.Positions) == 0
+	}
+	if empty {
+		v.add(LevelError, DiagNoApprovePersons, s, "审批人未填写")
+	}
+}
+
+func (v *validator) checkVoteRule(s ShapeModel) {
+	basicConfig := GetTaskBasicConfigModel(&s)
+	if basicConfig == nil || basicConfig.VoteRule.Type == "" || basicConfig.VoteRule.Type == VoteAll {
+		return
+	}
+	rule := basicConfig.VoteRule
+	switch {
+	case s.Type != Approve || basicConfig.MultiplePersonWay != "and":
+		v.add(LevelWarning, DiagVoteRule, s, "投票规则仅对会签审批节点生效")
+	case rule.Type == VotePercent && (rule.Value <= 0 || rule.Value > 100):
+		v.add(LevelError, DiagVoteRule, s, "通过比例需在0到100之间")
+	case rule.Type == VoteCount && rule.Value < 1:
+		v.add(LevelError, DiagVoteRule, s, "通过票数至少为1")
+	case !rule.Enabled():
+		v.add(LevelError, DiagVoteRule, s, "投票规则类型错误: "+rule.Type)
+	}
+	for userID, weight := range rule.Weights {
+		if weight < 0 {
+			v.add(LevelError, DiagVoteRule, s, "投票权重不能为负数: "+userID)
+		}
+	}
+}
+
+func (v *validator) checkTimeRule(s ShapeModel) {
+	basicConfig := GetTaskBasicConfigModel(&s)
+	if basicConfig == nil {
+		return
+	}
+	v.checkWhenTimeout(s, basicConfig.TimeRule)
+}
+
+func (v *validator) checkWhenTimeout(s ShapeModel, timeRule TaskTimeRuleModel) {
+	if !timeRule.Enabled {
+		return
+	}
+	timeout := timeRule.WhenTimeout
+	if timeout.Type == "escalate" {
+		v.checkEscalation(s, timeout.Levels)
+		return
+	}
+	if timeout.Type != "jump" {
+		return
+	}
+	target, ok := v.shapes[timeout.Value]
+	if !ok || isEdge(target) {
+		v.add(LevelError, DiagJumpTarget, s, "超时跳转的节点不存在: "+timeout.Value)
+	}
+}
+
+func (v *validator) checkEscalation(s ShapeModel, levels []EscalationLevelModel) {
+	if len(levels) == 0 {
+		v.add(LevelError, DiagEscalation, s, "超时升级未设置处理")
+		return
+	}
+	for _, level := range levels {
+		switch level.Action {
+		case EscalateNotifySuperior, EscalateReassignLead, EscalateAbnormal:
+		default:
+			v.add(LevelError, DiagEscalation, s, "超时升级的处理方式不存在: "+level.Action)
+		}
+	}
+}
+
+func (v *validator) checkWebHook(s ShapeModel) {
+	conf := utils.ChangeObjectToMap(s.Data.BusinessData["config"])
+	if conf == nil {
+		return
+	}
+	if conf["mode"] == WebHookModeCallback && conf["timeRule"] != nil {
+		timeRule := TaskTimeRuleModel{}
+		bytes, err := json.Marshal(conf["timeRule"])
+		if err == nil && json.Unmarshal(bytes, &timeRule) == nil {
+			v.checkWhenTimeout(s, timeRule)
+		}
+	}
+	if conf["inputs"] == nil {
+		return
+	}
+	inputs := make([]Input, 0)
+	bytes, err := json.Marshal(conf["inputs"])
+	if err != nil || json.Unmarshal(bytes, &inputs) != nil {
+		return
+	}
+	v.checkInputs(s, inputs)
+}
+
+func (v *validator) checkInputs(s ShapeModel, inputs []Input) {
+	for _, input := range inputs {
+		if input.Type == "object" {
+			children := make([]Input, 0)
+			bytes, err := json.Marshal(input.Data)
+			if err == nil && json.Unmarshal(bytes, &children) == nil {
+				v.checkInputs(s, children)
+			}
+			continue
+		}
+		if input.FieldName != "" && input.TableID == "" {
+			v.checkFormField(s, input.FieldName)
+		}
+		if input.Type == "direct_expr" {
+			v.checkRefs(s, utils.Strval(input.Data))
+		}
+	}
+}
+
+func (v *validator) checkBranchRule(s ShapeModel) {
+	bd := s.Data.BusinessData
+	if rule := bd["rule"]; rule != nil && utils.Strval(rule) != "" {
+		v.checkFormula(s, utils.Strval(rule))
+		return
+	}
+	if utils.Strval(bd["ignore"]) != "true" {
+		v.add(LevelError, DiagConditionNotSet, s, "分支条件未设置")
+	}
+}
+
+func (v *validator) checkAssignmentRules(s ShapeModel) {
+	v.checkValueRules(s, s.Data.BusinessData["assignmentRules"], true)
+}
+
+// checkSubProcess the child flow must be chosen, inputs are calculated against this flow,
+// outputs are written into variables of this flow
+func (v *validator) checkSubProcess(s ShapeModel) {
+	subFlowID := utils.Strval(s.Data.BusinessData["flowID"])
+	if subFlowID == "" {
+		v.add(LevelError, DiagSubFlowNotSet, s, "未选择子流程")
+	} else if v.startsSelf(subFlowID, make(map[string]bool)) {
+		v.add(LevelError, DiagSubFlowCycle, s, "子流程循环调用了本流程")
+	}
+	v.checkValueRules(s, s.Data.BusinessData["formMapping"], false)
+	v.checkValueRules(s, s.Data.BusinessData["variableMapping"], false)
+
+	rules, _ := s.Data.BusinessData["outputMapping"].([]interface{})
+	for _, e := range rules {
+		rule := utils.ChangeObjectToMap(e)
+		if rule == nil {
+			continue
+		}
+		variableName := utils.Strval(rule["variableName"])
+		if v.refs.Variables != nil && !v.refs.Variables[variableName] {
+			v.add(LevelError, DiagUnknownVariable, s, "流程变量不存在: "+variableName)
+		}
+	}
+}
+
+// startsSelf whether the child flow starts the flow being checked, directly or through its own child flows
+func (v *validator) startsSelf(flowID string, visited map[string]bool) bool {
+	if v.refs.FlowID == "" {
+		return false
+	}
+	if flowID == v.refs.FlowID {
+		return true
+	}
+	if visited[flowID] {
+		return false
+	}
+	visited[flowID] = true
+	for _, id := range v.refs.SubFlows[flowID] {
+		if v.startsSelf(id, visited) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkScript compile the script like the script node and check its references
+func (v *validator) checkScript(s ShapeModel) {
+	src := utils.Strval(s.Data.BusinessData["script"])
+	if strings.TrimSpace(src) == "" {
+		v.add(LevelWarning, DiagScript, s, "脚本为空")
+		return
+	}
+	v.checkRefs(s, src)
+	if _, err := eval.ParseScript(NormalizeExpression(src, v.formDefKey)); err != nil {
+		v.add(LevelError, DiagScript, s, "脚本错误: "+err.Error())
+	}
+}
+
+// checkDataCreate createRule is {field: {valueFrom, valueOf}}, values of subTableRow need the sub table to iterate
+func (v *validator) checkDataCreate(s ShapeModel) {
+	bd := s.Data.BusinessData
+	if utils.Strval(bd["targetTableId"]) == "" {
+		v.add(LevelError, DiagTargetTable, s, "未选择目标数据表")
+	}
+	iterate := utils.ChangeObjectToMap(bd["iterateSubTable"])
+	iterated := iterate != nil && utils.Strval(iterate["fieldName"]) != ""
+
+	rules := make([]interface{}, 0)
+	for _, rule := range utils.ChangeObjectToMap(bd["createRule"]) {
+		rules = append(rules, rule)
+	}
+	for _, ref := range utils.ChangeObjectToMap(bd["ref"]) {
+		createRules, _ := utils.ChangeObjectToMap(ref)["createRules"].([]interface{})
+		for _, e := range createRules {
+			for _, rule := range utils.ChangeObjectToMap(e) {
+				rules = append(rules, rule)
+			}
+		}
+	}
+	v.checkValueRules(s, rules, false)
+	for _, e := range rules {
+		rule := utils.ChangeObjectToMap(e)
+		if rule != nil && utils.Strval(rule["valueFrom"]) == "subTableRow" && !iterated {
+			v.add(LevelError, DiagSubTableNotSet, s, "使用子表行数据时需选择遍历的子表")
+			return
+		}
+	}
+}
+
+func (v *validator) checkDataDelete(s ShapeModel) {
+	bd := s.Data.BusinessData
+	if utils.Strval(bd["targetTableId"]) == "" {
+		v.add(LevelError, DiagTargetTable, s, "未选择目标数据表")
+	}
+	if !hasDeleteScope(s) {
+		v.add(LevelError, DiagDeleteScope, s, "未设置删除数据的筛选条件或数据ID变量")
+		return
+	}
+	v.checkRefs(s, utils.Strval(bd["idsVariable"]))
+}
+
+func (v *validator) checkDataQuery(s ShapeModel) {
+	bd := s.Data.BusinessData
+	if utils.Strval(bd["targetTableId"]) == "" {
+		v.add(LevelError, DiagTargetTable, s, "未选择目标数据表")
+	}
+	outputs, _ := bd["outputMapping"].([]interface{})
+	if len(outputs) == 0 {
+		v.add(LevelError, DiagQueryOutput, s, "未设置查询结果写入的流程变量")
+		return
+	}
+	for _, e := range outputs {
+		output := utils.ChangeObjectToMap(e)
+		if output == nil {
+			continue
+		}
+		variableName := strings.TrimPrefix(utils.Strval(output["variableName"]), "$variable.")
+		if v.refs.Variables != nil && !v.refs.Variables[variableName] {
+			v.add(LevelError, DiagUnknownVariable, s, "流程变量不存在: "+variableName)
+		}
+		switch utils.Strval(output["type"]) {
+		case "first", "count":
+		case "sum", "max", "min":
+			if utils.Strval(output["fieldName"]) == "" {
+				v.add(LevelError, DiagQueryOutput, s, "统计查询结果需选择字段: "+variableName)
+			}
+		default:
+			v.add(LevelError, DiagQueryOutput, s, "查询结果类型错误: "+utils.Strval(output["type"]))
+		}
+	}
+}
+
+// checkValueRules check rules of {variableName, valueFrom, valueOf}
+func (v *validator) checkValueRules(s ShapeModel, value interface{}, checkVariable bool) {
+	rules, ok := value.([]interface{})
+	if !ok {
+		return
+	}
+	for _, e := range rules {
+		rule := utils.ChangeObjectToMap(e)
+		if rule == nil {
+			continue
+		}
+		variableName := utils.Strval(rule["variableName"])
+		if checkVariable && v.refs.Variables != nil && !v.refs.Variables[variableName] {
+			v.add(LevelError, DiagUnknownVariable, s, "流程变量不存在: "+variableName)
+		}
+		switch utils.Strval(rule["valueFrom"]) {
+		case "formula":
+			v.checkFormula(s, utils.Strval(rule["valueOf"]))
+		case "currentFormValue":
+			v.checkFormField(s, utils.Strval(rule["valueOf"]))
+		case "processVariable":
+			v.checkRefs(s, utils.Strval(rule["valueOf"]))
+		}
+	}
+}
+
+// checkFormula check references of expression and compile it like the formula engine
+func (v *validator) checkFormula(s ShapeModel, expression string) {
+	v.checkRefs(s, expression)
+	if err := eval.Check(NormalizeExpression(expression, v.formDefKey)); err != nil {
+		v.add(LevelError, DiagFormula, s, "公式错误: "+expression+", "+err.Error())
+	}
+}
+
+// checkRefs check $variable.xxx and $formDefKey.xxx references
+func (v *validator) checkRefs(s ShapeModel, text string) {
+	for _, match := range refPattern.FindAllStringSubmatch(text, -1) {
+		if match[1] == "variable" {
+			if v.refs.Variables != nil && !v.refs.Variables[match[2]] {
+				v.add(LevelError, DiagUnknownVariable, s, "流程变量不存在: "+match[2])
+			}
+		} else if match[1] == v.formDefKey {
+			v.checkFormField(s, match[2])
+		}
+	}
+}
+
+func (v *validator) checkFormField(s ShapeModel, field string) {
+	if v.refs.FormFields == nil || field == "" {
+		return
+	}
+	// fieldxxx, fieldxxx.value, fieldxxx.[].value
+	field = strings.Split(field, ".")[0]
+	if !v.refs.FormFields[field] {
+		v.add(LevelError, DiagUnknownField, s, "表单字段不存在: "+field)
+	}
+}
+
+// NormalizeExpression strip $variable. and $formDefKey. prefix of chart expression
+func NormalizeExpression(expression string, formDefKey string) string {
+	expression = strings.Replace(expression, "$variable.", "", -1)
+	if formDefKey != "" {
+		expression = strings.Replace(expression, "$"+formDefKey+".", "", -1)
+	}
+	return strings.Replace(expression, "$", "", -1)
+}
@@ -0,0 +1,162 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package convert
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	chart := `{"shapes":[
+		{"id":"form","type":"formData","data":{"nodeData":{"name":"开始","childrenID":["src"]}}},
+		{"id":"src","type":"processBranchSource","data":{"nodeData":{"name":"分流","childrenID":["b1","b2"],"branchTargetElementID":"tgt"}}},
+		{"id":"b1","type":"processBranch","data":{"nodeData":{"name":"分支1","childrenID":["a1"]},"businessData":{"rule":"$form.field_a >= 10 && $variable.level == 'high'"}}},
+		{"id":"b2","type":"processBranch","data":{"nodeData":{"name":"分支2","childrenID":["tgt"]},"businessData":{"rule":"field_a >"}}},
+		{"id":"a1","type":"approve","data":{"nodeData":{"name":"审批1","childrenID":["tgt"]},"businessData":{"basicConfig":{"approvePersons":{"type":"person"},"multiplePersonWay":"and","voteRule":{"type":"percent","value":150},"timeRule":{"enabled":true,"whenTimeout":{"type":"jump","value":"nowhere"}}}}}},
+		{"id":"tgt","type":"processBranchTarget","data":{"nodeData":{"name":"合流","childrenID":["sub"]}}},
+		{"id":"sub","type":"subProcess","data":{"nodeData":{"name":"子流程","childrenID":["scr"]},"businessData":{"variableMapping":[{"variableName":"x","valueFrom":"formula","valueOf":"$form.field_a +"}],"outputMapping":[{"variableName":"missing","valueFrom":"processVariable","valueOf":"y"}]}}},
+		{"id":"scr","type":"script","data":{"nodeData":{"name":"脚本","childrenID":["hook"]},"businessData":{"script":"$variable.level = 'a'\nif ($form.field_a > 1 { }"}}},
+		{"id":"hook","type":"webhook","data":{"nodeData":{"name":"等待回调","childrenID":["dc"]},"businessData":{"type":"send","config":{"mode":"callback","timeRule":{"enabled":true,"deadLine":{"day":1},"whenTimeout":{"type":"jump","value":"gone"}}}}}},
+		{"id":"dc","type":"tableDataCreate","data":{"nodeData":{"name":"新增数据","childrenID":["dd"]},"businessData":{"createRule":{"field_b":{"valueFrom":"subTableRow","valueOf":"field_x"},"field_c":{"valueFrom":"formula","valueOf":"$form.field_a *"}}}}},
+		{"id":"dd","type":"tableDataDelete","data":{"nodeData":{"name":"删除数据","childrenID":["dd2"]},"businessData":{"targetTableId":"t1","filterRule":{"conditions":[]}}}},
+		{"id":"dd2","type":"tableDataDelete","data":{"nodeData":{"name":"按ID删除","childrenID":["dq"]},"businessData":{"targetTableId":"t1","idsVariable":"$variable.ids"}}},
+		{"id":"dq","type":"tableDataQuery","data":{"nodeData":{"name":"查询数据","childrenID":["end"]},"businessData":{"targetTableId":"t1","outputMapping":[{"variableName":"level","type":"first","fieldName":"field_a"},{"variableName":"level","type":"sum"},{"variableName":"level","type":"avg","fieldName":"field_a"}]}}},
+		{"id":"lost","type":"email","data":{"nodeData":{"name":"孤立节点"}}},
+		{"id":"end","type":"end","data":{"nodeData":{"name":"结束"}}},
+		{"id":"e1","type":"step","source":"form","target":"src"}
+	]}`
+	p := &ProcessModel{}
+	if err := json.Unmarshal([]byte(chart), p); err != nil {
+		t.Fatal(err)
+	}
+
+	got := make(map[string]string)
+	for _, d := range Validate(p, &ValidateRefs{
+		FormFields: map[string]bool{"field_a": true},
+		Variables:  map[string]bool{"level": true},
+	}) {
+		got[d.ShapeID+":"+d.Code] = d.Level
+	}
+	want := []string{
+		"a1:" + DiagNoApprovePersons,
+		"a1:" + DiagJumpTarget,
+		"a1:" + DiagVoteRule,
+		"b2:" + DiagFormula,
+		"lost:" + DiagUnreachable,
+		"sub:" + DiagSubFlowNotSet,
+		"sub:" + DiagFormula,
+		"sub:" + DiagUnknownVariable,
+		"scr:" + DiagScript,
+		"hook:" + DiagJumpTarget,
+		"dc:" + DiagTargetTable,
+		"dc:" + DiagSubTableNotSet,
+		"dc:" + DiagFormula,
+		"dd:" + DiagDeleteScope,
+		"dd2:" + DiagUnknownVariable,
+		"dq:" + DiagQueryOutput,
+	}
+	for _, key := range want {
+		if _, ok := got[key]; !ok {
+			t.Errorf("missing diagnostic %s, got %v", key, got)
+		}
+	}
+	if len(got) != len(want) {
+		t.Errorf("got %d diagnostics, want %d: %v", len(got), len(want), got)
+	}
+
+	got = make(map[string]string)
+	for _, d := range Validate(p, &ValidateRefs{
+		FormFields: map[string]bool{},
+		Variables:  map[string]bool{},
+	}) {
+		got[d.ShapeID+":"+d.Code] = d.Level
+	}
+	for _, key := range []string{"b1:" + DiagUnknownField, "b1:" + DiagUnknownVariable} {
+		if _, ok := got[key]; !ok {
+			t.Errorf("missing diagnostic %s, got %v", key, got)
+		}
+	}
+}
+
+func TestValidateFormula(t *testing.T) {
+	tests := []struct {
+		expression string
+		valid      bool
+	}{
+		{"$form.field_a >= 10 && $variable.level == 'high'", true},
+		{"round($form.field_a * 1.1) > max(1, 2)", true},
+		{"$form.field_a >", false},
+		{"unknown($form.field_a)", false},
+	}
+	for _, tt := range tests {
+		v := &validator{formDefKey: "form", refs: &ValidateRefs{}}
+		v.checkFormula(ShapeModel{ID: "s"}, tt.expression)
+		if valid := len(v.diagnostics) == 0; valid != tt.valid {
+			t.Errorf("checkFormula(%s) valid = %v, want %v: %v", tt.expression, valid, tt.valid, v.diagnostics)
+		}
+	}
+}
+
+func TestValidateEscalation(t *testing.T) {
+	chart := `{"shapes":[
+		{"id":"form","type":"formData","data":{"nodeData":{"name":"开始","childrenID":["a1"]}}},
+		{"id":"a1","type":"approve","data":{"nodeData":{"name":"审批1","childrenID":["a2"]},"businessData":{"basicConfig":{"timeRule":{"enabled":true,"whenTimeout":{"type":"escalate","levels":[{"hours":1,"action":"notifySuperior"},{"day":1,"action":"fire"}]}}}}}},
+		{"id":"a2","type":"approve","data":{"nodeData":{"name":"审批2","childrenID":["end"]},"businessData":{"basicConfig":{"timeRule":{"enabled":true,"whenTimeout":{"type":"escalate","levels":[{"hours":1,"action":"notifySuperior"},{"day":1,"action":"abnormal"}]}}}}}},
+		{"id":"end","type":"end","data":{"nodeData":{"name":"结束"}}}
+	]}`
+	p := &ProcessModel{}
+	if err := json.Unmarshal([]byte(chart), p); err != nil {
+		t.Fatal(err)
+	}
+
+	got := make(map[string]bool)
+	for _, d := range Validate(p, &ValidateRefs{}) {
+		got[d.ShapeID+":"+d.Code] = true
+	}
+	if !got["a1:"+DiagEscalation] {
+		t.Errorf("missing diagnostic a1:%s, got %v", DiagEscalation, got)
+	}
+	if got["a2:"+DiagEscalation] {
+		t.Errorf("unexpected diagnostic a2:%s", DiagEscalation)
+	}
+}
+
+func TestValidateSubFlowCycle(t *testing.T) {
+	chart := `{"shapes":[
+		{"id":"form","type":"formData","data":{"nodeData":{"name":"开始","childrenID":["sub"]}}},
+		{"id":"sub","type":"subProcess","data":{"nodeData":{"name":"子流程","childrenID":["end"]},"businessData":{"flowID":"f2"}}},
+		{"id":"end","type":"end","data":{"nodeData":{"name":"结束"}}}
+	]}`
+	p := &ProcessModel{}
+	if err := json.Unmarshal([]byte(chart), p); err != nil {
+		t.Fatal(err)
+	}
+
+	refs := &ValidateRefs{FlowID: "f1", SubFlows: map[string][]string{"f2": {"f3"}, "f3": {"f2"}}}
+	for _, d := range Validate(p, refs) {
+		if d.Code == DiagSubFlowCycle {
+			t.Errorf("unexpected diagnostic %v", d)
+		}
+	}
+	// f2 -> f3 -> f1
+	refs.SubFlows["f3"] = append(refs.SubFlows["f3"], "f1")
+	found := false
+	for _, d := range Validate(p, refs) {
+		found = found || d.ShapeID == "sub" && d.Code == DiagSubFlowCycle
+	}
+	if !found {
+		t.Errorf("missing diagnostic sub:%s", DiagSubFlowCycle)
+	}
+}
@@ -0,0 +1,60 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package convert
+
+// Vote rule type
+const (
+	VoteAll     = "all"     // 全部通过
+	VotePercent = "percent" // 通过比例不低于value%
+	VoteCount   = "count"   // 通过票数不低于value
+)
+
+// VoteRuleModel struct
+type VoteRuleModel struct {
+	Type    string             `json:"type"`
+	Value   float64            `json:"value"`
+	Weights map[string]float64 `json:"weights"` // 审批人票数权重，未设置为1
+}
+
+// Enabled vote rule other than all approvers agree
+func (v VoteRuleModel) Enabled() bool {
+	return v.Type == VotePercent || v.Type == VoteCount
+}
+
+// Weight of the approver's vote
+func (v VoteRuleModel) Weight(userID string) float64 {
+	if w, ok := v.Weights[userID]; ok && w >= 0 {
+		return w
+	}
+	return 1
+}
+
+// Decide outcome of the votes, pass when agreed reaches the threshold,
+// reject when the agreed and the not voted can not reach it
+func (v VoteRuleModel) Decide(agreed, refused, total float64) (pass bool, decided bool) {
+	threshold := v.Value
+	if v.Type == VotePercent {
+		threshold = total * v.Value / 100
+	}
+	if threshold > total {
+		threshold = total
+	}
+	if agreed >= threshold && agreed > 0 {
+		return true, true
+	}
+	if total-refused < threshold || refused >= total {
+		return false, true
+	}
+	return false, false
+}
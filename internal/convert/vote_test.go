@@ -0,0 +1,50 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package convert
+
+import (
+	"testing"
+)
+
+func TestVoteRuleModel_Decide(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    VoteRuleModel
+		agreed  float64
+		refused float64
+		total   float64
+		pass    bool
+		decided bool
+	}{
+		{"majority pending", VoteRuleModel{Type: VotePercent, Value: 50}, 1, 1, 5, false, false},
+		{"majority pass", VoteRuleModel{Type: VotePercent, Value: 50}, 3, 1, 5, true, true},
+		{"majority impossible", VoteRuleModel{Type: VotePercent, Value: 60}, 1, 3, 5, false, true},
+		{"count pass", VoteRuleModel{Type: VoteCount, Value: 2}, 2, 0, 5, true, true},
+		{"count pending", VoteRuleModel{Type: VoteCount, Value: 2}, 1, 2, 5, false, false},
+		{"count over total", VoteRuleModel{Type: VoteCount, Value: 9}, 2, 0, 3, false, false},
+		{"count over total reject", VoteRuleModel{Type: VoteCount, Value: 9}, 2, 1, 3, false, true},
+		{"zero threshold", VoteRuleModel{Type: VoteCount}, 0, 2, 3, false, false},
+		{"zero threshold all refused", VoteRuleModel{Type: VoteCount}, 0, 3, 3, false, true},
+	}
+	for _, tt := range tests {
+		pass, decided := tt.rule.Decide(tt.agreed, tt.refused, tt.total)
+		if pass != tt.pass || decided != tt.decided {
+			t.Errorf("%s: Decide() = %v, %v, want %v, %v", tt.name, pass, decided, tt.pass, tt.decided)
+		}
+	}
+	rule := VoteRuleModel{Type: VotePercent, Value: 50, Weights: map[string]float64{"chair": 2}}
+	if rule.Weight("chair") != 2 || rule.Weight("member") != 1 {
+		t.Errorf("unexpected weights %v, %v", rule.Weight("chair"), rule.Weight("member"))
+	}
+}
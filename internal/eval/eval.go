@@ -17,6 +17,7 @@ import (
 	"context"
 	"errors"
 	"github.com/quanxiang-cloud/flow/internal/eval/gova"
+	"github.com/quanxiang-cloud/flow/pkg/utils"
 	"reflect"
 	"regexp"
 	"strconv"
@@ -50,6 +51,19 @@ func Handler(c context.Context, req *FormulaReq) (Result, error) {
 	return r, nil
 }
 
+// Check check syntax of expression the same way as Handler
+func Check(expression string) error {
+	if len(expression) > exprMaxLength {
+		return errors.New("expr too long")
+	}
+	return gova.Check(symbolReplace(expression))
+}
+
+// Value unwrap the value of Handler result
+func Value(r Result) interface{} {
+	return utils.ChangeObjectToMap(r)["result"]
+}
+
 func arrayReplace(expr string, param map[string]interface{}) (string, error) {
 	if len(expr) > exprMaxLength {
 		return "", errors.New("expr too long")
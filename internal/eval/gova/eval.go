@@ -39,12 +39,7 @@ type result struct {
 
 // EvalFunc EvalFunc
 func EvalFunc(exprString string, parameters map[string]interface{}) (interface{}, error) {
-	// in exprString,parameter use [xxx] style
-	expr, err := govaluate.NewEvaluableExpressionWithFunctions(exprString, functions)
-	if err != nil {
-		return nil, err
-	}
-	res, err := expr.Evaluate(parameters)
+	res, err := Eval(exprString, parameters)
 	if err != nil {
 		return nil, err
 	}
@@ -52,3 +47,19 @@ func EvalFunc(exprString string, parameters map[string]interface{}) (interface{}
 		Res: res,
 	}, nil
 }
+
+// Eval evaluate expression and return the raw value
+func Eval(exprString string, parameters map[string]interface{}) (interface{}, error) {
+	// in exprString,parameter use [xxx] style
+	expr, err := govaluate.NewEvaluableExpressionWithFunctions(exprString, functions)
+	if err != nil {
+		return nil, err
+	}
+	return expr.Evaluate(parameters)
+}
+
+// Check compile expression without evaluating it
+func Check(exprString string) error {
+	_, err := govaluate.NewEvaluableExpressionWithFunctions(exprString, functions)
+	return err
+}
@@ -0,0 +1,480 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package eval
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/quanxiang-cloud/flow/internal/eval/gova"
+)
+
+// 脚本最大长度
+const scriptMaxLength = 20000
+
+var (
+	// ErrScriptTimeout script run out of time
+	ErrScriptTimeout = errors.New("script timeout")
+	// ErrScriptTooManySteps script executed too many statements
+	ErrScriptTooManySteps = errors.New("script executed too many statements")
+	// ErrScriptTooManyLoops loop of script iterated too many times
+	ErrScriptTooManyLoops = errors.New("script loop iterated too many times")
+)
+
+// ScriptLimit bounds of running a script
+type ScriptLimit struct {
+	MaxSteps int           // statements executed in total, loop iterations included
+	MaxLoops int           // iterations of one while loop
+	Timeout  time.Duration // time of the whole run
+}
+
+// DefaultScriptLimit default bounds of script
+var DefaultScriptLimit = ScriptLimit{
+	MaxSteps: 10000,
+	MaxLoops: 1000,
+	Timeout:  3 * time.Second,
+}
+
+// Script a program of statements, every expression in it is evaluated like a formula.
+//
+//	total = 0
+//	i = 0
+//	while (i < n) {
+//	    total = total + price * 2; i = i + 1
+//	}
+//	if (total >= 100) { level = 'high' } else if (total > 0) { level = 'mid' } else { level = 'low' }
+//
+// Statements are ended by ';' or a new line, '//' starts a comment.
+type Script struct {
+	stmts []stmt
+}
+
+type stmt interface {
+	exec(r *runner) error
+}
+
+type assignStmt struct {
+	line int
+	name string
+	expr string
+}
+
+type ifStmt struct {
+	line int
+	cond string
+	then []stmt
+	els  []stmt
+}
+
+type whileStmt struct {
+	line int
+	cond string
+	body []stmt
+}
+
+// ParseScript parse script and check every expression in it
+func ParseScript(src string) (*Script, error) {
+	if len(src) > scriptMaxLength {
+		return nil, errors.New("script too long")
+	}
+	p := &scriptParser{src: []rune(src), line: 1}
+	stmts, err := p.parseStmts(false)
+	if err != nil {
+		return nil, err
+	}
+	return &Script{stmts: stmts}, nil
+}
+
+// Run run the script with parameters, returns the variables assigned by the script
+func (s *Script) Run(ctx context.Context, parameters map[string]interface{}, limit ScriptLimit) (map[string]interface{}, error) {
+	if limit.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, limit.Timeout)
+		defer cancel()
+	}
+	r := &runner{
+		ctx:      ctx,
+		limit:    limit,
+		env:      make(map[string]interface{}, len(parameters)),
+		assigned: make(map[string]interface{}),
+	}
+	for k, v := range parameters {
+		r.env[k] = v
+	}
+	if err := r.run(s.stmts); err != nil {
+		return nil, err
+	}
+	return r.assigned, nil
+}
+
+type runner struct {
+	ctx      context.Context
+	limit    ScriptLimit
+	steps    int
+	env      map[string]interface{}
+	assigned map[string]interface{}
+}
+
+func (r *runner) run(stmts []stmt) error {
+	for _, s := range stmts {
+		if err := r.step(); err != nil {
+			return err
+		}
+		if err := s.exec(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *runner) step() error {
+	if r.ctx.Err() != nil {
+		return ErrScriptTimeout
+	}
+	r.steps++
+	if r.limit.MaxSteps > 0 && r.steps > r.limit.MaxSteps {
+		return ErrScriptTooManySteps
+	}
+	return nil
+}
+
+func (r *runner) eval(line int, expr string) (interface{}, error) {
+	expr, err := arrayReplace(expr, r.env)
+	if err != nil {
+		return nil, err
+	}
+	v, err := gova.Eval(symbolReplace(expr), r.env)
+	if err != nil {
+		return nil, fmt.Errorf("line %d: %s", line, err.Error())
+	}
+	return v, nil
+}
+
+func (r *runner) cond(line int, expr string) (bool, error) {
+	v, err := r.eval(line, expr)
+	if err != nil {
+		return false, err
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return false, fmt.Errorf("line %d: condition %s is not bool", line, expr)
+	}
+	return b, nil
+}
+
+func (s *assignStmt) exec(r *runner) error {
+	v, err := r.eval(s.line, s.expr)
+	if err != nil {
+		return err
+	}
+	r.env[s.name] = v
+	r.assigned[s.name] = v
+	return nil
+}
+
+func (s *ifStmt) exec(r *runner) error {
+	ok, err := r.cond(s.line, s.cond)
+	if err != nil {
+		return err
+	}
+	if ok {
+		return r.run(s.then)
+	}
+	return r.run(s.els)
+}
+
+func (s *whileStmt) exec(r *runner) error {
+	for i := 0; ; i++ {
+		ok, err := r.cond(s.line, s.cond)
+		if err != nil || !ok {
+			return err
+		}
+		if r.limit.MaxLoops > 0 && i >= r.limit.MaxLoops {
+			return fmt.Errorf("line %d: %w", s.line, ErrScriptTooManyLoops)
+		}
+		if err := r.step(); err != nil {
+			return err
+		}
+		if err := r.run(s.body); err != nil {
+			return err
+		}
+	}
+}
+
+// ---- parser ----
+
+type scriptParser struct {
+	src  []rune
+	pos  int
+	line int
+}
+
+func (p *scriptParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("line %d: %s", p.line, fmt.Sprintf(format, args...))
+}
+
+func (p *scriptParser) peek() rune {
+	if p.pos >= len(p.src) {
+		return 0
+	}
+	return p.src[p.pos]
+}
+
+func (p *scriptParser) advance() rune {
+	r := p.src[p.pos]
+	p.pos++
+	if r == '\n' {
+		p.line++
+	}
+	return r
+}
+
+// skip skips blanks and comments, new lines and ';' are skipped too when stmtEnd is true
+func (p *scriptParser) skip(stmtEnd bool) {
+	for p.pos < len(p.src) {
+		r := p.peek()
+		switch {
+		case r == '/' && p.pos+1 < len(p.src) && p.src[p.pos+1] == '/':
+			for p.pos < len(p.src) && p.peek() != '\n' {
+				p.advance()
+			}
+		case r == '\n' || r == ';':
+			if !stmtEnd {
+				return
+			}
+			p.advance()
+		case unicode.IsSpace(r):
+			p.advance()
+		default:
+			return
+		}
+	}
+}
+
+func (p *scriptParser) ident() string {
+	start := p.pos
+	for p.pos < len(p.src) {
+		r := p.peek()
+		if r == '_' || unicode.IsLetter(r) || (p.pos > start && unicode.IsDigit(r)) {
+			p.advance()
+			continue
+		}
+		break
+	}
+	return string(p.src[start:p.pos])
+}
+
+// keyword consume word if it is the next identifier
+func (p *scriptParser) keyword(word string) bool {
+	pos, line := p.pos, p.line
+	if p.ident() == word {
+		return true
+	}
+	p.pos, p.line = pos, line
+	return false
+}
+
+func (p *scriptParser) parseStmts(inBlock bool) ([]stmt, error) {
+	stmts := make([]stmt, 0)
+	for {
+		p.skip(true)
+		if p.pos >= len(p.src) {
+			if inBlock {
+				return nil, p.errorf("want '}'")
+			}
+			return stmts, nil
+		}
+		if p.peek() == '}' {
+			if !inBlock {
+				return nil, p.errorf("unexpected '}'")
+			}
+			return stmts, nil
+		}
+		s, err := p.parseStmt()
+		if err != nil {
+			return nil, err
+		}
+		stmts = append(stmts, s)
+	}
+}
+
+func (p *scriptParser) parseStmt() (stmt, error) {
+	line := p.line
+	if p.keyword("if") {
+		return p.parseIf(line)
+	}
+	if p.keyword("while") {
+		cond, err := p.parseCond()
+		if err != nil {
+			return nil, err
+		}
+		body, err := p.parseBlock()
+		if err != nil {
+			return nil, err
+		}
+		return &whileStmt{line: line, cond: cond, body: body}, nil
+	}
+
+	name := p.ident()
+	if name == "" {
+		return nil, p.errorf("unexpected %q", p.peek())
+	}
+	if name == "else" {
+		return nil, p.errorf("else without if")
+	}
+	p.skip(false)
+	if p.peek() != '=' || (p.pos+1 < len(p.src) && p.src[p.pos+1] == '=') {
+		return nil, p.errorf("want '=' after %s", name)
+	}
+	p.advance()
+	expr, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	return &assignStmt{line: line, name: name, expr: expr}, nil
+}
+
+func (p *scriptParser) parseIf(line int) (stmt, error) {
+	cond, err := p.parseCond()
+	if err != nil {
+		return nil, err
+	}
+	then, err := p.parseBlock()
+	if err != nil {
+		return nil, err
+	}
+	s := &ifStmt{line: line, cond: cond, then: then}
+
+	pos, lineBeforeElse := p.pos, p.line
+	p.skip(true)
+	if !p.keyword("else") {
+		p.pos, p.line = pos, lineBeforeElse
+		return s, nil
+	}
+	p.skip(true)
+	elseLine := p.line
+	if p.keyword("if") {
+		elseIf, err := p.parseIf(elseLine)
+		if err != nil {
+			return nil, err
+		}
+		s.els = []stmt{elseIf}
+		return s, nil
+	}
+	s.els, err = p.parseBlock()
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// parseCond parse '(' expr ')'
+func (p *scriptParser) parseCond() (string, error) {
+	p.skip(false)
+	if p.peek() != '(' {
+		return "", p.errorf("want '('")
+	}
+	start := p.pos
+	if err := p.scanExpr(true); err != nil {
+		return "", err
+	}
+	cond := strings.TrimSpace(string(p.src[start+1 : p.pos-1]))
+	if cond == "" {
+		return "", p.errorf("empty condition")
+	}
+	if err := Check(cond); err != nil {
+		return "", p.errorf("%s", err.Error())
+	}
+	return cond, nil
+}
+
+// parseBlock parse '{' stmts '}'
+func (p *scriptParser) parseBlock() ([]stmt, error) {
+	p.skip(true)
+	if p.peek() != '{' {
+		return nil, p.errorf("want '{'")
+	}
+	p.advance()
+	stmts, err := p.parseStmts(true)
+	if err != nil {
+		return nil, err
+	}
+	p.advance() // consume '}'
+	return stmts, nil
+}
+
+// parseExpr parse expression until the end of statement
+func (p *scriptParser) parseExpr() (string, error) {
+	line := p.line
+	start := p.pos
+	if err := p.scanExpr(false); err != nil {
+		return "", err
+	}
+	expr := strings.TrimSpace(string(p.src[start:p.pos]))
+	if expr == "" {
+		return "", fmt.Errorf("line %d: empty expression", line)
+	}
+	if err := Check(expr); err != nil {
+		return "", fmt.Errorf("line %d: %s", line, err.Error())
+	}
+	return expr, nil
+}
+
+// scanExpr move over an expression, brackets and quotes are matched.
+// When group is true the expression is a '(' ... ')' group, otherwise it ends before ';', new line or '}'.
+func (p *scriptParser) scanExpr(group bool) error {
+	depth := 0
+	for p.pos < len(p.src) {
+		r := p.peek()
+		switch r {
+		case '\'', '"':
+			p.advance()
+			for p.pos < len(p.src) && p.peek() != r {
+				if p.advance() == '\n' {
+					return p.errorf("string not closed")
+				}
+			}
+			if p.pos >= len(p.src) {
+				return p.errorf("string not closed")
+			}
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			if depth == 0 {
+				if r == '}' && !group {
+					return nil
+				}
+				return p.errorf("unexpected %q", r)
+			}
+			depth--
+			if depth == 0 && group {
+				p.advance()
+				return nil
+			}
+		case ';', '\n':
+			if depth == 0 && !group {
+				return nil
+			}
+		}
+		p.advance()
+	}
+	if group || depth > 0 {
+		return p.errorf("brackets not closed")
+	}
+	return nil
+}
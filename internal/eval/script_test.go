@@ -0,0 +1,107 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package eval
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestScript(t *testing.T) {
+	src := `
+	// sum prices
+	total = 0; i = 0
+	while (i < n) {
+		total = total + price * 2
+		i = i + 1
+	}
+	if (total >= 100 && name == "mark") {
+		level = 'high'
+	} else if (total > 0) { level = 'mid' } else {
+		level = 'low'
+	}
+	max_v = max(total, 10)
+	`
+	s, err := ParseScript(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	params := map[string]interface{}{"n": 3, "price": 20, "name": "mark"}
+	got, err := s.Run(context.Background(), params, DefaultScriptLimit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{"total": 120.0, "i": 3.0, "level": "high", "max_v": 120.0}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s = %v, want %v", k, got[k], v)
+		}
+	}
+	if _, ok := got["price"]; ok {
+		t.Errorf("parameter price should not be returned")
+	}
+	if params["total"] != nil {
+		t.Errorf("parameters should not be changed")
+	}
+
+	params["name"] = "bob"
+	got, err = s.Run(context.Background(), params, DefaultScriptLimit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got["level"] != "mid" {
+		t.Errorf("level = %v, want mid", got["level"])
+	}
+}
+
+func TestScriptLimit(t *testing.T) {
+	s, err := ParseScript("i = 0\nwhile (i >= 0) { i = i + 1 }")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.Run(context.Background(), nil, ScriptLimit{MaxLoops: 50})
+	if !errors.Is(err, ErrScriptTooManyLoops) {
+		t.Errorf("got %v, want %v", err, ErrScriptTooManyLoops)
+	}
+	_, err = s.Run(context.Background(), nil, ScriptLimit{MaxSteps: 50})
+	if err != ErrScriptTooManySteps {
+		t.Errorf("got %v, want %v", err, ErrScriptTooManySteps)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = s.Run(ctx, nil, DefaultScriptLimit)
+	if err != ErrScriptTimeout {
+		t.Errorf("got %v, want %v", err, ErrScriptTimeout)
+	}
+}
+
+func TestParseScriptError(t *testing.T) {
+	for _, src := range []string{
+		"a = ",
+		"a == 1",
+		"a = 1 +",
+		"if a > 1 { b = 1 }",
+		"if (a > 1) { b = 1",
+		"while (a > 1) b = 1",
+		"a = 'x",
+		"a = (1 + 2",
+		"else { a = 1 }",
+		"}",
+	} {
+		if _, err := ParseScript(src); err == nil {
+			t.Errorf("ParseScript(%q) want error", src)
+		}
+	}
+}
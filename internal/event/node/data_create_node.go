@@ -15,16 +15,20 @@ package node
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/quanxiang-cloud/flow/internal/convert"
+	"github.com/quanxiang-cloud/flow/internal/models"
 	"github.com/quanxiang-cloud/flow/pkg"
 	"github.com/quanxiang-cloud/flow/pkg/client"
 	"github.com/quanxiang-cloud/flow/pkg/config"
+	"github.com/quanxiang-cloud/flow/pkg/misc/id2"
 	"github.com/quanxiang-cloud/flow/pkg/misc/logger"
 	"github.com/quanxiang-cloud/flow/pkg/redis"
 	"github.com/quanxiang-cloud/flow/pkg/utils"
 	"github.com/quanxiang-cloud/flow/rpc/pb"
+	"strings"
 	"time"
 )
 
@@ -40,128 +44,8 @@ func NewDataCreate(conf *config.Configs, node *Node) *DataCreate {
 	}
 }
 
-// Init event
+// InitBegin event
 func (n *DataCreate) InitBegin(ctx context.Context, eventData *EventData) (*pb.NodeEventRespData, error) {
-	//flow, err := n.FlowRepo.FindByProcessID(n.Db, eventData.ProcessID)
-	//if err != nil {
-	//	return nil, err
-	//}
-	//
-	//bd := eventData.Shape.Data.BusinessData
-	//if bd == nil {
-	//	return nil, nil
-	//}
-	//
-	//var createRules map[string]interface{}
-	//if v := bd["createRule"]; v != nil {
-	//	createRules = v.(map[string]interface{})
-	//}
-	//if createRules == nil {
-	//	return nil, nil
-	//}
-	//var variables = make(map[string]interface{})
-	//var formID = ""
-	//filedValueReq := make(map[string]interface{})
-	//
-	//instance, err := n.InstanceRepo.GetEntityByProcessInstanceID(n.Db, eventData.ProcessInstanceID)
-	//if err != nil {
-	//	return nil, err
-	//}
-	//if !n.CheckRefuse(ctx, n.Db, instance.ProcessInstanceID) {
-	//	return nil, nil
-	//}
-	//
-	//if flow.TriggerMode == "FORM_DATA" {
-	//	formShape, err := convert.GetShapeByChartType(flow.BpmnText, convert.FormData)
-	//	if err != nil {
-	//		return nil, err
-	//	}
-	//	// master form
-	//
-	//	for k, v := range createRules {
-	//		tmp := v.(map[string]interface{})
-	//		valueFrom := utils.Strval(tmp["valueFrom"])
-	//		valueOf := tmp["valueOf"]
-	//
-	//		variables, err := n.Instance.GetInstanceVariableValues(ctx, instance)
-	//		if err != nil {
-	//			return nil, err
-	//		}
-	//
-	//		value, err := n.Instance.Cal(ctx, valueFrom, valueOf, nil, instance, variables, nil, formShape.ID)
-	//		if err != nil {
-	//			return nil, err
-	//		}
-	//		filedValueReq[k] = value
-	//		formID = formShape.ID
-	//	}
-	//}
-	//if flow.TriggerMode == "FORM_TIME" {
-	//	filedValueReq = createRules
-	//}
-	//
-	//// child form
-	//refDataReq := make(map[string]client.RefData)
-	//var refs map[string]interface{}
-	//if v := bd["ref"]; v != nil {
-	//	refs = v.(map[string]interface{})
-	//}
-	//if refs != nil {
-	//	for k, v := range refs {
-	//		tmp := v.(map[string]interface{})
-	//		refData := client.RefData{
-	//			AppID:   flow.AppID,
-	//			TableID: utils.Strval(tmp["tableId"]),
-	//			Type:    utils.Strval(tmp["type"]),
-	//		}
-	//
-	//		var subTableCreateRules []map[string]interface{}
-	//		if t := tmp["createRules"]; t != nil {
-	//			arr := t.([]interface{})
-	//			for _, e := range arr {
-	//				subTableCreateRules = append(subTableCreateRules, e.(map[string]interface{}))
-	//			}
-	//		}
-	//
-	//		new1 := make([]client.CreateEntity, 0)
-	//		if flow.TriggerMode == "FORM_DATA" {
-	//			if subTableCreateRules != nil {
-	//				for _, e := range subTableCreateRules {
-	//					record := client.CreateEntity{}
-	//					recordEntity := make(map[string]interface{})
-	//					for k1, v1 := range e {
-	//						valueFrom := utils.Strval(v1.(map[string]interface{})["valueFrom"])
-	//						valueOf := v1.(map[string]interface{})["valueOf"]
-	//						value, err := n.Instance.Cal(ctx, valueFrom, valueOf, nil, instance, variables, nil, formID)
-	//						if err != nil {
-	//							return nil, err
-	//						}
-	//						recordEntity[k1] = value
-	//					}
-	//					record.Entity = recordEntity
-	//					new1 = append(new1, record)
-	//				}
-	//			}
-	//		}
-	//		if flow.TriggerMode == "FORM_DATA" {
-	//			refData.New = new1
-	//			refDataReq[k] = refData
-	//		}
-	//		if flow.TriggerMode == "FORM_TIME" {
-	//			filedValueReq = getDataFromShape(createRules)
-	//			refDataReq = getDataFromRef(flow.AppID, refs)
-	//		}
-	//
-	//	}
-	//}
-	//ctx = pkg.SetRequestID2(ctx, instance.RequestID)
-	//err = n.FormAPI.CreateData(ctx, flow.AppID, utils.Strval(bd["targetTableId"]), client.CreateEntity{
-	//	Entity: filedValueReq,
-	//	Ref:    refDataReq,
-	//}, bd["silent"].(bool))
-	//if err != nil {
-	//	return nil, err
-	//}
 	return nil, nil
 }
 
@@ -212,27 +96,25 @@ func getDataFromRef(appID string, data map[string]interface{}) map[string]client
 	return res
 }
 
-// Execute event
+const (
+	// valueFromSubTableRow value of the sub table row the record is created for
+	valueFromSubTableRow = "subTableRow"
+)
+
+// IterateSubTable create one record per row of a sub table of the current form
+type IterateSubTable struct {
+	FieldName string `json:"fieldName"`
+	TableID   string `json:"tableId"`
+}
+
+// InitEnd event
 func (n *DataCreate) InitEnd(ctx context.Context, eventData *EventData) (*pb.NodeEventRespData, error) {
-	//if !n.CheckRefuse(ctx, n.Db, eventData.ProcessInstanceID) {
-	//	return nil, nil
-	//}
-	flow, err := n.FlowRepo.FindByProcessID(n.Db, eventData.ProcessID)
+	flow, err := n.FindInstanceFlow(eventData)
 	if err != nil {
 		return nil, err
 	}
 	if flow == nil {
-		flowProcessRelation, err := n.FlowProcessRelationRepo.FindByProcessID(n.Db, eventData.ProcessID)
-		if err != nil {
-			return nil, err
-		}
-		flow, err = n.FlowRepo.FindByID(n.Db, flowProcessRelation.FlowID)
-		if err != nil {
-			return nil, err
-		}
-		if flow == nil {
-			return nil, errors.New("send create form data not match flow")
-		}
+		return nil, errors.New("send create form data not match flow")
 	}
 	preNodeKey := CheckPreNode(flow.BpmnText, eventData.NodeDefKey)
 	if preNodeKey != "" {
@@ -270,107 +152,232 @@ func (n *DataCreate) InitEnd(ctx context.Context, eventData *EventData) (*pb.Nod
 	if createRules == nil {
 		return nil, nil
 	}
-	var variables = make(map[string]interface{})
-	var formID = ""
-	filedValueReq := make(map[string]interface{})
+	var refs map[string]interface{}
+	if v := bd["ref"]; v != nil {
+		refs = v.(map[string]interface{})
+	}
 
 	instance, err := n.InstanceRepo.GetEntityByProcessInstanceID(n.Db, eventData.ProcessInstanceID)
 	if err != nil {
 		return nil, err
 	}
+	if instance == nil {
+		return nil, errors.New("send create form data not match instance")
+	}
 
-	if flow.TriggerMode == "FORM_DATA" {
+	entities := make([]client.CreateEntity, 0)
+	if flow.TriggerMode == convert.FormTime {
+		entities = append(entities, client.CreateEntity{
+			Entity: getDataFromShape(createRules),
+			Ref:    getDataFromRef(flow.AppID, refs),
+		})
+	} else {
 		formShape, err := convert.GetShapeByChartType(flow.BpmnText, convert.FormData)
 		if err != nil {
 			return nil, err
 		}
-		// master form
+		variables, err := n.Instance.GetInstanceVariableValues(ctx, instance)
+		if err != nil {
+			return nil, err
+		}
 
-		for k, v := range createRules {
+		// master form, values not depending on sub table rows are calculated only once
+		base, err := n.calRules(ctx, createRules, instance, variables, formShape.ID)
+		if err != nil {
+			return nil, err
+		}
+		// child form
+		refBases := make(map[string][]map[string]interface{})
+		for k, v := range refs {
 			tmp := v.(map[string]interface{})
-			valueFrom := utils.Strval(tmp["valueFrom"])
-			valueOf := tmp["valueOf"]
-
-			variables, err := n.Instance.GetInstanceVariableValues(ctx, instance)
-			if err != nil {
-				return nil, err
+			for _, e := range toRuleList(tmp["createRules"]) {
+				value, err := n.calRules(ctx, e, instance, variables, formShape.ID)
+				if err != nil {
+					return nil, err
+				}
+				refBases[k] = append(refBases[k], value)
 			}
+		}
 
-			value, err := n.Instance.Cal(ctx, valueFrom, valueOf, nil, instance, variables, nil, formShape.ID)
-			if err != nil {
-				return nil, err
+		rows, err := n.subTableRows(ctx, bd["iterateSubTable"], instance)
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			entity := mergeRow(base, createRules, row)
+			refDataReq := make(map[string]client.RefData)
+			for k, v := range refs {
+				tmp := v.(map[string]interface{})
+				news := make([]client.CreateEntity, 0)
+				for index, e := range toRuleList(tmp["createRules"]) {
+					news = append(news, client.CreateEntity{
+						Entity: mergeRow(refBases[k][index], e, row),
+					})
+				}
+				refDataReq[k] = client.RefData{
+					AppID:   flow.AppID,
+					TableID: utils.Strval(tmp["tableId"]),
+					Type:    utils.Strval(tmp["type"]),
+					New:     news,
+				}
 			}
-			filedValueReq[k] = value
-			formID = formShape.ID
+			entities = append(entities, client.CreateEntity{
+				Entity: entity,
+				Ref:    refDataReq,
+			})
 		}
 	}
-	if flow.TriggerMode == "FORM_TIME" {
-		filedValueReq = createRules
-	}
 
-	// child form
-	refDataReq := make(map[string]client.RefData)
-	var refs map[string]interface{}
-	if v := bd["ref"]; v != nil {
-		refs = v.(map[string]interface{})
+	ctx = pkg.SetRequestID2(ctx, instance.RequestID)
+	ids := make([]string, 0, len(entities))
+	for _, e := range entities {
+		entity := e.Entity.(map[string]interface{})
+		dataID := id2.GenID()
+		entity["_id"] = dataID
+		err = n.FormAPI.CreateData(ctx, flow.AppID, utils.Strval(bd["targetTableId"]), e, bd["silent"] == true)
+		if err != nil {
+			n.rollbackCreated(ctx, eventData, flow.AppID, utils.Strval(bd["targetTableId"]), utils.Strval(bd["resultVariable"]), ids)
+			return nil, err
+		}
+		ids = append(ids, dataID)
 	}
-	if refs != nil {
-		for k, v := range refs {
-			tmp := v.(map[string]interface{})
-			refData := client.RefData{
-				AppID:   flow.AppID,
-				TableID: utils.Strval(tmp["tableId"]),
-				Type:    utils.Strval(tmp["type"]),
-			}
 
-			var subTableCreateRules []map[string]interface{}
-			if t := tmp["createRules"]; t != nil {
-				arr := t.([]interface{})
-				for _, e := range arr {
-					subTableCreateRules = append(subTableCreateRules, e.(map[string]interface{}))
-				}
-			}
+	// 保存新建数据的ID，供后续节点更新或关联
+	if err := n.saveCreatedIDs(ctx, eventData, utils.Strval(bd["resultVariable"]), ids); err != nil {
+		return nil, err
+	}
+	redis.ClusterClient.SetEX(ctx, "flow:node:"+eventData.ProcessInstanceID+":"+eventData.NodeDefKey, "over", 20*time.Second)
+	return nil, nil
+}
 
-			new1 := make([]client.CreateEntity, 0)
-			if flow.TriggerMode == "FORM_DATA" {
-				if subTableCreateRules != nil {
-					for _, e := range subTableCreateRules {
-						record := client.CreateEntity{}
-						recordEntity := make(map[string]interface{})
-						for k1, v1 := range e {
-							valueFrom := utils.Strval(v1.(map[string]interface{})["valueFrom"])
-							valueOf := v1.(map[string]interface{})["valueOf"]
-							value, err := n.Instance.Cal(ctx, valueFrom, valueOf, nil, instance, variables, nil, formID)
-							if err != nil {
-								return nil, err
-							}
-							recordEntity[k1] = value
-						}
-						record.Entity = recordEntity
-						new1 = append(new1, record)
-					}
-				}
-			}
-			if flow.TriggerMode == "FORM_DATA" {
-				refData.New = new1
-				refDataReq[k] = refData
-			}
-			if flow.TriggerMode == "FORM_TIME" {
-				filedValueReq = getDataFromShape(createRules)
-				refDataReq = getDataFromRef(flow.AppID, refs)
-			}
+// calRules calculate the rules through instance.Cal, rules of sub table row are left to mergeRow
+func (n *DataCreate) calRules(ctx context.Context, rules map[string]interface{}, instance *models.Instance, variables map[string]interface{}, formDefKey string) (map[string]interface{}, error) {
+	entity := make(map[string]interface{})
+	for k, v := range rules {
+		tmp, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		valueFrom := utils.Strval(tmp["valueFrom"])
+		if valueFrom == valueFromSubTableRow {
+			continue
+		}
+		value, err := n.Instance.Cal(ctx, valueFrom, tmp["valueOf"], nil, instance, variables, nil, formDefKey)
+		if err != nil {
+			return nil, err
+		}
+		entity[k] = value
+	}
+	return entity, nil
+}
 
+// subTableRows rows of the sub table to iterate, a single nil row when the node creates one record
+func (n *DataCreate) subTableRows(ctx context.Context, iterate interface{}, instance *models.Instance) ([]map[string]interface{}, error) {
+	conf := IterateSubTable{}
+	if iterate != nil {
+		marshal, err := json.Marshal(iterate)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(marshal, &conf); err != nil {
+			return nil, err
 		}
 	}
-	ctx = pkg.SetRequestID2(ctx, instance.RequestID)
-	err = n.FormAPI.CreateData(ctx, flow.AppID, utils.Strval(bd["targetTableId"]), client.CreateEntity{
-		Entity: filedValueReq,
-		Ref:    refDataReq,
-	}, bd["silent"].(bool))
+	if conf.FieldName == "" {
+		return []map[string]interface{}{nil}, nil
+	}
+
+	data, err := n.FormAPI.GetFormData(ctx, client.FormDataConditionModel{
+		AppID:   instance.AppID,
+		TableID: instance.FormID,
+		DataID:  instance.FormInstanceID,
+		Ref: map[string]map[string]string{
+			conf.FieldName: {
+				"appID":   instance.AppID,
+				"tableID": conf.TableID,
+				"type":    "sub_table",
+			},
+		},
+	})
 	if err != nil {
 		return nil, err
 	}
-	redis.ClusterClient.SetEX(ctx, "flow:node:"+eventData.ProcessInstanceID+":"+eventData.NodeDefKey, "over", 20*time.Second)
-	return nil, nil
 
+	rows := make([]map[string]interface{}, 0)
+	if arr, ok := data[conf.FieldName].([]interface{}); ok {
+		for _, e := range arr {
+			if row, ok := e.(map[string]interface{}); ok {
+				rows = append(rows, row)
+			}
+		}
+	}
+	return rows, nil
+}
+
+// rollbackCreated delete the rows created before the failure, so that the node creates all rows or none,
+// the IDs are saved if they can not be deleted
+func (n *DataCreate) rollbackCreated(ctx context.Context, eventData *EventData, appID string, tableID string, resultVariable string, ids []string) {
+	if len(ids) == 0 {
+		return
+	}
+	err := n.FormAPI.DeleteData(ctx, appID, tableID, ids)
+	if err == nil {
+		return
+	}
+	logger.Logger.Errorw("rollback created data failed", "processInstanceID", eventData.ProcessInstanceID, "ids", ids, "err", err.Error())
+	if err := n.saveCreatedIDs(ctx, eventData, resultVariable, ids); err != nil {
+		logger.Logger.Error(err)
+	}
+}
+
+func (n *DataCreate) saveCreatedIDs(ctx context.Context, eventData *EventData, resultVariable string, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	values := map[string]interface{}{
+		"$" + eventData.Shape.ID + ".id":  ids[0],
+		"$" + eventData.Shape.ID + ".ids": ids,
+	}
+	if resultVariable != "" {
+		values[strings.TrimPrefix(resultVariable, "$variable.")] = strings.Join(ids, ",")
+	}
+	for code, value := range values {
+		if err := n.Flow.SaveInstanceVariable(ctx, eventData.ProcessInstanceID, code, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mergeRow copy of base with the values taken from the sub table row
+func mergeRow(base map[string]interface{}, rules map[string]interface{}, row map[string]interface{}) map[string]interface{} {
+	entity := make(map[string]interface{}, len(base))
+	for k, v := range base {
+		entity[k] = v
+	}
+	if row == nil {
+		return entity
+	}
+	for k, v := range rules {
+		tmp, ok := v.(map[string]interface{})
+		if !ok || utils.Strval(tmp["valueFrom"]) != valueFromSubTableRow {
+			continue
+		}
+		entity[k] = utils.GetFieldValue(row, utils.Strval(tmp["valueOf"]))
+	}
+	return entity
+}
+
+func toRuleList(data interface{}) []map[string]interface{} {
+	rules := make([]map[string]interface{}, 0)
+	arr, ok := data.([]interface{})
+	if !ok {
+		return rules
+	}
+	for _, e := range arr {
+		if rule, ok := e.(map[string]interface{}); ok {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
 }
@@ -0,0 +1,230 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/quanxiang-cloud/flow/internal/convert"
+	flow2 "github.com/quanxiang-cloud/flow/internal/flow"
+	"github.com/quanxiang-cloud/flow/internal/models"
+	"github.com/quanxiang-cloud/flow/pkg"
+	"github.com/quanxiang-cloud/flow/pkg/config"
+	"github.com/quanxiang-cloud/flow/pkg/misc/id2"
+	"github.com/quanxiang-cloud/flow/pkg/misc/time2"
+	"github.com/quanxiang-cloud/flow/pkg/redis"
+	"github.com/quanxiang-cloud/flow/pkg/utils"
+	"github.com/quanxiang-cloud/flow/rpc/pb"
+)
+
+const (
+	// dataDeleteMaxRows default max rows deleted by one node
+	dataDeleteMaxRows = 100
+	// dataDeleteRowsLimit maxRows of node can not exceed
+	dataDeleteRowsLimit = 1000
+)
+
+// DataDelete struct
+type DataDelete struct {
+	*Node
+}
+
+// NewDataDelete new
+func NewDataDelete(conf *config.Configs, node *Node) *DataDelete {
+	return &DataDelete{
+		Node: node,
+	}
+}
+
+// InitBegin event
+func (n *DataDelete) InitBegin(ctx context.Context, eventData *EventData) (*pb.NodeEventRespData, error) {
+	return nil, nil
+}
+
+// InitEnd event
+func (n *DataDelete) InitEnd(ctx context.Context, eventData *EventData) (*pb.NodeEventRespData, error) {
+	flow, err := n.FindInstanceFlow(eventData)
+	if err != nil {
+		return nil, err
+	}
+	if flow == nil {
+		return nil, errors.New("send delete form data not match flow")
+	}
+	WaitPreNode(ctx, flow.BpmnText, eventData)
+	defer redis.ClusterClient.SetEX(ctx, "flow:node:"+eventData.ProcessInstanceID+":"+eventData.NodeDefKey, "over", 20*time.Second)
+
+	bd := eventData.Shape.Data.BusinessData
+	if bd == nil {
+		return nil, nil
+	}
+	targetTableID := utils.Strval(bd["targetTableId"])
+	if targetTableID == "" {
+		return nil, nil
+	}
+
+	instance, err := n.InstanceRepo.GetEntityByProcessInstanceID(n.Db, eventData.ProcessInstanceID)
+	if err != nil {
+		return nil, err
+	}
+	if instance == nil {
+		return nil, errors.New("send delete form data not match instance")
+	}
+	if instance.Status == flow2.Refuse {
+		return nil, nil
+	}
+
+	variables, err := n.Instance.GetInstanceVariableValues(ctx, instance)
+	if err != nil {
+		return nil, err
+	}
+	instanceVariables, err := n.InstanceVariablesRepo.FindVariablesByProcessInstanceID(n.Db, eventData.ProcessInstanceID)
+	if err != nil {
+		return nil, err
+	}
+	for k := range instanceVariables {
+		variables[instanceVariables[k].Code] = instanceVariables[k].Value
+	}
+
+	var deleteIDs []string
+	if idsVariable := utils.Strval(bd["idsVariable"]); idsVariable != "" {
+		deleteIDs = toIDs(variables[strings.TrimPrefix(idsVariable, "$variable.")])
+	} else {
+		filterRule := utils.ChangeObjectToMap(bd["filterRule"])
+		if filterRule == nil {
+			return nil, nil
+		}
+		formShape, err := convert.GetShapeByChartType(flow.BpmnText, convert.FormData)
+		if err != nil {
+			return nil, err
+		}
+		queryMap, err := n.filterQuery(ctx, filterRule, instance, variables, bd["formQueryRef"], formShape.ID)
+		if err != nil {
+			return nil, err
+		}
+		if queryMap == nil {
+			return nil, nil
+		}
+		deleteIDs, err = n.FormAPI.GetIDs(ctx, instance.AppID, targetTableID, queryMap)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// 流程当前关联的数据不允许删除
+	ids := make([]string, 0, len(deleteIDs))
+	for _, id := range deleteIDs {
+		if id == "" || (targetTableID == instance.FormID && id == instance.FormInstanceID) {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	maxRows := dataDeleteMaxRows
+	if v, ok := bd["maxRows"].(float64); ok && v > 0 {
+		maxRows = int(v)
+	}
+	if maxRows > dataDeleteRowsLimit {
+		maxRows = dataDeleteRowsLimit
+	}
+	if len(ids) > maxRows {
+		// 超过删除上限，不删除任何数据，节点挂起并记录异常任务，由管理员继续节点或作废流程
+		return n.ParkNode(instance, eventData, "删除数据超过上限", fmt.Sprintf("matched %d rows, max %d", len(ids), maxRows))
+	}
+
+	ctx = pkg.SetRequestID2(ctx, instance.RequestID)
+	if err := n.FormAPI.DeleteData(ctx, instance.AppID, targetTableID, ids); err != nil {
+		return nil, err
+	}
+	if err := n.addDeleteRecord(eventData, targetTableID, ids); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+// addDeleteRecord history step of the node, the record lists the deleted ids
+func (n *DataDelete) addDeleteRecord(eventData *EventData, tableID string, ids []string) error {
+	correlationData, err := json.Marshal(map[string]interface{}{
+		"tableID": tableID,
+		"ids":     ids,
+	})
+	if err != nil {
+		return err
+	}
+
+	step := &models.InstanceStep{
+		ProcessInstanceID: eventData.ProcessInstanceID,
+		TaskType:          convert.TableDataDelete,
+		TaskDefKey:        eventData.NodeDefKey,
+		TaskName:          eventData.Shape.Data.NodeData.Name,
+		Status:            flow2.OpDataDelete,
+		BaseModel: models.BaseModel{
+			ID:         id2.GenID(),
+			CreateTime: time2.Now(),
+			ModifyTime: time2.Now(),
+		},
+	}
+	if err := n.InstanceStepRepo.Create(n.Db, step); err != nil {
+		return err
+	}
+	return n.OperationRecordRepo.Create(n.Db, &models.OperationRecord{
+		ProcessInstanceID: eventData.ProcessInstanceID,
+		InstanceStepID:    step.ID,
+		HandleType:        flow2.OpDataDelete,
+		HandleDesc:        fmt.Sprintf("删除%d条数据", len(ids)),
+		Status:            flow2.Completed,
+		TaskName:          step.TaskName,
+		TaskDefKey:        eventData.NodeDefKey,
+		CorrelationData:   string(correlationData),
+		BaseModel: models.BaseModel{
+			ID:         id2.GenID(),
+			CreateTime: time2.Now(),
+			ModifyTime: time2.Now(),
+		},
+	})
+}
+
+// toIDs ids held in a variable: array, json array string or comma separated string
+func toIDs(value interface{}) []string {
+	ids := make([]string, 0)
+	switch v := value.(type) {
+	case nil:
+	case []string:
+		ids = append(ids, v...)
+	case []interface{}:
+		for _, e := range v {
+			ids = append(ids, utils.Strval(e))
+		}
+	default:
+		str := strings.TrimSpace(utils.Strval(v))
+		if strings.HasPrefix(str, "[") {
+			if err := json.Unmarshal([]byte(str), &ids); err == nil {
+				return ids
+			}
+		}
+		for _, e := range strings.Split(str, ",") {
+			if e = strings.TrimSpace(e); e != "" {
+				ids = append(ids, e)
+			}
+		}
+	}
+	return ids
+}
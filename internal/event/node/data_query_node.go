@@ -0,0 +1,223 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package node
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/quanxiang-cloud/flow/internal/convert"
+	"github.com/quanxiang-cloud/flow/pkg"
+	"github.com/quanxiang-cloud/flow/pkg/client"
+	"github.com/quanxiang-cloud/flow/pkg/config"
+	"github.com/quanxiang-cloud/flow/pkg/misc/logger"
+	"github.com/quanxiang-cloud/flow/pkg/redis"
+	"github.com/quanxiang-cloud/flow/pkg/utils"
+	"github.com/quanxiang-cloud/flow/rpc/pb"
+)
+
+// query result type
+const (
+	QueryFirst = "first"
+	QueryCount = "count"
+	QuerySum   = "sum"
+	QueryMax   = "max"
+	QueryMin   = "min"
+
+	// dataQueryRowsLimit max rows loaded for sum, max and min
+	dataQueryRowsLimit = 1000
+)
+
+// QueryOutput write the query result into a flow variable
+type QueryOutput struct {
+	VariableName string `json:"variableName"`
+	Type         string `json:"type"`      // first, count, sum, max, min
+	FieldName    string `json:"fieldName"` // field of first, sum, max and min, first without field is the data id
+}
+
+// DataQuery struct
+type DataQuery struct {
+	*Node
+}
+
+// NewDataQuery new
+func NewDataQuery(conf *config.Configs, node *Node) *DataQuery {
+	return &DataQuery{
+		Node: node,
+	}
+}
+
+// InitBegin event
+func (n *DataQuery) InitBegin(ctx context.Context, eventData *EventData) (*pb.NodeEventRespData, error) {
+	return nil, nil
+}
+
+// InitEnd event
+func (n *DataQuery) InitEnd(ctx context.Context, eventData *EventData) (*pb.NodeEventRespData, error) {
+	flow, err := n.FindInstanceFlow(eventData)
+	if err != nil {
+		return nil, err
+	}
+	if flow == nil {
+		return nil, errors.New("send query form data not match flow")
+	}
+	WaitPreNode(ctx, flow.BpmnText, eventData)
+	defer redis.ClusterClient.SetEX(ctx, "flow:node:"+eventData.ProcessInstanceID+":"+eventData.NodeDefKey, "over", 20*time.Second)
+
+	bd := eventData.Shape.Data.BusinessData
+	if bd == nil {
+		return nil, nil
+	}
+	targetTableID := utils.Strval(bd["targetTableId"])
+	outputs := toQueryOutputs(bd["outputMapping"])
+	if targetTableID == "" || len(outputs) == 0 {
+		return nil, nil
+	}
+
+	instance, err := n.InstanceRepo.GetEntityByProcessInstanceID(n.Db, eventData.ProcessInstanceID)
+	if err != nil {
+		return nil, err
+	}
+	if instance == nil {
+		return nil, errors.New("send query form data not match instance")
+	}
+
+	variables, err := n.Instance.GetInstanceVariableValues(ctx, instance)
+	if err != nil {
+		return nil, err
+	}
+	instanceVariables, err := n.InstanceVariablesRepo.FindVariablesByProcessInstanceID(n.Db, eventData.ProcessInstanceID)
+	if err != nil {
+		return nil, err
+	}
+	for k := range instanceVariables {
+		variables[instanceVariables[k].Code] = instanceVariables[k].Value
+	}
+
+	req := client.SearchReq{
+		Page: 1,
+		Size: 1,
+	}
+	for _, output := range outputs {
+		if output.Type == QuerySum || output.Type == QueryMax || output.Type == QueryMin {
+			req.Size = dataQueryRowsLimit
+		}
+	}
+	if sort, ok := bd["sort"].([]interface{}); ok {
+		for _, e := range sort {
+			req.Sort = append(req.Sort, utils.Strval(e))
+		}
+	}
+	if filterRule := utils.ChangeObjectToMap(bd["filterRule"]); filterRule != nil {
+		formShape, err := convert.GetShapeByChartType(flow.BpmnText, convert.FormData)
+		if err != nil {
+			return nil, err
+		}
+		req.Query, err = n.filterQuery(ctx, filterRule, instance, variables, bd["formQueryRef"], formShape.ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	ctx = pkg.SetRequestID2(ctx, instance.RequestID)
+	resp, err := n.FormAPI.Search(ctx, instance.AppID, targetTableID, req)
+	if err != nil {
+		return nil, err
+	}
+	if req.Size == dataQueryRowsLimit && resp.Total > len(resp.Entities) {
+		logger.Logger.Warnw("query node aggregates part of the matched data", "processInstanceID", eventData.ProcessInstanceID,
+			"nodeDefKey", eventData.NodeDefKey, "total", resp.Total, "loaded", len(resp.Entities))
+	}
+
+	for _, output := range outputs {
+		value := queryResult(output, resp.Entities, resp.Total)
+		if err := n.Flow.SaveInstanceVariable(ctx, eventData.ProcessInstanceID, output.VariableName, value); err != nil {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+func toQueryOutputs(data interface{}) []QueryOutput {
+	outputs := make([]QueryOutput, 0)
+	for _, e := range toRuleList(data) {
+		output := QueryOutput{
+			VariableName: strings.TrimPrefix(utils.Strval(e["variableName"]), "$variable."),
+			Type:         utils.Strval(e["type"]),
+			FieldName:    utils.Strval(e["fieldName"]),
+		}
+		if output.VariableName == "" {
+			continue
+		}
+		outputs = append(outputs, output)
+	}
+	return outputs
+}
+
+// queryResult value of the output, sum of no data is 0, first, max and min of no data are nil
+func queryResult(output QueryOutput, rows []map[string]interface{}, total int) interface{} {
+	switch output.Type {
+	case QueryCount:
+		return total
+	case QueryFirst:
+		if len(rows) == 0 {
+			return nil
+		}
+		if output.FieldName == "" {
+			return rows[0]["_id"]
+		}
+		return utils.GetFieldValue(rows[0], output.FieldName)
+	case QuerySum, QueryMax, QueryMin:
+		var result float64
+		var found bool
+		for _, row := range rows {
+			value, ok := toFloat(utils.GetFieldValue(row, output.FieldName))
+			if !ok {
+				continue
+			}
+			switch {
+			case !found:
+				result = value
+			case output.Type == QuerySum:
+				result += value
+			case output.Type == QueryMax && value > result, output.Type == QueryMin && value < result:
+				result = value
+			}
+			found = true
+		}
+		if !found && output.Type != QuerySum {
+			return nil
+		}
+		return result
+	}
+	return nil
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
@@ -0,0 +1,47 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package node
+
+import (
+	"testing"
+)
+
+func Test_queryResult(t *testing.T) {
+	rows := []map[string]interface{}{
+		{"_id": "1", "amount": float64(10), "name": "a"},
+		{"_id": "2", "amount": "2.5", "name": "b"},
+		{"_id": "3", "amount": nil, "name": "c"},
+		{"_id": "4", "amount": float64(-4), "name": "d"},
+	}
+	tests := []struct {
+		output QueryOutput
+		rows   []map[string]interface{}
+		want   interface{}
+	}{
+		{QueryOutput{Type: QueryCount}, rows, 12},
+		{QueryOutput{Type: QueryFirst}, rows, "1"},
+		{QueryOutput{Type: QueryFirst, FieldName: "name"}, rows, "a"},
+		{QueryOutput{Type: QueryFirst, FieldName: "name"}, nil, nil},
+		{QueryOutput{Type: QuerySum, FieldName: "amount"}, rows, 8.5},
+		{QueryOutput{Type: QueryMax, FieldName: "amount"}, rows, float64(10)},
+		{QueryOutput{Type: QueryMin, FieldName: "amount"}, rows, float64(-4)},
+		{QueryOutput{Type: QuerySum, FieldName: "amount"}, nil, float64(0)},
+		{QueryOutput{Type: QueryMax, FieldName: "amount"}, nil, nil},
+	}
+	for _, tt := range tests {
+		if got := queryResult(tt.output, tt.rows, 12); got != tt.want {
+			t.Errorf("queryResult(%v) = %v, want %v", tt.output, got, tt.want)
+		}
+	}
+}
@@ -393,22 +393,12 @@ func (n *DataUpdate) InitBegin(ctx context.Context, eventData *EventData) (*pb.N
 // InitEnd event
 func (n *DataUpdate) InitEnd(ctx context.Context, eventData *EventData) (*pb.NodeEventRespData, error) {
 	logger.Logger.Info("---------init update form end")
-	flow, err := n.FlowRepo.FindByProcessID(n.Db, eventData.ProcessID)
+	flow, err := n.FindInstanceFlow(eventData)
 	if err != nil {
 		return nil, err
 	}
 	if flow == nil {
-		flowProcessRelation, err := n.FlowProcessRelationRepo.FindByProcessID(n.Db, eventData.ProcessID)
-		if err != nil {
-			return nil, err
-		}
-		flow, err = n.FlowRepo.FindByID(n.Db, flowProcessRelation.FlowID)
-		if err != nil {
-			return nil, err
-		}
-		if flow == nil {
-			return nil, errors.New("send update form data not match flow")
-		}
+		return nil, errors.New("send update form data not match flow")
 	}
 	preNodeKey := CheckPreNode(flow.BpmnText, eventData.NodeDefKey)
 	if preNodeKey != "" {
@@ -482,83 +472,14 @@ func (n *DataUpdate) InitEnd(ctx context.Context, eventData *EventData) (*pb.Nod
 			return nil, nil
 		}
 
-		var conditions []map[string]interface{}
-		if v := filterRule["conditions"]; v != nil {
-			arr := v.([]interface{})
-			for _, e := range arr {
-				conditions = append(conditions, e.(map[string]interface{}))
-			}
+		queryMap, err := n.filterQuery(ctx, filterRule, instance, variables, formQueryRef, formDefKey)
+		if err != nil {
+			return nil, err
 		}
-		if conditions == nil {
+		if queryMap == nil {
 			return nil, nil
 		}
 
-		// reqConditions := make([]map[string]interface{}, 0)
-		boolMap := make(map[string]interface{})
-		queryMap := map[string]interface{}{
-			"bool": boolMap,
-		}
-		terms := make([]map[string]interface{}, 0)
-		if utils.Strval(filterRule["tag"]) == "or" {
-			boolMap["should"] = &terms
-		} else {
-			boolMap["must"] = &terms
-		}
-
-		for _, v := range conditions {
-
-			valueOf := v["value"]
-			value, err := n.Instance.Cal(ctx, "currentFormValue", valueOf, nil, instance, variables, formQueryRef, formDefKey)
-			if err != nil {
-				return nil, err
-			}
-
-			// 等于eq, 不等于neq，包含in，不包含nin
-			if utils.Strval(v["operator"]) == "eq" {
-				term := map[string]interface{}{
-					"term": map[string]interface{}{
-						utils.Strval(v["fieldName"]): value,
-					},
-				}
-				terms = append(terms, term)
-			} else if utils.Strval(v["operator"]) == "neq" {
-				mustNot := make([]map[string]interface{}, 0)
-				mustNot = append(mustNot, map[string]interface{}{
-					"term": map[string]interface{}{
-						utils.Strval(v["fieldName"]): value,
-					},
-				})
-				term := map[string]interface{}{
-					"bool": map[string]interface{}{
-						"mustNot": mustNot,
-					},
-				}
-				terms = append(terms, term)
-			} else if utils.Strval(v["operator"]) == "in" {
-				// todo 如果是数组格式的需要修改in判断
-				term := map[string]interface{}{
-					"term": map[string]interface{}{
-						utils.Strval(v["fieldName"]): value,
-					},
-				}
-				terms = append(terms, term)
-			} else if utils.Strval(v["operator"]) == "nin" {
-				// todo 如果是数组格式的需要修改in判断
-				mustNot := make([]map[string]interface{}, 0)
-				mustNot = append(mustNot, map[string]interface{}{
-					"term": map[string]interface{}{
-						utils.Strval(v["fieldName"]): value,
-					},
-				})
-				term := map[string]interface{}{
-					"bool": map[string]interface{}{
-						"mustNot": mustNot,
-					},
-				}
-				terms = append(terms, term)
-			}
-		}
-
 		updateIDs, err = n.FormAPI.GetIDs(ctx, instance.AppID, targetTableID, queryMap)
 		if err != nil {
 			return nil, err
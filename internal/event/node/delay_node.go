@@ -46,6 +46,8 @@ type DelayPolicyData struct {
 type DelayPolicy struct {
 	Type string     `json:"type"`
 	Data PolicyData `json:"data"`
+	// 计时方式：natural自然时间，working工作时间，仅对aTime生效
+	TimeType string `json:"timeType"`
 }
 
 // PolicyData PolicyData
@@ -103,8 +105,12 @@ func (d *Delay) InitEnd(ctx context.Context, eventData *EventData) (*pb.NodeEven
 	switch dp.DelayPolicy.Type {
 	// execTime = nowTime + delayTime
 	case convert.DelayTypeOfaTime:
-		execTimeTs := d.ExecTimeOfaTime(dp)
-		execTimeString = time2.UnixToISO8601(*execTimeTs)
+		if dp.DelayPolicy.TimeType == convert.TimeTypeWorking {
+			execTimeString = d.WorkCalendar.AddTime(ctx, instance.AppID, time2.Now(), convert.TimeTypeWorking, workingDelay(dp))
+		} else {
+			execTimeTs := d.ExecTimeOfaTime(dp)
+			execTimeString = time2.UnixToISO8601(*execTimeTs)
+		}
 	// execTime = ColumnTime + delayTime
 	case convert.DelayTypeOfTableColumn:
 		dataReq := client.FormDataConditionModel{
@@ -151,6 +157,8 @@ func (d *Delay) InitEnd(ctx context.Context, eventData *EventData) (*pb.NodeEven
 			Type:              dp.DelayPolicy.Type,
 			OtherInfo:         *otherInfo,
 			ProcessInstanceID: eventData.ProcessInstanceID,
+			TaskDefKey:        eventData.NodeDefKey,
+			ExecTime:          execTimeString,
 		}
 		ID := id2.GenID()
 		dbData.ID = *d.genNewCode(&ID)
@@ -250,6 +258,15 @@ func (d *Delay) ExecTimeOfaTime(dp *DelayPolicyData) *int64 {
 	return &execTime
 }
 
+// workingDelay 延时的秒数按工作时间计算，整天的部分为工作日，其余为上班时长
+func workingDelay(dp *DelayPolicyData) convert.DayHourMinuteModel {
+	t := dp.DelayPolicy.Data.TimeFmt.(int64)
+	return convert.DayHourMinuteModel{
+		Day:     int(t / 86400),
+		Minutes: int(t % 86400 / 60),
+	}
+}
+
 // ExecTimeOfTableColumn 按日期字段延时执行时间
 func (d *Delay) ExecTimeOfTableColumn(dp *DelayPolicyData, ColumnTime *string) *int64 {
 	t := dp.DelayPolicy.Data.TimeFmt.(int64)
@@ -0,0 +1,244 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"html"
+	"sort"
+	"strings"
+
+	"github.com/quanxiang-cloud/flow/internal/models"
+	"github.com/quanxiang-cloud/flow/pkg/client"
+	"github.com/quanxiang-cloud/flow/pkg/misc/logger"
+	"github.com/quanxiang-cloud/flow/pkg/utils"
+)
+
+// formSnapshotHTML 表单快照以html表格嵌入邮件正文
+const formSnapshotHTML = "html"
+
+// emailExtendBD 邮件节点的抄送、密送、附件及表单快照配置
+type emailExtendBD struct {
+	CcPersons          interface{} `json:"ccPersons"`          // 抄送人，规则同approvePersons
+	BccPersons         interface{} `json:"bccPersons"`         // 密送人，规则同approvePersons
+	AttachmentFields   []string    `json:"attachmentFields"`   // 作为附件发送的表单附件字段
+	CommentAttachments bool        `json:"commentAttachments"` // 是否附带评论中的附件
+	FormSnapshot       string      `json:"formSnapshot"`       // 表单快照：html
+	FieldPermission    interface{} `json:"fieldPermission"`    // 快照中可见的字段，为空时不发送快照
+}
+
+// extend 按节点配置补充抄送、密送、附件和表单快照
+func (n *Email) extend(ctx context.Context, bd map[string]interface{}, instance *models.Instance, email *client.Email) {
+	if instance == nil {
+		return
+	}
+	var extendBD emailExtendBD
+	b, err := json.Marshal(bd)
+	if err == nil {
+		err = json.Unmarshal(b, &extendBD)
+	}
+	if err != nil {
+		logger.Logger.Error("email extend business data err,", err)
+		return
+	}
+
+	if extendBD.CcPersons != nil {
+		email.Cc = n.emailAddrs(ctx, extendBD.CcPersons, instance)
+	}
+	if extendBD.BccPersons != nil {
+		email.Bcc = n.emailAddrs(ctx, extendBD.BccPersons, instance)
+	}
+
+	if len(extendBD.AttachmentFields) > 0 || extendBD.FormSnapshot != "" {
+		formData, err := n.FormAPI.GetFormData(ctx, client.FormDataConditionModel{
+			AppID:   instance.AppID,
+			TableID: instance.FormID,
+			DataID:  instance.FormInstanceID,
+		})
+		if err != nil {
+			logger.Logger.Error("get form data err,", err)
+		}
+		if formData != nil {
+			email.Files = append(email.Files, fileAttachments(formData, extendBD.AttachmentFields)...)
+			if extendBD.FormSnapshot == formSnapshotHTML {
+				email.Contents.Content += n.formSnapshot(ctx, instance, extendBD.FieldPermission, formData)
+			} else if extendBD.FormSnapshot != "" {
+				logger.Logger.Warnf("form snapshot [%s] is not supported", extendBD.FormSnapshot)
+			}
+		}
+	}
+
+	if extendBD.CommentAttachments {
+		email.Files = append(email.Files, n.commentAttachments(instance)...)
+	}
+}
+
+// emailAddrs 按人员规则取有效的邮箱地址
+func (n *Email) emailAddrs(ctx context.Context, persons interface{}, instance *models.Instance) []string {
+	addrs := make([]string, 0)
+	for _, user := range n.Flow.GetTaskHandleUsers2(ctx, persons, instance) {
+		if user == nil || !utils.EmailAddressValid(&user.Email) {
+			continue
+		}
+		addrs = append(addrs, user.Email)
+	}
+	return utils.RemoveReplicaSliceString(addrs)
+}
+
+// commentAttachments 实例所有评论中的附件
+func (n *Email) commentAttachments(instance *models.Instance) []map[string]interface{} {
+	files := make([]map[string]interface{}, 0)
+	comments, err := n.CommentRepo.FindComments(n.Db, map[string]interface{}{
+		"flow_instance_id": instance.ProcessInstanceID,
+	}, "create_time")
+	if err != nil {
+		logger.Logger.Error("find comments err,", err)
+		return files
+	}
+	for _, comment := range comments {
+		attachments, err := n.CommentAttachmentRepo.FindAttachments(n.Db, map[string]interface{}{
+			"flow_comment_id": comment.ID,
+		}, "create_time")
+		if err != nil {
+			logger.Logger.Error("find comment attachments err,", err)
+			continue
+		}
+		for _, attachment := range attachments {
+			files = append(files, map[string]interface{}{
+				"name": attachment.AttachmentName,
+				"path": attachment.AttachmentURL,
+			})
+		}
+	}
+	return files
+}
+
+// formSnapshot 按字段权限过滤后的表单快照，未配置字段权限时不生成
+func (n *Email) formSnapshot(ctx context.Context, instance *models.Instance, fieldPermission interface{}, formData map[string]interface{}) string {
+	if fieldPermission == nil {
+		return ""
+	}
+	formData = n.Task.FilterCanReadFormData(ctx, instance, fieldPermission, formData)
+	if formData == nil {
+		return ""
+	}
+	schema, err := n.FormAPI.GetFormSchema(ctx, instance.AppID, instance.FormID)
+	if err != nil || schema == nil {
+		logger.Logger.Error("get form schema err,", err)
+		return ""
+	}
+	return formSnapshotTable(utils.ChangeObjectToMap(schema), formData)
+}
+
+// fileAttachments 表单附件字段的文件，字段值为[{"label":文件名,"value":路径}]
+func fileAttachments(formData map[string]interface{}, fields []string) []map[string]interface{} {
+	files := make([]map[string]interface{}, 0)
+	for _, field := range fields {
+		if formData[field] == nil {
+			continue
+		}
+		for _, file := range utils.ChangeObjectToMapList(formData[field]) {
+			path := utils.Strval(file["value"])
+			if path == "" {
+				continue
+			}
+			files = append(files, map[string]interface{}{
+				"name": utils.Strval(file["label"]),
+				"path": path,
+			})
+		}
+	}
+	return files
+}
+
+// snapshotField 表单快照中的一个字段
+type snapshotField struct {
+	title string
+	index float64
+	value string
+}
+
+// formSnapshotTable 按schema顺序生成字段名和值的html表格，不在formData中的字段不展示
+func formSnapshotTable(schema map[string]interface{}, formData map[string]interface{}) string {
+	fields := make([]snapshotField, 0)
+	collectSnapshotFields(schema, formData, &fields)
+	if len(fields) == 0 {
+		return ""
+	}
+	sort.SliceStable(fields, func(i, j int) bool {
+		return fields[i].index < fields[j].index
+	})
+
+	var sb strings.Builder
+	sb.WriteString(`<table border="1" cellspacing="0" cellpadding="4">`)
+	for _, field := range fields {
+		sb.WriteString("<tr><td>")
+		sb.WriteString(html.EscapeString(field.title))
+		sb.WriteString("</td><td>")
+		sb.WriteString(html.EscapeString(field.value))
+		sb.WriteString("</td></tr>")
+	}
+	sb.WriteString("</table>")
+	return sb.String()
+}
+
+func collectSnapshotFields(schema map[string]interface{}, formData map[string]interface{}, fields *[]snapshotField) {
+	if schema == nil || schema["properties"] == nil {
+		return
+	}
+	for key, value := range utils.ChangeObjectToMap(schema["properties"]) {
+		fieldMap := utils.ChangeObjectToMap(value)
+		if fieldMap == nil {
+			continue
+		}
+		if fieldMap["properties"] != nil { // 布局组件
+			collectSnapshotFields(fieldMap, formData, fields)
+			continue
+		}
+		v, ok := formData[key]
+		if !ok || v == nil {
+			continue
+		}
+		title := utils.Strval(fieldMap["title"])
+		if title == "" {
+			title = key
+		}
+		index, _ := fieldMap["x-index"].(float64)
+		*fields = append(*fields, snapshotField{
+			title: title,
+			index: index,
+			value: snapshotValue(v),
+		})
+	}
+}
+
+// snapshotValue 选项、人员、附件等取label，其余原样输出
+func snapshotValue(v interface{}) string {
+	switch value := v.(type) {
+	case []interface{}:
+		labels := make([]string, 0, len(value))
+		for _, e := range value {
+			labels = append(labels, snapshotValue(e))
+		}
+		return strings.Join(labels, "、")
+	case map[string]interface{}:
+		if label, ok := value["label"]; ok {
+			return utils.Strval(label)
+		}
+		return utils.Strval(value["value"])
+	default:
+		return utils.Strval(v)
+	}
+}
@@ -0,0 +1,136 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package node
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/quanxiang-cloud/flow/internal/models"
+	"gorm.io/gorm"
+)
+
+type fakeCommentRepo struct {
+	models.CommentRepo
+	comments []*models.Comment
+}
+
+func (r *fakeCommentRepo) FindComments(db *gorm.DB, condition map[string]interface{}, order string) ([]*models.Comment, error) {
+	comments := make([]*models.Comment, 0)
+	for _, comment := range r.comments {
+		if comment.FlowInstanceID == condition["flow_instance_id"] {
+			comments = append(comments, comment)
+		}
+	}
+	return comments, nil
+}
+
+type fakeCommentAttachmentRepo struct {
+	models.CommentAttachmentRepo
+	attachments []*models.CommentAttachment
+}
+
+func (r *fakeCommentAttachmentRepo) FindAttachments(db *gorm.DB, condition map[string]interface{}, order string) ([]*models.CommentAttachment, error) {
+	attachments := make([]*models.CommentAttachment, 0)
+	for _, attachment := range r.attachments {
+		if attachment.FlowCommentID == condition["flow_comment_id"] {
+			attachments = append(attachments, attachment)
+		}
+	}
+	return attachments, nil
+}
+
+func TestEmail_commentAttachments(t *testing.T) {
+	// 评论按流程引擎的实例id关联
+	n := &Email{Node: &Node{
+		CommentRepo: &fakeCommentRepo{comments: []*models.Comment{
+			{BaseModel: models.BaseModel{ID: "c1"}, FlowInstanceID: "proc-1"},
+			{BaseModel: models.BaseModel{ID: "c2"}, FlowInstanceID: "proc-2"},
+		}},
+		CommentAttachmentRepo: &fakeCommentAttachmentRepo{attachments: []*models.CommentAttachment{
+			{FlowCommentID: "c1", AttachmentName: "合同.pdf", AttachmentURL: "app/contract.pdf"},
+			{FlowCommentID: "c2", AttachmentName: "other.pdf", AttachmentURL: "app/other.pdf"},
+		}},
+	}}
+	instance := &models.Instance{
+		BaseModel:         models.BaseModel{ID: "inst-1"},
+		ProcessInstanceID: "proc-1",
+	}
+	got := n.commentAttachments(instance)
+	want := []map[string]interface{}{
+		{"name": "合同.pdf", "path": "app/contract.pdf"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("commentAttachments() = %v, want %v", got, want)
+	}
+}
+
+func TestEmail_formSnapshotWithoutPermission(t *testing.T) {
+	n := &Email{Node: &Node{}}
+	if got := n.formSnapshot(context.TODO(), &models.Instance{}, nil, map[string]interface{}{"amount": "100"}); got != "" {
+		t.Errorf("formSnapshot() = %v, want empty", got)
+	}
+}
+
+func Test_fileAttachments(t *testing.T) {
+	formData := map[string]interface{}{
+		"quote": []interface{}{
+			map[string]interface{}{"label": "报价单.pdf", "value": "app/quote.pdf"},
+			map[string]interface{}{"label": "empty.pdf", "value": ""},
+		},
+		"remark": "备注",
+	}
+	got := fileAttachments(formData, []string{"quote", "missing"})
+	want := []map[string]interface{}{
+		{"name": "报价单.pdf", "path": "app/quote.pdf"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("fileAttachments() = %v, want %v", got, want)
+	}
+}
+
+func Test_formSnapshotTable(t *testing.T) {
+	schema := map[string]interface{}{
+		"properties": map[string]interface{}{
+			"amount": map[string]interface{}{"title": "金额", "x-index": float64(2)},
+			"layout": map[string]interface{}{
+				"properties": map[string]interface{}{
+					"vendor": map[string]interface{}{"title": "供应商<A>", "x-index": float64(1)},
+				},
+			},
+			"hidden": map[string]interface{}{"title": "隐藏", "x-index": float64(0)},
+			"type":   map[string]interface{}{"title": "类型", "x-index": float64(3)},
+		},
+	}
+	formData := map[string]interface{}{
+		"amount": "100",
+		"vendor": "ACME",
+		"type": []interface{}{
+			map[string]interface{}{"label": "硬件", "value": "hw"},
+			map[string]interface{}{"label": "软件", "value": "sw"},
+		},
+	}
+	want := `<table border="1" cellspacing="0" cellpadding="4">` +
+		"<tr><td>供应商&lt;A&gt;</td><td>ACME</td></tr>" +
+		"<tr><td>金额</td><td>100</td></tr>" +
+		"<tr><td>类型</td><td>硬件、软件</td></tr>" +
+		"</table>"
+	if got := formSnapshotTable(schema, formData); got != want {
+		t.Errorf("formSnapshotTable() = %v, want %v", got, want)
+	}
+	if got := formSnapshotTable(schema, map[string]interface{}{}); got != "" {
+		t.Errorf("formSnapshotTable() = %v, want empty", got)
+	}
+}
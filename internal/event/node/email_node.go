@@ -125,22 +125,12 @@ func (n *Email) InitEnd(ctx context.Context, eventData *EventData) (*pb.NodeEven
 	//if !n.CheckRefuse(ctx, n.Db, eventData.ProcessInstanceID) {
 	//	return nil, nil
 	//}
-	flow, err := n.FlowRepo.FindByProcessID(n.Db, eventData.ProcessID)
+	flow, err := n.FindInstanceFlow(eventData)
 	if err != nil {
 		return nil, err
 	}
 	if flow == nil {
-		flowProcessRelation, err := n.FlowProcessRelationRepo.FindByProcessID(n.Db, eventData.ProcessID)
-		if err != nil {
-			return nil, err
-		}
-		flow, err = n.FlowRepo.FindByID(n.Db, flowProcessRelation.FlowID)
-		if err != nil {
-			return nil, err
-		}
-		if flow == nil {
-			return nil, errors.New("send update form data not match flow")
-		}
+		return nil, errors.New("send update form data not match flow")
 	}
 	preNodeKey := CheckPreNode(flow.BpmnText, eventData.NodeDefKey)
 	if preNodeKey != "" {
@@ -315,6 +305,9 @@ func (n *Email) InitEnd(ctx context.Context, eventData *EventData) (*pb.NodeEven
 		title = bdData.Title
 		tempID = bdData.TemplateID
 	}
+	if rendered := n.RenderMessageTemplate(ctx, bd, instance); rendered != nil {
+		title, content, tempID = rendered.Title, rendered.Content, ""
+	}
 
 	if len(emailAddr) > 0 {
 		email := client.Email{
@@ -326,6 +319,7 @@ func (n *Email) InitEnd(ctx context.Context, eventData *EventData) (*pb.NodeEven
 			Title: utils.Strval(title),
 			Files: mesAttachments,
 		}
+		n.extend(ctx, bd, instance, &email)
 		msgReq := client.MsgReq{
 			Email: email,
 		}
@@ -92,22 +92,12 @@ func (n *Letter) InitEnd(ctx context.Context, eventData *EventData) (*pb.NodeEve
 	//	return nil, nil
 	//}
 
-	flow, err := n.FlowRepo.FindByProcessID(n.Db, eventData.ProcessID)
+	flow, err := n.FindInstanceFlow(eventData)
 	if err != nil {
 		return nil, err
 	}
 	if flow == nil {
-		flowProcessRelation, err := n.FlowProcessRelationRepo.FindByProcessID(n.Db, eventData.ProcessID)
-		if err != nil {
-			return nil, err
-		}
-		flow, err = n.FlowRepo.FindByID(n.Db, flowProcessRelation.FlowID)
-		if err != nil {
-			return nil, err
-		}
-		if flow == nil {
-			return nil, errors.New("send update form data not match flow")
-		}
+		return nil, errors.New("send update form data not match flow")
 	}
 	preNodeKey := CheckPreNode(flow.BpmnText, eventData.NodeDefKey)
 	if preNodeKey != "" {
@@ -221,9 +211,14 @@ func (n *Letter) InitEnd(ctx context.Context, eventData *EventData) (*pb.NodeEve
 		content = strings.Replace(content, "${"+k+"}", utils.Strval(v), 1)
 	}
 
+	title := utils.Strval(bd["title"])
+	if rendered := n.RenderMessageTemplate(ctx, bd, instance); rendered != nil {
+		title, content = rendered.Title, rendered.Content
+	}
+
 	web := client.Web{
 		IsSend: true,
-		Title:  utils.Strval(bd["title"]),
+		Title:  title,
 		Contents: client.Contents{
 			Content: content,
 		},
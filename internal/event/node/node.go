@@ -16,13 +16,20 @@ package node
 import (
 	"context"
 	"encoding/json"
+	redis2 "github.com/go-redis/redis/v8"
+	"github.com/quanxiang-cloud/flow/internal/convert"
 	"github.com/quanxiang-cloud/flow/internal/flow"
 	"github.com/quanxiang-cloud/flow/internal/flow/callback_tasks"
 	"github.com/quanxiang-cloud/flow/internal/models"
 	"github.com/quanxiang-cloud/flow/pkg/client"
 	"github.com/quanxiang-cloud/flow/pkg/misc/logger"
+	"github.com/quanxiang-cloud/flow/pkg/misc/time2"
+	"github.com/quanxiang-cloud/flow/pkg/redis"
+	"github.com/quanxiang-cloud/flow/pkg/utils"
+	"github.com/quanxiang-cloud/flow/rpc/pb"
 	"gorm.io/gorm"
 	"strings"
+	"time"
 )
 
 // Node struct
@@ -41,6 +48,10 @@ type Node struct {
 	Instance                flow.Instance
 	OperationRecord         flow.OperationRecord
 	Task                    flow.Task
+	SubProcess              flow.SubProcess
+	Webhook                 flow.Webhook
+	WorkCalendar            flow.WorkCalendar
+	MessageTemplate         flow.MessageTemplate
 	FormAPI                 client.Form
 	MessageCenterAPI        client.MessageCenter
 	StructorAPI             client.Structor
@@ -48,6 +59,13 @@ type Node struct {
 	IdentityAPI             client.Identity
 	Dispatcher              client.Dispatcher
 	FlowProcessRelationRepo models.FlowProcessRelationRepo
+	FlowVersionRepo         models.FlowVersionRepo
+	WebhookAttemptRepo      models.WebhookAttemptRepo
+	AppSecretRepo           models.AppSecretRepo
+	OperationRecordRepo     models.OperationRecordRepo
+	ApproveSequenceRepo     models.ApproveSequenceRepo
+	CommentRepo             models.CommentRepo
+	CommentAttachmentRepo   models.CommentAttachmentRepo
 }
 
 // SetDB set db
@@ -55,6 +73,102 @@ func (n *Node) SetDB(db *gorm.DB) {
 	n.Db = db
 }
 
+// FindFlowByProcessID find flow by process id, the definition is taken from the published version of that process
+func (n *Node) FindFlowByProcessID(processID string) (*models.Flow, error) {
+	version, err := n.FlowVersionRepo.FindByProcessID(n.Db, processID)
+	if err != nil {
+		return nil, err
+	}
+	if version != nil {
+		flow, err := n.FlowRepo.FindByID(n.Db, version.FlowID)
+		if err != nil || flow == nil {
+			return flow, err
+		}
+		flow.BpmnText = version.BpmnText
+		flow.ProcessID = version.ProcessID
+		return flow, nil
+	}
+
+	flow, err := n.FlowRepo.FindByProcessID(n.Db, processID)
+	if err != nil {
+		return nil, err
+	}
+	if flow != nil {
+		return flow, nil
+	}
+	flowProcessRelation, err := n.FlowProcessRelationRepo.FindByProcessID(n.Db, processID)
+	if err != nil || flowProcessRelation == nil {
+		return nil, err
+	}
+	return n.FlowRepo.FindByID(n.Db, flowProcessRelation.FlowID)
+}
+
+// FindInstanceFlow flow of the node event with the definition of the version the instance is pinned to,
+// the process id is kept as the deployment the process instance runs on
+func (n *Node) FindInstanceFlow(eventData *EventData) (*models.Flow, error) {
+	flow, err := n.FindInstanceFlow(eventData)
+	if err != nil || flow == nil {
+		return flow, err
+	}
+	instance, err := n.InstanceRepo.GetEntityByProcessInstanceID(n.Db, eventData.ProcessInstanceID)
+	if err != nil || instance == nil || instance.FlowVersionID == "" {
+		return flow, err
+	}
+	version, err := n.FlowVersionRepo.FindByID(n.Db, instance.FlowVersionID)
+	if err != nil || version == nil {
+		return flow, err
+	}
+	flow.BpmnText = version.BpmnText
+	return flow, nil
+}
+
+// WaitPreNode wait until the previous automatic node marks itself over in redis, at most 13 seconds,
+// after a gateway join it just waits 6 seconds
+func WaitPreNode(ctx context.Context, bpmnText string, eventData *EventData) {
+	preNodeKey := CheckPreNode(bpmnText, eventData.NodeDefKey)
+	if preNodeKey == "" {
+		return
+	}
+	if preNodeKey == "processBranchTarget" {
+		time.Sleep(6 * time.Second)
+		return
+	}
+	for i := 0; i < 13; i++ {
+		get, err := redis.ClusterClient.Get(ctx, "flow:node:"+eventData.ProcessInstanceID+":"+preNodeKey).Result()
+		if err != nil && err != redis2.Nil {
+			logger.Logger.Error(err)
+		}
+		if get == "over" {
+			return
+		}
+		logger.Logger.Info("等待上个节点执行完成---", preNodeKey)
+		time.Sleep(1 * time.Second)
+	}
+}
+
+// ParkNode keep the automatic node paused and record an abnormal task,
+// the admin continues the node or abandons the instance later
+func (n *Node) ParkNode(instance *models.Instance, eventData *EventData, reason string, remark string) (*pb.NodeEventRespData, error) {
+	abnormalTask := &models.AbnormalTask{
+		FlowInstanceID:    instance.ID,
+		ProcessInstanceID: eventData.ProcessInstanceID,
+		TaskName:          eventData.Shape.Data.NodeData.Name,
+		TaskDefKey:        eventData.NodeDefKey,
+		ExecutionID:       eventData.ExecutionID,
+		Reason:            reason,
+		Remark:            remark,
+		Status:            0,
+		BaseModel: models.BaseModel{
+			CreatorID:  "",
+			ModifyTime: time2.Now(),
+		},
+	}
+	if err := n.AbnormalTaskRepo.Create(n.Db, abnormalTask); err != nil {
+		return nil, err
+	}
+	return &pb.NodeEventRespData{ExecuteType: convert.PauseExecution}, nil
+}
+
 func (n *Node) CheckRefuse(ctx context.Context, db *gorm.DB, processInstanceID string) bool {
 	instanceSteps, err := n.InstanceStepRepo.FindInstanceStepsByStatus(n.Db, processInstanceID, []string{"REFUSE"})
 	if err != nil {
@@ -78,6 +192,20 @@ func (n *Node) CheckRefuse(ctx context.Context, db *gorm.DB, processInstanceID s
 	return true
 }
 
+// RenderMessageTemplate 节点配置了消息模板时按模板生成标题和内容，未配置或渲染失败返回nil
+func (n *Node) RenderMessageTemplate(ctx context.Context, bd map[string]interface{}, instance *models.Instance) *flow.RenderedMessage {
+	templateID := utils.Strval(bd["messageTemplateID"])
+	if templateID == "" || instance == nil {
+		return nil
+	}
+	rendered, err := n.MessageTemplate.Render(ctx, templateID, instance, nil)
+	if err != nil {
+		logger.Logger.Error("render message template err,", err)
+		return nil
+	}
+	return rendered
+}
+
 // NodeDataModel info
 type NodeDataModel struct {
 	Name                  string   `json:"name"`
@@ -145,3 +273,84 @@ func CheckPreNode(bpt string, nowNodeKey string) (preNodeKey string) {
 	}
 	return res
 }
+
+// filterQuery build the search query of filterRule {tag, conditions: [{fieldName, operator, value}]}, nil without conditions
+func (n *Node) filterQuery(ctx context.Context, filterRule map[string]interface{}, instance *models.Instance, variables map[string]interface{}, formQueryRef interface{}, formDefKey string) (map[string]interface{}, error) {
+	var conditions []map[string]interface{}
+	if v := filterRule["conditions"]; v != nil {
+		arr := v.([]interface{})
+		for _, e := range arr {
+			conditions = append(conditions, e.(map[string]interface{}))
+		}
+	}
+	if conditions == nil {
+		return nil, nil
+	}
+
+	// reqConditions := make([]map[string]interface{}, 0)
+	boolMap := make(map[string]interface{})
+	queryMap := map[string]interface{}{
+		"bool": boolMap,
+	}
+	terms := make([]map[string]interface{}, 0)
+	if utils.Strval(filterRule["tag"]) == "or" {
+		boolMap["should"] = &terms
+	} else {
+		boolMap["must"] = &terms
+	}
+
+	for _, v := range conditions {
+
+		valueOf := v["value"]
+		value, err := n.Instance.Cal(ctx, "currentFormValue", valueOf, nil, instance, variables, formQueryRef, formDefKey)
+		if err != nil {
+			return nil, err
+		}
+
+		// 等于eq, 不等于neq，包含in，不包含nin
+		if utils.Strval(v["operator"]) == "eq" {
+			term := map[string]interface{}{
+				"term": map[string]interface{}{
+					utils.Strval(v["fieldName"]): value,
+				},
+			}
+			terms = append(terms, term)
+		} else if utils.Strval(v["operator"]) == "neq" {
+			mustNot := make([]map[string]interface{}, 0)
+			mustNot = append(mustNot, map[string]interface{}{
+				"term": map[string]interface{}{
+					utils.Strval(v["fieldName"]): value,
+				},
+			})
+			term := map[string]interface{}{
+				"bool": map[string]interface{}{
+					"mustNot": mustNot,
+				},
+			}
+			terms = append(terms, term)
+		} else if utils.Strval(v["operator"]) == "in" {
+			// todo 如果是数组格式的需要修改in判断
+			term := map[string]interface{}{
+				"term": map[string]interface{}{
+					utils.Strval(v["fieldName"]): value,
+				},
+			}
+			terms = append(terms, term)
+		} else if utils.Strval(v["operator"]) == "nin" {
+			// todo 如果是数组格式的需要修改in判断
+			mustNot := make([]map[string]interface{}, 0)
+			mustNot = append(mustNot, map[string]interface{}{
+				"term": map[string]interface{}{
+					utils.Strval(v["fieldName"]): value,
+				},
+			})
+			term := map[string]interface{}{
+				"bool": map[string]interface{}{
+					"mustNot": mustNot,
+				},
+			}
+			terms = append(terms, term)
+		}
+	}
+	return queryMap, nil
+}
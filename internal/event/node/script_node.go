@@ -0,0 +1,124 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package node
+
+import (
+	"context"
+	"errors"
+
+	"github.com/quanxiang-cloud/flow/internal/convert"
+	"github.com/quanxiang-cloud/flow/internal/eval"
+	"github.com/quanxiang-cloud/flow/pkg/client"
+	"github.com/quanxiang-cloud/flow/pkg/config"
+	"github.com/quanxiang-cloud/flow/pkg/utils"
+	"github.com/quanxiang-cloud/flow/rpc/pb"
+)
+
+// Script struct
+type Script struct {
+	*Node
+}
+
+// NewScript 脚本节点
+func NewScript(conf *config.Configs, node *Node) *Script {
+	return &Script{
+		Node: node,
+	}
+}
+
+// InitBegin run the script over form data and instance variables, assigned variables are written back
+func (n *Script) InitBegin(ctx context.Context, eventData *EventData) (*pb.NodeEventRespData, error) {
+	bd := eventData.Shape.Data.BusinessData
+	src := utils.Strval(bd["script"])
+	if src == "" {
+		return nil, nil
+	}
+	flow, err := n.FindInstanceFlow(eventData)
+	if err != nil {
+		return nil, err
+	}
+	if flow == nil {
+		return nil, errors.New("script node not match flow")
+	}
+	instance, err := n.InstanceRepo.GetEntityByProcessInstanceID(n.Db, eventData.ProcessInstanceID)
+	if err != nil {
+		return nil, err
+	}
+	if instance == nil {
+		return nil, errors.New("script node not match instance")
+	}
+
+	formDefKey := ""
+	formShape, err := convert.GetShapeByChartType(flow.BpmnText, convert.FormData)
+	if err != nil {
+		return nil, err
+	}
+	if formShape != nil {
+		formDefKey = formShape.ID
+	}
+	script, err := eval.ParseScript(convert.NormalizeExpression(src, formDefKey))
+	if err != nil {
+		return nil, err
+	}
+
+	params := make(map[string]interface{})
+	if instance.FormInstanceID != "" {
+		formData, err := n.FormAPI.GetFormData(ctx, client.FormDataConditionModel{
+			AppID:   instance.AppID,
+			TableID: instance.FormID,
+			DataID:  instance.FormInstanceID,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range formData {
+			params[k] = v
+		}
+	}
+	// variables shadow form fields of the same name
+	variables, err := n.Flow.GetInstanceVariableValues(ctx, instance)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range variables {
+		params[k] = v
+	}
+
+	results, err := script.Run(ctx, n.Flow.FormatFormValue2(bd["formulaFields"], params), eval.DefaultScriptLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	instanceVariables, err := n.InstanceVariablesRepo.FindVariablesByProcessInstanceID(n.Db, eventData.ProcessInstanceID)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range instanceVariables {
+		value, ok := results[v.Code]
+		if !ok {
+			continue
+		}
+		fieldValue, fieldType := utils.StrvalAndType(value)
+		err = n.InstanceVariablesRepo.UpdateTypeAndValue(n.Db, eventData.ProcessInstanceID, v.Code, fieldType, fieldValue)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+// InitEnd event
+func (n *Script) InitEnd(ctx context.Context, eventData *EventData) (*pb.NodeEventRespData, error) {
+	return nil, nil
+}
@@ -0,0 +1,175 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package node
+
+import (
+	"context"
+	"errors"
+
+	"github.com/quanxiang-cloud/flow/internal/convert"
+	"github.com/quanxiang-cloud/flow/internal/flow"
+	"github.com/quanxiang-cloud/flow/internal/models"
+	"github.com/quanxiang-cloud/flow/pkg/client"
+	"github.com/quanxiang-cloud/flow/pkg/config"
+	"github.com/quanxiang-cloud/flow/pkg/misc/id2"
+	"github.com/quanxiang-cloud/flow/pkg/utils"
+	"github.com/quanxiang-cloud/flow/rpc/pb"
+)
+
+// SubProcess struct
+type SubProcess struct {
+	*Node
+}
+
+// NewSubProcess 子流程节点
+func NewSubProcess(conf *config.Configs, node *Node) *SubProcess {
+	return &SubProcess{
+		Node: node,
+	}
+}
+
+// InitBegin event
+func (n *SubProcess) InitBegin(ctx context.Context, eventData *EventData) (*pb.NodeEventRespData, error) {
+	return nil, nil
+}
+
+// InitEnd start the child instance, the node is paused until the child ends
+func (n *SubProcess) InitEnd(ctx context.Context, eventData *EventData) (*pb.NodeEventRespData, error) {
+	bd := eventData.Shape.Data.BusinessData
+	if bd == nil {
+		return nil, errors.New("sub process node has no business data")
+	}
+	subFlowID := utils.Strval(bd["flowID"])
+	if subFlowID == "" {
+		return nil, errors.New("sub process node has no flow")
+	}
+	parentFlow, err := n.FindInstanceFlow(eventData)
+	if err != nil {
+		return nil, err
+	}
+	if parentFlow == nil {
+		return nil, errors.New("sub process node not match flow")
+	}
+	subFlow, err := n.FlowRepo.FindByID(n.Db, subFlowID)
+	if err != nil {
+		return nil, err
+	}
+	if subFlow == nil || subFlow.Status != models.ENABLE {
+		return nil, errors.New("sub process flow is not enabled")
+	}
+	instance, err := n.InstanceRepo.GetEntityByProcessInstanceID(n.Db, eventData.ProcessInstanceID)
+	if err != nil {
+		return nil, err
+	}
+	if instance == nil {
+		return nil, errors.New("sub process node not match instance")
+	}
+	if err = n.SubProcess.CheckStart(ctx, instance, subFlow.ID); err != nil {
+		return nil, err
+	}
+
+	formDefKey := ""
+	formShape, err := convert.GetShapeByChartType(parentFlow.BpmnText, convert.FormData)
+	if err != nil {
+		return nil, err
+	}
+	if formShape != nil {
+		formDefKey = formShape.ID
+	}
+	variables, err := n.Flow.GetInstanceVariableValues(ctx, instance)
+	if err != nil {
+		return nil, err
+	}
+	formValues, err := n.mapping(ctx, bd["formMapping"], "fieldName", instance, variables, formDefKey)
+	if err != nil {
+		return nil, err
+	}
+	subVariables, err := n.mapping(ctx, bd["variableMapping"], "variableName", instance, variables, formDefKey)
+	if err != nil {
+		return nil, err
+	}
+
+	rel := &models.SubProcess{
+		ProcessID:         eventData.ProcessID,
+		ProcessInstanceID: eventData.ProcessInstanceID,
+		NodeDefKey:        eventData.NodeDefKey,
+		ExecutionID:       eventData.ExecutionID,
+	}
+	req := &flow.StartFlowModel{
+		UserID:    instance.ApplyUserID,
+		FlowID:    subFlow.ID,
+		FormData:  map[string]interface{}{},
+		Variables: subVariables,
+		Parent:    rel,
+	}
+	if subFlow.TriggerMode != convert.FormTime {
+		if subFlow.FormID == instance.FormID && len(formValues) == 0 {
+			// the child works on the same form data as the parent
+			req.FormData["_id"] = instance.FormInstanceID
+		} else {
+			dataID := id2.GenID()
+			formValues["_id"] = dataID
+			err = n.FormAPI.CreateData(ctx, subFlow.AppID, subFlow.FormID, client.CreateEntity{
+				Entity: formValues,
+			}, false)
+			if err != nil {
+				return nil, err
+			}
+			req.FormData["_id"] = dataID
+		}
+	}
+
+	childID, err := n.Instance.StartFlow(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	child, err := n.InstanceRepo.FindByID(n.Db, childID)
+	if err != nil {
+		return nil, err
+	}
+	if child == nil {
+		return nil, errors.New("sub process instance not started")
+	}
+	if !flow.IsFlowOngoing(child.Status) {
+		// the child ended while starting, no need to wait
+		return nil, n.SubProcess.MapOutputs(ctx, rel, child, child.Status)
+	}
+	return &pb.NodeEventRespData{ExecuteType: convert.PauseExecution}, nil
+}
+
+// mapping calculate the values of mapping rules against the parent instance
+func (n *SubProcess) mapping(ctx context.Context, rules interface{}, key string, instance *models.Instance,
+	variables map[string]interface{}, formDefKey string) (map[string]interface{}, error) {
+	values := make(map[string]interface{})
+	arr, ok := rules.([]interface{})
+	if !ok {
+		return values, nil
+	}
+	for _, e := range arr {
+		rule, ok := e.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name := utils.Strval(rule[key])
+		if name == "" {
+			continue
+		}
+		value, err := n.Instance.Cal(ctx, utils.Strval(rule["valueFrom"]), rule["valueOf"], nil, instance, variables, nil, formDefKey)
+		if err != nil {
+			return nil, err
+		}
+		values[name] = value
+	}
+	return values, nil
+}
@@ -18,10 +18,12 @@ import (
 	"errors"
 	"fmt"
 	"github.com/quanxiang-cloud/flow/internal/convert"
+	"github.com/quanxiang-cloud/flow/internal/models"
 	"github.com/quanxiang-cloud/flow/pkg/client"
 	"github.com/quanxiang-cloud/flow/pkg/config"
 	"github.com/quanxiang-cloud/flow/pkg/utils"
 	"github.com/quanxiang-cloud/flow/rpc/pb"
+	"strings"
 )
 
 // UserTask struct
@@ -47,8 +49,18 @@ func (n *UserTask) InitBegin(ctx context.Context, eventData *EventData) (*pb.Nod
 
 	// assigneeList dynamic handle users
 	_, assigneeList := n.Flow.GetTaskHandleUserIDs(ctx, eventData.Shape, flowInstanceEntity)
+	// 处理人不在岗时任务分配给受托人
+	assigneeList = n.Flow.DelegateUserIDs(ctx, flowInstanceEntity.AppID, assigneeList)
 
 	fmt.Println("事件：加载AssigneeList，AssigneeList=" + utils.ChangeStringArrayToString(assigneeList))
+	if basicConfig := convert.GetTaskBasicConfigModel(eventData.Shape); basicConfig != nil &&
+		basicConfig.MultiplePersonWay == convert.Sequential && len(assigneeList) > 0 {
+		// 依次处理：记录处理顺序，只把任务分配给第一位处理人
+		if err := n.saveSequence(eventData.ProcessInstanceID, eventData.NodeDefKey, assigneeList); err != nil {
+			return nil, err
+		}
+		assigneeList = assigneeList[:1]
+	}
 	if len(assigneeList) > 0 {
 		return nil, n.ProcessAPI.SetProcessVariables(ctx, eventData.ProcessID, eventData.ProcessInstanceID, eventData.NodeDefKey, convert.AssigneeList, assigneeList)
 	}
@@ -58,22 +70,12 @@ func (n *UserTask) InitBegin(ctx context.Context, eventData *EventData) (*pb.Nod
 
 // InitEnd event
 func (n *UserTask) InitEnd(ctx context.Context, eventData *EventData) (*pb.NodeEventRespData, error) {
-	flow, err := n.FlowRepo.FindByProcessID(n.Db, eventData.ProcessID)
+	flow, err := n.FindInstanceFlow(eventData)
 	if err != nil {
 		return nil, err
 	}
 	if flow == nil {
-		flowProcessRelation, err := n.FlowProcessRelationRepo.FindByProcessID(n.Db, eventData.ProcessID)
-		if err != nil {
-			return nil, err
-		}
-		flow, err = n.FlowRepo.FindByID(n.Db, flowProcessRelation.FlowID)
-		if err != nil {
-			return nil, err
-		}
-		if flow == nil {
-			return nil, errors.New("user node not match flow")
-		}
+		return nil, errors.New("user node not match flow")
 	}
 	instance, err := n.InstanceRepo.GetEntityByProcessInstanceID(n.Db, eventData.ProcessInstanceID)
 	if err != nil {
@@ -97,3 +99,23 @@ func (n *UserTask) InitEnd(ctx context.Context, eventData *EventData) (*pb.NodeE
 	}
 	return nil, nil
 }
+
+// saveSequence assignees of the sequential node, each entry of the node starts from the first one
+func (n *UserTask) saveSequence(processInstanceID string, nodeDefKey string, assigneeList []string) error {
+	seq, err := n.ApproveSequenceRepo.FindByNodeDefKey(n.Db, processInstanceID, nodeDefKey)
+	if err != nil {
+		return err
+	}
+	assignees := strings.Join(assigneeList, ",")
+	if seq != nil {
+		return n.ApproveSequenceRepo.Update(n.Db, seq.ID, map[string]interface{}{
+			"assignees": assignees,
+			"current":   0,
+		})
+	}
+	return n.ApproveSequenceRepo.Create(n.Db, &models.ApproveSequence{
+		ProcessInstanceID: processInstanceID,
+		NodeDefKey:        nodeDefKey,
+		Assignees:         assignees,
+	})
+}
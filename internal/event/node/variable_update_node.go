@@ -36,22 +36,12 @@ func NewVariableUpdate(conf *config.Configs, node *Node) *VariableUpdate {
 
 // InitBegin event
 func (n *VariableUpdate) InitBegin(ctx context.Context, eventData *EventData) (*pb.NodeEventRespData, error) {
-	flow, err := n.FlowRepo.FindByProcessID(n.Db, eventData.ProcessID)
+	flow, err := n.FindInstanceFlow(eventData)
 	if err != nil {
 		return nil, err
 	}
 	if flow == nil {
-		flowProcessRelation, err := n.FlowProcessRelationRepo.FindByProcessID(n.Db, eventData.ProcessID)
-		if err != nil {
-			return nil, err
-		}
-		flow, err = n.FlowRepo.FindByID(n.Db, flowProcessRelation.FlowID)
-		if err != nil {
-			return nil, err
-		}
-		if flow == nil {
-			return nil, errors.New("variable update node not match flow")
-		}
+		return nil, errors.New("variable update node not match flow")
 	}
 	formShape, err := convert.GetShapeByChartType(flow.BpmnText, convert.FormData)
 	if err != nil {
@@ -19,6 +19,7 @@ import (
 	"errors"
 	"fmt"
 	"github.com/quanxiang-cloud/flow/internal/convert"
+	flow2 "github.com/quanxiang-cloud/flow/internal/flow"
 	"github.com/quanxiang-cloud/flow/internal/models"
 	"github.com/quanxiang-cloud/flow/pkg/client"
 	"github.com/quanxiang-cloud/flow/pkg/config"
@@ -28,8 +29,10 @@ import (
 	"github.com/quanxiang-cloud/flow/rpc/pb"
 	"net/url"
 	"reflect"
+	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 )
 
 // WebHook struct
@@ -48,435 +51,17 @@ func NewWebHook(conf *config.Configs, node *Node) *WebHook {
 
 // InitBegin event
 func (n *WebHook) InitBegin(ctx context.Context, eventData *EventData) (*pb.NodeEventRespData, error) {
-	fmt.Println("================执行b")
-	//if !n.CheckRefuse(ctx, n.Db, eventData.ProcessID) {
-	//	return nil, nil
-	//}
-	//flow, err := n.FlowRepo.FindByProcessID(n.Db, eventData.ProcessID)
-	//if err != nil {
-	//	return nil, err
-	//}
-	//// do req
-	//queryFlag := false
-	//requestBody := make(map[string]interface{})
-	//requestHeader := make(map[string]string) // req header
-	//path := ""
-	//bd := eventData.Shape.Data.BusinessData
-	//if bd == nil {
-	//	return nil, nil
-	//}
-	//hookType := utils.Strval(bd["type"])
-	//var conf map[string]interface{}
-	//if v := bd["config"]; v != nil {
-	//	conf = v.(map[string]interface{})
-	//}
-	//var inputs []convert.Input
-	//if v := conf["inputs"]; v != nil {
-	//	arr := v.([]interface{})
-	//	for _, e := range arr {
-	//		marshal, err := json.Marshal(e)
-	//		if err != nil {
-	//			return nil, err
-	//		}
-	//
-	//		input := convert.Input{}
-	//		err = json.Unmarshal(marshal, &input)
-	//		if err != nil {
-	//			return nil, err
-	//		}
-	//		inputs = append(inputs, input)
-	//	}
-	//}
-	//v := url.Values{}
-	//if flow.TriggerMode == "FORM_DATA" {
-	//	formShape, err := convert.GetShapeByChartType(flow.BpmnText, convert.FormData)
-	//	if err != nil {
-	//		return nil, err
-	//	}
-	//	formDefKey := formShape.ID
-	//
-	//	instance, err := n.InstanceRepo.GetEntityByProcessInstanceID(n.Db, eventData.ProcessInstanceID)
-	//	if err != nil {
-	//		return nil, err
-	//	}
-	//
-	//	variables, err := n.Instance.GetInstanceVariableValues(ctx, instance)
-	//	if err != nil {
-	//		return nil, err
-	//	}
-	//
-	//	dataReq := client.FormDataConditionModel{
-	//		AppID:   instance.AppID,
-	//		TableID: instance.FormID,
-	//		DataID:  instance.FormInstanceID,
-	//	}
-	//	dataResp, err := n.FormAPI.GetFormData(ctx, dataReq)
-	//	if err != nil {
-	//		return nil, err
-	//	}
-	//	if dataResp == nil {
-	//		return nil, nil
-	//	}
-	//
-	//	for k, v := range dataResp {
-	//		variables[k] = v
-	//	}
-	//
-	//	// gen req
-	//
-	//	if hookType == "request" {
-	//		var api map[string]interface{}
-	//		if v := conf["api"]; v != nil {
-	//			api = v.(map[string]interface{})
-	//		}
-	//		path = utils.Strval(api["value"])
-	//	} else {
-	//		path = utils.Strval(conf["sendUrl"])
-	//	}
-	//
-	//	for _, e := range inputs {
-	//		if e.In == convert.Header {
-	//			val, err := n.webHookCal(ctx, e, variables, formDefKey)
-	//			if err != nil {
-	//				return nil, err
-	//			}
-	//			if e.Name != "" {
-	//				requestHeader[e.Name] = utils.Strval(val)
-	//			}
-	//		} else if e.In == convert.Path {
-	//			val, err := n.webHookCal(ctx, e, variables, formDefKey)
-	//			if err != nil {
-	//				return nil, err
-	//			}
-	//			path = strings.Replace(path, e.Name, utils.Strval(val), 1)
-	//		} else if e.In == convert.Query {
-	//			val, err := n.webHookCal(ctx, e, variables, formDefKey)
-	//			if err != nil {
-	//				return nil, err
-	//			}
-	//			queryFlag = true
-	//			if e.Name != "" {
-	//				v.Add(e.Name, utils.Strval(val))
-	//			}
-	//			// path += e.Name + "=" + utils.Strval(val) + "&"
-	//		} else if e.In == convert.Body {
-	//			if e.Type == "object" {
-	//				obj := make(map[string]interface{})
-	//				if v := e.Data; v != nil {
-	//					if reflect.TypeOf(v).Kind() == reflect.Slice {
-	//						arr := v.([]interface{})
-	//						bdata := make(map[string]interface{})
-	//						for _, e1 := range arr {
-	//							marshal, err := json.Marshal(e1)
-	//							if err != nil {
-	//								return nil, err
-	//							}
-	//
-	//							input := convert.Input{}
-	//							err = json.Unmarshal(marshal, &input)
-	//							if err != nil {
-	//								return nil, err
-	//							}
-	//							val, err := n.exchangeParam(ctx, input, nil, "")
-	//							if err != nil {
-	//								return nil, err
-	//							}
-	//							if input.Name != "" {
-	//								bdata[input.Name] = val
-	//							}
-	//
-	//						}
-	//						obj[e.Name] = bdata
-	//						requestBody = obj
-	//					} else {
-	//						// requestBody[e.Name] = e.Data
-	//
-	//						val, err := n.webHookCal(ctx, e, nil, "")
-	//						if err != nil {
-	//							return nil, err
-	//						}
-	//						if e.Name != "" {
-	//							requestBody[e.Name] = utils.Strval(val)
-	//						}
-	//
-	//					}
-	//
-	//				}
-	//			} else {
-	//				if v := e.Data; v != nil {
-	//					if reflect.TypeOf(v).Kind() == reflect.Slice {
-	//						arr := v.([]interface{})
-	//						for _, e := range arr {
-	//							marshal, err := json.Marshal(e)
-	//							if err != nil {
-	//								return nil, err
-	//							}
-	//
-	//							input := convert.Input{}
-	//							err = json.Unmarshal(marshal, &input)
-	//							if err != nil {
-	//								return nil, err
-	//							}
-	//							val, err := n.exchangeParam(ctx, input, variables, formDefKey)
-	//							if err != nil {
-	//								return nil, err
-	//							}
-	//							if input.Name != "" {
-	//								requestBody[input.Name] = val
-	//							}
-	//
-	//						}
-	//					} else {
-	//						// requestBody[e.Name] = e.Data
-	//
-	//						val, err := n.webHookCal(ctx, e, variables, formDefKey)
-	//						if err != nil {
-	//							return nil, err
-	//						}
-	//						if e.Name != "" {
-	//							requestBody[e.Name] = utils.Strval(val)
-	//						}
-	//
-	//					}
-	//
-	//				}
-	//			}
-	//
-	//		}
-	//
-	//	}
-	//} else {
-	//	mp := conf["api"].(map[string]interface{})
-	//	path = mp["value"].(string)
-	//	for _, e := range inputs {
-	//		if e.In == convert.Header {
-	//			val, err := n.webHookCal(ctx, e, nil, "")
-	//			if err != nil {
-	//				return nil, err
-	//			}
-	//			if e.Name != "" {
-	//				requestHeader[e.Name] = utils.Strval(val)
-	//			}
-	//		} else if e.In == convert.Path {
-	//			val, err := n.webHookCal(ctx, e, nil, "")
-	//			if err != nil {
-	//				return nil, err
-	//			}
-	//			path = strings.Replace(path, e.Name, utils.Strval(val), 1)
-	//		} else if e.In == convert.Query {
-	//			val, err := n.webHookCal(ctx, e, nil, "")
-	//			if err != nil {
-	//				return nil, err
-	//			}
-	//			queryFlag = true
-	//			if e.Name != "" {
-	//				v.Add(e.Name, utils.Strval(val))
-	//			}
-	//			// path += e.Name + "=" + utils.Strval(val) + "&"
-	//		} else if e.In == convert.Body {
-	//			if e.Type == "object" {
-	//				if v := e.Data; v != nil {
-	//					if reflect.TypeOf(v).Kind() == reflect.Slice {
-	//						arr := v.([]interface{})
-	//						bdata := make(map[string]interface{})
-	//						for _, e1 := range arr {
-	//							marshal, err := json.Marshal(e1)
-	//							if err != nil {
-	//								return nil, err
-	//							}
-	//
-	//							input := convert.Input{}
-	//							err = json.Unmarshal(marshal, &input)
-	//							if err != nil {
-	//								return nil, err
-	//							}
-	//							val, err := n.exchangeParam(ctx, input, nil, "")
-	//							if err != nil {
-	//								return nil, err
-	//							}
-	//							if input.Name != "" {
-	//								bdata[input.Name] = val
-	//							}
-	//
-	//						}
-	//						requestBody[e.Name] = bdata
-	//					} else {
-	//						// requestBody[e.Name] = e.Data
-	//
-	//						val, err := n.webHookCal(ctx, e, nil, "")
-	//						if err != nil {
-	//							return nil, err
-	//						}
-	//						if e.Name != "" {
-	//							requestBody[e.Name] = utils.FormatValue(utils.Strval(val), e.Type)
-	//						}
-	//
-	//					}
-	//
-	//				}
-	//			} else {
-	//				if v := e.Data; v != nil {
-	//					if reflect.TypeOf(v).Kind() == reflect.Slice {
-	//						arr := v.([]interface{})
-	//						for _, e := range arr {
-	//							marshal, err := json.Marshal(e)
-	//							if err != nil {
-	//								return nil, err
-	//							}
-	//
-	//							input := convert.Input{}
-	//							err = json.Unmarshal(marshal, &input)
-	//							if err != nil {
-	//								return nil, err
-	//							}
-	//							val, err := n.exchangeParam(ctx, input, nil, "")
-	//							if err != nil {
-	//								return nil, err
-	//							}
-	//							if input.Name != "" {
-	//								if val != nil {
-	//									requestBody[input.Name] = utils.Strval(val)
-	//								}
-	//							}
-	//
-	//						}
-	//					} else {
-	//						// requestBody[e.Name] = e.Data
-	//
-	//						val, err := n.webHookCal(ctx, e, nil, "")
-	//						if err != nil {
-	//							return nil, err
-	//						}
-	//						if e.Name != "" {
-	//							if val != nil {
-	//								requestBody[e.Name] = utils.FormatValue(utils.Strval(val), e.Type)
-	//							}
-	//
-	//						}
-	//
-	//					}
-	//
-	//				}
-	//
-	//			}
-	//
-	//		}
-	//
-	//	}
-	//}
-	//
-	//if queryFlag {
-	//	if strings.Contains(path, "?") {
-	//		if v.Encode() != "" {
-	//			path += "&"
-	//			path += v.Encode()
-	//		}
-	//
-	//	} else {
-	//		if v.Encode() != "" {
-	//			path += "?"
-	//			path += v.Encode()
-	//		}
-	//
-	//	}
-	//
-	//}
-	//var method = ""
-	//if hookType == "request" {
-	//	_, ok := requestHeader["Content-Type"]
-	//	if !ok {
-	//		requestHeader["Content-Type"] = "application/json"
-	//	}
-	//	method = utils.Strval(conf["method"])
-	//	resp, err := n.PolyAPI.InnerRequest(ctx, path, requestBody, requestHeader, method)
-	//	if err != nil {
-	//		return nil, err
-	//	}
-	//	if resp == nil {
-	//		return nil, nil
-	//	}
-	//	apiMap := n.apiMap(resp)
-	//	// save resp
-	//	for k, v := range apiMap {
-	//		code := "$" + eventData.Shape.ID + "." + k
-	//		variable, err := n.InstanceVariablesRepo.FindVariablesByCode(n.Db, eventData.ProcessInstanceID, code)
-	//		if err != nil {
-	//			return nil, err
-	//		}
-	//
-	//		fieldValue, fieldType := utils.StrvalAndType(v)
-	//		if variable.ID != "" {
-	//			if err := n.InstanceVariablesRepo.UpdateTypeAndValue(n.Db, eventData.ProcessInstanceID, code, fieldType, fieldValue); err != nil {
-	//				return nil, err
-	//			}
-	//		} else {
-	//			variable := models.InstanceVariables{
-	//				ProcessInstanceID: eventData.ProcessInstanceID,
-	//				Code:              code,
-	//				FieldType:         fieldType,
-	//				Value:             fieldValue,
-	//			}
-	//			if err := n.InstanceVariablesRepo.Create(n.Db, &variable); err != nil {
-	//				return nil, err
-	//			}
-	//		}
-	//	}
-	//} else if hookType == "send" {
-	//	requestHeader["Content-Type"] = utils.Strval(conf["contentType"])
-	//	_, ok := requestHeader["Content-Type"]
-	//	if !ok {
-	//		requestHeader["Content-Type"] = "application/json"
-	//	}
-	//	method = utils.Strval(conf["sendMethod"])
-	//	_, err := n.PolyAPI.SendRequest(ctx, path, requestBody, requestHeader, method)
-	//	if err != nil {
-	//		return nil, err
-	//	}
-	//}
 	return nil, nil
 }
 
-func (n *WebHook) apiMap(apiMap map[string]interface{}) map[string]interface{} {
-	ret := make(map[string]interface{})
-	for k, v := range apiMap {
-		if v != nil {
-			typeOf := reflect.TypeOf(v).Kind()
-			if typeOf == reflect.Map {
-				m := n.apiMap(v.(map[string]interface{}))
-				for k1, v1 := range m {
-					ret[k+"."+k1] = v1
-				}
-			} else {
-				ret[k] = v
-			}
-		}
-
-	}
-	return ret
-}
-
 // InitEnd event
 func (n *WebHook) InitEnd(ctx context.Context, eventData *EventData) (*pb.NodeEventRespData, error) {
-	fmt.Println("================执行e")
-	//if !n.CheckRefuse(ctx, n.Db, eventData.ProcessInstanceID) {
-	//	return nil, nil
-	//}
-	flow, err := n.FlowRepo.FindByProcessID(n.Db, eventData.ProcessID)
+	flow, err := n.FindInstanceFlow(eventData)
 	if err != nil {
 		return nil, err
 	}
 	if flow == nil {
-		flowProcessRelation, err := n.FlowProcessRelationRepo.FindByProcessID(n.Db, eventData.ProcessID)
-		if err != nil {
-			return nil, err
-		}
-		flow, err = n.FlowRepo.FindByID(n.Db, flowProcessRelation.FlowID)
-		if err != nil {
-			return nil, err
-		}
-		if flow == nil {
-			return nil, errors.New("webhook node not match flow")
-		}
+		return nil, errors.New("webhook node not match flow")
 	}
 	preNodeKey := CheckPreNode(flow.BpmnText, eventData.NodeDefKey)
 	if preNodeKey != "" {
@@ -501,12 +86,8 @@ func (n *WebHook) InitEnd(ctx context.Context, eventData *EventData) (*pb.NodeEv
 			}
 		}
 	}
+	defer redis.ClusterClient.SetEX(ctx, "flow:node:"+eventData.ProcessInstanceID+":"+eventData.NodeDefKey, "over", 20*time.Second)
 
-	// do req
-	queryFlag := false
-	requestBody := make(map[string]interface{})
-	requestHeader := make(map[string]string) // req header
-	path := ""
 	bd := eventData.Shape.Data.BusinessData
 	if bd == nil {
 		return nil, nil
@@ -514,88 +95,47 @@ func (n *WebHook) InitEnd(ctx context.Context, eventData *EventData) (*pb.NodeEv
 	hookType := utils.Strval(bd["type"])
 	var conf map[string]interface{}
 	if v := bd["config"]; v != nil {
-		conf = v.(map[string]interface{})
+		var ok bool
+		if conf, ok = v.(map[string]interface{}); !ok {
+			return nil, errors.New("webhook node config is invalid")
+		}
+	}
+	policy, err := n.webHookPolicy(conf)
+	if err != nil {
+		return nil, err
+	}
+	inputs, err := n.toInputs(conf["inputs"])
+	if err != nil {
+		return nil, err
 	}
-	var inputs []convert.Input
-	if v := conf["inputs"]; v != nil {
-		arr := v.([]interface{})
-		for _, e := range arr {
-			marshal, err := json.Marshal(e)
-			if err != nil {
-				return nil, err
-			}
 
-			input := convert.Input{}
-			err = json.Unmarshal(marshal, &input)
-			if err != nil {
-				return nil, err
-			}
-			inputs = append(inputs, input)
-		}
+	instance, err := n.InstanceRepo.GetEntityByProcessInstanceID(n.Db, eventData.ProcessInstanceID)
+	if err != nil {
+		return nil, err
 	}
-	if hookType == "request" {
-		var api map[string]interface{}
-		if v := conf["api"]; v != nil {
-			api = v.(map[string]interface{})
-		}
-		path = utils.Strval(api["value"])
-	} else {
-		path = utils.Strval(conf["sendUrl"])
+	if instance == nil {
+		return nil, errors.New("webhook node not match instance")
 	}
-	v := url.Values{}
+
+	var variables map[string]interface{}
+	formDefKey := ""
 	if flow.TriggerMode == "FORM_DATA" {
 		formShape, err := convert.GetShapeByChartType(flow.BpmnText, convert.FormData)
 		if err != nil {
 			return nil, err
 		}
-		formDefKey := formShape.ID
+		formDefKey = formShape.ID
 
-		instance, err := n.InstanceRepo.GetEntityByProcessInstanceID(n.Db, eventData.ProcessInstanceID)
+		variables, err = n.Instance.GetInstanceVariableValues(ctx, instance)
 		if err != nil {
 			return nil, err
 		}
 
-		variables, err := n.Instance.GetInstanceVariableValues(ctx, instance)
-		if err != nil {
-			return nil, err
-		}
-		var ref = make(map[string]map[string]string)
-		for k := range inputs {
-			if inputs[k].FieldType == "AssociatedRecords" {
-				m := make(map[string]string)
-				m["appID"] = instance.AppID
-				m["tableID"] = inputs[k].TableID
-				m["type"] = "associated_records"
-				ref[inputs[k].FieldName] = m
-			}
-			if inputs[k].FieldType == "ForeignTable" {
-				m := make(map[string]string)
-				m["appID"] = instance.AppID
-				m["tableID"] = inputs[k].TableID
-				m["type"] = "foreign_table"
-				ref[inputs[k].FieldName] = m
-			}
-			if inputs[k].FieldType == "SubTable" {
-				m := make(map[string]string)
-				m["appID"] = instance.AppID
-				m["tableID"] = inputs[k].TableID
-				m["type"] = "sub_table"
-				ref[inputs[k].FieldName] = m
-			}
-			if inputs[k].FieldType == "AggregationRecords" {
-				m := make(map[string]string)
-				m["appID"] = instance.AppID
-				m["tableID"] = inputs[k].TableID
-				m["type"] = "aggregation"
-				ref[inputs[k].FieldName] = m
-			}
-		}
-
 		dataReq := client.FormDataConditionModel{
 			AppID:   instance.AppID,
 			TableID: instance.FormID,
 			DataID:  instance.FormInstanceID,
-			Ref:     ref,
+			Ref:     n.refs(instance.AppID, inputs),
 		}
 		dataResp, err := n.FormAPI.GetFormData(ctx, dataReq)
 		if err != nil {
@@ -608,312 +148,380 @@ func (n *WebHook) InitEnd(ctx context.Context, eventData *EventData) (*pb.NodeEv
 		for k, v := range dataResp {
 			variables[k] = v
 		}
+	}
 
-		// gen req
+	req, err := n.buildRequest(ctx, hookType, conf, inputs, variables, formDefKey)
+	if err != nil {
+		return nil, err
+	}
+	req.secret, err = n.AppSecretRepo.FindActiveByAppID(n.Db, instance.AppID)
+	if err != nil {
+		return nil, err
+	}
 
-		for _, e := range inputs {
-			if e.Name == "" {
-				continue
-			}
-			if e.In == convert.Header {
-				val, err := n.webHookCal(ctx, e, variables, formDefKey)
-				if err != nil {
-					return nil, err
-				}
-				if e.Name != "" {
-					requestHeader[e.Name] = utils.Strval(val)
-				}
-			} else if e.In == convert.Path {
-				val, err := n.webHookCal(ctx, e, variables, formDefKey)
-				if err != nil {
-					return nil, err
-				}
-				path = strings.Replace(path, e.Name, utils.Strval(val), 1)
-			} else if e.In == convert.Query {
-				val, err := n.webHookCal(ctx, e, variables, formDefKey)
-				if err != nil {
-					return nil, err
-				}
-				queryFlag = true
-				if e.Name != "" {
-					v.Add(e.Name, utils.Strval(val))
-				}
-				// path += e.Name + "=" + utils.Strval(val) + "&"
-			} else if e.In == convert.Body {
-				if e.Type == "object" {
-					obj := make(map[string]interface{})
-					if v := e.Data; v != nil {
-						if reflect.TypeOf(v).Kind() == reflect.Slice {
-							arr := v.([]interface{})
-							bdata := make(map[string]interface{})
-							for _, e1 := range arr {
-								marshal, err := json.Marshal(e1)
-								if err != nil {
-									return nil, err
-								}
-
-								input := convert.Input{}
-								err = json.Unmarshal(marshal, &input)
-								if err != nil {
-									return nil, err
-								}
-								val, err := n.exchangeParam(ctx, input, nil, "")
-								if err != nil {
-									return nil, err
-								}
-								if input.Name != "" {
-									bdata[input.Name] = val
-								}
-
-							}
-							obj[e.Name] = bdata
-							requestBody = obj
-						} else {
-							// requestBody[e.Name] = e.Data
-
-							val, err := n.webHookCal(ctx, e, nil, "")
-							if err != nil {
-								return nil, err
-							}
-							if e.Name != "" {
-								requestBody[e.Name] = utils.Strval(val)
-							}
-
-						}
+	var wait *flow2.WaitCallbackResp
+	if policy.Mode == convert.WebHookModeCallback {
+		wait, err = n.Webhook.Wait(ctx, &flow2.WaitCallbackReq{
+			Instance:    instance,
+			ProcessID:   eventData.ProcessID,
+			NodeDefKey:  eventData.NodeDefKey,
+			ExecutionID: eventData.ExecutionID,
+			Outputs:     policy.Outputs,
+			TimeRule:    policy.TimeRule,
+		})
+		if err != nil {
+			return nil, err
+		}
+		req.header[webHookHeaderCallbackURL] = wait.CallbackURL
+		req.header[webHookHeaderCallbackToken] = wait.Token
+	}
 
-					}
-				} else {
-					if v := e.Data; v != nil {
-						if reflect.TypeOf(v).Kind() == reflect.Slice {
-							arr := v.([]interface{})
-							for _, e := range arr {
-								marshal, err := json.Marshal(e)
-								if err != nil {
-									return nil, err
-								}
-
-								input := convert.Input{}
-								err = json.Unmarshal(marshal, &input)
-								if err != nil {
-									return nil, err
-								}
-								val, err := n.exchangeParam(ctx, input, variables, formDefKey)
-								if err != nil {
-									return nil, err
-								}
-								if input.Name != "" {
-									requestBody[input.Name] = val
-								}
-
-							}
-						} else {
-							// requestBody[e.Name] = e.Data
-
-							val, err := n.webHookCal(ctx, e, variables, formDefKey)
-							if err != nil {
-								return nil, err
-							}
-							if e.Name != "" {
-								requestBody[e.Name] = utils.Strval(val)
-							}
-
-						}
+	resp, err := n.call(ctx, eventData, req, policy)
+	if err != nil {
+		if wait != nil {
+			if err := n.Webhook.Abort(ctx, wait.ID); err != nil {
+				return nil, err
+			}
+		}
+		// 调用最终失败，节点挂起并记录异常任务，由管理员继续节点或作废流程
+		return n.ParkNode(instance, eventData, "webhook调用失败", truncate(err.Error(), webHookBodyLimit))
+	}
 
-					}
-				}
+	if wait != nil {
+		// 等待外部系统回调后再完成节点，回调从此刻起才被接受
+		if err := n.Webhook.Ready(ctx, wait.ID); err != nil {
+			return nil, err
+		}
+		return &pb.NodeEventRespData{ExecuteType: convert.PauseExecution}, nil
+	}
 
+	var body interface{}
+	if len(resp.Body) > 0 {
+		if err := json.Unmarshal(resp.Body, &body); err != nil {
+			logger.Logger.Warn("webhook response is not json", err.Error())
+			return nil, nil
+		}
+	}
+	if hookType == "request" {
+		if m, ok := body.(map[string]interface{}); ok {
+			// save resp
+			if err := n.Webhook.SaveFlatten(ctx, eventData.ProcessInstanceID, eventData.Shape.ID, m); err != nil {
+				return nil, err
 			}
-
 		}
-	} else {
-		for _, e := range inputs {
-			if e.In == convert.Header {
-				val, err := n.webHookCal(ctx, e, nil, "")
-				if err != nil {
-					return nil, err
-				}
-				if e.Name != "" {
-					requestHeader[e.Name] = utils.Strval(val)
-				}
-			} else if e.In == convert.Path {
-				val, err := n.webHookCal(ctx, e, nil, "")
-				if err != nil {
-					return nil, err
-				}
-				path = strings.Replace(path, e.Name, utils.Strval(val), 1)
-			} else if e.In == convert.Query {
-				val, err := n.webHookCal(ctx, e, nil, "")
-				if err != nil {
-					return nil, err
-				}
-				queryFlag = true
-				if e.Name != "" {
-					v.Add(e.Name, utils.Strval(val))
-				}
-				// path += e.Name + "=" + utils.Strval(val) + "&"
-			} else if e.In == convert.Body {
-				if e.Type == "object" {
-					if v := e.Data; v != nil {
-						if reflect.TypeOf(v).Kind() == reflect.Slice {
-							arr := v.([]interface{})
-							bdata := make(map[string]interface{})
-							for _, e1 := range arr {
-								marshal, err := json.Marshal(e1)
-								if err != nil {
-									return nil, err
-								}
-
-								input := convert.Input{}
-								err = json.Unmarshal(marshal, &input)
-								if err != nil {
-									return nil, err
-								}
-								val, err := n.exchangeParam(ctx, input, nil, "")
-								if err != nil {
-									return nil, err
-								}
-								if input.Name != "" {
-									bdata[input.Name] = val
-								}
-
-							}
-							requestBody[e.Name] = bdata
-						} else {
-							// requestBody[e.Name] = e.Data
-
-							val, err := n.webHookCal(ctx, e, nil, "")
-							if err != nil {
-								return nil, err
-							}
-							if e.Name != "" {
-								requestBody[e.Name] = utils.FormatValue(utils.Strval(val), e.Type)
-							}
-
-						}
+	}
+	if err := n.Webhook.MapOutputs(ctx, instance, policy.Outputs, body); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
 
-					}
-				} else {
-					if v := e.Data; v != nil {
-						if reflect.TypeOf(v).Kind() == reflect.Slice {
-							arr := v.([]interface{})
-							for _, e := range arr {
-								marshal, err := json.Marshal(e)
-								if err != nil {
-									return nil, err
-								}
-
-								input := convert.Input{}
-								err = json.Unmarshal(marshal, &input)
-								if err != nil {
-									return nil, err
-								}
-								val, err := n.exchangeParam(ctx, input, nil, "")
-								if err != nil {
-									return nil, err
-								}
-								if input.Name != "" {
-									if val != nil {
-										requestBody[input.Name] = utils.Strval(val)
-									}
-								}
-
-							}
-						} else {
-							// requestBody[e.Name] = e.Data
-
-							val, err := n.webHookCal(ctx, e, nil, "")
-							if err != nil {
-								return nil, err
-							}
-							if e.Name != "" {
-								if val != nil {
-									requestBody[e.Name] = utils.FormatValue(utils.Strval(val), e.Type)
-								}
-
-							}
-
-						}
+const (
+	webHookDefaultTimeout = 30 // seconds
+	webHookMaxTimeout     = 300
+	webHookMaxRetry       = 5
+	webHookMaxBackoff     = 60 * time.Second
+	webHookBodyLimit      = 2000
 
-					}
+	webHookHeaderTimestamp = "X-Flow-Timestamp"
+	webHookHeaderSignature = "X-Flow-Signature"
+	webHookHeaderKeyID     = "X-Flow-Key-Id"
 
-				}
+	webHookHeaderCallbackURL   = "X-Flow-Callback-Url"
+	webHookHeaderCallbackToken = "X-Flow-Callback-Token"
+)
 
-			}
+// WebHookPolicy timeout, retry and response mapping of webhook node
+type WebHookPolicy struct {
+	Mode     string                    `json:"mode"`    // sync, callback
+	Timeout  int                       `json:"timeout"` // 单次调用超时时间，秒
+	Retry    WebHookRetry              `json:"retry"`
+	Outputs  []convert.WebHookOutput   `json:"outputs"`
+	TimeRule convert.TaskTimeRuleModel `json:"timeRule"` // callback模式等待回调的时间限制
+}
 
-		}
+// WebHookRetry retry policy
+type WebHookRetry struct {
+	Times    int `json:"times"`    // 失败后的重试次数
+	Interval int `json:"interval"` // 首次重试间隔，秒，之后按指数退避
+}
+
+type webHookRequest struct {
+	inner  bool
+	path   string
+	method string
+	header map[string]string
+	body   map[string]interface{}
+	secret *models.AppSecret // 应用签名密钥，为空则不签名
+}
+
+// signedHeader header of one attempt, signed with the app secret if there is one
+func (r *webHookRequest) signedHeader() (map[string]string, error) {
+	if r.secret == nil {
+		return r.header, nil
+	}
+	// same encoding as client.RequestRaw, so the receiver verifies the exact bytes it gets
+	body, err := json.Marshal(r.body)
+	if err != nil {
+		return nil, err
 	}
+	timestamp := time.Now().Unix()
 
-	if queryFlag {
-		if strings.Contains(path, "?") {
-			if v.Encode() != "" {
-				path += "&"
-				path += v.Encode()
-			}
+	header := make(map[string]string, len(r.header)+3)
+	for k, v := range r.header {
+		header[k] = v
+	}
+	header[webHookHeaderTimestamp] = strconv.FormatInt(timestamp, 10)
+	header[webHookHeaderSignature] = utils.HMACSign(r.secret.Secret, timestamp, body)
+	header[webHookHeaderKeyID] = r.secret.ID
+	return header, nil
+}
 
-		} else {
-			if v.Encode() != "" {
-				path += "?"
-				path += v.Encode()
-			}
+func (n *WebHook) webHookPolicy(conf map[string]interface{}) (*WebHookPolicy, error) {
+	policy := &WebHookPolicy{}
+	marshal, err := json.Marshal(conf)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(marshal, policy); err != nil {
+		return nil, err
+	}
 
-		}
+	if policy.Timeout <= 0 {
+		policy.Timeout = webHookDefaultTimeout
+	}
+	if policy.Timeout > webHookMaxTimeout {
+		policy.Timeout = webHookMaxTimeout
+	}
+	if policy.Retry.Times < 0 {
+		policy.Retry.Times = 0
+	}
+	if policy.Retry.Times > webHookMaxRetry {
+		policy.Retry.Times = webHookMaxRetry
+	}
+	if policy.Retry.Interval <= 0 {
+		policy.Retry.Interval = 1
+	}
+	return policy, nil
+}
 
+func (n *WebHook) toInputs(data interface{}) ([]convert.Input, error) {
+	var inputs []convert.Input
+	arr, ok := data.([]interface{})
+	if !ok {
+		return inputs, nil
 	}
-	var method = ""
-	if hookType == "request" {
-		_, ok := requestHeader["Content-Type"]
-		if !ok {
-			requestHeader["Content-Type"] = "application/json"
+	for _, e := range arr {
+		marshal, err := json.Marshal(e)
+		if err != nil {
+			return nil, err
 		}
-		method = utils.Strval(conf["method"])
-		resp, err := n.PolyAPI.InnerRequest(ctx, path, requestBody, requestHeader, method)
+
+		input := convert.Input{}
+		err = json.Unmarshal(marshal, &input)
 		if err != nil {
 			return nil, err
 		}
-		if resp == nil {
-			return nil, nil
+		inputs = append(inputs, input)
+	}
+	return inputs, nil
+}
+
+// refs relation fields of form data used by inputs
+func (n *WebHook) refs(appID string, inputs []convert.Input) map[string]map[string]string {
+	refTypes := map[string]string{
+		"AssociatedRecords":  "associated_records",
+		"ForeignTable":       "foreign_table",
+		"SubTable":           "sub_table",
+		"AggregationRecords": "aggregation",
+	}
+	var ref = make(map[string]map[string]string)
+	for k := range inputs {
+		if refType, ok := refTypes[inputs[k].FieldType]; ok {
+			ref[inputs[k].FieldName] = map[string]string{
+				"appID":   appID,
+				"tableID": inputs[k].TableID,
+				"type":    refType,
+			}
+		}
+	}
+	return ref
+}
+
+// buildRequest build header, path, query and body from inputs
+func (n *WebHook) buildRequest(ctx context.Context, hookType string, conf map[string]interface{}, inputs []convert.Input, variables map[string]interface{}, formDefKey string) (*webHookRequest, error) {
+	req := &webHookRequest{
+		inner:  hookType == "request",
+		header: make(map[string]string),
+		body:   make(map[string]interface{}),
+	}
+	if req.inner {
+		var api map[string]interface{}
+		if v := conf["api"]; v != nil {
+			api = v.(map[string]interface{})
+		}
+		req.path = utils.Strval(api["value"])
+		req.method = utils.Strval(conf["method"])
+	} else {
+		req.path = utils.Strval(conf["sendUrl"])
+		req.method = utils.Strval(conf["sendMethod"])
+		if contentType := utils.Strval(conf["contentType"]); contentType != "" {
+			req.header["Content-Type"] = contentType
+		}
+	}
+
+	query := url.Values{}
+	for _, e := range inputs {
+		if e.Name == "" {
+			continue
 		}
-		apiMap := n.apiMap(resp)
-		// save resp
-		for k, v := range apiMap {
-			code := "$" + eventData.Shape.ID + "." + k
-			variable, err := n.InstanceVariablesRepo.FindVariablesByCode(n.Db, eventData.ProcessInstanceID, code)
+		switch e.In {
+		case convert.Header:
+			val, err := n.webHookCal(ctx, e, variables, formDefKey)
 			if err != nil {
 				return nil, err
 			}
-
-			fieldValue, fieldType := utils.StrvalAndType(v)
-			if variable.ID != "" {
-				if err := n.InstanceVariablesRepo.UpdateTypeAndValue(n.Db, eventData.ProcessInstanceID, code, fieldType, fieldValue); err != nil {
+			req.header[e.Name] = utils.Strval(val)
+		case convert.Path:
+			val, err := n.webHookCal(ctx, e, variables, formDefKey)
+			if err != nil {
+				return nil, err
+			}
+			req.path = strings.Replace(req.path, e.Name, utils.Strval(val), 1)
+		case convert.Query:
+			val, err := n.webHookCal(ctx, e, variables, formDefKey)
+			if err != nil {
+				return nil, err
+			}
+			query.Add(e.Name, utils.Strval(val))
+		case convert.Body:
+			if e.Data == nil {
+				continue
+			}
+			if reflect.TypeOf(e.Data).Kind() == reflect.Slice {
+				children, err := n.toInputs(e.Data)
+				if err != nil {
 					return nil, err
 				}
-			} else {
-				variable := models.InstanceVariables{
-					ProcessInstanceID: eventData.ProcessInstanceID,
-					Code:              code,
-					FieldType:         fieldType,
-					Value:             fieldValue,
+				bdata := make(map[string]interface{})
+				for _, child := range children {
+					val, err := n.exchangeParam(ctx, child, variables, formDefKey)
+					if err != nil {
+						return nil, err
+					}
+					if child.Name != "" && val != nil {
+						bdata[child.Name] = val
+					}
 				}
-				if err := n.InstanceVariablesRepo.Create(n.Db, &variable); err != nil {
-					return nil, err
+				if e.Type == "object" {
+					req.body[e.Name] = bdata
+				} else {
+					for k, v := range bdata {
+						req.body[k] = v
+					}
 				}
+				continue
+			}
+			val, err := n.webHookCal(ctx, e, variables, formDefKey)
+			if err != nil {
+				return nil, err
+			}
+			if val != nil {
+				req.body[e.Name] = utils.FormatValue(utils.Strval(val), e.Type)
 			}
 		}
-	} else if hookType == "send" {
-		requestHeader["Content-Type"] = utils.Strval(conf["contentType"])
-		_, ok := requestHeader["Content-Type"]
-		if !ok {
-			requestHeader["Content-Type"] = "application/json"
+	}
+
+	if encode := query.Encode(); encode != "" {
+		if strings.Contains(req.path, "?") {
+			req.path += "&" + encode
+		} else {
+			req.path += "?" + encode
 		}
-		method = utils.Strval(conf["sendMethod"])
-		_, err := n.PolyAPI.SendRequest(ctx, path, requestBody, requestHeader, method)
-		if err != nil {
-			return nil, err
+	}
+	if _, ok := req.header["Content-Type"]; !ok {
+		req.header["Content-Type"] = "application/json"
+	}
+	return req, nil
+}
+
+// call do the request, retry with exponential backoff until it succeeds or the retry times are used up
+func (n *WebHook) call(ctx context.Context, eventData *EventData, req *webHookRequest, policy *WebHookPolicy) (*client.RawResponse, error) {
+	var lastErr error
+	backoff := time.Duration(policy.Retry.Interval) * time.Second
+	for attempt := 1; attempt <= policy.Retry.Times+1; attempt++ {
+		if attempt > 1 {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+			if backoff > webHookMaxBackoff {
+				backoff = webHookMaxBackoff
+			}
+		}
+
+		resp, err := n.callOnce(ctx, eventData, req, policy, attempt)
+		if err == nil {
+			return resp, nil
 		}
+		lastErr = err
+		logger.Logger.Errorf("webhook %s attempt %d failed: %s", eventData.NodeDefKey, attempt, err.Error())
 	}
-	fmt.Println("================执行e over")
-	redis.ClusterClient.SetEX(ctx, "flow:node:"+eventData.ProcessInstanceID+":"+eventData.NodeDefKey, "over", 20*time.Second)
-	return nil, nil
+	return nil, lastErr
+}
+
+func (n *WebHook) callOnce(ctx context.Context, eventData *EventData, req *webHookRequest, policy *WebHookPolicy, attempt int) (*client.RawResponse, error) {
+	c, cancel := context.WithTimeout(ctx, time.Duration(policy.Timeout)*time.Second)
+	defer cancel()
+
+	header, err := req.signedHeader()
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	var resp *client.RawResponse
+	if req.inner {
+		resp, err = n.PolyAPI.InnerRequestRaw(c, req.path, req.body, header, req.method)
+	} else {
+		resp, err = n.PolyAPI.SendRequestRaw(c, req.path, req.body, header, req.method)
+	}
+	if err == nil && (resp.StatusCode < 200 || resp.StatusCode >= 300) {
+		err = fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	record := &models.WebhookAttempt{
+		ProcessInstanceID: eventData.ProcessInstanceID,
+		NodeDefKey:        eventData.NodeDefKey,
+		NodeName:          eventData.Shape.Data.NodeData.Name,
+		ExecutionID:       eventData.ExecutionID,
+		Attempt:           attempt,
+		URL:               truncate(req.path, 1000),
+		Method:            req.method,
+		Latency:           time.Since(start).Milliseconds(),
+	}
+	if resp != nil {
+		record.StatusCode = resp.StatusCode
+		record.ResponseBody = truncate(string(resp.Body), webHookBodyLimit)
+	}
+	if err != nil {
+		record.Error = truncate(err.Error(), 1000)
+	}
+	if err := n.WebhookAttemptRepo.Create(n.Db, record); err != nil {
+		logger.Logger.Error("save webhook attempt failed", err.Error())
+	}
+	return resp, err
+}
+
+func truncate(s string, limit int) string {
+	if len(s) <= limit {
+		return s
+	}
+	for limit > 0 && !utf8.RuneStart(s[limit]) {
+		limit--
+	}
+	return s[:limit]
 }
 
 // exchangeParam exchange body val
@@ -69,7 +69,7 @@ func (neh *nodeEventHandler) convertToEventData(ctx context.Context, eventReqDat
 
 	ctx = pkg.RPCCTXTransfer(eventData.RequestID, eventData.UserID)
 
-	shape, err := neh.Flow.GetShapeByProcessID(ctx, eventData.ProcessID, eventData.NodeDefKey)
+	shape, err := neh.Flow.GetShapeByInstance(ctx, eventData.ProcessID, eventData.ProcessInstanceID, eventData.NodeDefKey)
 	if err != nil {
 		return nil, err
 	}
@@ -31,10 +31,14 @@ type NodeFactory struct {
 	cc             *node.CC
 	dataCreate     *node.DataCreate
 	dataUpdate     *node.DataUpdate
+	dataDelete     *node.DataDelete
+	dataQuery      *node.DataQuery
 	webHook        *node.WebHook
 	variableUpdate *node.VariableUpdate
 	userTask       *node.UserTask
 	delay          *node.Delay
+	subProcess     *node.SubProcess
+	script         *node.Script
 }
 
 // NewNodeFactory new
@@ -55,6 +59,22 @@ func NewNodeFactory(conf *config.Configs, opts ...options.Options) (*NodeFactory
 	if err != nil {
 		return nil, nil
 	}
+	subProcess, err := flow.NewSubProcess(conf, opts...)
+	if err != nil {
+		return nil, nil
+	}
+	webhook, err := flow.NewWebhook(conf, opts...)
+	if err != nil {
+		return nil, nil
+	}
+	workCalendar, err := flow.NewWorkCalendar(conf, opts...)
+	if err != nil {
+		return nil, nil
+	}
+	messageTemplate, err := flow.NewMessageTemplate(conf, opts...)
+	if err != nil {
+		return nil, nil
+	}
 	flow, err := flow.NewFlow(conf, opts...)
 	if err != nil {
 		return nil, nil
@@ -80,7 +100,18 @@ func NewNodeFactory(conf *config.Configs, opts ...options.Options) (*NodeFactory
 		OperationRecord:         operationRecord,
 		InstanceExecutionRepo:   mysql.NewInstanceExecutionRepo(),
 		Task:                    task,
+		SubProcess:              subProcess,
+		Webhook:                 webhook,
+		WorkCalendar:            workCalendar,
+		MessageTemplate:         messageTemplate,
 		FlowProcessRelationRepo: mysql.NewFlowProcessRelationRepo(),
+		FlowVersionRepo:         mysql.NewFlowVersionRepo(),
+		WebhookAttemptRepo:      mysql.NewWebhookAttemptRepo(),
+		AppSecretRepo:           mysql.NewAppSecretRepo(),
+		OperationRecordRepo:     mysql.NewOperationRecordRepo(),
+		ApproveSequenceRepo:     mysql.NewApproveSequenceRepo(),
+		CommentRepo:             mysql.NewCommentRepo(),
+		CommentAttachmentRepo:   mysql.NewCommentAttachmentRepo(),
 	}
 	for _, opt := range opts {
 		opt(n)
@@ -92,10 +123,14 @@ func NewNodeFactory(conf *config.Configs, opts ...options.Options) (*NodeFactory
 		cc:             node.NewCC(conf, n),
 		dataCreate:     node.NewDataCreate(conf, n),
 		dataUpdate:     node.NewDataUpdate(conf, n),
+		dataDelete:     node.NewDataDelete(conf, n),
+		dataQuery:      node.NewDataQuery(conf, n),
 		webHook:        node.NewWebHook(conf, n),
 		variableUpdate: node.NewVariableUpdate(conf, n),
 		userTask:       node.NewUserTask(conf, n),
 		delay:          node.NewDelay(conf, n),
+		subProcess:     node.NewSubProcess(conf, n),
+		script:         node.NewScript(conf, n),
 	}, nil
 }
 
@@ -112,6 +147,10 @@ func (f *NodeFactory) GetNode(nodeName string) node.INode {
 		return f.dataCreate
 	case convert.TableDataUpdate:
 		return f.dataUpdate
+	case convert.TableDataDelete:
+		return f.dataDelete
+	case convert.TableDataQuery:
+		return f.dataQuery
 	case convert.WebHook:
 		return f.webHook
 	case convert.ProcessVariableAssignment:
@@ -122,6 +161,10 @@ func (f *NodeFactory) GetNode(nodeName string) node.INode {
 		return f.userTask
 	case convert.Delayed:
 		return f.delay
+	case convert.SubFlow:
+		return f.subProcess
+	case convert.ScriptTask:
+		return f.script
 	}
 
 	return nil
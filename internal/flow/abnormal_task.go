@@ -23,6 +23,7 @@ import (
 	"github.com/quanxiang-cloud/flow/pkg/client"
 	"github.com/quanxiang-cloud/flow/pkg/config"
 	"github.com/quanxiang-cloud/flow/pkg/misc/error2"
+	"github.com/quanxiang-cloud/flow/pkg/misc/logger"
 	"github.com/quanxiang-cloud/flow/pkg/page"
 	"gorm.io/gorm"
 )
@@ -35,32 +36,48 @@ type AbnormalTask interface {
 	AdminAbandon(ctx context.Context, req *AdminTaskReq) (bool, error)
 	AdminDeliverTask(ctx context.Context, req *AdminTaskReq, model *models.HandleTaskModel) (bool, error)
 	AdminGetTaskForm(ctx context.Context, req *AdminTaskReq) (*InstanceDetailModel, error)
+	AdminContinueNode(ctx context.Context, req *AdminNodeReq) (bool, error)
+	AdminAbandonNode(ctx context.Context, req *AdminNodeReq) (bool, error)
+	BatchHandle(ctx context.Context, req *BatchAbnormalTaskReq) (*BatchAbnormalTaskResp, error)
+	BatchJob(ctx context.Context, req *AbnormalTaskJobReq) (*models.AbnormalTaskJob, error)
 }
 
 type abnormalTask struct {
-	db               *gorm.DB
-	conf             *config.Configs
-	abnormalTaskRepo models.AbnormalTaskRepo
-	processAPI       client.Process
-	operationRecord  OperationRecord
-	instanceRepo     models.InstanceRepo
-	flowRepo         models.FlowRepo
-	formAPI          client.Form
-	appCenterAPI     client.AppCenter
+	db                  *gorm.DB
+	conf                *config.Configs
+	abnormalTaskRepo    models.AbnormalTaskRepo
+	processAPI          client.Process
+	operationRecord     OperationRecord
+	instanceRepo        models.InstanceRepo
+	flow                Flow
+	formAPI             client.Form
+	appCenterAPI        client.AppCenter
+	abnormalTaskJobRepo models.AbnormalTaskJobRepo
+	subProcess          SubProcess
 }
 
 // NewAbnormalTask init
 func NewAbnormalTask(conf *config.Configs, opts ...options.Options) (AbnormalTask, error) {
 	operationRecord, _ := NewOperationRecord(conf, opts...)
+	flow, err := NewFlow(conf, opts...)
+	if err != nil {
+		return nil, err
+	}
+	subProcess, err := NewSubProcess(conf, opts...)
+	if err != nil {
+		return nil, err
+	}
 	t := &abnormalTask{
-		conf:             conf,
-		abnormalTaskRepo: mysql.NewAbnormalTaskRepo(),
-		processAPI:       client.NewProcess(conf),
-		operationRecord:  operationRecord,
-		instanceRepo:     mysql.NewInstanceRepo(),
-		flowRepo:         mysql.NewFlowRepo(),
-		formAPI:          client.NewForm(conf),
-		appCenterAPI:     client.NewAppCenter(conf),
+		conf:                conf,
+		abnormalTaskRepo:    mysql.NewAbnormalTaskRepo(),
+		processAPI:          client.NewProcess(conf),
+		operationRecord:     operationRecord,
+		instanceRepo:        mysql.NewInstanceRepo(),
+		flow:                flow,
+		formAPI:             client.NewForm(conf),
+		appCenterAPI:        client.NewAppCenter(conf),
+		abnormalTaskJobRepo: mysql.NewAbnormalTaskJobRepo(),
+		subProcess:          subProcess,
 	}
 
 	for _, opt := range opts {
@@ -112,6 +129,9 @@ func (a *abnormalTask) AdminStepBack(ctx context.Context, req *AdminTaskReq, mod
 	if err != nil {
 		return false, err
 	}
+	if err = checkNotSuspended(flowInstanceEntity); err != nil {
+		return false, err
+	}
 
 	toNode, err := a.processAPI.GetModelNode(ctx, req.ProcessInstanceID, model.TaskDefKey)
 	if err != nil {
@@ -148,11 +168,6 @@ func (a *abnormalTask) AdminSendBack(ctx context.Context, req *AdminTaskReq) (bo
 		Name:       "打回重填",
 		Desc:       convert.SendBackTask,
 	}
-	err = a.processAPI.SendBack(ctx, addTaskReq)
-	if err != nil {
-		return false, err
-	}
-
 	flowInstanceEntity, err := a.instanceRepo.GetEntityByProcessInstanceID(a.db, req.ProcessInstanceID)
 	if err != nil {
 		return false, err
@@ -160,6 +175,14 @@ func (a *abnormalTask) AdminSendBack(ctx context.Context, req *AdminTaskReq) (bo
 	if flowInstanceEntity == nil {
 		return false, error2.NewErrorWithString(error2.Internal, "Can not find flow instance data ")
 	}
+	if err = checkNotSuspended(flowInstanceEntity); err != nil {
+		return false, err
+	}
+
+	err = a.processAPI.SendBack(ctx, addTaskReq)
+	if err != nil {
+		return false, err
+	}
 
 	updateMap := make(map[string]interface{}, 0)
 	updateMap["modifier_id"] = pkg.STDUserID(ctx)
@@ -195,8 +218,16 @@ func (a *abnormalTask) AdminAbandon(ctx context.Context, req *AdminTaskReq) (boo
 	if instance == nil {
 		return false, error2.NewErrorWithString(error2.Internal, "Can not find flow instance data ")
 	}
+	return a.abandon(ctx, instance)
+}
+
+// abandon abend the process instance and mark all its abnormal tasks handled
+func (a *abnormalTask) abandon(ctx context.Context, instance *models.Instance) (bool, error) {
+	if err := checkNotSuspended(instance); err != nil {
+		return false, err
+	}
 
-	flow, err := a.flowRepo.FindByID(a.db, instance.FlowID)
+	flow, err := a.flow.GetInstanceFlow(ctx, instance)
 	if err != nil {
 		return false, err
 	}
@@ -204,12 +235,12 @@ func (a *abnormalTask) AdminAbandon(ctx context.Context, req *AdminTaskReq) (boo
 		return false, error2.NewErrorWithString(error2.Internal, "Can not find flow data ")
 	}
 
-	tasks, err := a.processAPI.GetTasksByInstanceID(ctx, req.ProcessInstanceID)
+	tasks, err := a.processAPI.GetTasksByInstanceID(ctx, instance.ProcessInstanceID)
 	if err != nil {
 		return false, err
 	}
 
-	err = a.processAPI.AbendInstance(ctx, req.ProcessInstanceID)
+	err = a.processAPI.AbendInstance(ctx, instance.ProcessInstanceID)
 	if err != nil {
 		return false, err
 	}
@@ -220,8 +251,11 @@ func (a *abnormalTask) AdminAbandon(ctx context.Context, req *AdminTaskReq) (boo
 	if err = a.instanceRepo.Update(a.db, instance.ID, updateMap); err != nil {
 		return false, err
 	}
+	if err = a.subProcess.Finish(ctx, instance, Abandon); err != nil {
+		logger.Logger.Error(err)
+	}
 
-	if err = a.abnormalTaskRepo.UpdateByProcessInstanceID(a.db, req.ProcessInstanceID, map[string]interface{}{
+	if err = a.abnormalTaskRepo.UpdateByProcessInstanceID(a.db, instance.ProcessInstanceID, map[string]interface{}{
 		"status": 1,
 	}); err != nil {
 		return false, err
@@ -232,7 +266,11 @@ func (a *abnormalTask) AdminAbandon(ctx context.Context, req *AdminTaskReq) (boo
 		HandleType: opAbandon,
 		HandleDesc: "作废",
 	}
-	a.operationRecord.AddOperationRecord(ctx, instance, tasks[0], handleTaskModel)
+	var task *client.ProcessTask
+	if len(tasks) > 0 {
+		task = tasks[0]
+	}
+	a.operationRecord.AddOperationRecord(ctx, instance, task, handleTaskModel)
 
 	return true, nil
 }
@@ -257,6 +295,9 @@ func (a *abnormalTask) AdminDeliverTask(ctx context.Context, req *AdminTaskReq,
 	if flowInstanceEntity == nil {
 		return false, error2.NewErrorWithString(error2.Internal, "Can not find flow instance data ")
 	}
+	if err = checkNotSuspended(flowInstanceEntity); err != nil {
+		return false, err
+	}
 
 	task := resp.Data[0]
 
@@ -296,7 +337,7 @@ func (a *abnormalTask) AdminGetTaskForm(ctx context.Context, req *AdminTaskReq)
 	if instance == nil {
 		return nil, error2.NewErrorWithString(error2.Internal, "Can not find flow instance data ")
 	}
-	flow, err := a.flowRepo.FindByID(a.db, instance.FlowID)
+	flow, err := a.flow.GetInstanceFlow(ctx, instance)
 	if err != nil {
 		return nil, err
 	}
@@ -323,6 +364,88 @@ func (a *abnormalTask) AdminGetTaskForm(ctx context.Context, req *AdminTaskReq)
 	}, nil
 }
 
+// AdminContinueNode complete the automatic node paused by failure, the flow goes on as if the node was done
+func (a *abnormalTask) AdminContinueNode(ctx context.Context, req *AdminNodeReq) (bool, error) {
+	abnormal, instance, err := a.checkNode(ctx, req.ID)
+	if err != nil {
+		return false, err
+	}
+	processInstance, err := a.processAPI.GetInstanceByID(ctx, instance.ProcessInstanceID)
+	if err != nil {
+		return false, err
+	}
+	if processInstance == nil {
+		return false, error2.NewErrorWithString(error2.Internal, "Can not find process instance ")
+	}
+	params, err := a.flow.GetInstanceVariableValues(ctx, instance)
+	if err != nil {
+		return false, err
+	}
+	err = a.processAPI.CompleteNode(ctx, &client.CompleteNodeReq{
+		ProcessID:   processInstance.ProcID,
+		InstanceID:  instance.ProcessInstanceID,
+		NodeDefKey:  abnormal.TaskDefKey,
+		ExecutionID: abnormal.ExecutionID,
+		Params:      a.flow.FormatFormValue(instance, params),
+		UserID:      pkg.STDUserID(ctx),
+	})
+	if err != nil {
+		return false, err
+	}
+	if err = a.abnormalTaskRepo.Update(a.db, abnormal.ID, map[string]interface{}{
+		"status":      1,
+		"modifier_id": pkg.STDUserID(ctx),
+	}); err != nil {
+		return false, err
+	}
+
+	// Add operation record
+	handleTaskModel := &models.HandleTaskModel{
+		HandleType: opContinue,
+		HandleDesc: "继续执行“" + abnormal.TaskName + "”",
+	}
+	a.operationRecord.AddOperationRecord(ctx, instance, nil, handleTaskModel)
+
+	return true, nil
+}
+
+// AdminAbandonNode abandon the instance paused at the automatic node
+func (a *abnormalTask) AdminAbandonNode(ctx context.Context, req *AdminNodeReq) (bool, error) {
+	_, instance, err := a.checkNode(ctx, req.ID)
+	if err != nil {
+		return false, err
+	}
+	return a.abandon(ctx, instance)
+}
+
+// checkNode the abnormal task must be an unhandled paused node of an ongoing instance the user administrates
+func (a *abnormalTask) checkNode(ctx context.Context, id string) (*models.AbnormalTask, *models.Instance, error) {
+	abnormal, err := a.abnormalTaskRepo.FindByID(a.db, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if abnormal == nil || abnormal.Status != 0 || abnormal.ExecutionID == "" {
+		return nil, nil, error2.NewErrorWithString(error2.Internal, "is not abnormal node")
+	}
+	instance, err := a.instanceRepo.GetEntityByProcessInstanceID(a.db, abnormal.ProcessInstanceID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if instance == nil {
+		return nil, nil, error2.NewErrorWithString(error2.Internal, "Can not find flow instance data ")
+	}
+	if err = checkNotSuspended(instance); err != nil {
+		return nil, nil, err
+	}
+	if !IsFlowOngoing(instance.Status) {
+		return nil, nil, error2.NewErrorWithString(error2.Internal, "flow instance is finished ")
+	}
+	if err = checkAppAdmin(ctx, a.appCenterAPI, instance.AppID); err != nil {
+		return nil, nil, err
+	}
+	return abnormal, instance, nil
+}
+
 func (a *abnormalTask) check(ctx context.Context, processInstanceID string, taskID string) (*client.GetTasksResp, bool, error) {
 	if !a.isUnhandleAbnormalTask(processInstanceID, taskID) {
 		return nil, false, error2.NewErrorWithString(error2.Internal, "is not abnormal task")
@@ -368,3 +491,8 @@ type AdminTaskReq struct {
 	ProcessInstanceID string
 	TaskID            string
 }
+
+// AdminNodeReq admin req params on a paused automatic node
+type AdminNodeReq struct {
+	ID string // abnormal task id
+}
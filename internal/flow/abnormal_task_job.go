@@ -0,0 +1,208 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package flow
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/quanxiang-cloud/flow/internal/models"
+	"github.com/quanxiang-cloud/flow/pkg"
+	"github.com/quanxiang-cloud/flow/pkg/misc/error2"
+	"github.com/quanxiang-cloud/flow/pkg/misc/logger"
+)
+
+const (
+	// abnormalTaskBatchMax max abnormal tasks handled by one job
+	abnormalTaskBatchMax = 5000
+	// abnormalTaskMessageLength length of handle_message
+	abnormalTaskMessageLength = 500
+
+	abnormalTaskSuccess = "SUCCESS"
+	abnormalTaskFail    = "FAIL"
+)
+
+// BatchAbnormalTaskReq handle the abnormal tasks of the ID list or the filter in one job
+type BatchAbnormalTaskReq struct {
+	models.AbnormalTaskFilter
+
+	Operation     string   `json:"operation" binding:"required"` // STEP_BACK、SEND_BACK、ABANDON、DELIVER
+	TaskDefKey    string   `json:"taskDefKey"`                   // 回退的节点
+	HandleUserIDs []string `json:"handleUserIds"`                // 转交的处理人
+	Remark        string   `json:"remark"`
+}
+
+// BatchAbnormalTaskResp batch abnormal task resp
+type BatchAbnormalTaskResp struct {
+	JobID string `json:"jobId"`
+	Total int    `json:"total"`
+}
+
+// AbnormalTaskJobReq abnormal task job req
+type AbnormalTaskJobReq struct {
+	JobID string `json:"jobId" binding:"required"`
+}
+
+// BatchHandle create a job handling the abnormal tasks asynchronously
+func (a *abnormalTask) BatchHandle(ctx context.Context, req *BatchAbnormalTaskReq) (*BatchAbnormalTaskResp, error) {
+	switch req.Operation {
+	case opStepBack:
+		if req.TaskDefKey == "" {
+			return nil, error2.NewErrorWithString(error2.ErrParams, "event id is nil")
+		}
+	case opDeliver:
+		if len(req.HandleUserIDs) != 1 {
+			return nil, error2.NewErrorWithString(error2.ErrParams, "Deliver user requried ")
+		}
+	case opSendBack, opAbandon:
+	default:
+		return nil, error2.NewErrorWithString(error2.ErrParams, "operation must be STEP_BACK、SEND_BACK、ABANDON or DELIVER")
+	}
+	filter := req.AbnormalTaskFilter
+	if len(filter.IDs) == 0 && filter.AppID == "" && filter.FlowID == "" && filter.Reason == "" && filter.StartTime == "" && filter.EndTime == "" {
+		return nil, error2.NewErrorWithString(error2.ErrParams, "ids or filter is required")
+	}
+
+	appIDs, err := a.appCenterAPI.GetAdminAppIDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(appIDs) == 0 {
+		return nil, error2.NewErrorWithString(error2.Internal, "No permission to handle abnormal tasks ")
+	}
+	filter.AdminAppIDs = appIDs
+	filter.Limit = abnormalTaskBatchMax + 1
+	tasks, err := a.abnormalTaskRepo.FindUnhandled(a.db, &filter)
+	if err != nil {
+		return nil, err
+	}
+	if len(tasks) == 0 {
+		return nil, error2.NewErrorWithString(error2.ErrParams, "no abnormal task matched")
+	}
+	if len(tasks) > abnormalTaskBatchMax {
+		return nil, error2.NewErrorWithString(error2.ErrParams, "too many abnormal tasks in one job")
+	}
+
+	params, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	userID := pkg.STDUserID(ctx)
+	job := &models.AbnormalTaskJob{
+		Operation: req.Operation,
+		Params:    string(params),
+		Status:    models.AbnormalTaskJobRunning,
+		Total:     len(tasks),
+		BaseModel: models.BaseModel{
+			CreatorID:  userID,
+			ModifierID: userID,
+		},
+	}
+	if err := a.abnormalTaskJobRepo.Create(a.db, job); err != nil {
+		return nil, err
+	}
+
+	go a.runJob(ctx, job, tasks, req)
+
+	return &BatchAbnormalTaskResp{
+		JobID: job.ID,
+		Total: job.Total,
+	}, nil
+}
+
+// BatchJob progress of the job
+func (a *abnormalTask) BatchJob(ctx context.Context, req *AbnormalTaskJobReq) (*models.AbnormalTaskJob, error) {
+	job, err := a.abnormalTaskJobRepo.FindByID(a.db, req.JobID)
+	if err != nil {
+		return nil, err
+	}
+	if job == nil {
+		return nil, error2.NewErrorWithString(error2.ErrParams, "job not exist")
+	}
+	return job, nil
+}
+
+func (a *abnormalTask) runJob(ctx context.Context, job *models.AbnormalTaskJob, tasks []*models.AbnormalTask, req *BatchAbnormalTaskReq) {
+	defer func() {
+		if err := recover(); err != nil {
+			logger.Logger.Errorw("abnormal task job panic", "jobID", job.ID, "err", err)
+		}
+		err := a.abnormalTaskJobRepo.Update(a.db, job.ID, map[string]interface{}{
+			"status":        models.AbnormalTaskJobFinished,
+			"processed":     job.Processed,
+			"success_count": job.SuccessCount,
+			"fail_count":    job.FailCount,
+		})
+		if err != nil {
+			logger.Logger.Error(err)
+		}
+	}()
+
+	for _, task := range tasks {
+		result, message := abnormalTaskSuccess, ""
+		if err := a.handleOne(ctx, task, req); err != nil {
+			result, message = abnormalTaskFail, err.Error()
+			if len(message) > abnormalTaskMessageLength {
+				message = message[:abnormalTaskMessageLength]
+			}
+			job.FailCount++
+		} else {
+			job.SuccessCount++
+		}
+		job.Processed++
+
+		err := a.abnormalTaskRepo.Update(a.db, task.ID, map[string]interface{}{
+			"job_id":         job.ID,
+			"handle_result":  result,
+			"handle_message": message,
+			"modifier_id":    job.CreatorID,
+		})
+		if err != nil {
+			logger.Logger.Error(err)
+		}
+		err = a.abnormalTaskJobRepo.Update(a.db, job.ID, map[string]interface{}{
+			"processed":     job.Processed,
+			"success_count": job.SuccessCount,
+			"fail_count":    job.FailCount,
+		})
+		if err != nil {
+			logger.Logger.Error(err)
+		}
+	}
+}
+
+func (a *abnormalTask) handleOne(ctx context.Context, task *models.AbnormalTask, req *BatchAbnormalTaskReq) error {
+	adminTaskReq := &AdminTaskReq{
+		ProcessInstanceID: task.ProcessInstanceID,
+		TaskID:            task.TaskID,
+	}
+	var err error
+	switch req.Operation {
+	case opStepBack:
+		_, err = a.AdminStepBack(ctx, adminTaskReq, &models.HandleTaskModel{
+			TaskDefKey: req.TaskDefKey,
+			Remark:     req.Remark,
+		})
+	case opSendBack:
+		_, err = a.AdminSendBack(ctx, adminTaskReq)
+	case opAbandon:
+		_, err = a.AdminAbandon(ctx, adminTaskReq)
+	case opDeliver:
+		_, err = a.AdminDeliverTask(ctx, adminTaskReq, &models.HandleTaskModel{
+			HandleUserIDs: req.HandleUserIDs,
+			Remark:        req.Remark,
+		})
+	}
+	return err
+}
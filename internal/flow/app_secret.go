@@ -0,0 +1,185 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package flow
+
+import (
+	"context"
+
+	"github.com/quanxiang-cloud/flow/internal/models"
+	"github.com/quanxiang-cloud/flow/internal/models/mysql"
+	"github.com/quanxiang-cloud/flow/internal/server/options"
+	"github.com/quanxiang-cloud/flow/pkg"
+	"github.com/quanxiang-cloud/flow/pkg/client"
+	"github.com/quanxiang-cloud/flow/pkg/config"
+	"github.com/quanxiang-cloud/flow/pkg/misc/error2"
+	"github.com/quanxiang-cloud/flow/pkg/utils"
+	"gorm.io/gorm"
+)
+
+const (
+	appSecretBytes     = 32
+	appSecretMinLength = 16
+)
+
+// AppSecret service, signing secrets of the app's outbound webhooks
+type AppSecret interface {
+	Save(ctx context.Context, req *SaveAppSecretReq) (*AppSecretResp, error)
+	Rotate(ctx context.Context, req *AppSecretReq) (*AppSecretResp, error)
+	Revoke(ctx context.Context, req *AppSecretReq) (bool, error)
+	Info(ctx context.Context, req *AppSecretReq) (*AppSecretResp, error)
+}
+
+type appSecret struct {
+	db            *gorm.DB
+	appSecretRepo models.AppSecretRepo
+	appCenterAPI  client.AppCenter
+}
+
+// NewAppSecret init
+func NewAppSecret(conf *config.Configs, opts ...options.Options) (AppSecret, error) {
+	s := &appSecret{
+		appSecretRepo: mysql.NewAppSecretRepo(),
+		appCenterAPI:  client.NewAppCenter(conf),
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s, nil
+}
+
+// SetDB set db
+func (s *appSecret) SetDB(db *gorm.DB) {
+	s.db = db
+}
+
+// AppSecretReq app secret req
+type AppSecretReq struct {
+	AppID string `json:"appID" binding:"required"`
+}
+
+// SaveAppSecretReq save app secret req, a random secret is generated if secret is empty
+type SaveAppSecretReq struct {
+	AppID  string `json:"appID" binding:"required"`
+	Secret string `json:"secret"`
+}
+
+// AppSecretResp app secret resp, the plain secret is only returned when it is saved or rotated
+type AppSecretResp struct {
+	ID         string `json:"id"`
+	AppID      string `json:"appID"`
+	Secret     string `json:"secret"`
+	Status     string `json:"status"`
+	CreateTime string `json:"createTime"`
+}
+
+// Save store a secret for the app, the former one is revoked at once without overlap
+func (s *appSecret) Save(ctx context.Context, req *SaveAppSecretReq) (*AppSecretResp, error) {
+	if err := checkAppAdmin(ctx, s.appCenterAPI, req.AppID); err != nil {
+		return nil, err
+	}
+	if req.Secret == "" {
+		secret, err := utils.RandomSecret(appSecretBytes)
+		if err != nil {
+			return nil, err
+		}
+		req.Secret = secret
+	}
+	if len(req.Secret) < appSecretMinLength {
+		return nil, error2.NewErrorWithString(error2.ErrParams, "secret is too short")
+	}
+	return s.replace(ctx, req.AppID, req.Secret)
+}
+
+// Rotate replace the active secret of the app with a random one
+func (s *appSecret) Rotate(ctx context.Context, req *AppSecretReq) (*AppSecretResp, error) {
+	if err := checkAppAdmin(ctx, s.appCenterAPI, req.AppID); err != nil {
+		return nil, err
+	}
+	active, err := s.appSecretRepo.FindActiveByAppID(s.db, req.AppID)
+	if err != nil {
+		return nil, err
+	}
+	if active == nil {
+		return nil, error2.NewErrorWithString(error2.ErrParams, "app has no active secret")
+	}
+	secret, err := utils.RandomSecret(appSecretBytes)
+	if err != nil {
+		return nil, err
+	}
+	return s.replace(ctx, req.AppID, secret)
+}
+
+// Revoke revoke the active secret, webhooks of the app are no longer signed
+func (s *appSecret) Revoke(ctx context.Context, req *AppSecretReq) (bool, error) {
+	if err := checkAppAdmin(ctx, s.appCenterAPI, req.AppID); err != nil {
+		return false, err
+	}
+	if err := s.appSecretRepo.RevokeByAppID(s.db, req.AppID, pkg.STDUserID(ctx)); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Info active secret of the app, the secret is masked
+func (s *appSecret) Info(ctx context.Context, req *AppSecretReq) (*AppSecretResp, error) {
+	if err := checkAppAdmin(ctx, s.appCenterAPI, req.AppID); err != nil {
+		return nil, err
+	}
+	active, err := s.appSecretRepo.FindActiveByAppID(s.db, req.AppID)
+	if err != nil || active == nil {
+		return nil, err
+	}
+	return &AppSecretResp{
+		ID:         active.ID,
+		AppID:      active.AppID,
+		Secret:     active.Secret[:4] + "****",
+		Status:     active.Status,
+		CreateTime: active.CreateTime,
+	}, nil
+}
+
+func (s *appSecret) replace(ctx context.Context, appID string, secret string) (*AppSecretResp, error) {
+	userID := pkg.STDUserID(ctx)
+	entity := &models.AppSecret{
+		AppID:  appID,
+		Secret: secret,
+		Status: models.AppSecretActive,
+		BaseModel: models.BaseModel{
+			CreatorID:  userID,
+			ModifierID: userID,
+		},
+	}
+
+	tx := s.db.Begin()
+	if err := s.appSecretRepo.RevokeByAppID(tx, appID, userID); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := s.appSecretRepo.Create(tx, entity); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	return &AppSecretResp{
+		ID:         entity.ID,
+		AppID:      entity.AppID,
+		Secret:     entity.Secret,
+		Status:     entity.Status,
+		CreateTime: entity.CreateTime,
+	}, nil
+}
@@ -0,0 +1,259 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package flow
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+
+	"github.com/quanxiang-cloud/flow/internal/convert"
+	"github.com/quanxiang-cloud/flow/internal/models"
+	"github.com/quanxiang-cloud/flow/pkg"
+	"github.com/quanxiang-cloud/flow/pkg/client"
+	"github.com/quanxiang-cloud/flow/pkg/code"
+	"github.com/quanxiang-cloud/flow/pkg/misc/error2"
+	"github.com/quanxiang-cloud/flow/pkg/misc/logger"
+	"github.com/quanxiang-cloud/flow/pkg/utils"
+)
+
+// batchReviewMaxTasks max tasks handled by one batch review
+const batchReviewMaxTasks = 200
+
+// BatchReviewReq approve or refuse tasks of the waiting list with one remark
+type BatchReviewReq struct {
+	HandleType string            `json:"handleType" binding:"required"`
+	Remark     string            `json:"remark"`
+	Tasks      []BatchReviewTask `json:"tasks" binding:"required"`
+}
+
+// BatchReviewTask task of the batch
+type BatchReviewTask struct {
+	ProcessInstanceID string `json:"processInstanceID"`
+	TaskID            string `json:"taskID"`
+}
+
+// BatchReviewResult result of one task
+type BatchReviewResult struct {
+	ProcessInstanceID string `json:"processInstanceID"`
+	TaskID            string `json:"taskID"`
+	Success           bool   `json:"success"`
+	Message           string `json:"message"`
+}
+
+// BatchReviewResp batch review resp
+type BatchReviewResp struct {
+	SuccessCount int                  `json:"successCount"`
+	FailCount    int                  `json:"failCount"`
+	Results      []*BatchReviewResult `json:"results"`
+}
+
+// BatchReviewTask each task is checked and reviewed on its own, a failed one does not stop the others
+func (i *instance) BatchReviewTask(ctx context.Context, req *BatchReviewReq) (*BatchReviewResp, error) {
+	if req.HandleType != Agree && req.HandleType != Refuse {
+		return nil, error2.NewErrorWithString(error2.ErrParams, "Handle type must be agree or refuse")
+	}
+	if len(req.Tasks) == 0 {
+		return nil, error2.NewErrorWithString(error2.ErrParams, "tasks is empty")
+	}
+	if len(req.Tasks) > batchReviewMaxTasks {
+		return nil, error2.NewErrorWithString(error2.ErrParams, "too many tasks in one batch")
+	}
+
+	resp := &BatchReviewResp{
+		Results: make([]*BatchReviewResult, 0, len(req.Tasks)),
+	}
+	for _, t := range req.Tasks {
+		result := &BatchReviewResult{
+			ProcessInstanceID: t.ProcessInstanceID,
+			TaskID:            t.TaskID,
+		}
+		if err := i.batchReviewOne(ctx, t, req); err != nil {
+			logger.Logger.Errorw("batch review task failed", "processInstanceID", t.ProcessInstanceID, "taskID", t.TaskID, "err", err.Error())
+			result.Message = err.Error()
+			resp.FailCount++
+		} else {
+			result.Success = true
+			resp.SuccessCount++
+		}
+		resp.Results = append(resp.Results, result)
+	}
+	return resp, nil
+}
+
+func (i *instance) batchReviewOne(ctx context.Context, t BatchReviewTask, req *BatchReviewReq) error {
+	task, err := i.processAPI.CheckActiveTask(ctx, t.ProcessInstanceID, t.TaskID, pkg.STDUserID(ctx))
+	if err != nil {
+		return err
+	}
+	if task == nil {
+		return error2.NewError(code.TaskCannotFind)
+	}
+	if task.Desc != convert.ReviewTask {
+		return error2.NewErrorWithString(error2.ErrParams, "只有审批任务可以批量处理")
+	}
+
+	instance, err := i.instanceRepo.GetEntityByProcessInstanceID(i.db, t.ProcessInstanceID)
+	if err != nil {
+		return err
+	}
+	if instance == nil {
+		return error2.NewErrorWithString(error2.Internal, "Can not find flow instance data ")
+	}
+	if err = checkNotSuspended(instance); err != nil {
+		return err
+	}
+	flow, err := i.flow.GetInstanceFlow(ctx, instance)
+	if err != nil {
+		return err
+	}
+	if flow == nil {
+		return error2.NewErrorWithString(error2.Internal, "Can not find flow data ")
+	}
+	shape, err := convert.GetShapeByTaskDefKey(flow.BpmnText, task.NodeDefKey)
+	if err != nil {
+		return err
+	}
+
+	// 按钮权限
+	operatorPermission := &convert.OperatorPermissionModel{}
+	if obj := shape.Data.BusinessData["operatorPermission"]; obj != nil {
+		marshal, err := json.Marshal(obj)
+		if err == nil {
+			err = json.Unmarshal(marshal, operatorPermission)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if err := checkOperatorPermission(operatorPermission, req.HandleType, req.Remark); err != nil {
+		return err
+	}
+
+	// 通过时必填字段需要有值，批量处理不提交表单数据
+	if req.HandleType == Agree {
+		missing, err := i.missingRequiredFields(ctx, flow, instance, task.NodeDefKey, shape)
+		if err != nil {
+			return err
+		}
+		if len(missing) > 0 {
+			return error2.NewErrorWithString(error2.ErrParams, "存在未填写的必填字段，请单独处理")
+		}
+	}
+
+	_, err = i.ReviewTask(ctx, t.ProcessInstanceID, t.TaskID, &models.HandleTaskModel{
+		HandleType: req.HandleType,
+		Remark:     req.Remark,
+	})
+	return err
+}
+
+// missingRequiredFields required fields the node can write, left empty after the submit values of the node are applied
+func (i *instance) missingRequiredFields(ctx context.Context, flow *models.Flow, instance *models.Instance, nodeDefKey string, shape *convert.ShapeModel) ([]string, error) {
+	fieldPermission := utils.ChangeObjectToMap(shape.Data.BusinessData["fieldPermission"])
+	if len(fieldPermission) == 0 {
+		return nil, nil
+	}
+	formSchema, err := i.formAPI.GetFormSchema(ctx, instance.AppID, instance.FormID)
+	if err != nil {
+		return nil, err
+	}
+	required := make(map[string]bool)
+	collectRequiredFields(utils.ChangeObjectToMap(formSchema), required)
+	if len(required) == 0 {
+		return nil, nil
+	}
+
+	formData, err := i.formAPI.GetFormData(ctx, client.FormDataConditionModel{
+		AppID:   instance.AppID,
+		TableID: instance.FormID,
+		DataID:  instance.FormInstanceID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	submit := i.task.FilterCanEditFormData(ctx, flow, instance, nodeDefKey, nil)
+	return emptyRequiredFields(required, fieldPermission, formData, utils.ChangeObjectToMap(submit["entity"])), nil
+}
+
+// checkOperatorPermission the handle type must be enabled on the node, with a remark if the node asks for one
+func checkOperatorPermission(permission *convert.OperatorPermissionModel, handleType string, remark string) error {
+	for _, item := range append(permission.Custom, permission.System...) {
+		if item.Value != handleType {
+			continue
+		}
+		if !item.Enabled {
+			return error2.NewErrorWithString(error2.ErrParams, "节点未开启该操作")
+		}
+		if item.ReasonRequired && remark == "" {
+			return error2.NewErrorWithString(error2.ErrParams, "该操作需要填写处理意见")
+		}
+	}
+	return nil
+}
+
+// collectRequiredFields collect keys of required fields in form schema, including fields in layout components
+func collectRequiredFields(schema map[string]interface{}, fields map[string]bool) {
+	if schema == nil || schema["properties"] == nil {
+		return
+	}
+	for key, value := range utils.ChangeObjectToMap(schema["properties"]) {
+		fieldMap := utils.ChangeObjectToMap(value)
+		if fieldMap == nil {
+			continue
+		}
+		if fieldMap["properties"] != nil { // 布局组件
+			collectRequiredFields(fieldMap, fields)
+			continue
+		}
+		if required, ok := fieldMap["required"].(bool); ok && required {
+			fields[key] = true
+		}
+	}
+}
+
+// emptyRequiredFields writable required fields without a value in the form data or the submit values
+func emptyRequiredFields(required map[string]bool, fieldPermission map[string]interface{}, formData map[string]interface{}, submit map[string]interface{}) []string {
+	missing := make([]string, 0)
+	for key := range required {
+		permission := &convert.FieldPermissionModel{}
+		marshal, err := json.Marshal(fieldPermission[key])
+		if err != nil || json.Unmarshal(marshal, permission) != nil {
+			continue
+		}
+		if permission.XInternal.Permission&2 != 2 { // 不可写的字段由其他节点填写
+			continue
+		}
+		if !isEmptyValue(submit[key]) || !isEmptyValue(formData[key]) {
+			continue
+		}
+		missing = append(missing, key)
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+func isEmptyValue(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	case []interface{}:
+		return len(v) == 0
+	case map[string]interface{}:
+		return len(v) == 0
+	}
+	return false
+}
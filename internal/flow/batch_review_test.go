@@ -0,0 +1,77 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package flow
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/quanxiang-cloud/flow/internal/convert"
+)
+
+func Test_emptyRequiredFields(t *testing.T) {
+	schema := map[string]interface{}{
+		"properties": map[string]interface{}{
+			"a": map[string]interface{}{"required": true},
+			"b": map[string]interface{}{"required": true},
+			"layout": map[string]interface{}{
+				"properties": map[string]interface{}{
+					"c": map[string]interface{}{"required": true},
+					"d": map[string]interface{}{},
+				},
+			},
+			"e": map[string]interface{}{"required": true},
+		},
+	}
+	required := make(map[string]bool)
+	collectRequiredFields(schema, required)
+	if want := map[string]bool{"a": true, "b": true, "c": true, "e": true}; !reflect.DeepEqual(required, want) {
+		t.Fatalf("collectRequiredFields() = %v, want %v", required, want)
+	}
+
+	writable := map[string]interface{}{"x-internal": map[string]interface{}{"permission": 3}}
+	readonly := map[string]interface{}{"x-internal": map[string]interface{}{"permission": 1}}
+	fieldPermission := map[string]interface{}{"a": writable, "b": writable, "c": writable, "e": readonly}
+	formData := map[string]interface{}{"a": "filled", "b": ""}
+	submit := map[string]interface{}{"c": "submit value"}
+
+	got := emptyRequiredFields(required, fieldPermission, formData, submit)
+	if want := []string{"b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("emptyRequiredFields() = %v, want %v", got, want)
+	}
+}
+
+func Test_checkOperatorPermission(t *testing.T) {
+	permission := &convert.OperatorPermissionModel{
+		System: []convert.OperatorPermissionItemModel{
+			{Value: Agree, Enabled: true},
+			{Value: Refuse, Enabled: true, ReasonRequired: true},
+		},
+		Custom: []convert.OperatorPermissionItemModel{
+			{Value: opDeliver, Enabled: false},
+		},
+	}
+	if err := checkOperatorPermission(permission, Agree, ""); err != nil {
+		t.Errorf("agree: %v", err)
+	}
+	if err := checkOperatorPermission(permission, Refuse, ""); err == nil {
+		t.Errorf("refuse without remark should fail")
+	}
+	if err := checkOperatorPermission(permission, Refuse, "no"); err != nil {
+		t.Errorf("refuse with remark: %v", err)
+	}
+	if err := checkOperatorPermission(permission, opDeliver, ""); err == nil {
+		t.Errorf("disabled operation should fail")
+	}
+}
@@ -16,8 +16,11 @@ package callback_tasks
 import (
 	"context"
 	flow2 "github.com/quanxiang-cloud/flow/internal/flow"
+	"github.com/quanxiang-cloud/flow/internal/flow/notifier"
 	"github.com/quanxiang-cloud/flow/internal/models"
 	"github.com/quanxiang-cloud/flow/pkg/client"
+	"github.com/quanxiang-cloud/flow/pkg/misc/logger"
+	"github.com/quanxiang-cloud/flow/pkg/misc/time2"
 	"gorm.io/gorm"
 )
 
@@ -40,4 +43,44 @@ type C struct {
 	flow                   flow2.Flow
 	instance               flow2.Instance
 	operationRecord        flow2.OperationRecord
+	subProcess             flow2.SubProcess
+	workCalendar           flow2.WorkCalendar
+	messageTemplate        flow2.MessageTemplate
+	notifier               notifier.Registry
+	identityAPI            client.Identity
+	abnormalTaskRepo       models.AbnormalTaskRepo
+	homeHost               string
+}
+
+// postponed 实例挂起中，或者回调已随实例恢复顺延，本次不执行
+func (c *C) postponed(callback *models.DispatcherCallback) bool {
+	if callback.ExecTime != "" {
+		execTime, err := time2.ISO8601ToUnix(callback.ExecTime)
+		if err == nil && execTime-time2.NowUnix() > 60 {
+			return true
+		}
+	}
+	if callback.ProcessInstanceID == "" {
+		return false
+	}
+	instance, err := c.instanceRepo.GetEntityByProcessInstanceID(c.db, callback.ProcessInstanceID)
+	if err != nil {
+		logger.Logger.Error("get flow instance err,", err)
+		return false
+	}
+	return instance != nil && instance.Status == flow2.Suspended
+}
+
+// renderMessage 按模板类型生成消息标题和内容，没有模板时使用默认标题，内容为默认前缀加任务链接
+func (c *C) renderMessage(ctx context.Context, templateType string, instance *models.Instance, task *client.ProcessTask, title string, prefix string) (string, string) {
+	if c.messageTemplate != nil {
+		rendered, err := c.messageTemplate.RenderByType(ctx, templateType, instance, task)
+		if err != nil {
+			logger.Logger.Error("render message template err,", err)
+		}
+		if rendered != nil {
+			return rendered.Title, rendered.Content
+		}
+	}
+	return title, prefix + c.homeHost + "approvals/" + instance.ProcessInstanceID + "/" + task.ID + "/WAIT_HANDLE_PAGE"
 }
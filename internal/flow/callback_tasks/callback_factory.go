@@ -54,7 +54,12 @@ func InitCallbacks(conf *config.Configs, opts ...options.Options) error {
 		return err
 	}
 
-	PackCallback(d, u)
+	w, err := withWebhook(conf, opts...)
+	if err != nil {
+		return err
+	}
+
+	PackCallback(d, u, w)
 	return nil
 }
 
@@ -80,3 +85,14 @@ func withUrge(conf *config.Configs, opts ...options.Options) (callbackFunc, erro
 
 	}, nil
 }
+
+// withWebhook 返回webhook回调超时对象
+func withWebhook(conf *config.Configs, opts ...options.Options) (callbackFunc, error) {
+	w, err := NewWebhook(conf, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return func(m map[string]CallBackInterface) {
+		m[convert.CallbackOfWebhook] = w
+	}, nil
+}
@@ -79,6 +79,9 @@ func (u *Delay) Execute(ctx context.Context, code *string) error {
 	if err != nil {
 		return err
 	}
+	if data == nil || u.postponed(data) {
+		return nil
+	}
 	req := client.CompleteNodeReq{}
 	if err := json.Unmarshal([]byte(data.OtherInfo), &req); err != nil {
 		return err
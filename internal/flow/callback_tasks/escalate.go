@@ -0,0 +1,195 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package callback_tasks
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/quanxiang-cloud/flow/internal/convert"
+	flow2 "github.com/quanxiang-cloud/flow/internal/flow"
+	"github.com/quanxiang-cloud/flow/internal/flow/notifier"
+	"github.com/quanxiang-cloud/flow/internal/models"
+	"github.com/quanxiang-cloud/flow/pkg/client"
+	"github.com/quanxiang-cloud/flow/pkg/misc/id2"
+	"github.com/quanxiang-cloud/flow/pkg/misc/logger"
+	"github.com/quanxiang-cloud/flow/pkg/misc/time2"
+)
+
+// scheduleEscalation 任务超时后，按各级设置的时间注册升级回调
+func (u *Urge) scheduleEscalation(ctx context.Context, instance *models.Instance, timeRule convert.TaskTimeRuleModel, otherInfo *flow2.DispatcherCallOtherInfo) error {
+	now := time2.Now()
+	for level, escalation := range timeRule.WhenTimeout.Levels {
+		info := *otherInfo
+		info.Level = level
+		infoJSON, _ := json.Marshal(&info)
+
+		execTime := u.workCalendar.AddTime(ctx, instance.AppID, now, timeRule.TimeType, escalation.DayHourMinuteModel)
+		id := id2.GenID()
+		dc := models.DispatcherCallback{
+			BaseModel: models.BaseModel{
+				ID: id,
+			},
+			Type:              flow2.ESCALATE,
+			OtherInfo:         string(infoJSON),
+			ProcessInstanceID: instance.ProcessInstanceID,
+			TaskDefKey:        otherInfo.TaskDefKey,
+			ExecTime:          execTime,
+		}
+		if err := u.dispatcherCallbackRepo.Create(u.db, &dc); err != nil {
+			return err
+		}
+		err := u.dispatcherAPI.TakePost(ctx, client.TaskPostReq{
+			Code:    "flow:" + id,
+			Type:    1,
+			TimeBar: execTime,
+			State:   1,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// escalation 执行一级超时升级，任务已处理时不再执行
+func (u *Urge) escalation(ctx context.Context, callback models.DispatcherCallback) error {
+	otherInfo := &flow2.DispatcherCallOtherInfo{}
+	err := json.Unmarshal([]byte(callback.OtherInfo), otherInfo)
+	if err != nil {
+		return err
+	}
+	instance, err := u.instanceRepo.FindByID(u.db, otherInfo.FlowInstanceID)
+	if err != nil {
+		return err
+	}
+	if instance == nil || !flow2.IsFlowOngoing(instance.Status) {
+		return nil
+	}
+	task, err := u.activeTask(ctx, instance.ProcessInstanceID, otherInfo.TaskID)
+	if err != nil || task == nil {
+		return err
+	}
+
+	flow, err := u.flow.GetInstanceFlow(ctx, instance)
+	if err != nil || flow == nil {
+		return err
+	}
+	s, err := convert.GetShapeByTaskDefKey(flow.BpmnText, otherInfo.TaskDefKey)
+	if err != nil {
+		return err
+	}
+	basicConfig := convert.GetTaskBasicConfigModel(s)
+	if basicConfig == nil {
+		return nil
+	}
+	levels := basicConfig.TimeRule.WhenTimeout.Levels
+	channels := notifier.Channels(flow.NotifyChannels, notifier.EventTimeout)
+	if otherInfo.Level < 0 || otherInfo.Level >= len(levels) {
+		return nil
+	}
+
+	model := &models.HandleTaskModel{
+		HandleType: flow2.OpEscalate,
+	}
+	switch levels[otherInfo.Level].Action {
+	case convert.EscalateNotifySuperior:
+		superior, err := u.identityAPI.GetSuperior(ctx, task.Assignee)
+		if err != nil {
+			return err
+		}
+		if superior == "" {
+			logger.Logger.Warn("escalation: superior not found, task ", task.ID)
+			return nil
+		}
+		if err = u.sendEscalationMessage(ctx, channels, superior, instance, task, "您的下属有超时未处理的"+instance.Name+"流程审批，请督促处理："); err != nil {
+			logger.Logger.Error("send escalation message err,", err)
+		}
+		model.HandleDesc = "该节点超时未处理，已通知上级领导"
+		model.HandleUserIDs = []string{superior}
+	case convert.EscalateReassignLead:
+		lead, err := u.identityAPI.GetLeadOfDepartment(ctx, task.Assignee)
+		if err != nil {
+			return err
+		}
+		if lead == "" || lead == task.Assignee {
+			logger.Logger.Warn("escalation: lead of department not found, task ", task.ID)
+			return nil
+		}
+		if err = u.processAPI.SetAssignee(ctx, instance.ProcessInstanceID, task.ID, lead); err != nil {
+			return err
+		}
+		if err = u.sendEscalationMessage(ctx, channels, lead, instance, task, "您有超时转交的"+instance.Name+"流程的审批，请点击查看："); err != nil {
+			logger.Logger.Error("send escalation message err,", err)
+		}
+		model.HandleDesc = "该节点超时未处理，已转交部门负责人"
+		model.HandleUserIDs = []string{lead}
+	case convert.EscalateAbnormal:
+		abnormalTask := &models.AbnormalTask{
+			FlowInstanceID:    instance.ID,
+			ProcessInstanceID: instance.ProcessInstanceID,
+			TaskID:            task.ID,
+			TaskName:          task.Name,
+			TaskDefKey:        task.NodeDefKey,
+			Reason:            "节点超时未处理",
+			Status:            0,
+			BaseModel: models.BaseModel{
+				CreatorID:  "",
+				ModifyTime: time2.Now(),
+			},
+		}
+		if err = u.abnormalTaskRepo.Create(u.db, abnormalTask); err != nil {
+			return err
+		}
+		model.HandleDesc = "该节点超时未处理，已转为异常任务由管理员处理"
+	default:
+		return nil
+	}
+
+	return u.operationRecord.AddOperationRecord(ctx, instance, task, model)
+}
+
+// activeTask the task if it is still active
+func (u *Urge) activeTask(ctx context.Context, processInstanceID string, taskID string) (*client.ProcessTask, error) {
+	tasks, err := u.processAPI.GetTasksByInstanceID(ctx, processInstanceID)
+	if err != nil {
+		return nil, err
+	}
+	for _, task := range tasks {
+		if task.ID == taskID {
+			return task, nil
+		}
+	}
+	return nil, nil
+}
+
+func (u *Urge) sendEscalationMessage(ctx context.Context, channels []string, userID string, instance *models.Instance, task *client.ProcessTask, prefix string) error {
+	if len(channels) == 0 {
+		return nil
+	}
+	user, err := u.identityAPI.FindUserByID(ctx, userID)
+	if err != nil || user == nil {
+		return err
+	}
+	title, content := u.renderMessage(ctx, models.MessageTemplateTimeout, instance, task, "超时提醒", prefix)
+	u.notifier.Notify(ctx, channels, &notifier.Message{
+		Event:             notifier.EventTimeout,
+		ProcessInstanceID: instance.ProcessInstanceID,
+		TaskID:            task.ID,
+		Title:             title,
+		Content:           content,
+		Receivers:         []*client.UserInfoResp{user},
+	})
+	return nil
+}
@@ -18,6 +18,7 @@ import (
 	"encoding/json"
 	"github.com/quanxiang-cloud/flow/internal/convert"
 	flow2 "github.com/quanxiang-cloud/flow/internal/flow"
+	"github.com/quanxiang-cloud/flow/internal/flow/notifier"
 	"github.com/quanxiang-cloud/flow/internal/models"
 	"github.com/quanxiang-cloud/flow/internal/models/mysql"
 	"github.com/quanxiang-cloud/flow/internal/server/options"
@@ -34,6 +35,7 @@ const (
 	noDealWith   = "noDealWith"
 	autoDealWith = "autoDealWith"
 	jump         = "jump"
+	escalate     = "escalate"
 )
 
 // Urge info
@@ -54,6 +56,10 @@ func NewUrge(conf *config.Configs, opts ...options.Options) (*Urge, error) {
 	flow, _ := flow2.NewFlow(conf, opts...)
 	instance, _ := flow2.NewInstance(conf, opts...)
 	operationRecord, _ := flow2.NewOperationRecord(conf, opts...)
+	subProcess, _ := flow2.NewSubProcess(conf, opts...)
+	workCalendar, _ := flow2.NewWorkCalendar(conf, opts...)
+	messageTemplate, _ := flow2.NewMessageTemplate(conf, opts...)
+	registry, _ := notifier.NewRegistry(conf, opts...)
 
 	u.processAPI = client.NewProcess(conf)
 	u.formAPI = client.NewForm(conf)
@@ -66,6 +72,13 @@ func NewUrge(conf *config.Configs, opts ...options.Options) (*Urge, error) {
 	u.flow = flow
 	u.instance = instance
 	u.operationRecord = operationRecord
+	u.subProcess = subProcess
+	u.workCalendar = workCalendar
+	u.messageTemplate = messageTemplate
+	u.notifier = registry
+	u.identityAPI = client.NewIdentity(conf)
+	u.abnormalTaskRepo = mysql.NewAbnormalTaskRepo()
+	u.homeHost = conf.APIHost.HomeHost
 
 	for _, opt := range opts {
 		opt(&u)
@@ -94,7 +107,7 @@ func (u *Urge) TaskUrge(ctx context.Context, req *TaskUrgeModel) error {
 		return error2.NewErrorWithString(error2.Internal, "Can not find flow instance data ")
 	}
 
-	flowEntity, err := u.flowRepo.FindByID(u.db, flowInstanceEntity.FlowID)
+	flowEntity, err := u.flow.GetInstanceFlow(ctx, flowInstanceEntity)
 	if err != nil {
 		return err
 	}
@@ -113,6 +126,11 @@ func (u *Urge) TaskUrge(ctx context.Context, req *TaskUrgeModel) error {
 		return error2.NewErrorWithString(error2.Internal, "Can not urge this instance ")
 	}
 
+	policy := parseUrgePolicy(flowEntity.UrgePolicy)
+	if err = u.checkUrgePolicy(ctx, flowInstanceEntity.AppID, policy, tasks); err != nil {
+		return err
+	}
+
 	for _, task := range tasks {
 		// urges, err := u.urgeRepo.FindByTaskID(u.db, task.ID)
 		// if err != nil {
@@ -134,6 +152,15 @@ func (u *Urge) TaskUrge(ctx context.Context, req *TaskUrgeModel) error {
 		// return error2.NewErrorWithString(code.CannotRepeatUrge, code.CodeTable[code.CannotRepeatUrge])
 		// }
 	}
+
+	// 催办策略的渠道优先，否则使用流程配置的催办通知渠道
+	channels := notifier.Channels(flowEntity.NotifyChannels, notifier.EventUrge)
+	if policy != nil && len(policy.Channels) > 0 {
+		channels = policy.Channels
+	}
+	if len(channels) > 0 {
+		u.notifyUrge(ctx, channels, flowInstanceEntity, tasks)
+	}
 	return nil
 }
 
@@ -146,11 +173,16 @@ func (u *Urge) UrgingExecute(ctx context.Context, code string) error {
 	if err != nil {
 		return err
 	}
+	if d == nil || u.postponed(d) {
+		return nil
+	}
 	switch d.Type {
 	case flow2.URGE:
 		err = u.urge(ctx, *d)
 	case flow2.DEADLINE:
 		err = u.dealLine(ctx, *d)
+	case flow2.ESCALATE:
+		err = u.escalation(ctx, *d)
 	}
 	return err
 }
@@ -205,11 +237,11 @@ func (u *Urge) dealLine(ctx context.Context, callback models.DispatcherCallback)
 	}
 
 	instance, err := u.instanceRepo.FindByID(u.db, otherInfo.FlowInstanceID)
-	if err != nil {
+	if err != nil || instance == nil {
 		return err
 	}
-	flow, err := u.flowRepo.FindByID(u.db, instance.FlowID)
-	if err != nil {
+	flow, err := u.flow.GetInstanceFlow(ctx, instance)
+	if err != nil || flow == nil {
 		return err
 	}
 
@@ -268,6 +300,9 @@ func (u *Urge) dealLine(ctx context.Context, callback models.DispatcherCallback)
 			if err != nil {
 				return err
 			}
+			if err = u.subProcess.Finish(ctx, instance, res); err != nil {
+				return err
+			}
 		}
 	} else if timeOutType == jump {
 		params, err := u.instance.GetInstanceVariableValues(ctx, instance)
@@ -292,6 +327,8 @@ func (u *Urge) dealLine(ctx context.Context, callback models.DispatcherCallback)
 			Name:       s.Data.NodeData.Name,
 		}
 		u.operationRecord.AddOperationRecord(ctx, instance, task, model)
+	} else if timeOutType == escalate {
+		err = u.scheduleEscalation(ctx, instance, timeRule, otherInfo)
 	}
 	if err != nil {
 		tx.Rollback()
@@ -0,0 +1,130 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package callback_tasks
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/quanxiang-cloud/flow/internal/flow/notifier"
+	"github.com/quanxiang-cloud/flow/internal/models"
+	"github.com/quanxiang-cloud/flow/pkg/client"
+	"github.com/quanxiang-cloud/flow/pkg/code"
+	"github.com/quanxiang-cloud/flow/pkg/misc/error2"
+	"github.com/quanxiang-cloud/flow/pkg/misc/logger"
+	"github.com/quanxiang-cloud/flow/pkg/misc/time2"
+)
+
+// parseUrgePolicy urge policy of the flow, nil if not set
+func parseUrgePolicy(s string) *models.UrgePolicy {
+	if s == "" {
+		return nil
+	}
+	policy := &models.UrgePolicy{}
+	if err := json.Unmarshal([]byte(s), policy); err != nil {
+		logger.Logger.Error("bad urge policy,", err)
+		return nil
+	}
+	return policy
+}
+
+// checkUrgePolicy 免打扰时段、催办间隔及次数检查
+func (u *Urge) checkUrgePolicy(ctx context.Context, appID string, policy *models.UrgePolicy, tasks []*client.ProcessTask) error {
+	if policy == nil {
+		return nil
+	}
+	// 免打扰时段按应用工作日历的时区
+	clock := time.Now().In(u.workCalendar.Location(ctx, appID)).Format("15:04")
+	if inQuietHours(clock, policy.QuietStart, policy.QuietEnd) {
+		return error2.NewError(code.UrgeInQuietHours)
+	}
+	now := time2.Now()
+	if policy.MinInterval <= 0 && policy.MaxTimes <= 0 {
+		return nil
+	}
+	nowUnix, _ := time2.ISO8601ToUnix(now)
+	for _, task := range tasks {
+		urges, err := u.urgeRepo.FindByTaskID(u.db, task.ID)
+		if err != nil {
+			return err
+		}
+		if errCode := checkUrges(policy, urges, nowUnix); errCode != 0 {
+			return error2.NewError(errCode)
+		}
+	}
+	return nil
+}
+
+// checkUrges the error code if one more urge on the task violates the policy, 0 if not
+func checkUrges(policy *models.UrgePolicy, urges []*models.Urge, now int64) int64 {
+	if policy.MaxTimes > 0 && len(urges) >= policy.MaxTimes {
+		return code.UrgeExceedMaxTimes
+	}
+	if policy.MinInterval > 0 {
+		for _, urge := range urges {
+			createTime, err := time2.ISO8601ToUnix(urge.CreateTime)
+			if err == nil && now-createTime < int64(policy.MinInterval*60) {
+				return code.UrgeTooFrequent
+			}
+		}
+	}
+	return 0
+}
+
+// inQuietHours clock is in [start, end), the range may cross midnight
+func inQuietHours(clock string, start string, end string) bool {
+	if start == "" || end == "" || start == end {
+		return false
+	}
+	if start < end {
+		return clock >= start && clock < end
+	}
+	return clock >= start || clock < end
+}
+
+// notifyUrge 按催办渠道通知任务处理人
+func (u *Urge) notifyUrge(ctx context.Context, channels []string, instance *models.Instance, tasks []*client.ProcessTask) {
+	userIDs := make([]string, 0, len(tasks))
+	for _, task := range tasks {
+		if task.Assignee != "" {
+			userIDs = append(userIDs, task.Assignee)
+		}
+	}
+	if len(userIDs) == 0 {
+		return
+	}
+	users, err := u.identityAPI.FindUsersByIDs(ctx, userIDs)
+	if err != nil {
+		logger.Logger.Error("find users err,", err)
+		return
+	}
+
+	for _, task := range tasks {
+		user, ok := users[task.Assignee]
+		if !ok || user == nil {
+			continue
+		}
+		title, content := u.renderMessage(ctx, models.MessageTemplateUrge, instance, task, "催办提醒",
+			"您有被催办的"+instance.Name+"流程的审批，请尽快处理：")
+		u.notifier.Notify(ctx, channels, &notifier.Message{
+			Event:             notifier.EventUrge,
+			ProcessInstanceID: instance.ProcessInstanceID,
+			TaskID:            task.ID,
+			Title:             title,
+			Content:           content,
+			Receivers:         []*client.UserInfoResp{user},
+		})
+	}
+}
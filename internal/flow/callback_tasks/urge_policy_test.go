@@ -0,0 +1,67 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package callback_tasks
+
+import (
+	"testing"
+
+	"github.com/quanxiang-cloud/flow/internal/models"
+	"github.com/quanxiang-cloud/flow/pkg/code"
+	"github.com/quanxiang-cloud/flow/pkg/misc/time2"
+)
+
+func Test_inQuietHours(t *testing.T) {
+	tests := []struct {
+		clock, start, end string
+		want              bool
+	}{
+		{"12:00", "", "", false},
+		{"12:00", "12:00", "13:30", true},
+		{"13:30", "12:00", "13:30", false},
+		{"23:10", "22:00", "08:00", true},
+		{"07:59", "22:00", "08:00", true},
+		{"08:00", "22:00", "08:00", false},
+	}
+	for _, tt := range tests {
+		if got := inQuietHours(tt.clock, tt.start, tt.end); got != tt.want {
+			t.Errorf("inQuietHours(%s, %s, %s) = %v, want %v", tt.clock, tt.start, tt.end, got, tt.want)
+		}
+	}
+}
+
+func Test_checkUrges(t *testing.T) {
+	now := int64(1700000000)
+	urgeAt := func(ago int64) *models.Urge {
+		return &models.Urge{BaseModel: models.BaseModel{CreateTime: time2.UnixToISO8601(now - ago)}}
+	}
+	tests := []struct {
+		name   string
+		policy *models.UrgePolicy
+		urges  []*models.Urge
+		want   int64
+	}{
+		{"no limit", &models.UrgePolicy{}, []*models.Urge{urgeAt(1)}, 0},
+		{"too frequent", &models.UrgePolicy{MinInterval: 30}, []*models.Urge{urgeAt(600)}, code.UrgeTooFrequent},
+		{"interval passed", &models.UrgePolicy{MinInterval: 30}, []*models.Urge{urgeAt(3600)}, 0},
+		{"max times", &models.UrgePolicy{MaxTimes: 2}, []*models.Urge{urgeAt(7200), urgeAt(3600)}, code.UrgeExceedMaxTimes},
+		{"under max times", &models.UrgePolicy{MaxTimes: 2}, []*models.Urge{urgeAt(3600)}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := checkUrges(tt.policy, tt.urges, now); got != tt.want {
+				t.Errorf("checkUrges() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
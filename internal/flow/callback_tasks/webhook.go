@@ -0,0 +1,49 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package callback_tasks
+
+import (
+	"context"
+	"errors"
+	flow2 "github.com/quanxiang-cloud/flow/internal/flow"
+	"github.com/quanxiang-cloud/flow/internal/server/options"
+	"github.com/quanxiang-cloud/flow/pkg/config"
+	"strings"
+)
+
+// Webhook timeout of webhook nodes waiting for callback
+type Webhook struct {
+	webhook flow2.Webhook
+}
+
+// NewWebhook init
+func NewWebhook(conf *config.Configs, opts ...options.Options) (*Webhook, error) {
+	webhook, err := flow2.NewWebhook(conf, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &Webhook{
+		webhook: webhook,
+	}, nil
+}
+
+// Execute 执行
+// code: webhookCallback_{webhook_callback.id}
+func (w *Webhook) Execute(ctx context.Context, code *string) error {
+	c := strings.Split(*code, "_")
+	if len(c) != 2 {
+		return errors.New("bad code fmt")
+	}
+	return w.webhook.CallbackTimeout(ctx, c[1])
+}
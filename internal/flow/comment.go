@@ -38,7 +38,7 @@ type Comment interface {
 type comment struct {
 	db                    *gorm.DB
 	commentRepo           models.CommentRepo
-	flowRepo              models.FlowRepo
+	flow                  Flow
 	instanceRepo          models.InstanceRepo
 	processAPI            client.Process
 	commentAttachmentRepo models.CommentAttachmentRepo
@@ -47,9 +47,13 @@ type comment struct {
 
 // NewComment init
 func NewComment(conf *config.Configs, opts ...options.Options) (Comment, error) {
+	flow, err := NewFlow(conf, opts...)
+	if err != nil {
+		return nil, err
+	}
 	var c = &comment{
 		commentRepo:           mysql.NewCommentRepo(),
-		flowRepo:              mysql.NewFlowRepo(),
+		flow:                  flow,
 		instanceRepo:          mysql.NewInstanceRepo(),
 		processAPI:            client.NewProcess(conf),
 		commentAttachmentRepo: mysql.NewCommentAttachmentRepo(),
@@ -175,7 +179,7 @@ func (c *comment) verificationAuthority(ctx context.Context, flowInstanceID stri
 		return error2.NewErrorWithString(code.InvalidProcessID, " process model is nil")
 	}
 
-	flow, err := c.flowRepo.FindByID(c.db, instances[0].FlowID)
+	flow, err := c.flow.GetInstanceFlow(ctx, instances[0])
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,311 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package flow
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/quanxiang-cloud/flow/internal/convert"
+	"github.com/quanxiang-cloud/flow/internal/models"
+	"github.com/quanxiang-cloud/flow/internal/models/mysql"
+	"github.com/quanxiang-cloud/flow/internal/server/options"
+	"github.com/quanxiang-cloud/flow/pkg"
+	"github.com/quanxiang-cloud/flow/pkg/client"
+	"github.com/quanxiang-cloud/flow/pkg/config"
+	"github.com/quanxiang-cloud/flow/pkg/misc/error2"
+	"github.com/quanxiang-cloud/flow/pkg/misc/logger"
+	"github.com/quanxiang-cloud/flow/pkg/misc/time2"
+	"gorm.io/gorm"
+)
+
+// delegationMaxDepth max length of a delegation chain
+const delegationMaxDepth = 10
+
+// Delegation service, routing tasks of the absent user to the delegate
+type Delegation interface {
+	Save(ctx context.Context, req *SaveDelegationReq) (*models.Delegation, error)
+	Cancel(ctx context.Context, req *DelegationReq) (bool, error)
+	List(ctx context.Context, req *ListDelegationReq) ([]*models.Delegation, error)
+}
+
+type delegation struct {
+	db             *gorm.DB
+	delegationRepo models.DelegationRepo
+	identityAPI    client.Identity
+}
+
+// NewDelegation init
+func NewDelegation(conf *config.Configs, opts ...options.Options) (Delegation, error) {
+	d := &delegation{
+		delegationRepo: mysql.NewDelegationRepo(),
+		identityAPI:    client.NewIdentity(conf),
+	}
+
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d, nil
+}
+
+// SetDB set db
+func (d *delegation) SetDB(db *gorm.DB) {
+	d.db = db
+}
+
+// SaveDelegationReq save delegation req, tasks of all apps are delegated if appIDs is empty
+type SaveDelegationReq struct {
+	DelegateID string   `json:"delegateID" binding:"required"`
+	AppIDs     []string `json:"appIDs"`
+	StartTime  string   `json:"startTime" binding:"required"`
+	EndTime    string   `json:"endTime" binding:"required"`
+}
+
+// DelegationReq delegation req
+type DelegationReq struct {
+	ID string `json:"id" binding:"required"`
+}
+
+// ListDelegationReq list delegations of the current user
+type ListDelegationReq struct {
+	Status string `json:"status"`
+}
+
+// Save create a delegation of the current user
+func (d *delegation) Save(ctx context.Context, req *SaveDelegationReq) (*models.Delegation, error) {
+	userID := pkg.STDUserID(ctx)
+	if req.DelegateID == userID {
+		return nil, error2.NewErrorWithString(error2.ErrParams, "can not delegate to yourself")
+	}
+	startTime, err := toUTC(req.StartTime)
+	if err != nil {
+		return nil, error2.NewErrorWithString(error2.ErrParams, "invalid startTime")
+	}
+	endTime, err := toUTC(req.EndTime)
+	if err != nil {
+		return nil, error2.NewErrorWithString(error2.ErrParams, "invalid endTime")
+	}
+	if endTime <= startTime {
+		return nil, error2.NewErrorWithString(error2.ErrParams, "endTime must be after startTime")
+	}
+	delegate, err := d.identityAPI.FindUserByID(ctx, req.DelegateID)
+	if err != nil {
+		return nil, err
+	}
+	if delegate == nil || delegate.ID == "" {
+		return nil, error2.NewErrorWithString(error2.ErrParams, "delegate user not exist")
+	}
+
+	entity := &models.Delegation{
+		UserID:     userID,
+		DelegateID: req.DelegateID,
+		AppIDs:     strings.Join(req.AppIDs, ","),
+		StartTime:  startTime,
+		EndTime:    endTime,
+		Status:     models.DelegationActive,
+		BaseModel: models.BaseModel{
+			CreatorID:  userID,
+			ModifierID: userID,
+		},
+	}
+
+	// 同一时间段内的委托不能重叠，也不能形成循环
+	actives, err := d.delegationRepo.FindActive(d.db, startTime, endTime)
+	if err != nil {
+		return nil, err
+	}
+	edges := make(map[string][]string)
+	for _, e := range actives {
+		if !overlapApps(e.AppIDs, entity.AppIDs) {
+			continue
+		}
+		if e.UserID == userID {
+			return nil, error2.NewErrorWithString(error2.ErrParams, "delegation of the period already exists")
+		}
+		edges[e.UserID] = append(edges[e.UserID], e.DelegateID)
+	}
+	if delegationLoop(userID, req.DelegateID, edges) {
+		return nil, error2.NewErrorWithString(error2.ErrParams, "delegation would form a loop")
+	}
+
+	if err := d.delegationRepo.Create(d.db, entity); err != nil {
+		return nil, err
+	}
+	entity.DelegateName = delegate.UserName
+	return entity, nil
+}
+
+// Cancel cancel a delegation of the current user
+func (d *delegation) Cancel(ctx context.Context, req *DelegationReq) (bool, error) {
+	userID := pkg.STDUserID(ctx)
+	entity, err := d.delegationRepo.FindByID(d.db, req.ID)
+	if err != nil {
+		return false, err
+	}
+	if entity == nil || entity.UserID != userID {
+		return false, error2.NewErrorWithString(error2.ErrParams, "delegation not exist")
+	}
+	err = d.delegationRepo.Update(d.db, entity.ID, map[string]interface{}{
+		"status":      models.DelegationCanceled,
+		"modifier_id": userID,
+	})
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// List delegations of the current user
+func (d *delegation) List(ctx context.Context, req *ListDelegationReq) ([]*models.Delegation, error) {
+	entities, err := d.delegationRepo.FindByUserID(d.db, pkg.STDUserID(ctx))
+	if err != nil {
+		return nil, err
+	}
+	list := make([]*models.Delegation, 0, len(entities))
+	userIDs := make([]string, 0, len(entities))
+	for _, e := range entities {
+		if req.Status != "" && e.Status != req.Status {
+			continue
+		}
+		list = append(list, e)
+		userIDs = append(userIDs, e.DelegateID)
+	}
+	if len(userIDs) > 0 {
+		users, err := d.identityAPI.FindUsersByIDs(ctx, userIDs)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range list {
+			if user, ok := users[e.DelegateID]; ok && user != nil {
+				e.DelegateName = user.UserName
+			}
+		}
+	}
+	return list, nil
+}
+
+// GetTaskHandleDelegations handle users of the node with delegations applied, and the delegations used (delegator -> delegate)
+func (f *flow) GetTaskHandleDelegations(ctx context.Context, shape *convert.ShapeModel, flowInstanceEntity *models.Instance) ([]string, map[string]string) {
+	if shape.Data.BusinessData == nil {
+		return nil, nil
+	}
+	handleUserIds, _ := f.GetTaskHandleUserIDs2(ctx, convert.GetValueFromBusinessData(*shape, "basicConfig.approvePersons"), flowInstanceEntity)
+	delegates := f.activeDelegates(flowInstanceEntity.AppID)
+	used := make(map[string]string)
+	for _, userID := range handleUserIds {
+		if delegate := resolveDelegate(userID, delegates); delegate != userID {
+			used[userID] = delegate
+		}
+	}
+	return delegateUserIDs(handleUserIds, delegates), used
+}
+
+// DelegateUserIDs users away are replaced with their delegates, only used when tasks are assigned,
+// cc, letter and email receivers are resolved without delegations
+func (f *flow) DelegateUserIDs(ctx context.Context, appID string, userIDs []string) []string {
+	return delegateUserIDs(userIDs, f.activeDelegates(appID))
+}
+
+// activeDelegates delegator -> delegate of the delegations in use for the app
+func (f *flow) activeDelegates(appID string) map[string]string {
+	now := time2.Now()
+	actives, err := f.delegationRepo.FindActive(f.db, now, now)
+	if err != nil {
+		logger.Logger.Error(err)
+		return nil
+	}
+	delegates := make(map[string]string)
+	for _, e := range actives {
+		if _, ok := delegates[e.UserID]; !ok && e.Covers(appID) {
+			delegates[e.UserID] = e.DelegateID
+		}
+	}
+	return delegates
+}
+
+// resolveDelegate follow the delegation chain of the user, stopping before a loop
+func resolveDelegate(userID string, delegates map[string]string) string {
+	visited := map[string]bool{userID: true}
+	current := userID
+	for i := 0; i < delegationMaxDepth; i++ {
+		next, ok := delegates[current]
+		if !ok || visited[next] {
+			break
+		}
+		visited[next] = true
+		current = next
+	}
+	return current
+}
+
+// delegateUserIDs replace the users with their delegates
+func delegateUserIDs(userIDs []string, delegates map[string]string) []string {
+	if len(delegates) == 0 {
+		return userIDs
+	}
+	ret := make([]string, 0, len(userIDs))
+	exists := make(map[string]bool)
+	for _, userID := range userIDs {
+		delegate := resolveDelegate(userID, delegates)
+		if !exists[delegate] {
+			exists[delegate] = true
+			ret = append(ret, delegate)
+		}
+	}
+	return ret
+}
+
+// delegationLoop whether adding userID -> delegateID makes the delegate reach the user again
+func delegationLoop(userID string, delegateID string, edges map[string][]string) bool {
+	visited := make(map[string]bool)
+	stack := []string{delegateID}
+	for len(stack) > 0 {
+		current := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if current == userID {
+			return true
+		}
+		if visited[current] {
+			continue
+		}
+		visited[current] = true
+		stack = append(stack, edges[current]...)
+	}
+	return false
+}
+
+// overlapApps whether two app scopes share an app, empty means all apps
+func overlapApps(a string, b string) bool {
+	if a == "" || b == "" {
+		return true
+	}
+	for _, x := range strings.Split(a, ",") {
+		for _, y := range strings.Split(b, ",") {
+			if x == y {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// toUTC parse the ISO8601 time and format it in UTC, so that times can be compared as strings
+func toUTC(s string) (string, error) {
+	t, err := time.Parse(time2.ISO8601, s)
+	if err != nil {
+		return "", err
+	}
+	return t.UTC().Format(time2.ISO8601), nil
+}
@@ -0,0 +1,63 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package flow
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_resolveDelegate(t *testing.T) {
+	delegates := map[string]string{
+		"a": "b",
+		"b": "c",
+		"x": "y",
+		"y": "x",
+	}
+	tests := []struct {
+		userID string
+		want   string
+	}{
+		{"a", "c"},
+		{"b", "c"},
+		{"c", "c"},
+		{"x", "y"}, // 循环时停在回到自己之前
+	}
+	for _, tt := range tests {
+		if got := resolveDelegate(tt.userID, delegates); got != tt.want {
+			t.Errorf("resolveDelegate(%s) = %s, want %s", tt.userID, got, tt.want)
+		}
+	}
+
+	got := delegateUserIDs([]string{"a", "b", "d"}, delegates)
+	if want := []string{"c", "d"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("delegateUserIDs() = %v, want %v", got, want)
+	}
+}
+
+func Test_delegationLoop(t *testing.T) {
+	edges := map[string][]string{
+		"b": {"c"},
+		"c": {"d", "e"},
+	}
+	if delegationLoop("a", "b", edges) {
+		t.Errorf("a -> b should not loop")
+	}
+	if !delegationLoop("e", "b", edges) {
+		t.Errorf("e -> b should loop")
+	}
+	if !overlapApps("", "app1") || !overlapApps("app1,app2", "app2") || overlapApps("app1", "app2") {
+		t.Errorf("overlapApps() unexpected")
+	}
+}
@@ -20,9 +20,11 @@ import (
 	"fmt"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/quanxiang-cloud/flow/internal"
 	"github.com/quanxiang-cloud/flow/internal/convert"
+	"github.com/quanxiang-cloud/flow/internal/flow/notifier"
 	"github.com/quanxiang-cloud/flow/internal/models"
 	"github.com/quanxiang-cloud/flow/internal/models/mysql"
 	"github.com/quanxiang-cloud/flow/internal/server/options"
@@ -50,17 +52,21 @@ type Flow interface {
 	UpdateFlowStatus(ctx context.Context, req *PublishProcessReq, usrID string) (*UpdateFlowStatusResp, error)
 	GetNodes(ctx context.Context, ID string) ([]*models.NodeModel, error)
 	GetShapeByProcessID(ctx context.Context, processID, nodeDefKey string) (*convert.ShapeModel, error)
+	GetShapeByInstance(ctx context.Context, processID, processInstanceID, nodeDefKey string) (*convert.ShapeModel, error)
 
 	GetVariableList(ctx context.Context, ID string) ([]*models.Variables, error)
 	SaveFlowVariable(ctx context.Context, req *SaveVariablesReq, userID string) (*models.Variables, error)
 	DeleteFlowVariable(ctx context.Context, ID string) (bool, error)
 	GetInstanceVariableValues(ctx context.Context, instance *models.Instance) (map[string]interface{}, error)
+	SaveInstanceVariable(ctx context.Context, processInstanceID string, code string, value interface{}) error
 	GetFlowVariableValues(ctx context.Context, instance *models.Instance) (map[string]interface{}, error)
 
 	RefreshRule(ctx context.Context) (bool, error)
 
 	GetTaskHandleUserIDs(ctx context.Context, shape *convert.ShapeModel, flowInstanceEntity *models.Instance) ([]string, []string)
 	GetTaskHandleUserIDs2(ctx context.Context, approvePersonsStr interface{}, flowInstanceEntity *models.Instance) ([]string, []string)
+	GetTaskHandleDelegations(ctx context.Context, shape *convert.ShapeModel, flowInstanceEntity *models.Instance) ([]string, map[string]string)
+	DelegateUserIDs(ctx context.Context, appID string, userIDs []string) []string
 	GetTaskHandleUsers(ctx context.Context, shape *convert.ShapeModel, flowInstanceEntity *models.Instance) []*client.UserInfoResp
 	GetTaskHandleUsers2(ctx context.Context, approvePersonsStr interface{}, flowInstanceEntity *models.Instance) []*client.UserInfoResp
 	GetFlowKeyFields(ctx context.Context, flowIDs []string) (map[string][]string, error)
@@ -71,6 +77,15 @@ type Flow interface {
 	AppReplicationExport(ctx context.Context, req *AppReplicationExportReq) (string, error)
 	AppReplicationImport(ctx context.Context, req *AppReplicationImportReq, userID string) (bool bool, err error)
 
+	VersionList(ctx context.Context, req *VersionListReq) (*page.RespPage, error)
+	VersionInfo(ctx context.Context, ID string) (*models.FlowVersion, error)
+	RollbackVersion(ctx context.Context, req *RollbackVersionReq, userID string) (*models.Flow, error)
+	GetInstanceFlow(ctx context.Context, instance *models.Instance) (*models.Flow, error)
+	Validate(ctx context.Context, req *ValidateReq) (*ValidateResp, error)
+	Simulate(ctx context.Context, req *SimulateReq, userID string) (*SimulateResp, error)
+	ExportBPMN(ctx context.Context, ID string) ([]byte, error)
+	ImportBPMN(ctx context.Context, req *ImportBPMNReq, userID string) (*models.Flow, error)
+
 	suspendApp(ctx context.Context, appID string) error
 	recoveryApp(ctx context.Context, appID string) error
 }
@@ -95,6 +110,8 @@ type flow struct {
 	dispatcher            client.Dispatcher
 	conf                  *config.Configs
 	flowProcessRepo       models.FlowProcessRelationRepo
+	flowVersionRepo       models.FlowVersionRepo
+	delegationRepo        models.DelegationRepo
 }
 
 const (
@@ -125,6 +142,8 @@ func NewFlow(conf *config.Configs, opts ...options.Options) (Flow, error) {
 		dispatcher:            client.NewDispatcher(conf),
 		conf:                  conf,
 		flowProcessRepo:       mysql.NewFlowProcessRelationRepo(),
+		flowVersionRepo:       mysql.NewFlowVersionRepo(),
+		delegationRepo:        mysql.NewDelegationRepo(),
 	}
 
 	for _, opt := range opts {
@@ -139,6 +158,13 @@ func (f *flow) SetDB(db *gorm.DB) {
 }
 
 func (f *flow) GetShapeByProcessID(ctx context.Context, processID, nodeDefKey string) (*convert.ShapeModel, error) {
+	version, err := f.flowVersionRepo.FindByProcessID(f.db, processID)
+	if err != nil {
+		return nil, err
+	}
+	if version != nil {
+		return convert.GetShapeByTaskDefKey(version.BpmnText, nodeDefKey)
+	}
 	flow, err := f.flowRepo.FindByProcessID(f.db, processID)
 	if err != nil {
 		return nil, err
@@ -173,6 +199,12 @@ func (f *flow) SaveFlow(ctx context.Context, req *models.Flow, userID string) (*
 	if len(req.BpmnText) > 0 {
 		req.BpmnText = utils.UnicodeEmojiCode(req.BpmnText)
 	}
+	if err := checkUrgePolicy(req.UrgePolicy); err != nil {
+		return nil, err
+	}
+	if err := checkNotifyChannels(req.NotifyChannels); err != nil {
+		return nil, err
+	}
 	tx := f.db.Begin()
 	if len(req.ID) > 0 {
 		flow, err := f.flowRepo.FindByID(f.db, req.ID)
@@ -840,6 +872,10 @@ func (f *flow) deleteFlowByAppID(ctx context.Context, appID string) error {
 	if err = f.instanceExecutionRepo.DeleteByProcessInstanceIDs(tx, processInstanceIDs); err != nil {
 		return err
 	}
+	// 删除flow_version
+	if err = f.flowVersionRepo.DeleteByFlowIDs(tx, flowIDs); err != nil {
+		return err
+	}
 	// 调process接口
 	if err = f.processAPI.UpdateAppStatus(ctx, processIDs, mysql.AppDeleteStatus); err != nil {
 		return err
@@ -915,13 +951,22 @@ type UpdateFlowStatusResp struct {
 
 func (f *flow) UpdateFlowStatus(ctx context.Context, req *PublishProcessReq, usrID string) (resp *UpdateFlowStatusResp, err error) {
 	tx := f.db.Begin()
-
-	fl, err := f.flowRepo.FindByID(tx, req.ID)
 	defer func() {
 		if err != nil {
 			tx.Rollback()
 		}
 	}()
+	resp, err = f.updateFlowStatus(ctx, tx, req, usrID)
+	if err != nil {
+		return resp, err
+	}
+	tx.Commit()
+	return resp, nil
+}
+
+// updateFlowStatus publish or disable the flow in the transaction
+func (f *flow) updateFlowStatus(ctx context.Context, tx *gorm.DB, req *PublishProcessReq, usrID string) (*UpdateFlowStatusResp, error) {
+	fl, err := f.flowRepo.FindByID(tx, req.ID)
 	flowStatusResp := &UpdateFlowStatusResp{}
 	flowStatusResp.TriggerMode = fl.TriggerMode
 	if err != nil {
@@ -1007,6 +1052,11 @@ func (f *flow) UpdateFlowStatus(ctx context.Context, req *PublishProcessReq, usr
 			flowStatusResp.Flag = false
 			return flowStatusResp, err
 		}
+		err = f.addVersion(ctx, tx, fl, usrID, req.Remark)
+		if err != nil {
+			flowStatusResp.Flag = false
+			return flowStatusResp, err
+		}
 		// save form field with path
 		f.formFieldRepo.DeleteByFlowID(f.db, fl.ID)
 		if formulaFields != nil {
@@ -1083,9 +1133,8 @@ func (f *flow) UpdateFlowStatus(ctx context.Context, req *PublishProcessReq, usr
 		}
 
 	}
-	tx.Commit()
 	flowStatusResp.Flag = true
-	return flowStatusResp, err
+	return flowStatusResp, nil
 }
 
 func checkChartJSON(s *convert.ShapeModel) error {
@@ -1344,6 +1393,25 @@ func (f *flow) checkCanCancel(ctx context.Context, flow *models.Flow, flowInstan
 	return false
 }
 
+// SaveInstanceVariable save value into the instance variable, the variable is created if missing
+func (f *flow) SaveInstanceVariable(ctx context.Context, processInstanceID string, code string, value interface{}) error {
+	variable, err := f.instanceVariablesRepo.FindVariablesByCode(f.db, processInstanceID, code)
+	if err != nil {
+		return err
+	}
+
+	fieldValue, fieldType := utils.StrvalAndType(value)
+	if variable != nil && variable.ID != "" {
+		return f.instanceVariablesRepo.UpdateTypeAndValue(f.db, processInstanceID, code, fieldType, fieldValue)
+	}
+	return f.instanceVariablesRepo.Create(f.db, &models.InstanceVariables{
+		ProcessInstanceID: processInstanceID,
+		Code:              code,
+		FieldType:         fieldType,
+		Value:             fieldValue,
+	})
+}
+
 func (f *flow) GetInstanceVariableValues(ctx context.Context, instance *models.Instance) (map[string]interface{}, error) {
 	// default variables
 	valueMap := make(map[string]interface{})
@@ -1456,6 +1524,7 @@ func (f *flow) AppReplicationExport(ctx context.Context, req *AppReplicationExpo
 		}
 		flow.Variables = variables
 	}
+	// webhook签名密钥存放在flow_app_secret中，不随流程导出
 	marshal, err := json.Marshal(flows)
 	if err != nil {
 		return "", err
@@ -1641,6 +1710,7 @@ type SaveVariablesReq struct {
 type PublishProcessReq struct {
 	ID     string `json:"id"`
 	Status string `json:"status"`
+	Remark string `json:"remark"` // publish remark, saved to the flow version
 }
 
 // QueryFlowReq flow request
@@ -1676,3 +1746,65 @@ type AppReplicationImportReq struct {
 	// FormID map[string]string `json:"formID"`
 	Flows string `json:"flows"`
 }
+
+// checkUrgePolicy check the urge policy json of the flow
+func checkUrgePolicy(policy string) error {
+	if policy == "" {
+		return nil
+	}
+	p := &models.UrgePolicy{}
+	if err := json.Unmarshal([]byte(policy), p); err != nil {
+		return error2.NewErrorWithString(error2.ErrParams, "bad urge policy")
+	}
+	if p.MinInterval < 0 || p.MaxTimes < 0 {
+		return error2.NewErrorWithString(error2.ErrParams, "urge interval and times can not be negative")
+	}
+	if (p.QuietStart == "") != (p.QuietEnd == "") {
+		return error2.NewErrorWithString(error2.ErrParams, "quiet start and end must be set together")
+	}
+	for _, clock := range []string{p.QuietStart, p.QuietEnd} {
+		if _, err := time.Parse(calendarClockFmt, clock); clock != "" && err != nil {
+			return error2.NewErrorWithString(error2.ErrParams, "bad quiet hours "+clock)
+		}
+	}
+	for _, channel := range p.Channels {
+		if !notifier.IsChannel(channel) {
+			return error2.NewErrorWithString(error2.ErrParams, "unknown urge channel "+channel)
+		}
+	}
+	return nil
+}
+
+// checkNotifyChannels check the notify channels json of the flow
+func checkNotifyChannels(notifyChannels string) error {
+	if notifyChannels == "" {
+		return nil
+	}
+	setting := make(map[string][]string)
+	if err := json.Unmarshal([]byte(notifyChannels), &setting); err != nil {
+		return error2.NewErrorWithString(error2.ErrParams, "bad notify channels")
+	}
+	for event, channels := range setting {
+		if !notifier.IsEvent(event) {
+			return error2.NewErrorWithString(error2.ErrParams, "unknown notify event "+event)
+		}
+		for _, channel := range channels {
+			if !notifier.IsChannel(channel) {
+				return error2.NewErrorWithString(error2.ErrParams, "unknown notify channel "+channel)
+			}
+		}
+	}
+	return nil
+}
+
+// checkAppAdmin the current user must be an admin of the app
+func checkAppAdmin(ctx context.Context, appCenterAPI client.AppCenter, appID string) error {
+	appIDs, err := appCenterAPI.GetAdminAppIDs(ctx)
+	if err != nil {
+		return err
+	}
+	if !utils.Contain(appIDs, appID) {
+		return error2.NewErrorWithString(error2.Internal, "No permission, not an admin of the app ")
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package flow
+
+import (
+	"context"
+
+	"github.com/quanxiang-cloud/flow/internal/convert"
+	"github.com/quanxiang-cloud/flow/internal/models"
+	"github.com/quanxiang-cloud/flow/pkg/misc/error2"
+)
+
+// ImportBPMNReq import BPMN 2.0 xml req, the flow of ID is overwritten when ID is set
+type ImportBPMNReq struct {
+	ID    string `json:"id"`
+	AppID string `json:"appID"`
+	Name  string `json:"name"`
+	XML   string `json:"xml" binding:"required"`
+}
+
+// ExportBPMN export flow as BPMN 2.0 xml
+func (f *flow) ExportBPMN(ctx context.Context, ID string) ([]byte, error) {
+	fl, err := f.flowRepo.FindByID(f.db, ID)
+	if err != nil {
+		return nil, err
+	}
+	if fl == nil {
+		return nil, error2.NewErrorWithString(error2.Internal, "flow not exists")
+	}
+	processID := fl.ProcessKey
+	if processID == "" {
+		processID = "flow_" + fl.ID
+	}
+	return convert.ToBPMN(fl.BpmnText, processID, fl.Name, fl.Cron)
+}
+
+// ImportBPMN import BPMN 2.0 xml as a draft flow
+func (f *flow) ImportBPMN(ctx context.Context, req *ImportBPMNReq, userID string) (*models.Flow, error) {
+	model, err := convert.FromBPMN([]byte(req.XML))
+	if err != nil {
+		return nil, error2.NewErrorWithString(error2.Internal, "invalid bpmn xml, "+err.Error())
+	}
+
+	fl := &models.Flow{}
+	if req.ID != "" {
+		fl, err = f.flowRepo.FindByID(f.db, req.ID)
+		if err != nil {
+			return nil, err
+		}
+		if fl == nil {
+			return nil, error2.NewErrorWithString(error2.Internal, "flow not exists")
+		}
+	} else {
+		if req.AppID == "" {
+			return nil, error2.NewErrorWithString(error2.Internal, "appID is empty")
+		}
+		fl.AppID = req.AppID
+		fl.Name = model.Name
+	}
+	if req.Name != "" {
+		fl.Name = req.Name
+	}
+	if fl.Name == "" {
+		return nil, error2.NewErrorWithString(error2.Internal, "name is empty")
+	}
+	fl.TriggerMode = model.TriggerMode
+	fl.BpmnText = model.BpmnText
+	if model.Cron != "" {
+		fl.Cron = model.Cron
+	}
+	if p, err := convert.ToProcessModel(model.BpmnText); err == nil {
+		fl.FormID = convert.GetFormInfoFromShapes(p.Shapes)
+	}
+	return f.SaveFlow(ctx, fl, userID)
+}
@@ -0,0 +1,359 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package flow
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/quanxiang-cloud/flow/internal/convert"
+	"github.com/quanxiang-cloud/flow/internal/eval"
+	"github.com/quanxiang-cloud/flow/internal/models"
+	"github.com/quanxiang-cloud/flow/pkg/misc/error2"
+	"github.com/quanxiang-cloud/flow/pkg/utils"
+)
+
+// SimulateReq simulate flow req, BpmnText defaults to the saved chart of flow
+type SimulateReq struct {
+	FlowID    string                 `json:"flowID"`
+	AppID     string                 `json:"appID"`
+	BpmnText  string                 `json:"bpmnText"`
+	FormData  map[string]interface{} `json:"formData"`
+	Variables map[string]interface{} `json:"variables"`
+	UserID    string                 `json:"userID"` // 发起人，默认当前用户
+}
+
+// SimulateResp simulate flow resp
+type SimulateResp struct {
+	Path      []*SimulateStep        `json:"path"`
+	Variables map[string]interface{} `json:"variables"`
+}
+
+// SimulateStep one node passed by the simulation
+type SimulateStep struct {
+	NodeID    string                 `json:"nodeID"`
+	Name      string                 `json:"name"`
+	Type      string                 `json:"type"`
+	Assignees []string               `json:"assignees,omitempty"`
+	Outputs   map[string]interface{} `json:"outputs,omitempty"`
+	Message   string                 `json:"message,omitempty"`
+}
+
+type simulator struct {
+	f          *flow
+	shapes     map[string]convert.ShapeModel
+	formDefKey string
+	instance   *models.Instance
+	formData   map[string]interface{}
+	variables  map[string]interface{}
+	path       []*SimulateStep
+	maxSteps   int
+}
+
+// Simulate walk the flow chart with sample data, without starting a process or writing any rows
+func (f *flow) Simulate(ctx context.Context, req *SimulateReq, userID string) (*SimulateResp, error) {
+	bpmnText := req.BpmnText
+	appID := req.AppID
+	variables := make(map[string]interface{})
+	if req.FlowID != "" {
+		fl, err := f.flowRepo.FindByID(f.db, req.FlowID)
+		if err != nil {
+			return nil, err
+		}
+		if fl == nil {
+			return nil, error2.NewErrorWithString(error2.Internal, "flow not exists")
+		}
+		if bpmnText == "" {
+			bpmnText = fl.BpmnText
+		}
+		if appID == "" {
+			appID = fl.AppID
+		}
+		flowVariables, err := f.variablesRepo.FindVariablesByFlowID(f.db, req.FlowID)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range flowVariables {
+			variables[v.Code] = v.DefaultValue
+		}
+	}
+	if bpmnText == "" {
+		return nil, error2.NewErrorWithString(error2.Internal, "bpmnText is empty")
+	}
+	p, err := convert.ToProcessModel(bpmnText)
+	if err != nil {
+		return nil, error2.NewErrorWithString(error2.Internal, "invalid bpmnText, "+err.Error())
+	}
+	for k, v := range req.Variables {
+		variables[k] = v
+	}
+	if req.UserID != "" {
+		userID = req.UserID
+	}
+
+	s := &simulator{
+		f:         f,
+		shapes:    make(map[string]convert.ShapeModel, len(p.Shapes)),
+		formData:  req.FormData,
+		variables: variables,
+		path:      make([]*SimulateStep, 0),
+		maxSteps:  len(p.Shapes) * 2,
+		instance: &models.Instance{
+			AppID:       appID,
+			FormID:      convert.GetFormInfoFromShapes(p.Shapes),
+			ApplyUserID: userID,
+		},
+	}
+	if s.formData == nil {
+		s.formData = make(map[string]interface{})
+	}
+	startID := ""
+	for _, shape := range p.Shapes {
+		s.shapes[shape.ID] = shape
+		if shape.Type == convert.FormData || shape.Type == convert.FormTime {
+			startID = shape.ID
+			s.formDefKey = shape.ID
+		}
+	}
+	if startID == "" {
+		return nil, error2.NewErrorWithString(error2.Internal, "start node not exists")
+	}
+
+	if err := s.walk(ctx, startID, ""); err != nil {
+		return nil, err
+	}
+	return &SimulateResp{
+		Path:      s.path,
+		Variables: s.variables,
+	}, nil
+}
+
+// walk walk from nodeID until stopID or end node
+func (s *simulator) walk(ctx context.Context, nodeID, stopID string) error {
+	for nodeID != "" && nodeID != stopID {
+		if len(s.path) > s.maxSteps {
+			return error2.NewErrorWithString(error2.Internal, "flow chart has loop")
+		}
+		shape, ok := s.shapes[nodeID]
+		if !ok {
+			return error2.NewErrorWithString(error2.Internal, "node not exists: "+nodeID)
+		}
+		step := &SimulateStep{
+			NodeID: shape.ID,
+			Name:   shape.Data.NodeData.Name,
+			Type:   shape.Type,
+		}
+		s.path = append(s.path, step)
+
+		switch shape.Type {
+		case "processBranchSource":
+			targetID := shape.Data.NodeData.BranchTargetElementID
+			for _, branch := range s.chooseBranches(step, shape) {
+				s.path = append(s.path, &SimulateStep{
+					NodeID: branch.ID,
+					Name:   branch.Data.NodeData.Name,
+					Type:   branch.Type,
+				})
+				if len(branch.Data.NodeData.ChildrenID) == 0 {
+					continue
+				}
+				if err := s.walk(ctx, branch.Data.NodeData.ChildrenID[0], targetID); err != nil {
+					return err
+				}
+			}
+			nodeID = targetID
+			continue
+		case convert.Approve, convert.FillIn:
+			step.Assignees = s.assignees(ctx, shape)
+		case convert.ProcessVariableAssignment:
+			step.Outputs = s.assign(ctx, step, shape)
+		case convert.ScriptTask:
+			step.Outputs = s.runScript(ctx, step, shape)
+		case convert.SubFlow:
+			step.Message = "子流程不会被模拟运行: " + utils.Strval(shape.Data.BusinessData["flowID"])
+		}
+
+		if len(shape.Data.NodeData.ChildrenID) == 0 {
+			return nil
+		}
+		nodeID = shape.Data.NodeData.ChildrenID[0]
+	}
+	return nil
+}
+
+// chooseBranches every branch whose rule is true is taken, default branch is taken when none matched,
+// a branch with empty rule is deployed without condition, so it is always taken like in the engine
+func (s *simulator) chooseBranches(step *SimulateStep, shape convert.ShapeModel) []convert.ShapeModel {
+	matched := make([]convert.ShapeModel, 0)
+	defaults := make([]convert.ShapeModel, 0)
+	messages := make([]string, 0)
+	for _, childID := range shape.Data.NodeData.ChildrenID {
+		branch, ok := s.shapes[childID]
+		if !ok {
+			continue
+		}
+		// 与部署时的转换一致：空条件无条件流转，未设置条件时只能是默认分支，否则条件未设置
+		bd := branch.Data.BusinessData
+		if bd["rule"] == nil {
+			if utils.Strval(bd["ignore"]) == "true" {
+				defaults = append(defaults, branch)
+			} else {
+				messages = append(messages, branch.Data.NodeData.Name+": Condition not set")
+			}
+			continue
+		}
+		rule := utils.Strval(bd["rule"])
+		if rule == "" {
+			matched = append(matched, branch)
+			continue
+		}
+		value, err := s.cal(rule, bd["formulaFields"])
+		if err != nil {
+			messages = append(messages, branch.Data.NodeData.Name+": "+err.Error())
+			continue
+		}
+		if utils.Strval(value) == "true" {
+			matched = append(matched, branch)
+		}
+	}
+	step.Message = strings.Join(messages, ";")
+	if len(matched) == 0 {
+		return defaults
+	}
+	return matched
+}
+
+// parameter form data and variables, variables take precedence
+func (s *simulator) parameter(formulaFields interface{}) map[string]interface{} {
+	parameter := make(map[string]interface{}, len(s.formData)+len(s.variables))
+	for k, v := range s.formData {
+		parameter[k] = v
+	}
+	for k, v := range s.variables {
+		parameter[k] = v
+	}
+	return s.f.FormatFormValue2(formulaFields, parameter)
+}
+
+// cal calculate expression with form data and variables
+func (s *simulator) cal(expression string, formulaFields interface{}) (interface{}, error) {
+	ret, err := eval.Handler(context.Background(), &eval.FormulaReq{
+		Expression: convert.NormalizeExpression(expression, s.formDefKey),
+		Parameter:  s.parameter(formulaFields),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return eval.Value(ret), nil
+}
+
+// assignees resolve handle users, field and variable persons are read from sample data
+func (s *simulator) assignees(ctx context.Context, shape convert.ShapeModel) []string {
+	approvePersonsObj := convert.GetValueFromBusinessData(shape, "basicConfig.approvePersons")
+	var approvePersons convert.ApprovePersonsModel
+	if bytes, err := json.Marshal(approvePersonsObj); err == nil {
+		json.Unmarshal(bytes, &approvePersons)
+	}
+
+	userIDs := make([]string, 0)
+	switch approvePersons.Type {
+	case convert.EmailTypeOfField, convert.EmailTypeOfMultipleField:
+		for _, field := range approvePersons.Fields {
+			value := s.formData[field]
+			if str, ok := value.(string); ok {
+				userIDs = append(userIDs, str)
+				continue
+			}
+			for _, mm := range utils.ChangeObjectToMapList(value) {
+				userIDs = append(userIDs, utils.GetAsString(mm["value"]))
+			}
+		}
+	case "processVariable":
+		split := strings.Split(approvePersons.VariablePath, ".")
+		if value := utils.Strval(s.variables[split[len(split)-1]]); value != "" {
+			userIDs = append(userIDs, value)
+		}
+	default:
+		userIDs, _ = s.f.GetTaskHandleUserIDs2(ctx, approvePersonsObj, s.instance)
+	}
+	return utils.RemoveReplicaSliceString(userIDs)
+}
+
+// assign calculate assignment rules like the variable update node
+func (s *simulator) assign(ctx context.Context, step *SimulateStep, shape convert.ShapeModel) map[string]interface{} {
+	outputs := make(map[string]interface{})
+	rules, _ := shape.Data.BusinessData["assignmentRules"].([]interface{})
+	messages := make([]string, 0)
+	for _, e := range rules {
+		rule := utils.ChangeObjectToMap(e)
+		if rule == nil {
+			continue
+		}
+		variableName := utils.Strval(rule["variableName"])
+		valueOf := rule["valueOf"]
+
+		var value interface{}
+		switch utils.Strval(rule["valueFrom"]) {
+		case "fixedValue":
+			value = valueOf
+		case "currentFormValue":
+			value, _ = s.f.formAPI.GetValue(s.formData, utils.Strval(valueOf), nil)
+		case "formula":
+			value = valueOf
+			if strings.Contains(utils.Strval(valueOf), "$") {
+				ret, err := s.cal(utils.Strval(valueOf), nil)
+				if err != nil {
+					messages = append(messages, variableName+": "+err.Error())
+					ret = ""
+				}
+				value = ret
+			}
+		case "processVariable":
+			key := utils.Strval(valueOf)
+			key = strings.Replace(key, "$variable.", "", 1)
+			key = strings.Replace(key, "$"+s.formDefKey+".", "", 1)
+			value = utils.GetFieldValue(s.variables, key)
+			if value == nil {
+				value = utils.GetFieldValue(s.formData, key)
+			}
+		}
+		outputs[variableName] = value
+		s.variables[variableName] = value
+	}
+	step.Message = strings.Join(messages, ";")
+	return outputs
+}
+
+// runScript run the script node, only variables of flow are kept like the script node
+func (s *simulator) runScript(ctx context.Context, step *SimulateStep, shape convert.ShapeModel) map[string]interface{} {
+	outputs := make(map[string]interface{})
+	script, err := eval.ParseScript(convert.NormalizeExpression(utils.Strval(shape.Data.BusinessData["script"]), s.formDefKey))
+	if err != nil {
+		step.Message = err.Error()
+		return outputs
+	}
+	results, err := script.Run(ctx, s.parameter(shape.Data.BusinessData["formulaFields"]), eval.DefaultScriptLimit)
+	if err != nil {
+		step.Message = err.Error()
+		return outputs
+	}
+	for name, value := range results {
+		if _, ok := s.variables[name]; ok {
+			outputs[name] = value
+			s.variables[name] = value
+		}
+	}
+	return outputs
+}
@@ -0,0 +1,77 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package flow
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/quanxiang-cloud/flow/internal/convert"
+)
+
+func Test_chooseBranches(t *testing.T) {
+	branch := func(id string, bd map[string]interface{}) convert.ShapeModel {
+		s := convert.ShapeModel{ID: id, Type: "processBranch"}
+		s.Data.NodeData.Name = id
+		s.Data.BusinessData = bd
+		return s
+	}
+	tests := []struct {
+		name     string
+		branches []convert.ShapeModel
+		amount   int
+		want     []string
+	}{
+		{"empty rule and true rule", []convert.ShapeModel{
+			branch("b1", map[string]interface{}{"rule": ""}),
+			branch("b2", map[string]interface{}{"rule": "$form.amount > 10"}),
+		}, 20, []string{"b1", "b2"}},
+		{"empty rule and false rule", []convert.ShapeModel{
+			branch("b1", map[string]interface{}{"rule": ""}),
+			branch("b2", map[string]interface{}{"rule": "$form.amount > 10"}),
+		}, 5, []string{"b1"}},
+		{"empty rule before default", []convert.ShapeModel{
+			branch("b1", map[string]interface{}{"rule": ""}),
+			branch("b2", map[string]interface{}{"ignore": true}),
+		}, 5, []string{"b1"}},
+		{"default when none matched", []convert.ShapeModel{
+			branch("b1", map[string]interface{}{"rule": "$form.amount > 10"}),
+			branch("b2", map[string]interface{}{"ignore": true}),
+		}, 5, []string{"b2"}},
+		{"rule not set", []convert.ShapeModel{
+			branch("b1", map[string]interface{}{}),
+			branch("b2", map[string]interface{}{"rule": "$form.amount > 10"}),
+		}, 20, []string{"b2"}},
+	}
+	for _, tt := range tests {
+		s := &simulator{
+			f:          &flow{},
+			shapes:     make(map[string]convert.ShapeModel),
+			formDefKey: "form",
+			formData:   map[string]interface{}{"amount": tt.amount},
+		}
+		gateway := convert.ShapeModel{ID: "src", Type: "processBranchSource"}
+		for _, b := range tt.branches {
+			s.shapes[b.ID] = b
+			gateway.Data.NodeData.ChildrenID = append(gateway.Data.NodeData.ChildrenID, b.ID)
+		}
+		got := make([]string, 0)
+		for _, b := range s.chooseBranches(&SimulateStep{}, gateway) {
+			got = append(got, b.ID)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: chooseBranches() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
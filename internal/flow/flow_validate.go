@@ -0,0 +1,162 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package flow
+
+import (
+	"context"
+
+	"github.com/quanxiang-cloud/flow/internal/convert"
+	"github.com/quanxiang-cloud/flow/pkg/misc/error2"
+	"github.com/quanxiang-cloud/flow/pkg/misc/logger"
+	"github.com/quanxiang-cloud/flow/pkg/utils"
+)
+
+// ValidateReq validate flow chart req, BpmnText defaults to the saved chart of flow
+type ValidateReq struct {
+	FlowID   string `json:"flowID"`
+	AppID    string `json:"appID"`
+	BpmnText string `json:"bpmnText"`
+}
+
+// ValidateResp validate flow chart resp
+type ValidateResp struct {
+	Valid       bool                  `json:"valid"`
+	Diagnostics []*convert.Diagnostic `json:"diagnostics"`
+}
+
+// Validate check flow chart without publishing it
+func (f *flow) Validate(ctx context.Context, req *ValidateReq) (*ValidateResp, error) {
+	bpmnText := req.BpmnText
+	appID := req.AppID
+	if req.FlowID != "" {
+		fl, err := f.flowRepo.FindByID(f.db, req.FlowID)
+		if err != nil {
+			return nil, err
+		}
+		if fl == nil {
+			return nil, error2.NewErrorWithString(error2.Internal, "flow not exists")
+		}
+		if bpmnText == "" {
+			bpmnText = fl.BpmnText
+		}
+		if appID == "" {
+			appID = fl.AppID
+		}
+	}
+	if bpmnText == "" {
+		return nil, error2.NewErrorWithString(error2.Internal, "bpmnText is empty")
+	}
+
+	p, err := convert.ToProcessModel(bpmnText)
+	if err != nil {
+		return nil, error2.NewErrorWithString(error2.Internal, "invalid bpmnText, "+err.Error())
+	}
+
+	refs := &convert.ValidateRefs{}
+	if req.FlowID != "" {
+		refs.FlowID = req.FlowID
+		refs.SubFlows, err = f.collectSubFlows(p)
+		if err != nil {
+			return nil, err
+		}
+		variables, err := f.variablesRepo.FindVariablesByFlowID(f.db, req.FlowID)
+		if err != nil {
+			return nil, err
+		}
+		refs.Variables = map[string]bool{SystemAudit: true}
+		for _, v := range variables {
+			refs.Variables[v.Code] = true
+		}
+	}
+	if formID := convert.GetFormInfoFromShapes(p.Shapes); formID != "" && appID != "" {
+		formSchema, err := f.formAPI.GetFormSchema(ctx, appID, formID)
+		if err != nil {
+			logger.Logger.Error("get form schema err,", err)
+		} else if formSchema != nil {
+			refs.FormFields = make(map[string]bool)
+			collectFormFields(utils.ChangeObjectToMap(formSchema), refs.FormFields)
+		}
+	}
+
+	diagnostics := convert.Validate(p, refs)
+	resp := &ValidateResp{
+		Valid:       true,
+		Diagnostics: diagnostics,
+	}
+	for _, d := range diagnostics {
+		if d.Level == convert.LevelError {
+			resp.Valid = false
+			break
+		}
+	}
+	return resp, nil
+}
+
+// collectSubFlows collect child flows started by the chart and by those child flows, recursively
+func (f *flow) collectSubFlows(p *convert.ProcessModel) (map[string][]string, error) {
+	subFlows := make(map[string][]string)
+	queue := subFlowIDs(p)
+	for len(queue) > 0 {
+		flowID := queue[0]
+		queue = queue[1:]
+		if _, ok := subFlows[flowID]; ok {
+			continue
+		}
+		subFlows[flowID] = nil
+		fl, err := f.flowRepo.FindByID(f.db, flowID)
+		if err != nil {
+			return nil, err
+		}
+		if fl == nil || fl.BpmnText == "" {
+			continue
+		}
+		child, err := convert.ToProcessModel(fl.BpmnText)
+		if err != nil {
+			logger.Logger.Error("invalid bpmnText of sub flow,", err)
+			continue
+		}
+		subFlows[flowID] = subFlowIDs(child)
+		queue = append(queue, subFlows[flowID]...)
+	}
+	return subFlows, nil
+}
+
+// subFlowIDs child flow ids of sub process nodes
+func subFlowIDs(p *convert.ProcessModel) []string {
+	ids := make([]string, 0)
+	for _, s := range p.Shapes {
+		if s.Type != convert.SubFlow {
+			continue
+		}
+		if id := utils.Strval(s.Data.BusinessData["flowID"]); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// collectFormFields collect field keys of form schema, including fields in layout components
+func collectFormFields(schema map[string]interface{}, fields map[string]bool) {
+	if schema == nil || schema["properties"] == nil {
+		return
+	}
+	for key, value := range utils.ChangeObjectToMap(schema["properties"]) {
+		fieldMap := utils.ChangeObjectToMap(value)
+		if fieldMap != nil && fieldMap["properties"] != nil { // 布局组件
+			collectFormFields(fieldMap, fields)
+			continue
+		}
+		fields[key] = true
+	}
+}
@@ -0,0 +1,179 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package flow
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/quanxiang-cloud/flow/internal/convert"
+	"github.com/quanxiang-cloud/flow/internal/models"
+	"github.com/quanxiang-cloud/flow/pkg/misc/error2"
+	"github.com/quanxiang-cloud/flow/pkg/misc/logger"
+	"github.com/quanxiang-cloud/flow/pkg/misc/time2"
+	"github.com/quanxiang-cloud/flow/pkg/page"
+	"gorm.io/gorm"
+)
+
+// VersionListReq flow version list req
+type VersionListReq struct {
+	FlowID string `json:"flowID" binding:"required"`
+	Page   int    `json:"page"`
+	Size   int    `json:"size"`
+}
+
+// RollbackVersionReq rollback flow to a published version
+type RollbackVersionReq struct {
+	FlowID    string `json:"flowID" binding:"required"`
+	VersionID string `json:"versionID" binding:"required"`
+}
+
+// addVersion snapshot the published flow as a new immutable version
+func (f *flow) addVersion(ctx context.Context, tx *gorm.DB, fl *models.Flow, userID string, remark string) error {
+	latest, err := f.flowVersionRepo.FindLatest(tx, fl.ID)
+	if err != nil {
+		return err
+	}
+	version := &models.FlowVersion{
+		FlowID:      fl.ID,
+		Version:     1,
+		Name:        fl.Name,
+		BpmnText:    fl.BpmnText,
+		ProcessID:   fl.ProcessID,
+		FormID:      fl.FormID,
+		PublisherID: userID,
+		PublishTime: time2.Now(),
+		Remark:      remark,
+		BaseModel: models.BaseModel{
+			CreatorID: userID,
+		},
+	}
+	oldText := ""
+	if latest != nil {
+		version.Version = latest.Version + 1
+		oldText = latest.BpmnText
+	}
+	version.DiffSummary = convert.DiffSummary(oldText, fl.BpmnText)
+
+	user, err := f.identityAPI.FindUserByID(ctx, userID)
+	if err != nil {
+		logger.Logger.Error("find publisher err,", err)
+	}
+	if user != nil {
+		version.PublisherName = user.UserName
+	}
+	return f.flowVersionRepo.Create(tx, version)
+}
+
+// VersionList list published versions of flow, newest first
+func (f *flow) VersionList(ctx context.Context, req *VersionListReq) (*page.RespPage, error) {
+	versions, count, err := f.flowVersionRepo.FindPageByFlowID(f.db, req.FlowID, req.Page, req.Size)
+	if err != nil {
+		return nil, err
+	}
+	return &page.RespPage{
+		Data:       versions,
+		TotalCount: count,
+	}, nil
+}
+
+// VersionInfo get flow version detail
+func (f *flow) VersionInfo(ctx context.Context, ID string) (*models.FlowVersion, error) {
+	version, err := f.flowVersionRepo.FindByID(f.db, ID)
+	if err != nil {
+		return nil, err
+	}
+	if version == nil {
+		return nil, error2.NewErrorWithString(error2.Internal, "Flow version is not exists ")
+	}
+	return version, nil
+}
+
+// RollbackVersion replace the live definition with an older version, republish it if the flow is enabled
+func (f *flow) RollbackVersion(ctx context.Context, req *RollbackVersionReq, userID string) (*models.Flow, error) {
+	version, err := f.flowVersionRepo.FindByID(f.db, req.VersionID)
+	if err != nil {
+		return nil, err
+	}
+	if version == nil || version.FlowID != req.FlowID {
+		return nil, error2.NewErrorWithString(error2.Internal, "Flow version is not exists ")
+	}
+	fl, err := f.flowRepo.FindByID(f.db, req.FlowID)
+	if err != nil {
+		return nil, err
+	}
+	if fl == nil {
+		return nil, error2.NewErrorWithString(error2.Internal, "Process is not exists ")
+	}
+
+	err = f.db.Transaction(func(tx *gorm.DB) error {
+		err := f.flowRepo.Update(tx, fl.ID, map[string]interface{}{
+			"bpmn_text":   version.BpmnText,
+			"modifier_id": userID,
+		})
+		if err != nil || fl.Status != models.ENABLE {
+			return err
+		}
+		publishReq := &PublishProcessReq{
+			ID:     fl.ID,
+			Status: models.ENABLE,
+			Remark: fmt.Sprintf("回滚至版本%d", version.Version),
+		}
+		_, err = f.updateFlowStatus(ctx, tx, publishReq, userID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return f.flowRepo.FindByID(f.db, fl.ID)
+}
+
+// GetInstanceFlow get flow with the definition pinned by instance
+func (f *flow) GetInstanceFlow(ctx context.Context, instance *models.Instance) (*models.Flow, error) {
+	fl, err := f.flowRepo.FindByID(f.db, instance.FlowID)
+	if err != nil || fl == nil {
+		return fl, err
+	}
+	if instance.FlowVersionID == "" {
+		return fl, nil
+	}
+	version, err := f.flowVersionRepo.FindByID(f.db, instance.FlowVersionID)
+	if err != nil {
+		return nil, err
+	}
+	if version != nil {
+		fl.BpmnText = version.BpmnText
+		fl.ProcessID = version.ProcessID
+	}
+	return fl, nil
+}
+
+// GetShapeByInstance get shape of the version the instance is pinned to, which differs from the
+// deployment the process instance runs on once the instance is migrated
+func (f *flow) GetShapeByInstance(ctx context.Context, processID, processInstanceID, nodeDefKey string) (*convert.ShapeModel, error) {
+	instance, err := f.instanceRepo.GetEntityByProcessInstanceID(f.db, processInstanceID)
+	if err != nil {
+		return nil, err
+	}
+	if instance != nil && instance.FlowVersionID != "" {
+		version, err := f.flowVersionRepo.FindByID(f.db, instance.FlowVersionID)
+		if err != nil {
+			return nil, err
+		}
+		if version != nil {
+			return convert.GetShapeByTaskDefKey(version.BpmnText, nodeDefKey)
+		}
+	}
+	return f.GetShapeByProcessID(ctx, processID, nodeDefKey)
+}
@@ -38,6 +38,15 @@ import (
 	"strings"
 )
 
+const (
+	// stepWebhook history step of a webhook node
+	stepWebhook = "WEBHOOK"
+	// webhookSuccess the last attempt of the webhook node succeeded
+	webhookSuccess = "SUCCESS"
+	// webhookFail all attempts of the webhook node failed
+	webhookFail = "FAIL"
+)
+
 const (
 	// Completed status
 	Completed = "COMPLETED"
@@ -62,6 +71,8 @@ const (
 	Abandon = "ABANDON" // 作废
 	// Abend instance status
 	Abend = "ABEND" // 异常结束
+	// Suspended instance status
+	Suspended = "SUSPENDED" // 挂起
 
 	// UnTreated 还未处理
 	UnTreated = "UNTREATED"
@@ -107,6 +118,10 @@ func GetStatusName(status string) string {
 		{
 			return "异常结束"
 		}
+	case "SUSPENDED":
+		{
+			return "挂起"
+		}
 	}
 
 	return ""
@@ -163,6 +178,9 @@ type Instance interface {
 	FlowInstanceCount(ctx context.Context) (*InstanceCountModel, error)
 
 	ReviewTask(ctx context.Context, processInstanceID string, taskID string, model *models.HandleTaskModel) (bool, error)
+	BatchReviewTask(ctx context.Context, req *BatchReviewReq) (*BatchReviewResp, error)
+	Suspend(ctx context.Context, req *InstanceSuspendReq) (bool, error)
+	Resume(ctx context.Context, req *InstanceSuspendReq) (bool, error)
 	GetFlowInstanceForm(ctx context.Context, processInstanceID string, taskTypeDetailModel *TaskTypeDetailModel) (*InstanceDetailModel, error)
 	GetFormData(ctx context.Context, processInstanceID string, taskID string, req *GetFormDataReq) (interface{}, error)
 	ProcessHistories(ctx context.Context, processInstanceID string) ([]*models.InstanceStep, error)
@@ -172,26 +190,33 @@ type Instance interface {
 }
 
 type instance struct {
-	db                    *gorm.DB
-	conf                  *config.Configs
-	instanceRepo          models.InstanceRepo
-	flowRepo              models.FlowRepo
-	operationRecordRepo   models.OperationRecordRepo
-	operationRecord       OperationRecord
-	flow                  Flow
-	task                  Task
-	processAPI            client.Process
-	identityAPI           client.Identity
-	formAPI               client.Form
-	structorAPI           client.Structor
-	appCenterAPI          client.AppCenter
-	urgeRepo              models.UrgeRepo
-	stepRepo              models.InstanceStepRepo
-	recordRepo            models.OperationRecordRepo
-	instanceVariablesRepo models.InstanceVariablesRepo
-	variablesRepo         models.VariablesRepo
-	instanceExecutionRepo models.InstanceExecutionRepo
-	abnormalTaskRepo      models.AbnormalTaskRepo
+	db                     *gorm.DB
+	conf                   *config.Configs
+	instanceRepo           models.InstanceRepo
+	flowRepo               models.FlowRepo
+	operationRecordRepo    models.OperationRecordRepo
+	operationRecord        OperationRecord
+	flow                   Flow
+	task                   Task
+	processAPI             client.Process
+	identityAPI            client.Identity
+	formAPI                client.Form
+	structorAPI            client.Structor
+	appCenterAPI           client.AppCenter
+	urgeRepo               models.UrgeRepo
+	stepRepo               models.InstanceStepRepo
+	recordRepo             models.OperationRecordRepo
+	instanceVariablesRepo  models.InstanceVariablesRepo
+	variablesRepo          models.VariablesRepo
+	instanceExecutionRepo  models.InstanceExecutionRepo
+	abnormalTaskRepo       models.AbnormalTaskRepo
+	flowVersionRepo        models.FlowVersionRepo
+	subProcessRepo         models.SubProcessRepo
+	webhookAttemptRepo     models.WebhookAttemptRepo
+	approveSequenceRepo    models.ApproveSequenceRepo
+	dispatcherCallbackRepo models.DispatcherCallbackRepo
+	dispatcherAPI          client.Dispatcher
+	subProcess             SubProcess
 }
 
 // NewInstance init
@@ -199,26 +224,34 @@ func NewInstance(conf *config.Configs, opts ...options.Options) (Instance, error
 	flow, _ := NewFlow(conf, opts...)
 	operationRecord, _ := NewOperationRecord(conf, opts...)
 	task, _ := NewTask(conf, opts...)
+	subProcess, _ := NewSubProcess(conf, opts...)
 	i := &instance{
-		conf:                  conf,
-		instanceRepo:          mysql.NewInstanceRepo(),
-		flowRepo:              mysql.NewFlowRepo(),
-		flow:                  flow,
-		operationRecord:       operationRecord,
-		task:                  task,
-		processAPI:            client.NewProcess(conf),
-		identityAPI:           client.NewIdentity(conf),
-		structorAPI:           client.NewStructor(conf),
-		formAPI:               client.NewForm(conf),
-		appCenterAPI:          client.NewAppCenter(conf),
-		urgeRepo:              mysql.NewUrgeRepo(),
-		operationRecordRepo:   mysql.NewOperationRecordRepo(),
-		stepRepo:              mysql.NewInstanceStepRepo(),
-		recordRepo:            mysql.NewOperationRecordRepo(),
-		variablesRepo:         mysql.NewVariablesRepo(),
-		instanceVariablesRepo: mysql.NewInstanceVariablesRepo(),
-		instanceExecutionRepo: mysql.NewInstanceExecutionRepo(),
-		abnormalTaskRepo:      mysql.NewAbnormalTaskRepo(),
+		conf:                   conf,
+		instanceRepo:           mysql.NewInstanceRepo(),
+		flowRepo:               mysql.NewFlowRepo(),
+		flow:                   flow,
+		operationRecord:        operationRecord,
+		task:                   task,
+		processAPI:             client.NewProcess(conf),
+		identityAPI:            client.NewIdentity(conf),
+		structorAPI:            client.NewStructor(conf),
+		formAPI:                client.NewForm(conf),
+		appCenterAPI:           client.NewAppCenter(conf),
+		urgeRepo:               mysql.NewUrgeRepo(),
+		operationRecordRepo:    mysql.NewOperationRecordRepo(),
+		stepRepo:               mysql.NewInstanceStepRepo(),
+		recordRepo:             mysql.NewOperationRecordRepo(),
+		variablesRepo:          mysql.NewVariablesRepo(),
+		instanceVariablesRepo:  mysql.NewInstanceVariablesRepo(),
+		instanceExecutionRepo:  mysql.NewInstanceExecutionRepo(),
+		abnormalTaskRepo:       mysql.NewAbnormalTaskRepo(),
+		flowVersionRepo:        mysql.NewFlowVersionRepo(),
+		subProcessRepo:         mysql.NewSubProcessRepo(),
+		webhookAttemptRepo:     mysql.NewWebhookAttemptRepo(),
+		approveSequenceRepo:    mysql.NewApproveSequenceRepo(),
+		dispatcherCallbackRepo: mysql.NewDispatcherCallbackRepo(),
+		dispatcherAPI:          client.NewDispatcher(conf),
+		subProcess:             subProcess,
 	}
 
 	for _, opt := range opts {
@@ -329,6 +362,15 @@ func (i *instance) StartFlow(ctx context.Context, req *StartFlowModel) (string,
 		flowInstanceEntity.RequestID = pkg.STDRequestID2(ctx) + "_" + req.FlowID
 
 	}
+	// pin the instance to the version deployed as the started process
+	version, err := i.flowVersionRepo.FindByProcessID(i.db, flowEntity.ProcessID)
+	if err != nil {
+		return "", err
+	}
+	if version != nil {
+		flowInstanceEntity.FlowVersionID = version.ID
+		flowInstanceEntity.FlowVersion = version.Version
+	}
 
 	if len(flowEntity.InstanceName) > 0 {
 		flowInstanceEntity.Name = i.instanceNameConverter(nil, flowEntity.InstanceName, flowInstanceEntity)
@@ -339,6 +381,24 @@ func (i *instance) StartFlow(ctx context.Context, req *StartFlowModel) (string,
 
 	// add instance variable value
 	err = i.addInstanceVariableValues(ctx, flowInstanceEntity)
+	for code, value := range req.Variables {
+		fieldValue, fieldType := utils.StrvalAndType(value)
+		err = i.instanceVariablesRepo.UpdateTypeAndValue(i.db, flowInstanceEntity.ProcessInstanceID, code, fieldType, fieldValue)
+		if err != nil {
+			return "", err
+		}
+	}
+	// started by a sub process node, link it before the process runs as it may end at once
+	if req.Parent != nil {
+		req.Parent.SubFlowID = flowInstanceEntity.FlowID
+		req.Parent.SubInstanceID = flowInstanceEntity.ID
+		req.Parent.SubProcessInstanceID = flowInstanceEntity.ProcessInstanceID
+		req.Parent.CreatorID = pkg.STDUserID(ctx)
+		err = i.subProcessRepo.Create(i.db, req.Parent)
+		if err != nil {
+			return "", err
+		}
+	}
 	params, err := i.GetInstanceVariableValues(ctx, flowInstanceEntity)
 	params = utils.MergeMap(params, req.FormData)
 	if err != nil {
@@ -485,6 +545,9 @@ func (i *instance) AbendFlowInstance(ctx context.Context, flowInstanceEntity *mo
 	if err != nil {
 		return err
 	}
+	if err = i.subProcess.Finish(ctx, flowInstanceEntity, opAbend); err != nil {
+		logger.Logger.Error(err)
+	}
 
 	handleTaskModel := &models.HandleTaskModel{
 		HandleType: opAbend,
@@ -711,11 +774,21 @@ func (i *instance) WaitReviewList(ctx context.Context, req *TaskListReq) (*page.
 	orders := []client.QueryOrder{order}
 	tasksReq.Order = orders
 
-	if len(req.AppID) > 0 || len(req.Keyword) > 0 {
+	// 挂起的实例不展示待办，存在挂起实例时只查询进行中实例的任务
+	suspendedReq := &models.PageInstancesReq{
+		Status: Suspended,
+	}
+	suspendedReq.Page, suspendedReq.Size = 1, 1
+	_, suspendedCount, _ := i.instanceRepo.PageInstances(i.db, suspendedReq)
+
+	if len(req.AppID) > 0 || len(req.Keyword) > 0 || suspendedCount > 0 {
 		queryWrapper := &models.PageInstancesReq{
 			AppID:   req.AppID,
 			Keyword: req.Keyword,
 		}
+		if suspendedCount > 0 {
+			queryWrapper.Statuses = GetOngoingStatus()
+		}
 		instances, _, _ := i.instanceRepo.PageInstances(i.db, queryWrapper)
 		if len(instances) > 0 {
 			instanceIDs := make([]string, 0)
@@ -723,6 +796,11 @@ func (i *instance) WaitReviewList(ctx context.Context, req *TaskListReq) (*page.
 				instanceIDs = append(instanceIDs, value.ProcessInstanceID)
 			}
 			tasksReq.InstanceID = instanceIDs
+		} else if suspendedCount > 0 {
+			return &page.RespPage{
+				TotalCount: 0,
+				Data:       make([]*models.ActTaskEntity, 0),
+			}, nil
 		}
 	}
 
@@ -742,6 +820,9 @@ func (i *instance) WaitReviewList(ctx context.Context, req *TaskListReq) (*page.
 		}
 	}
 
+	// 委托人
+	i.fillDelegators(ctx, list, taskIDs)
+
 	flowInstanceMap := i.FlowInstanceMapAddFormData(ctx, processInstanceIDs)
 	for _, task := range list {
 		flowInstanceEntity := flowInstanceMap[task.ProcInstID]
@@ -753,6 +834,32 @@ func (i *instance) WaitReviewList(ctx context.Context, req *TaskListReq) (*page.
 	return resp, nil
 }
 
+// fillDelegators fill the delegator of the tasks routed by delegations
+func (i *instance) fillDelegators(ctx context.Context, list []*models.ActTaskEntity, taskIDs []string) {
+	if len(taskIDs) == 0 {
+		return
+	}
+	records, err := i.operationRecordRepo.FindRecordsByTaskIDs(i.db, opDelegate, taskIDs)
+	if err != nil || len(records) == 0 {
+		return
+	}
+	delegators := make(map[string]string)
+	userIDs := make([]string, 0, len(records))
+	for _, record := range records {
+		delegators[record.TaskID] = record.HandleUserID
+		userIDs = append(userIDs, record.HandleUserID)
+	}
+	users, _ := i.identityAPI.FindUsersByIDs(ctx, userIDs)
+	for _, task := range list {
+		if delegatorID, ok := delegators[task.ID]; ok {
+			task.DelegatorID = delegatorID
+			if user, ok := users[delegatorID]; ok && user != nil {
+				task.DelegatorName = user.UserName
+			}
+		}
+	}
+}
+
 func (i *instance) ReviewedList(ctx context.Context, req *TaskListReq) (*page.RespPage, error) {
 	userID := pkg.STDUserID(ctx)
 	resp := &page.RespPage{}
@@ -775,11 +882,21 @@ func (i *instance) ReviewedList(ctx context.Context, req *TaskListReq) (*page.Re
 	orders := []client.QueryOrder{order}
 	tasksReq.Order = orders
 
-	if len(req.AppID) > 0 || len(req.Keyword) > 0 {
+	// 挂起的实例不展示待办，存在挂起实例时只查询进行中实例的任务
+	suspendedReq := &models.PageInstancesReq{
+		Status: Suspended,
+	}
+	suspendedReq.Page, suspendedReq.Size = 1, 1
+	_, suspendedCount, _ := i.instanceRepo.PageInstances(i.db, suspendedReq)
+
+	if len(req.AppID) > 0 || len(req.Keyword) > 0 || suspendedCount > 0 {
 		queryWrapper := &models.PageInstancesReq{
 			AppID:   req.AppID,
 			Keyword: req.Keyword,
 		}
+		if suspendedCount > 0 {
+			queryWrapper.Statuses = GetOngoingStatus()
+		}
 		instances, _, _ := i.instanceRepo.PageInstances(i.db, queryWrapper)
 		if len(instances) > 0 {
 			instanceIDs := make([]string, 0)
@@ -787,6 +904,11 @@ func (i *instance) ReviewedList(ctx context.Context, req *TaskListReq) (*page.Re
 				instanceIDs = append(instanceIDs, value.ProcessInstanceID)
 			}
 			tasksReq.InstanceID = instanceIDs
+		} else if suspendedCount > 0 {
+			return &page.RespPage{
+				TotalCount: 0,
+				Data:       make([]*models.ActTaskEntity, 0),
+			}, nil
 		}
 	}
 
@@ -848,11 +970,21 @@ func (i *instance) CcToMeList(ctx context.Context, req *CcListReq) (*page.RespPa
 	orders := []client.QueryOrder{order}
 	tasksReq.Order = orders
 
-	if len(req.AppID) > 0 || len(req.Keyword) > 0 {
+	// 挂起的实例不展示待办，存在挂起实例时只查询进行中实例的任务
+	suspendedReq := &models.PageInstancesReq{
+		Status: Suspended,
+	}
+	suspendedReq.Page, suspendedReq.Size = 1, 1
+	_, suspendedCount, _ := i.instanceRepo.PageInstances(i.db, suspendedReq)
+
+	if len(req.AppID) > 0 || len(req.Keyword) > 0 || suspendedCount > 0 {
 		queryWrapper := &models.PageInstancesReq{
 			AppID:   req.AppID,
 			Keyword: req.Keyword,
 		}
+		if suspendedCount > 0 {
+			queryWrapper.Statuses = GetOngoingStatus()
+		}
 		instances, _, _ := i.instanceRepo.PageInstances(i.db, queryWrapper)
 		if len(instances) > 0 {
 			instanceIDs := make([]string, 0)
@@ -860,6 +992,11 @@ func (i *instance) CcToMeList(ctx context.Context, req *CcListReq) (*page.RespPa
 				instanceIDs = append(instanceIDs, value.ProcessInstanceID)
 			}
 			tasksReq.InstanceID = instanceIDs
+		} else if suspendedCount > 0 {
+			return &page.RespPage{
+				TotalCount: 0,
+				Data:       make([]*models.ActTaskEntity, 0),
+			}, nil
 		}
 	}
 
@@ -910,11 +1047,21 @@ func (i *instance) AllList(ctx context.Context, req *TaskListReq) (*page.RespPag
 	orders := []client.QueryOrder{order}
 	tasksReq.Order = orders
 
-	if len(req.AppID) > 0 || len(req.Keyword) > 0 {
+	// 挂起的实例不展示待办，存在挂起实例时只查询进行中实例的任务
+	suspendedReq := &models.PageInstancesReq{
+		Status: Suspended,
+	}
+	suspendedReq.Page, suspendedReq.Size = 1, 1
+	_, suspendedCount, _ := i.instanceRepo.PageInstances(i.db, suspendedReq)
+
+	if len(req.AppID) > 0 || len(req.Keyword) > 0 || suspendedCount > 0 {
 		queryWrapper := &models.PageInstancesReq{
 			AppID:   req.AppID,
 			Keyword: req.Keyword,
 		}
+		if suspendedCount > 0 {
+			queryWrapper.Statuses = GetOngoingStatus()
+		}
 		instances, _, _ := i.instanceRepo.PageInstances(i.db, queryWrapper)
 		if len(instances) > 0 {
 			instanceIDs := make([]string, 0)
@@ -922,6 +1069,11 @@ func (i *instance) AllList(ctx context.Context, req *TaskListReq) (*page.RespPag
 				instanceIDs = append(instanceIDs, value.ProcessInstanceID)
 			}
 			tasksReq.InstanceID = instanceIDs
+		} else if suspendedCount > 0 {
+			return &page.RespPage{
+				TotalCount: 0,
+				Data:       make([]*models.ActTaskEntity, 0),
+			}, nil
 		}
 	}
 
@@ -984,8 +1136,11 @@ func (i *instance) Cancel(ctx context.Context, processInstanceID string) (bool,
 	if flowInstanceEntity == nil {
 		return false, error2.NewErrorWithString(error2.Internal, "Can not find flow instance data ")
 	}
+	if err = checkNotSuspended(flowInstanceEntity); err != nil {
+		return false, err
+	}
 
-	flowEntity, err := i.flowRepo.FindByID(i.db, flowInstanceEntity.FlowID)
+	flowEntity, err := i.flow.GetInstanceFlow(ctx, flowInstanceEntity)
 	if err != nil {
 		return false, err
 	}
@@ -1011,6 +1166,9 @@ func (i *instance) Cancel(ctx context.Context, processInstanceID string) (bool,
 	updateMap["modifier_id"] = userID
 	updateMap["status"] = Cancel
 	i.instanceRepo.Update(i.db, flowInstanceEntity.ID, updateMap)
+	if err = i.subProcess.Finish(ctx, flowInstanceEntity, Cancel); err != nil {
+		logger.Logger.Error(err)
+	}
 
 	// Add operation record
 	handleTaskModel := &models.HandleTaskModel{
@@ -1033,7 +1191,7 @@ func (i *instance) Resubmit(ctx context.Context, processInstanceID string, param
 		return false, error2.NewErrorWithString(error2.Internal, "Can not find flow instance data ")
 	}
 
-	flowEntity, err := i.flowRepo.FindByID(i.db, flowInstanceEntity.FlowID)
+	flowEntity, err := i.flow.GetInstanceFlow(ctx, flowInstanceEntity)
 	if err != nil {
 		return false, err
 	}
@@ -1102,7 +1260,7 @@ func (i *instance) FlowInfo(ctx context.Context, processInstanceID string) (*mod
 		return nil, error2.NewErrorWithString(error2.Internal, "Can not find flow instance data ")
 	}
 
-	flowEntity, err := i.flowRepo.FindByID(i.db, flowInstanceEntity.FlowID)
+	flowEntity, err := i.flow.GetInstanceFlow(ctx, flowInstanceEntity)
 	if err != nil {
 		return nil, err
 	}
@@ -1127,10 +1285,6 @@ func (i *instance) SendBack(ctx context.Context, processInstanceID string, taskI
 		Name:       "打回重填",
 		Desc:       convert.SendBackTask,
 	}
-	err = i.processAPI.SendBack(ctx, req)
-	if err != nil {
-		return false, err
-	}
 
 	flowInstanceEntity, err := i.instanceRepo.GetEntityByProcessInstanceID(i.db, processInstanceID)
 	if err != nil {
@@ -1139,6 +1293,14 @@ func (i *instance) SendBack(ctx context.Context, processInstanceID string, taskI
 	if flowInstanceEntity == nil {
 		return false, error2.NewErrorWithString(error2.Internal, "Can not find flow instance data ")
 	}
+	if err = checkNotSuspended(flowInstanceEntity); err != nil {
+		return false, err
+	}
+
+	err = i.processAPI.SendBack(ctx, req)
+	if err != nil {
+		return false, err
+	}
 
 	updateMap := make(map[string]interface{}, 0)
 	updateMap["modifier_id"] = userID
@@ -1167,6 +1329,9 @@ func (i *instance) StepBack(ctx context.Context, processInstanceID string, taskI
 	if err != nil {
 		return false, err
 	}
+	if err = checkNotSuspended(flowInstanceEntity); err != nil {
+		return false, err
+	}
 
 	task, err := i.processAPI.CheckActiveTask(ctx, processInstanceID, taskID, userID)
 	if err != nil {
@@ -1475,6 +1640,9 @@ func (i *instance) DeliverTask(ctx context.Context, processInstanceID string, ta
 	if flowInstanceEntity == nil {
 		return false, error2.NewErrorWithString(error2.Internal, "Can not find flow instance data ")
 	}
+	if err = checkNotSuspended(flowInstanceEntity); err != nil {
+		return false, err
+	}
 
 	req := &client.AddHistoryTaskReq{
 		Name:     task.Name,
@@ -1593,6 +1761,9 @@ func (i *instance) AddSign(ctx context.Context, processInstanceID string, taskID
 	if err != nil {
 		return false, err
 	}
+	if err = checkNotSuspended(flowInstanceEntity); err != nil {
+		return false, err
+	}
 
 	task, err := i.processAPI.CheckActiveTask(ctx, processInstanceID, taskID, userID)
 	if err != nil {
@@ -1608,6 +1779,22 @@ func (i *instance) AddSign(ctx context.Context, processInstanceID string, taskID
 		assignees = append(assignees, valueMap["id"].(string))
 	}
 
+	// 依次审批节点的加签插入到处理顺序中
+	entity, err := i.flow.GetInstanceFlow(ctx, flowInstanceEntity)
+	if err != nil {
+		return false, err
+	}
+	var inserted bool
+	if model.Type == "BEFORE" || model.Type == "AFTER" {
+		inserted, err = i.addSignSequence(ctx, entity, processInstanceID, task, assignees, model.Type == "BEFORE")
+		if err != nil {
+			return false, err
+		}
+	}
+	if inserted {
+		model.MultiplePersonWay = convert.Sequential
+	}
+
 	relNodeDefKey := task.NodeDefKey + ":" + id2.GenID() // currentNodeID+":"+newNodeID
 	req := &client.AddTaskReq{
 		TaskID: taskID,
@@ -1626,7 +1813,9 @@ func (i *instance) AddSign(ctx context.Context, processInstanceID string, taskID
 		req.Node.Type = convert.MultiUser
 	}
 
-	if model.Type == "BEFORE" {
+	if inserted {
+		relNodeDefKey = ""
+	} else if model.Type == "BEFORE" {
 		_, err := i.processAPI.AddBeforeModelTask(ctx, req)
 		if err != nil {
 			return false, err
@@ -1683,6 +1872,16 @@ func (i *instance) ReviewTask(ctx context.Context, processInstanceID string, tas
 	if !isReviewStatus(model.HandleType) {
 		return false, error2.NewErrorWithString(error2.Internal, "Handle type must be agree、refuse、fillIn ")
 	}
+	flowInstanceEntity, err := i.instanceRepo.GetEntityByProcessInstanceID(i.db, processInstanceID)
+	if err != nil {
+		return false, err
+	}
+	if flowInstanceEntity == nil {
+		return false, error2.NewErrorWithString(error2.Internal, "Can not find flow instance data ")
+	}
+	if err = checkNotSuspended(flowInstanceEntity); err != nil {
+		return false, err
+	}
 	if model.HandleType == Refuse || model.HandleType == Agree { // 拒绝或者同意
 		variables, err := i.instanceVariablesRepo.FindVariablesByProcessInstanceID(i.db, processInstanceID)
 		if err != nil {
@@ -1704,14 +1903,7 @@ func (i *instance) ReviewTask(ctx context.Context, processInstanceID string, tas
 
 		}
 	}
-	flowInstanceEntity, err := i.instanceRepo.GetEntityByProcessInstanceID(i.db, processInstanceID)
-	if err != nil {
-		return false, err
-	}
-	if flowInstanceEntity == nil {
-		return false, error2.NewErrorWithString(error2.Internal, "Can not find flow instance data ")
-	}
-	entity, err := i.flowRepo.FindByID(i.db, flowInstanceEntity.FlowID)
+	entity, err := i.flow.GetInstanceFlow(ctx, flowInstanceEntity)
 	if err != nil {
 		return false, err
 	}
@@ -1762,8 +1954,27 @@ func (i *instance) ReviewTask(ctx context.Context, processInstanceID string, tas
 		return false, err
 	}
 
+	// 依次审批：未到最后一位处理人时转交给下一位，不完成任务
+	var handed bool
+	if model.HandleType == Agree || model.HandleType == opFillIn {
+		handed, err = i.handToNext(ctx, entity, flowInstanceEntity, task)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	// 会签投票：拒绝不能决定结果时只完成当前任务，结果确定通过后跳过其余审批人
+	var votePass, voteDecided bool
+	voteRule := i.voteRule(entity, task)
+	if voteRule != nil && (model.HandleType == Agree || model.HandleType == Refuse) {
+		votePass, voteDecided, err = i.countVotes(ctx, processInstanceID, task, *voteRule, model.HandleType)
+		if err != nil {
+			return false, err
+		}
+	}
+
 	status := ""
-	if model.HandleType == Refuse { // 拒绝
+	if model.HandleType == Refuse && (voteRule == nil || voteDecided) { // 拒绝
 		shapeModel, err := convert.GetShapeByTaskDefKey(entity.BpmnText, task.NodeDefKey)
 		if err != nil {
 			return false, err
@@ -1788,7 +1999,7 @@ func (i *instance) ReviewTask(ctx context.Context, processInstanceID string, tas
 			}
 		}
 
-	} else {
+	} else if !handed {
 		err = i.processAPI.CompleteTask(ctx, processInstanceID, taskID, i.flow.FormatFormValue(flowInstanceEntity, params), comments)
 		if err != nil {
 			return false, err
@@ -1798,6 +2009,12 @@ func (i *instance) ReviewTask(ctx context.Context, processInstanceID string, tas
 	// 增加操作日志
 	i.operationRecord.AddOperationRecord(ctx, flowInstanceEntity, task, model)
 
+	if voteDecided && votePass {
+		if err := i.skipVoters(ctx, flowInstanceEntity, task, params); err != nil {
+			return false, err
+		}
+	}
+
 	dataMap := make(map[string]interface{})
 	if len(status) > 0 {
 		dataMap["status"] = status
@@ -1835,6 +2052,13 @@ func (i *instance) ReviewTask(ctx context.Context, processInstanceID string, tas
 	if err != nil {
 		return false, err
 	}
+	if instanceStatus := utils.Strval(dataMap["status"]); !IsFlowOngoing(instanceStatus) {
+		err = i.subProcess.Finish(ctx, flowInstanceEntity, instanceStatus)
+		if err != nil {
+			logger.Logger.Error(err)
+		}
+		go i.task.sendFinishedMessage(ctx, flowInstanceEntity, instanceStatus)
+	}
 
 	return true, nil
 }
@@ -1999,7 +2223,7 @@ func (i *instance) GetFormData(ctx context.Context, processInstanceID string, ta
 		return formData, err
 	}
 
-	flowEntity, err := i.flowRepo.FindByID(i.db, flowInstanceEntity.FlowID)
+	flowEntity, err := i.flow.GetInstanceFlow(ctx, flowInstanceEntity)
 	if err != nil {
 		return nil, err
 	}
@@ -2064,7 +2288,7 @@ func (i *instance) GetFlowInstanceForm(ctx context.Context, processInstanceID st
 		return nil, error2.NewErrorWithString(error2.Internal, "Can not find flow instance data ")
 	}
 
-	flowEntity, err := i.flowRepo.FindByID(i.db, flowInstanceEntity.FlowID)
+	flowEntity, err := i.flow.GetInstanceFlow(ctx, flowInstanceEntity)
 	if err != nil {
 		return nil, err
 	}
@@ -2518,6 +2742,41 @@ func (i *instance) propertiesFieldPermissionUpdate(propertiesMap map[string]inte
 	return propertiesMap
 }
 
+// webhookSteps one step for each execution of a webhook node, carrying its attempts
+func (i *instance) webhookSteps(processInstanceID string) ([]*models.InstanceStep, error) {
+	attempts, err := i.webhookAttemptRepo.FindByProcessInstanceID(i.db, processInstanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	steps := make([]*models.InstanceStep, 0)
+	stepMap := make(map[string]*models.InstanceStep)
+	for _, attempt := range attempts {
+		key := attempt.NodeDefKey + ":" + attempt.ExecutionID
+		step, ok := stepMap[key]
+		if !ok {
+			step = &models.InstanceStep{
+				ProcessInstanceID: processInstanceID,
+				TaskType:          stepWebhook,
+				TaskDefKey:        attempt.NodeDefKey,
+				TaskName:          attempt.NodeName,
+				BaseModel: models.BaseModel{
+					CreateTime: attempt.CreateTime,
+				},
+			}
+			stepMap[key] = step
+			steps = append(steps, step)
+		}
+		step.WebhookAttempts = append(step.WebhookAttempts, attempt)
+		step.ModifyTime = attempt.CreateTime
+		step.Status = webhookFail
+		if attempt.Error == "" && attempt.StatusCode >= 200 && attempt.StatusCode < 300 {
+			step.Status = webhookSuccess
+		}
+	}
+	return steps, nil
+}
+
 func (i *instance) ProcessHistories(ctx context.Context, processInstanceID string) ([]*models.InstanceStep, error) {
 	instance, err := i.instanceRepo.GetEntityByProcessInstanceID(i.db, processInstanceID)
 	if err != nil {
@@ -2526,7 +2785,7 @@ func (i *instance) ProcessHistories(ctx context.Context, processInstanceID strin
 	if instance == nil {
 		return nil, error2.NewErrorWithString(code.InvalidInstanceID, "flowInstance is nil")
 	}
-	flow, err := i.flowRepo.FindByID(i.db, instance.FlowID)
+	flow, err := i.flow.GetInstanceFlow(ctx, instance)
 	if err != nil {
 		return nil, err
 	}
@@ -2559,7 +2818,7 @@ func (i *instance) ProcessHistories(ctx context.Context, processInstanceID strin
 			step.FlowName = flow.Name
 			continue
 		}
-		records, err := i.recordRepo.FindRecords(i.db, processInstanceID, step.ID, []string{opDeliver, opRead, opCC, opHandleRead, opHandleCC}, false)
+		records, err := i.recordRepo.FindRecords(i.db, processInstanceID, step.ID, []string{opDeliver, opDelegate, OpEscalate, opRead, opCC, opHandleRead, opHandleCC}, false)
 		if err != nil {
 			return make([]*models.InstanceStep, 0), err
 		}
@@ -2631,7 +2890,7 @@ func (i *instance) ProcessHistories(ctx context.Context, processInstanceID strin
 		i.fillComment(ctx, records)
 		step.OperationRecords = records
 
-		derivationRecords, err := i.operationRecordRepo.FindRecords(i.db, processInstanceID, step.ID, []string{opDeliver, opRead, opCC, opAddSign}, true)
+		derivationRecords, err := i.operationRecordRepo.FindRecords(i.db, processInstanceID, step.ID, []string{opDeliver, opDelegate, OpEscalate, opRead, opCC, opAddSign}, true)
 		for _, derivationRecord := range derivationRecords {
 			user, _ := i.identityAPI.FindUserByID(ctx, derivationRecord.CreatorID)
 			if derivationRecord.HandleType == opDeliver {
@@ -2658,6 +2917,55 @@ func (i *instance) ProcessHistories(ctx context.Context, processInstanceID strin
 				}
 				derivationSteps = append(derivationSteps, derivationStep)
 			}
+			if derivationRecord.HandleType == opDelegate {
+				delegateUser, _ := i.identityAPI.FindUserByID(ctx, derivationRecord.CorrelationData)
+				replenish := &models.OperationRecord{
+					HandleType: opDelegate,
+					BaseModel: models.BaseModel{
+						CreatorID:     delegateUser.ID,
+						CreatorName:   delegateUser.UserName,
+						CreatorAvatar: delegateUser.Avatar,
+					},
+				}
+				derivationStep := &models.InstanceStep{
+					FlowName: "委托",
+					Status:   opDelegate,
+					TaskType: opDelegate,
+					BaseModel: models.BaseModel{
+						CreateTime:    derivationRecord.CreateTime,
+						ModifyTime:    derivationRecord.ModifyTime,
+						CreatorName:   user.UserName,
+						CreatorAvatar: user.Avatar,
+					},
+					OperationRecords: []*models.OperationRecord{replenish},
+				}
+				derivationSteps = append(derivationSteps, derivationStep)
+			}
+			if derivationRecord.HandleType == OpEscalate {
+				replenishes := make([]*models.OperationRecord, 0)
+				if escalateUser, _ := i.identityAPI.FindUserByID(ctx, derivationRecord.CorrelationData); escalateUser != nil && escalateUser.ID != "" {
+					replenishes = append(replenishes, &models.OperationRecord{
+						HandleType: OpEscalate,
+						BaseModel: models.BaseModel{
+							CreatorID:     escalateUser.ID,
+							CreatorName:   escalateUser.UserName,
+							CreatorAvatar: escalateUser.Avatar,
+						},
+					})
+				}
+				derivationStep := &models.InstanceStep{
+					FlowName: "超时升级",
+					Status:   OpEscalate,
+					TaskType: OpEscalate,
+					Reason:   derivationRecord.HandleDesc,
+					BaseModel: models.BaseModel{
+						CreateTime: derivationRecord.CreateTime,
+						ModifyTime: derivationRecord.ModifyTime,
+					},
+					OperationRecords: replenishes,
+				}
+				derivationSteps = append(derivationSteps, derivationStep)
+			}
 			if derivationRecord.HandleType == opRead {
 				operationRecords, _ := i.operationRecordRepo.FindRecords(i.db, processInstanceID, step.ID, []string{opHandleRead}, true)
 				for _, r := range operationRecords {
@@ -2745,6 +3053,16 @@ func (i *instance) ProcessHistories(ctx context.Context, processInstanceID strin
 		}
 
 	}
+	subProcessSteps, err := i.subProcess.Steps(ctx, processInstanceID)
+	if err != nil {
+		return nil, err
+	}
+	derivationSteps = append(derivationSteps, subProcessSteps...)
+	webhookSteps, err := i.webhookSteps(processInstanceID)
+	if err != nil {
+		return nil, err
+	}
+	derivationSteps = append(derivationSteps, webhookSteps...)
 	steps = append(steps, derivationSteps...)
 	sort.Sort(StepSlice(steps))
 
@@ -2809,7 +3127,7 @@ func (i *instance) ProcessHistories(ctx context.Context, processInstanceID strin
 			basicConfig := convert.GetTaskBasicConfigModel(shape)
 			if basicConfig != nil {
 				currentStep.TaskType = convert.GetCurrentNodeType(shape.Type, basicConfig.MultiplePersonWay)
-				taskHandleUserIDs, _ = i.flow.GetTaskHandleUserIDs(ctx, shape, instance)
+				taskHandleUserIDs, _ = i.flow.GetTaskHandleDelegations(ctx, shape, instance)
 			}
 		}
 
@@ -2891,7 +3209,7 @@ func (i *instance) fillComment(ctx context.Context, records []*models.OperationR
 }
 
 func derivation(opType string) bool {
-	return opType != "" && (opCC == opType || opRead == opType || opDeliver == opType)
+	return opType != "" && (opCC == opType || opRead == opType || opDeliver == opType || opDelegate == opType || OpEscalate == opType)
 }
 
 // ProcessTask 需要转换成 ActTaskEntity
@@ -3030,6 +3348,9 @@ type StartFlowModel struct {
 	UserID   string                 `json:"userId" binding:"required"`
 	FormData map[string]interface{} `json:"formData" binding:"required"`
 	FlowID   string                 `json:"flowId" binding:"required"`
+
+	Variables map[string]interface{} `json:"-"` // initial values of flow variables
+	Parent    *models.SubProcess     `json:"-"` // set when started by a sub process node
 }
 
 // MyApplyReq my apply request
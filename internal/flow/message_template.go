@@ -0,0 +1,363 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package flow
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"github.com/quanxiang-cloud/flow/internal/models"
+	"github.com/quanxiang-cloud/flow/internal/models/mysql"
+	"github.com/quanxiang-cloud/flow/internal/server/options"
+	"github.com/quanxiang-cloud/flow/pkg"
+	"github.com/quanxiang-cloud/flow/pkg/client"
+	"github.com/quanxiang-cloud/flow/pkg/config"
+	"github.com/quanxiang-cloud/flow/pkg/misc/error2"
+	"github.com/quanxiang-cloud/flow/pkg/misc/logger"
+	"github.com/quanxiang-cloud/flow/pkg/utils"
+	"gorm.io/gorm"
+)
+
+// templateVarCompile ${form.field}、${variable.code}、${instance.applyNo}、${task.link}，不带前缀时为表单字段或流程变量
+var templateVarCompile = regexp.MustCompile(`\$\{\s*([\w.]+?)\s*\}`)
+
+// MessageTemplate service, per app templates of letter, email and system messages
+type MessageTemplate interface {
+	Save(ctx context.Context, req *SaveMessageTemplateReq) (*models.MessageTemplate, error)
+	Delete(ctx context.Context, req *MessageTemplateReq) (bool, error)
+	List(ctx context.Context, req *ListMessageTemplateReq) ([]*models.MessageTemplate, error)
+	Preview(ctx context.Context, req *PreviewMessageTemplateReq) (*RenderedMessage, error)
+
+	Render(ctx context.Context, templateID string, instance *models.Instance, task *client.ProcessTask) (*RenderedMessage, error)
+	RenderByType(ctx context.Context, templateType string, instance *models.Instance, task *client.ProcessTask) (*RenderedMessage, error)
+}
+
+type messageTemplate struct {
+	db                  *gorm.DB
+	conf                *config.Configs
+	messageTemplateRepo models.MessageTemplateRepo
+	instanceRepo        models.InstanceRepo
+	flow                Flow
+	formAPI             client.Form
+	processAPI          client.Process
+	appCenterAPI        client.AppCenter
+}
+
+// NewMessageTemplate init
+func NewMessageTemplate(conf *config.Configs, opts ...options.Options) (MessageTemplate, error) {
+	flow, err := NewFlow(conf, opts...)
+	if err != nil {
+		return nil, err
+	}
+	m := &messageTemplate{
+		conf:                conf,
+		messageTemplateRepo: mysql.NewMessageTemplateRepo(),
+		instanceRepo:        mysql.NewInstanceRepo(),
+		flow:                flow,
+		formAPI:             client.NewForm(conf),
+		processAPI:          client.NewProcess(conf),
+		appCenterAPI:        client.NewAppCenter(conf),
+	}
+
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m, nil
+}
+
+// SetDB set db
+func (m *messageTemplate) SetDB(db *gorm.DB) {
+	m.db = db
+}
+
+// SaveMessageTemplateReq save message template req, created if id is empty
+type SaveMessageTemplateReq struct {
+	ID      string `json:"id"`
+	AppID   string `json:"appID" binding:"required"`
+	FlowID  string `json:"flowID"`
+	Type    string `json:"type" binding:"required"`
+	Name    string `json:"name"`
+	Title   string `json:"title"`
+	Content string `json:"content" binding:"required"`
+}
+
+// MessageTemplateReq message template req
+type MessageTemplateReq struct {
+	ID string `json:"id" binding:"required"`
+}
+
+// ListMessageTemplateReq list message template req
+type ListMessageTemplateReq struct {
+	AppID  string `json:"appID" binding:"required"`
+	FlowID string `json:"flowID"`
+}
+
+// PreviewMessageTemplateReq preview a saved template or the given title and content,
+// rendered against the instance or sample data if the instance is not given
+type PreviewMessageTemplateReq struct {
+	ID                string `json:"id"`
+	Title             string `json:"title"`
+	Content           string `json:"content"`
+	ProcessInstanceID string `json:"processInstanceID"`
+}
+
+// RenderedMessage rendered title and content
+type RenderedMessage struct {
+	Title   string `json:"title"`
+	Content string `json:"content"`
+}
+
+// Save create or update a message template
+func (m *messageTemplate) Save(ctx context.Context, req *SaveMessageTemplateReq) (*models.MessageTemplate, error) {
+	switch req.Type {
+	case models.MessageTemplateCustom, models.MessageTemplateTaskAssigned, models.MessageTemplateUrge, models.MessageTemplateTimeout, models.MessageTemplateFinished:
+	default:
+		return nil, error2.NewErrorWithString(error2.ErrParams, "unknown template type "+req.Type)
+	}
+	if err := checkAppAdmin(ctx, m.appCenterAPI, req.AppID); err != nil {
+		return nil, err
+	}
+
+	userID := pkg.STDUserID(ctx)
+	if req.ID == "" {
+		if req.Type != models.MessageTemplateCustom {
+			// 系统消息模板在应用或流程内唯一
+			templates, err := m.messageTemplateRepo.FindByAppID(m.db, req.AppID, req.FlowID)
+			if err != nil {
+				return nil, err
+			}
+			for _, t := range templates {
+				if t.FlowID == req.FlowID && t.Type == req.Type {
+					return nil, error2.NewErrorWithString(error2.ErrParams, "template of the type already exists")
+				}
+			}
+		}
+		entity := &models.MessageTemplate{
+			AppID:   req.AppID,
+			FlowID:  req.FlowID,
+			Type:    req.Type,
+			Name:    req.Name,
+			Title:   req.Title,
+			Content: req.Content,
+			BaseModel: models.BaseModel{
+				CreatorID:  userID,
+				ModifierID: userID,
+			},
+		}
+		if err := m.messageTemplateRepo.Create(m.db, entity); err != nil {
+			return nil, err
+		}
+		return entity, nil
+	}
+
+	entity, err := m.messageTemplateRepo.FindByID(m.db, req.ID)
+	if err != nil {
+		return nil, err
+	}
+	if entity == nil || entity.AppID != req.AppID {
+		return nil, error2.NewErrorWithString(error2.ErrParams, "template is not exists")
+	}
+	err = m.messageTemplateRepo.Update(m.db, entity.ID, map[string]interface{}{
+		"name":        req.Name,
+		"title":       req.Title,
+		"content":     req.Content,
+		"modifier_id": userID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	entity.Name, entity.Title, entity.Content = req.Name, req.Title, req.Content
+	return entity, nil
+}
+
+// Delete delete a message template
+func (m *messageTemplate) Delete(ctx context.Context, req *MessageTemplateReq) (bool, error) {
+	entity, err := m.messageTemplateRepo.FindByID(m.db, req.ID)
+	if err != nil {
+		return false, err
+	}
+	if entity == nil {
+		return false, error2.NewErrorWithString(error2.ErrParams, "template is not exists")
+	}
+	if err = checkAppAdmin(ctx, m.appCenterAPI, entity.AppID); err != nil {
+		return false, err
+	}
+	if err = m.messageTemplateRepo.Delete(m.db, entity.ID); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// List templates of the app or the flow
+func (m *messageTemplate) List(ctx context.Context, req *ListMessageTemplateReq) ([]*models.MessageTemplate, error) {
+	if err := checkAppAdmin(ctx, m.appCenterAPI, req.AppID); err != nil {
+		return nil, err
+	}
+	return m.messageTemplateRepo.FindByAppID(m.db, req.AppID, req.FlowID)
+}
+
+// Preview render the template against the instance, or sample data if the instance is not given,
+// only admins of the app of the template and the instance can preview
+func (m *messageTemplate) Preview(ctx context.Context, req *PreviewMessageTemplateReq) (*RenderedMessage, error) {
+	title, content := req.Title, req.Content
+	appID := ""
+	if req.ID != "" {
+		entity, err := m.messageTemplateRepo.FindByID(m.db, req.ID)
+		if err != nil {
+			return nil, err
+		}
+		if entity == nil {
+			return nil, error2.NewErrorWithString(error2.ErrParams, "template is not exists")
+		}
+		if err = checkAppAdmin(ctx, m.appCenterAPI, entity.AppID); err != nil {
+			return nil, err
+		}
+		title, content = entity.Title, entity.Content
+		appID = entity.AppID
+	}
+
+	if req.ProcessInstanceID == "" {
+		values := m.sampleValues()
+		return &RenderedMessage{
+			Title:   renderTemplate(title, values, true),
+			Content: renderTemplate(content, values, true),
+		}, nil
+	}
+
+	instance, err := m.instanceRepo.GetEntityByProcessInstanceID(m.db, req.ProcessInstanceID)
+	if err != nil {
+		return nil, err
+	}
+	if instance == nil {
+		return nil, error2.NewErrorWithString(error2.Internal, "Can not find flow instance data ")
+	}
+	if appID != "" && appID != instance.AppID {
+		return nil, error2.NewErrorWithString(error2.ErrParams, "template and instance are not in the same app")
+	}
+	if err = checkAppAdmin(ctx, m.appCenterAPI, instance.AppID); err != nil {
+		return nil, err
+	}
+	var task *client.ProcessTask
+	if tasks, err := m.processAPI.GetTasksByInstanceID(ctx, instance.ProcessInstanceID); err == nil && len(tasks) > 0 {
+		task = tasks[0]
+	}
+	values := m.templateValues(ctx, instance, task)
+	return &RenderedMessage{
+		Title:   renderTemplate(title, values, false),
+		Content: renderTemplate(content, values, false),
+	}, nil
+}
+
+// Render render the template against the instance
+func (m *messageTemplate) Render(ctx context.Context, templateID string, instance *models.Instance, task *client.ProcessTask) (*RenderedMessage, error) {
+	entity, err := m.messageTemplateRepo.FindByID(m.db, templateID)
+	if err != nil || entity == nil {
+		return nil, err
+	}
+	return m.render(ctx, entity, instance, task), nil
+}
+
+// RenderByType render the template of the type used by the instance's flow, nil if there is no such template
+func (m *messageTemplate) RenderByType(ctx context.Context, templateType string, instance *models.Instance, task *client.ProcessTask) (*RenderedMessage, error) {
+	entity, err := m.messageTemplateRepo.FindByType(m.db, instance.AppID, instance.FlowID, templateType)
+	if err != nil || entity == nil {
+		return nil, err
+	}
+	return m.render(ctx, entity, instance, task), nil
+}
+
+func (m *messageTemplate) render(ctx context.Context, entity *models.MessageTemplate, instance *models.Instance, task *client.ProcessTask) *RenderedMessage {
+	values := m.templateValues(ctx, instance, task)
+	return &RenderedMessage{
+		Title:   renderTemplate(entity.Title, values, false),
+		Content: renderTemplate(entity.Content, values, false),
+	}
+}
+
+// templateValues 模板变量的取值：表单字段、流程变量、实例信息及任务链接
+func (m *messageTemplate) templateValues(ctx context.Context, instance *models.Instance, task *client.ProcessTask) map[string]string {
+	values := make(map[string]string)
+
+	formData, err := m.formAPI.GetFormData(ctx, client.FormDataConditionModel{
+		AppID:   instance.AppID,
+		TableID: instance.FormID,
+		DataID:  instance.FormInstanceID,
+	})
+	if err != nil {
+		logger.Logger.Error("get form data err,", err)
+	}
+	if formData != nil {
+		for k, v := range m.flow.FormatFormValue(instance, formData) {
+			values[k] = utils.Strval(v)
+			values["form."+k] = values[k]
+		}
+	}
+
+	variables, err := m.flow.GetInstanceVariableValues(ctx, instance)
+	if err != nil {
+		logger.Logger.Error("get instance variables err,", err)
+	}
+	for k, v := range variables {
+		values["variable."+k] = utils.Strval(v)
+		if _, ok := values[k]; !ok {
+			values[k] = values["variable."+k]
+		}
+	}
+
+	values["instance.name"] = instance.Name
+	values["instance.applyNo"] = instance.ApplyNo
+	values["instance.applyUserName"] = instance.ApplyUserName
+	values["instance.status"] = GetStatusName(instance.Status)
+	values["instance.createTime"] = utils.ChangeISO8601ToBjTime(instance.CreateTime)
+	if task != nil {
+		values["task.name"] = task.Name
+		values["task.link"] = m.taskLink(instance.ProcessInstanceID, task.ID)
+	}
+	return values
+}
+
+// sampleValues 预览时的示例数据，表单字段和流程变量保留原样
+func (m *messageTemplate) sampleValues() map[string]string {
+	return map[string]string{
+		"instance.name":          "示例流程",
+		"instance.applyNo":       "202201010001",
+		"instance.applyUserName": "张三",
+		"instance.status":        GetStatusName(InReview),
+		"instance.createTime":    "2022-01-01 09:00:00",
+		"task.name":              "审批",
+		"task.link":              m.taskLink("{processInstanceID}", "{taskID}"),
+	}
+}
+
+func (m *messageTemplate) taskLink(processInstanceID string, taskID string) string {
+	return m.conf.APIHost.HomeHost + "approvals/" + processInstanceID + "/" + taskID + "/WAIT_HANDLE_PAGE"
+}
+
+// renderTemplate replace ${key} with the value, unknown keys are kept if keep else removed
+func renderTemplate(tpl string, values map[string]string, keep bool) string {
+	if !strings.Contains(tpl, "${") {
+		return tpl
+	}
+	return templateVarCompile.ReplaceAllStringFunc(tpl, func(s string) string {
+		key := templateVarCompile.FindStringSubmatch(s)[1]
+		if v, ok := values[key]; ok {
+			return v
+		}
+		if keep {
+			return s
+		}
+		return ""
+	})
+}
@@ -0,0 +1,45 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package flow
+
+import "testing"
+
+func Test_renderTemplate(t *testing.T) {
+	values := map[string]string{
+		"amount":                 "100",
+		"form.amount":            "100",
+		"instance.applyUserName": "张三",
+		"task.link":              "http://home/approvals/1/2/WAIT_HANDLE_PAGE",
+	}
+	tests := []struct {
+		name string
+		tpl  string
+		keep bool
+		want string
+	}{
+		{"no placeholder", "审批提醒", false, "审批提醒"},
+		{"form and instance", "${instance.applyUserName}提交了${form.amount}元的申请", false, "张三提交了100元的申请"},
+		{"plain field with spaces", "金额：${ amount }", false, "金额：100"},
+		{"task link", "请点击查看：${task.link}", false, "请点击查看：http://home/approvals/1/2/WAIT_HANDLE_PAGE"},
+		{"unknown removed", "${variable.none}金额${amount}", false, "金额100"},
+		{"unknown kept", "${variable.none}金额${amount}", true, "${variable.none}金额100"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := renderTemplate(tt.tpl, values, tt.keep); got != tt.want {
+				t.Errorf("renderTemplate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
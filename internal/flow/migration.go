@@ -0,0 +1,346 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package flow
+
+import (
+	"context"
+	"reflect"
+	"strings"
+
+	"github.com/quanxiang-cloud/flow/internal/convert"
+	"github.com/quanxiang-cloud/flow/internal/models"
+	"github.com/quanxiang-cloud/flow/internal/models/mysql"
+	"github.com/quanxiang-cloud/flow/internal/server/options"
+	"github.com/quanxiang-cloud/flow/pkg"
+	"github.com/quanxiang-cloud/flow/pkg/client"
+	"github.com/quanxiang-cloud/flow/pkg/config"
+	"github.com/quanxiang-cloud/flow/pkg/misc/error2"
+	"github.com/quanxiang-cloud/flow/pkg/misc/logger"
+	"gorm.io/gorm"
+)
+
+// Migration service, pin running instances to the published flow version and move their active tasks
+// to the mapped nodes. The process engine can not move an instance between deployments, so the published
+// version must keep the node graph of the deployment the instance runs on, the node configuration of the
+// published version is used by the following node events and task handling.
+type Migration interface {
+	MigrateInstances(ctx context.Context, req *MigrateInstancesReq) (*MigrateInstancesResp, error)
+}
+
+type migration struct {
+	db              *gorm.DB
+	instanceRepo    models.InstanceRepo
+	flowRepo        models.FlowRepo
+	flowVersionRepo models.FlowVersionRepo
+	processAPI      client.Process
+	appCenterAPI    client.AppCenter
+	operationRecord OperationRecord
+}
+
+// NewMigration init
+func NewMigration(conf *config.Configs, opts ...options.Options) (Migration, error) {
+	operationRecord, err := NewOperationRecord(conf, opts...)
+	if err != nil {
+		return nil, err
+	}
+	m := &migration{
+		instanceRepo:    mysql.NewInstanceRepo(),
+		flowRepo:        mysql.NewFlowRepo(),
+		flowVersionRepo: mysql.NewFlowVersionRepo(),
+		processAPI:      client.NewProcess(conf),
+		appCenterAPI:    client.NewAppCenter(conf),
+		operationRecord: operationRecord,
+	}
+
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m, nil
+}
+
+// SetDB set db
+func (m *migration) SetDB(db *gorm.DB) {
+	m.db = db
+}
+
+// MigrateInstancesReq migrate instances req
+type MigrateInstancesReq struct {
+	FlowID             string            `json:"flowID" binding:"required"`
+	ProcessInstanceIDs []string          `json:"processInstanceIDs" binding:"required"`
+	NodeMapping        map[string]string `json:"nodeMapping"` // old taskDefKey -> new taskDefKey
+	KeepAssignee       bool              `json:"keepAssignee"`
+	DryRun             bool              `json:"dryRun"`
+	Remark             string            `json:"remark"`
+}
+
+// MigrateInstancesResp migrate instances resp
+type MigrateInstancesResp struct {
+	DryRun    bool                     `json:"dryRun"`
+	Version   int                      `json:"version"`
+	Instances []*MigrateInstanceResult `json:"instances"`
+}
+
+// MigrateInstanceResult migrate result of one instance
+type MigrateInstanceResult struct {
+	ProcessInstanceID string               `json:"processInstanceID"`
+	Migrated          bool                 `json:"migrated"`
+	Reason            string               `json:"reason"`
+	Tasks             []*MigrateTaskResult `json:"tasks"`
+	UnmappedTasks     []*MigrateTaskResult `json:"unmappedTasks"`
+}
+
+// MigrateTaskResult active task mapping
+type MigrateTaskResult struct {
+	TaskID         string `json:"taskID"`
+	TaskName       string `json:"taskName"`
+	Assignee       string `json:"assignee"`
+	FromNodeDefKey string `json:"fromNodeDefKey"`
+	ToNodeDefKey   string `json:"toNodeDefKey"`
+}
+
+func (m *migration) MigrateInstances(ctx context.Context, req *MigrateInstancesReq) (*MigrateInstancesResp, error) {
+	fl, err := m.flowRepo.FindByID(m.db, req.FlowID)
+	if err != nil {
+		return nil, err
+	}
+	if fl == nil {
+		return nil, error2.NewErrorWithString(error2.Internal, "Process is not exists ")
+	}
+	if fl.Status != models.ENABLE {
+		return nil, error2.NewErrorWithString(error2.Internal, "Process is not published ")
+	}
+	if err = checkAppAdmin(ctx, m.appCenterAPI, fl.AppID); err != nil {
+		return nil, err
+	}
+	version, err := m.flowVersionRepo.FindByProcessID(m.db, fl.ProcessID)
+	if err != nil {
+		return nil, err
+	}
+	if version == nil {
+		return nil, error2.NewErrorWithString(error2.Internal, "Flow version is not exists ")
+	}
+
+	resp := &MigrateInstancesResp{
+		DryRun:    req.DryRun,
+		Version:   version.Version,
+		Instances: make([]*MigrateInstanceResult, 0, len(req.ProcessInstanceIDs)),
+	}
+	for _, processInstanceID := range req.ProcessInstanceIDs {
+		result := m.migrateInstance(ctx, req, version, processInstanceID)
+		resp.Instances = append(resp.Instances, result)
+	}
+	return resp, nil
+}
+
+func (m *migration) migrateInstance(ctx context.Context, req *MigrateInstancesReq, version *models.FlowVersion, processInstanceID string) *MigrateInstanceResult {
+	result := &MigrateInstanceResult{
+		ProcessInstanceID: processInstanceID,
+		Tasks:             make([]*MigrateTaskResult, 0),
+		UnmappedTasks:     make([]*MigrateTaskResult, 0),
+	}
+
+	instance, err := m.instanceRepo.GetEntityByProcessInstanceID(m.db, processInstanceID)
+	if err != nil {
+		result.Reason = err.Error()
+		return result
+	}
+	if instance == nil || instance.FlowID != req.FlowID {
+		result.Reason = "instance not belong to flow"
+		return result
+	}
+	if !IsFlowOngoing(instance.Status) {
+		result.Reason = "instance is not ongoing"
+		return result
+	}
+	if instance.FlowVersionID == version.ID {
+		result.Reason = "instance is already on this version"
+		return result
+	}
+
+	// 引擎不能跨部署移动实例，迁移的版本须与实例运行的部署节点图一致
+	processInstance, err := m.processAPI.GetInstanceByID(ctx, processInstanceID)
+	if err != nil {
+		result.Reason = err.Error()
+		return result
+	}
+	if processInstance == nil {
+		result.Reason = "process instance is not exists"
+		return result
+	}
+	running, err := m.flowVersionRepo.FindByProcessID(m.db, processInstance.ProcID)
+	if err != nil {
+		result.Reason = err.Error()
+		return result
+	}
+	if running == nil {
+		result.Reason = "the deployment the instance runs on has no flow version"
+		return result
+	}
+	if !sameGraph(running.BpmnText, version.BpmnText) {
+		result.Reason = "the node graph of this version differs from the deployment the instance runs on"
+		return result
+	}
+
+	tasks, err := m.processAPI.GetTasksByInstanceID(ctx, processInstanceID)
+	if err != nil {
+		result.Reason = err.Error()
+		return result
+	}
+	for _, task := range tasks {
+		if task.TaskType == "NonModel" {
+			// 抄送、阅示任务不参与迁移
+			continue
+		}
+		item := &MigrateTaskResult{
+			TaskID:         task.ID,
+			TaskName:       task.Name,
+			Assignee:       task.Assignee,
+			FromNodeDefKey: task.NodeDefKey,
+		}
+		item.ToNodeDefKey = mapNodeDefKey(req.NodeMapping, version.BpmnText, task.NodeDefKey)
+		if item.ToNodeDefKey == "" {
+			result.UnmappedTasks = append(result.UnmappedTasks, item)
+			continue
+		}
+		result.Tasks = append(result.Tasks, item)
+	}
+
+	if len(result.UnmappedTasks) > 0 {
+		result.Reason = "some active tasks can not be mapped"
+		return result
+	}
+	if req.DryRun {
+		return result
+	}
+
+	taskMap := make(map[string]*client.ProcessTask, len(tasks))
+	for _, task := range tasks {
+		taskMap[task.ID] = task
+	}
+	moved := make(map[string]bool)
+	for _, item := range result.Tasks {
+		task := taskMap[item.TaskID]
+		if baseNodeDefKey(item.FromNodeDefKey) != item.ToNodeDefKey && !moved[item.FromNodeDefKey] {
+			// 同一节点的多个任务只需移动一次
+			if err = m.moveTask(ctx, processInstanceID, task, item.ToNodeDefKey, req.KeepAssignee); err != nil {
+				logger.Logger.Error("migrate task err,", err)
+				result.Reason = err.Error()
+				return result
+			}
+			moved[item.FromNodeDefKey] = true
+		}
+
+		handleTaskModel := &models.HandleTaskModel{
+			HandleType:    opMigrate,
+			HandleDesc:    "将工作流迁移至新版本",
+			Remark:        req.Remark,
+			RelNodeDefKey: item.ToNodeDefKey,
+		}
+		m.operationRecord.AddOperationRecord(ctx, instance, task, handleTaskModel)
+	}
+
+	err = m.instanceRepo.Update(m.db, instance.ID, map[string]interface{}{
+		"flow_version_id": version.ID,
+		"flow_version":    version.Version,
+		"modifier_id":     pkg.STDUserID(ctx),
+	})
+	if err != nil {
+		result.Reason = err.Error()
+		return result
+	}
+	result.Migrated = true
+	return result
+}
+
+// moveTask reposition the token of task, step back when the target node was executed before
+func (m *migration) moveTask(ctx context.Context, processInstanceID string, task *client.ProcessTask, toNodeDefKey string, keepAssignee bool) error {
+	historyResp, err := m.processAPI.GetHistoryTasks(ctx, client.GetTasksReq{
+		InstanceID: []string{processInstanceID},
+		NodeDefKey: toNodeDefKey,
+	})
+	if err != nil {
+		return err
+	}
+	if historyResp != nil && len(historyResp.Data) > 0 {
+		err = m.processAPI.StepBack(ctx, processInstanceID, task.ID, toNodeDefKey)
+	} else {
+		comments := map[string]interface{}{
+			"reviewResult": opMigrate,
+		}
+		err = m.processAPI.CompleteTaskToNode(ctx, processInstanceID, task.ID, nil, toNodeDefKey, comments)
+	}
+	if err != nil {
+		return err
+	}
+	if !keepAssignee || task.Assignee == "" {
+		return nil
+	}
+
+	newTasks, err := m.processAPI.GetTasks(ctx, client.GetTasksReq{
+		InstanceID: []string{processInstanceID},
+		NodeDefKey: toNodeDefKey,
+	})
+	if err != nil {
+		return err
+	}
+	for _, newTask := range newTasks.Data {
+		if err = m.processAPI.SetAssignee(ctx, processInstanceID, newTask.ID, task.Assignee); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mapNodeDefKey find the node of new flow model json for an old taskDefKey, returns "" if can not be mapped
+func mapNodeDefKey(mapping map[string]string, bpmnText string, nodeDefKey string) string {
+	key := baseNodeDefKey(nodeDefKey)
+	if target, ok := mapping[key]; ok {
+		key = target
+	}
+	shape, err := convert.GetShapeByTaskDefKey(bpmnText, key)
+	if err != nil || shape == nil {
+		return ""
+	}
+	return shape.ID
+}
+
+// sameGraph the process engine runs both flow model jsons the same way: same nodes, node types, next nodes and conditions
+func sameGraph(a string, b string) bool {
+	nodesA, _, err := convert.GenProcessNodes(a)
+	if err != nil {
+		return false
+	}
+	nodesB, _, err := convert.GenProcessNodes(b)
+	if err != nil {
+		return false
+	}
+	return reflect.DeepEqual(processGraph(nodesA), processGraph(nodesB))
+}
+
+func processGraph(nodes []*convert.ProcessNodeModel) map[string]string {
+	graph := make(map[string]string, len(nodes))
+	for _, node := range nodes {
+		next := make([]string, 0, len(node.NextNodes))
+		for _, nextNode := range node.NextNodes {
+			next = append(next, nextNode.ID+"?"+nextNode.Condition)
+		}
+		graph[node.ID] = node.Type + ":" + strings.Join(next, ",")
+	}
+	return graph
+}
+
+// baseNodeDefKey 加签节点ID如下 currentNodeID+":"+newNodeID
+func baseNodeDefKey(nodeDefKey string) string {
+	return strings.Split(nodeDefKey, ":")[0]
+}
@@ -0,0 +1,191 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package flow
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/quanxiang-cloud/flow/internal/models"
+	"github.com/quanxiang-cloud/flow/pkg/client"
+	"gorm.io/gorm"
+)
+
+type fakeMigrationFlowRepo struct {
+	models.FlowRepo
+	flow *models.Flow
+}
+
+func (r *fakeMigrationFlowRepo) FindByID(db *gorm.DB, ID string) (*models.Flow, error) {
+	return r.flow, nil
+}
+
+type fakeMigrationVersionRepo struct {
+	models.FlowVersionRepo
+	versions []*models.FlowVersion
+}
+
+func (r *fakeMigrationVersionRepo) FindByProcessID(db *gorm.DB, processID string) (*models.FlowVersion, error) {
+	for _, version := range r.versions {
+		if version.ProcessID == processID {
+			return version, nil
+		}
+	}
+	return nil, nil
+}
+
+type fakeMigrationInstanceRepo struct {
+	models.InstanceRepo
+	instance *models.Instance
+}
+
+func (r *fakeMigrationInstanceRepo) GetEntityByProcessInstanceID(db *gorm.DB, processInstanceID string) (*models.Instance, error) {
+	return r.instance, nil
+}
+
+func (r *fakeMigrationInstanceRepo) Update(db *gorm.DB, ID string, updateMap map[string]interface{}) error {
+	r.instance.FlowVersionID = updateMap["flow_version_id"].(string)
+	r.instance.FlowVersion = updateMap["flow_version"].(int)
+	return nil
+}
+
+type fakeMigrationProcess struct {
+	client.Process
+	procID   string
+	tasks    []*client.ProcessTask
+	history  []*client.ProcessTask
+	stepBack []string
+}
+
+func (p *fakeMigrationProcess) GetInstanceByID(ctx context.Context, processInstanceID string) (*client.ProcessInstance, error) {
+	return &client.ProcessInstance{ID: processInstanceID, ProcID: p.procID}, nil
+}
+
+func (p *fakeMigrationProcess) GetTasksByInstanceID(ctx context.Context, instanceID string) ([]*client.ProcessTask, error) {
+	return p.tasks, nil
+}
+
+func (p *fakeMigrationProcess) GetHistoryTasks(ctx context.Context, req client.GetTasksReq) (*client.GetTasksResp, error) {
+	data := make([]*client.ProcessTask, 0)
+	for _, task := range p.history {
+		if task.NodeDefKey == req.NodeDefKey {
+			data = append(data, task)
+		}
+	}
+	return &client.GetTasksResp{Data: data}, nil
+}
+
+func (p *fakeMigrationProcess) StepBack(ctx context.Context, processInstanceID string, taskID string, nodeDefKey string) error {
+	p.stepBack = append(p.stepBack, taskID+"->"+nodeDefKey)
+	return nil
+}
+
+type fakeMigrationAppCenter struct {
+	client.AppCenter
+}
+
+func (a *fakeMigrationAppCenter) GetAdminAppIDs(c context.Context) ([]string, error) {
+	return []string{"app1"}, nil
+}
+
+type fakeMigrationRecord struct {
+	OperationRecord
+	records int
+}
+
+func (o *fakeMigrationRecord) AddOperationRecord(ctx context.Context, instance *models.Instance, task *client.ProcessTask, handleTaskModel *models.HandleTaskModel) error {
+	o.records++
+	return nil
+}
+
+// migrationChart form -> approve nodes -> end, the approver of every node is user
+func migrationChart(user string, approves ...string) string {
+	nodes := append(append([]string{"form"}, approves...), "end")
+	shapes := ""
+	for i, id := range nodes {
+		children := ""
+		if i+1 < len(nodes) {
+			children = `"` + nodes[i+1] + `"`
+		}
+		switch id {
+		case "form":
+			shapes += fmt.Sprintf(`{"id":"form","type":"formData","data":{"nodeData":{"name":"开始","childrenID":[%s]},"businessData":{"form":{"value":"form1"}}}},`, children)
+		case "end":
+			shapes += `{"id":"end","type":"end","data":{"nodeData":{"name":"结束"}}}`
+		default:
+			shapes += fmt.Sprintf(`{"id":"%s","type":"approve","data":{"nodeData":{"name":"审批%s","childrenID":[%s]},"businessData":{"basicConfig":{"multiplePersonWay":"or","approvePersons":{"type":"person","users":[{"id":"%s"}]}}}}},`, id, id, children, user)
+		}
+	}
+	return `{"shapes":[` + shapes + `]}`
+}
+
+func TestMigrateInstances(t *testing.T) {
+	v1 := &models.FlowVersion{BaseModel: models.BaseModel{ID: "v1"}, FlowID: "f1", Version: 1, ProcessID: "proc1", BpmnText: migrationChart("u1", "a1", "a2")}
+	v2 := &models.FlowVersion{BaseModel: models.BaseModel{ID: "v2"}, FlowID: "f1", Version: 2, ProcessID: "proc2", BpmnText: migrationChart("u2", "a1", "a2")}
+	v3 := &models.FlowVersion{BaseModel: models.BaseModel{ID: "v3"}, FlowID: "f1", Version: 3, ProcessID: "proc3", BpmnText: migrationChart("u2", "a1")}
+
+	tests := []struct {
+		name     string
+		target   *models.FlowVersion
+		mapping  map[string]string
+		migrated bool
+		stepBack []string
+	}{
+		{"same graph", v2, nil, true, nil},
+		{"move task", v2, map[string]string{"a2": "a1"}, true, []string{"t1->a1"}},
+		{"graph changed", v3, nil, false, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			instance := &models.Instance{FlowID: "f1", ProcessInstanceID: "pi1", Status: InReview, FlowVersionID: v1.ID, FlowVersion: v1.Version}
+			process := &fakeMigrationProcess{
+				procID:  v1.ProcessID,
+				tasks:   []*client.ProcessTask{{ID: "t1", NodeDefKey: "a2", Assignee: "u1"}},
+				history: []*client.ProcessTask{{ID: "t0", NodeDefKey: "a1"}},
+			}
+			record := &fakeMigrationRecord{}
+			m := &migration{
+				instanceRepo:    &fakeMigrationInstanceRepo{instance: instance},
+				flowRepo:        &fakeMigrationFlowRepo{flow: &models.Flow{BaseModel: models.BaseModel{ID: "f1"}, AppID: "app1", Status: models.ENABLE, ProcessID: tt.target.ProcessID}},
+				flowVersionRepo: &fakeMigrationVersionRepo{versions: []*models.FlowVersion{v1, v2, v3}},
+				processAPI:      process,
+				appCenterAPI:    &fakeMigrationAppCenter{},
+				operationRecord: record,
+			}
+
+			resp, err := m.MigrateInstances(context.Background(), &MigrateInstancesReq{
+				FlowID:             "f1",
+				ProcessInstanceIDs: []string{"pi1"},
+				NodeMapping:        tt.mapping,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			result := resp.Instances[0]
+			if result.Migrated != tt.migrated {
+				t.Fatalf("migrated = %v, want %v: %s", result.Migrated, tt.migrated, result.Reason)
+			}
+			if tt.migrated && (instance.FlowVersionID != tt.target.ID || instance.FlowVersion != tt.target.Version || record.records != 1) {
+				t.Errorf("instance is not moved to version %d: %+v, records %d", tt.target.Version, instance, record.records)
+			}
+			if !tt.migrated && instance.FlowVersionID != v1.ID {
+				t.Errorf("instance should stay on version 1: %+v", instance)
+			}
+			if fmt.Sprint(process.stepBack) != fmt.Sprint(tt.stepBack) {
+				t.Errorf("step back = %v, want %v", process.stepBack, tt.stepBack)
+			}
+		})
+	}
+}
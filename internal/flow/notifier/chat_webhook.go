@@ -0,0 +1,120 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/quanxiang-cloud/flow/pkg/client"
+	"github.com/quanxiang-cloud/flow/pkg/config"
+)
+
+const (
+	chatSlack    = "slack"
+	chatDingTalk = "dingtalk"
+	chatFeishu   = "feishu"
+)
+
+// chatWebhook 群机器人，按slack、钉钉、飞书的格式推送
+type chatWebhook struct {
+	conf   config.ChatWebhook
+	client http.Client
+}
+
+func newChatWebhook(conf *config.Configs) *chatWebhook {
+	return &chatWebhook{
+		conf:   conf.Notifier.ChatWebhook,
+		client: client.NewClient(conf.InternalNet),
+	}
+}
+
+func (c *chatWebhook) Name() string {
+	return ChannelChat
+}
+
+func (c *chatWebhook) Send(ctx context.Context, msg *Message) error {
+	names := make([]string, 0, len(msg.Receivers))
+	for _, user := range msg.Receivers {
+		names = append(names, user.UserName)
+	}
+	content := msg.Content
+	if len(names) > 0 {
+		content += "\n处理人：" + strings.Join(names, "、")
+	}
+	return postJSON(ctx, &c.client, c.conf.URL, chatPayload(c.conf.Type, msg.Title, content))
+}
+
+// chatPayload 各平台机器人的消息格式
+func chatPayload(kind string, title string, content string) interface{} {
+	switch kind {
+	case chatDingTalk:
+		return map[string]interface{}{
+			"msgtype": "markdown",
+			"markdown": map[string]string{
+				"title": title,
+				"text":  "### " + title + "\n\n" + content,
+			},
+		}
+	case chatFeishu:
+		return map[string]interface{}{
+			"msg_type": "text",
+			"content": map[string]string{
+				"text": title + "\n" + content,
+			},
+		}
+	default:
+		return map[string]string{
+			"text": "*" + title + "*\n" + content,
+		}
+	}
+}
+
+// postJSON post to an external webhook, non 2xx status or a non zero errcode/code in the body is an error
+func postJSON(ctx context.Context, c *http.Client, url string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	respBody, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("status %d: %s", resp.StatusCode, respBody)
+	}
+	result := struct {
+		ErrCode int    `json:"errcode"`
+		Code    int    `json:"code"`
+		ErrMsg  string `json:"errmsg"`
+		Msg     string `json:"msg"`
+	}{}
+	// slack等返回非json时忽略
+	if json.Unmarshal(respBody, &result) == nil && (result.ErrCode != 0 || result.Code != 0) {
+		return fmt.Errorf("code %d: %s%s", result.ErrCode+result.Code, result.ErrMsg, result.Msg)
+	}
+	return nil
+}
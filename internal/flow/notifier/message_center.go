@@ -0,0 +1,84 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notifier
+
+import (
+	"context"
+
+	"github.com/quanxiang-cloud/flow/pkg/client"
+)
+
+// letter 站内信
+type letter struct {
+	messageCenterAPI client.MessageCenter
+}
+
+func (l *letter) Name() string {
+	return ChannelLetter
+}
+
+func (l *letter) Send(ctx context.Context, msg *Message) error {
+	receivers := make([]client.Receivers, 0, len(msg.Receivers))
+	for _, user := range msg.Receivers {
+		receivers = append(receivers, client.Receivers{
+			Type: 1,
+			ID:   user.ID,
+			Name: user.UserName,
+		})
+	}
+	if len(receivers) == 0 {
+		return ErrNoReceiver
+	}
+	return l.messageCenterAPI.MessageCreateff(ctx, client.Mail{
+		Web: client.Web{
+			IsSend: true,
+			Title:  msg.Title,
+			Contents: client.Contents{
+				Content: msg.Content,
+			},
+			Receivers: receivers,
+			Types:     1,
+		},
+	})
+}
+
+// email 邮件
+type email struct {
+	messageCenterAPI client.MessageCenter
+}
+
+func (e *email) Name() string {
+	return ChannelEmail
+}
+
+func (e *email) Send(ctx context.Context, msg *Message) error {
+	emailAddrs := make([]string, 0, len(msg.Receivers))
+	for _, user := range msg.Receivers {
+		if len(user.Email) > 0 {
+			emailAddrs = append(emailAddrs, user.Email)
+		}
+	}
+	if len(emailAddrs) == 0 {
+		return ErrNoReceiver
+	}
+	return e.messageCenterAPI.MessageCreate(ctx, client.MsgReq{
+		Email: client.Email{
+			To: emailAddrs,
+			Contents: client.Contents{
+				Content: msg.Content,
+			},
+			Title: msg.Title,
+		},
+	})
+}
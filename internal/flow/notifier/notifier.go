@@ -0,0 +1,189 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/quanxiang-cloud/flow/internal/models"
+	"github.com/quanxiang-cloud/flow/internal/models/mysql"
+	"github.com/quanxiang-cloud/flow/internal/server/options"
+	"github.com/quanxiang-cloud/flow/pkg/client"
+	"github.com/quanxiang-cloud/flow/pkg/config"
+	"github.com/quanxiang-cloud/flow/pkg/misc/logger"
+	"gorm.io/gorm"
+)
+
+const (
+	// EventTaskAssigned new task of the assignee
+	EventTaskAssigned = models.MessageTemplateTaskAssigned
+	// EventUrge task urged
+	EventUrge = models.MessageTemplateUrge
+	// EventTimeout task timed out
+	EventTimeout = models.MessageTemplateTimeout
+	// EventFinished instance finished, sent to the applicant
+	EventFinished = models.MessageTemplateFinished
+)
+
+const (
+	// ChannelLetter 站内信
+	ChannelLetter = "letter"
+	// ChannelEmail 邮件
+	ChannelEmail = "email"
+	// ChannelChat 群机器人
+	ChannelChat = "chat"
+	// ChannelSMS 短信
+	ChannelSMS = "sms"
+)
+
+// defaultChannels 流程未配置时各事件的通知渠道
+var defaultChannels = map[string][]string{
+	EventTaskAssigned: {ChannelEmail},
+	EventTimeout:      {ChannelEmail},
+}
+
+// ErrNoReceiver none of the receivers has an address of the channel
+var ErrNoReceiver = errors.New("no receiver of the channel")
+
+// Message notification to send
+type Message struct {
+	Event             string
+	ProcessInstanceID string
+	TaskID            string
+	Title             string
+	Content           string
+	Receivers         []*client.UserInfoResp
+}
+
+// Notifier a notification channel
+type Notifier interface {
+	Name() string
+	Send(ctx context.Context, msg *Message) error
+}
+
+// Registry notification channels, sends a message on the given channels and records the results
+type Registry interface {
+	Register(n Notifier)
+	Notify(ctx context.Context, channels []string, msg *Message)
+}
+
+type registry struct {
+	db               *gorm.DB
+	notifiers        map[string]Notifier
+	notifyRecordRepo models.NotifyRecordRepo
+}
+
+// NewRegistry init, message center channels are always registered, chat and sms if configured
+func NewRegistry(conf *config.Configs, opts ...options.Options) (Registry, error) {
+	r := &registry{
+		notifiers:        make(map[string]Notifier),
+		notifyRecordRepo: mysql.NewNotifyRecordRepo(),
+	}
+	messageCenterAPI := client.NewMessageCenter(conf)
+	r.Register(&letter{messageCenterAPI: messageCenterAPI})
+	r.Register(&email{messageCenterAPI: messageCenterAPI})
+	if conf.Notifier.ChatWebhook.URL != "" {
+		r.Register(newChatWebhook(conf))
+	}
+	if conf.Notifier.SMS.Host != "" {
+		r.Register(newSMS(conf))
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r, nil
+}
+
+// SetDB set db
+func (r *registry) SetDB(db *gorm.DB) {
+	r.db = db
+}
+
+// Register add or replace a channel
+func (r *registry) Register(n Notifier) {
+	r.notifiers[n.Name()] = n
+}
+
+// Notify send the message on each channel, failures are logged and recorded but not returned
+func (r *registry) Notify(ctx context.Context, channels []string, msg *Message) {
+	receiverIDs := make([]string, 0, len(msg.Receivers))
+	for _, user := range msg.Receivers {
+		receiverIDs = append(receiverIDs, user.ID)
+	}
+	for _, channel := range channels {
+		record := &models.NotifyRecord{
+			ProcessInstanceID: msg.ProcessInstanceID,
+			TaskID:            msg.TaskID,
+			Event:             msg.Event,
+			Channel:           channel,
+			Receivers:         strings.Join(receiverIDs, ","),
+			Title:             msg.Title,
+			Status:            models.NotifySuccess,
+		}
+		var err error
+		if n, ok := r.notifiers[channel]; ok {
+			err = n.Send(ctx, msg)
+		} else {
+			err = errors.New("channel " + channel + " is not available")
+		}
+		switch {
+		case err == ErrNoReceiver:
+			record.Status = models.NotifySkipped
+		case err != nil:
+			record.Status = models.NotifyFailed
+			record.Error = err.Error()
+			logger.Logger.Errorf("notify %s by %s failed, instance %s: %s", msg.Event, channel, msg.ProcessInstanceID, err)
+		default:
+			logger.Logger.Infof("notify %s by %s, instance %s, receivers %v", msg.Event, channel, msg.ProcessInstanceID, receiverIDs)
+		}
+		if err = r.notifyRecordRepo.Create(r.db, record); err != nil {
+			logger.Logger.Error("create notify record err,", err)
+		}
+	}
+}
+
+// IsChannel whether the name is a known channel
+func IsChannel(name string) bool {
+	switch name {
+	case ChannelLetter, ChannelEmail, ChannelChat, ChannelSMS:
+		return true
+	}
+	return false
+}
+
+// IsEvent whether the name is a known event
+func IsEvent(name string) bool {
+	switch name {
+	case EventTaskAssigned, EventUrge, EventTimeout, EventFinished:
+		return true
+	}
+	return false
+}
+
+// Channels channels of the event configured by the flow, the defaults if the flow does not set the event
+func Channels(notifyChannels string, event string) []string {
+	if notifyChannels != "" {
+		setting := make(map[string][]string)
+		if err := json.Unmarshal([]byte(notifyChannels), &setting); err != nil {
+			logger.Logger.Error("bad notify channels,", err)
+		} else if channels, ok := setting[event]; ok {
+			return channels
+		}
+	}
+	return defaultChannels[event]
+}
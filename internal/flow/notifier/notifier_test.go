@@ -0,0 +1,63 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notifier
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestChannels(t *testing.T) {
+	setting := `{"TASK_ASSIGNED":["letter","chat"],"TIMEOUT":[]}`
+	tests := []struct {
+		name           string
+		notifyChannels string
+		event          string
+		want           []string
+	}{
+		{"default of task assigned", "", EventTaskAssigned, []string{ChannelEmail}},
+		{"no default of finished", "", EventFinished, nil},
+		{"set by flow", setting, EventTaskAssigned, []string{ChannelLetter, ChannelChat}},
+		{"turned off by flow", setting, EventTimeout, []string{}},
+		{"not set by flow", setting, EventUrge, nil},
+		{"bad setting", "{", EventTaskAssigned, []string{ChannelEmail}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Channels(tt.notifyChannels, tt.event); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Channels() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_chatPayload(t *testing.T) {
+	tests := []struct {
+		kind string
+		want string
+	}{
+		{chatSlack, `{"text":"*审批提醒*\n请处理"}`},
+		{chatDingTalk, `{"markdown":{"text":"### 审批提醒\n\n请处理","title":"审批提醒"},"msgtype":"markdown"}`},
+		{chatFeishu, `{"content":{"text":"审批提醒\n请处理"},"msg_type":"text"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.kind, func(t *testing.T) {
+			b, _ := json.Marshal(chatPayload(tt.kind, "审批提醒", "请处理"))
+			if string(b) != tt.want {
+				t.Errorf("chatPayload() = %s, want %s", b, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notifier
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/quanxiang-cloud/flow/pkg/client"
+	"github.com/quanxiang-cloud/flow/pkg/config"
+)
+
+// sms 短信网关
+type sms struct {
+	conf   config.SMS
+	client http.Client
+}
+
+func newSMS(conf *config.Configs) *sms {
+	return &sms{
+		conf:   conf.Notifier.SMS,
+		client: client.NewClient(conf.InternalNet),
+	}
+}
+
+func (s *sms) Name() string {
+	return ChannelSMS
+}
+
+func (s *sms) Send(ctx context.Context, msg *Message) error {
+	phones := make([]string, 0, len(msg.Receivers))
+	for _, user := range msg.Receivers {
+		if len(user.Phone) > 0 {
+			phones = append(phones, user.Phone)
+		}
+	}
+	if len(phones) == 0 {
+		return ErrNoReceiver
+	}
+	return postJSON(ctx, &s.client, s.conf.Host, map[string]interface{}{
+		"phones":  phones,
+		"sign":    s.conf.Sign,
+		"title":   msg.Title,
+		"content": msg.Content,
+	})
+}
@@ -54,6 +54,8 @@ const (
 
 	// OpDeliver Operation
 	opDeliver = "DELIVER" // 转交
+	// OpDelegate Operation
+	opDelegate = "DELEGATE" // 委托
 	// OpStepBack Operation
 	opStepBack = "STEP_BACK" // 回退
 	// OpSendBack Operation
@@ -73,8 +75,16 @@ const (
 	opAutoReview = "AUTO_REVIEW" // 自动审批
 	// OpAutoSkip Operation
 	OpAutoSkip = "AUTO_SKIP" // 跳过
+	// OpDataDelete Operation
+	OpDataDelete = "DATA_DELETE" // 删除数据
 	// OpAutoCC Operation
 	opAutoCC = "AUTO_CC" // 自动抄送
+	// OpMigrate Operation
+	opMigrate = "MIGRATE" // 迁移
+	// OpEscalate Operation
+	OpEscalate = "ESCALATE" // 超时升级
+	// opContinue Operation
+	opContinue = "CONTINUE" // 管理员继续执行挂起的节点
 )
 
 // OperationRecord service
@@ -222,6 +232,10 @@ func (or *operationRecord) processTaskStep(ctx context.Context, instance *models
 		fallthrough
 	case opDeliver:
 		fallthrough
+	case opDelegate:
+		fallthrough
+	case OpEscalate:
+		fallthrough
 	case opCC:
 		fallthrough
 	case opRead:
@@ -237,6 +251,10 @@ func (or *operationRecord) processTaskStep(ctx context.Context, instance *models
 	case opSendBack:
 		fallthrough
 	case opAddSign:
+		fallthrough
+	case opMigrate:
+		fallthrough
+	case opContinue:
 		ID = or.processBaseTaskStep(ctx, instance, task, handleTaskModel)
 	case opReSubmit:
 		ID = or.processReSubmitTaskStep(ctx, instance.ProcessInstanceID)
@@ -263,7 +281,7 @@ func (or *operationRecord) processSubmitTaskStep(ctx context.Context, processIns
 func (or *operationRecord) processAutoSkipTaskStep(ctx context.Context, instance *models.Instance, task *client.ProcessTask) string {
 	logger.Logger.Info("processAutoSkipTaskStep")
 	logger.Logger.Info(task)
-	f, _ := or.flowRepo.FindByID(or.db, instance.FlowID)
+	f, _ := or.flow.GetInstanceFlow(ctx, instance)
 	// 获取当前节点类型
 	// 节点相关数据
 	shape, _ := convert.GetShapeByTaskDefKey(f.BpmnText, task.NodeDefKey)
@@ -313,7 +331,7 @@ func (or *operationRecord) processBaseTaskStep(ctx context.Context, instance *mo
 	processInstanceID := instance.ProcessInstanceID
 	userID := pkg.STDUserID(ctx)
 
-	f, _ := or.flowRepo.FindByID(or.db, instance.FlowID)
+	f, _ := or.flow.GetInstanceFlow(ctx, instance)
 	// 获取当前节点类型
 	// 节点相关数据
 	shape, _ := convert.GetShapeByTaskDefKey(f.BpmnText, task.NodeDefKey)
@@ -359,7 +377,7 @@ func (or *operationRecord) processBaseTaskStep(ctx context.Context, instance *mo
 			relRecord, _ := or.operationRecordRepo.FindRecordByRelDefKey(or.db, processInstanceID, task.NodeDefKey)
 			taskHandleUserIDStr = relRecord.CorrelationData
 		} else {
-			taskHandleUserIds, _ := or.flow.GetTaskHandleUserIDs(ctx, shape, instance)
+			taskHandleUserIds, _ := or.flow.GetTaskHandleDelegations(ctx, shape, instance)
 			taskHandleUserIDStr = strings.Join(taskHandleUserIds, ",")
 		}
 
@@ -638,7 +656,7 @@ func (or *operationRecord) agree(ctx context.Context, processInstanceID string,
 	}
 	if step == nil {
 		flowInstance, _ := or.instanceRepo.GetEntityByProcessInstanceID(or.db, processInstanceID)
-		flow, _ := or.flowRepo.FindByID(or.db, flowInstance.FlowID)
+		flow, _ := or.flow.GetInstanceFlow(ctx, flowInstance)
 		// 获取当前节点类型
 		// 节点相关数据
 		shape, _ := convert.GetShapeByTaskDefKey(flow.BpmnText, task.NodeDefKey)
@@ -746,7 +764,7 @@ func (or *operationRecord) linkNextStep(ctx context.Context, processInstanceID s
 		or.linkEndStep(ctx, processInstanceID, userID)
 	} else {
 		flowInstance, _ := or.instanceRepo.GetEntityByProcessInstanceID(or.db, processInstanceID)
-		flow, _ := or.flowRepo.FindByID(or.db, flowInstance.FlowID)
+		flow, _ := or.flow.GetInstanceFlow(ctx, flowInstance)
 		currentTask := tasksResp.Data[0]
 
 		// 获取当前节点类型
@@ -758,7 +776,7 @@ func (or *operationRecord) linkNextStep(ctx context.Context, processInstanceID s
 		basicConfig := convert.GetTaskBasicConfigModel(shape)
 
 		// 该节点定义的可以审批的人
-		taskHandleUserIds, _ := or.flow.GetTaskHandleUserIDs(ctx, shape, flowInstance)
+		taskHandleUserIds, _ := or.flow.GetTaskHandleDelegations(ctx, shape, flowInstance)
 
 		// 该节点的类型
 		currentNodeType := convert.GetCurrentNodeType(shape.Type, basicConfig.MultiplePersonWay)
@@ -0,0 +1,118 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package flow
+
+import (
+	"context"
+	"strings"
+
+	"github.com/quanxiang-cloud/flow/internal/convert"
+	"github.com/quanxiang-cloud/flow/internal/models"
+	"github.com/quanxiang-cloud/flow/pkg/client"
+	"github.com/quanxiang-cloud/flow/pkg/misc/logger"
+)
+
+// sequence assignees of the sequential node of the task, nil if the node is not sequential
+func (i *instance) sequence(flow *models.Flow, processInstanceID string, task *client.ProcessTask) (*models.ApproveSequence, error) {
+	shape, err := convert.GetShapeByTaskDefKey(flow.BpmnText, task.NodeDefKey)
+	if err != nil || shape == nil {
+		return nil, err
+	}
+	basicConfig := convert.GetTaskBasicConfigModel(shape)
+	if basicConfig == nil || basicConfig.MultiplePersonWay != convert.Sequential {
+		return nil, nil
+	}
+	return i.approveSequenceRepo.FindByNodeDefKey(i.db, processInstanceID, task.NodeDefKey)
+}
+
+// handToNext the current assignee of a sequential node has agreed, the task is handed to the next one
+// instead of being completed, false when the current assignee is the last one
+func (i *instance) handToNext(ctx context.Context, flow *models.Flow, instance *models.Instance, task *client.ProcessTask) (bool, error) {
+	processInstanceID := instance.ProcessInstanceID
+	seq, err := i.sequence(flow, processInstanceID, task)
+	if err != nil || seq == nil {
+		return false, err
+	}
+	assignees := splitAssignees(seq.Assignees)
+	next := seq.Current + 1
+	if next >= len(assignees) {
+		return false, nil
+	}
+
+	// 当前处理人的任务记入已处理
+	_, err = i.processAPI.AddHistoryTask(ctx, &client.AddHistoryTaskReq{
+		Name:        task.Name,
+		Desc:        task.Desc,
+		Assignee:    task.Assignee,
+		UserID:      task.Assignee,
+		NodeDefKey:  task.NodeDefKey,
+		InstanceID:  processInstanceID,
+		ExecutionID: task.ExecutionID,
+	})
+	if err != nil {
+		return false, err
+	}
+	if err := i.processAPI.SetAssignee(ctx, processInstanceID, task.ID, assignees[next]); err != nil {
+		return false, err
+	}
+	logger.Logger.Infow("sequential task handed to next", "processInstanceID", processInstanceID, "nodeDefKey", task.NodeDefKey, "assignee", assignees[next])
+	go i.task.sendHandleMessage(ctx, instance, task, assignees[next:next+1])
+	return true, i.approveSequenceRepo.Update(i.db, seq.ID, map[string]interface{}{
+		"current": next,
+	})
+}
+
+// addSignSequence insert the assignees into the sequence before or after the current one,
+// false if the node is not sequential
+func (i *instance) addSignSequence(ctx context.Context, flow *models.Flow, processInstanceID string, task *client.ProcessTask, userIDs []string, before bool) (bool, error) {
+	seq, err := i.sequence(flow, processInstanceID, task)
+	if err != nil || seq == nil {
+		return false, err
+	}
+	assignees := insertAssignees(splitAssignees(seq.Assignees), seq.Current, userIDs, before)
+	if before {
+		if err := i.processAPI.SetAssignee(ctx, processInstanceID, task.ID, userIDs[0]); err != nil {
+			return false, err
+		}
+	}
+	return true, i.approveSequenceRepo.Update(i.db, seq.ID, map[string]interface{}{
+		"assignees": strings.Join(assignees, ","),
+	})
+}
+
+// insertAssignees insert userIDs before or after the current assignee, the current index points to
+// the first inserted one when inserting before
+func insertAssignees(assignees []string, current int, userIDs []string, before bool) []string {
+	at := current + 1
+	if before {
+		at = current
+	}
+	if at > len(assignees) {
+		at = len(assignees)
+	}
+	if at < 0 {
+		at = 0
+	}
+	result := make([]string, 0, len(assignees)+len(userIDs))
+	result = append(result, assignees[:at]...)
+	result = append(result, userIDs...)
+	return append(result, assignees[at:]...)
+}
+
+func splitAssignees(assignees string) []string {
+	if assignees == "" {
+		return []string{}
+	}
+	return strings.Split(assignees, ",")
+}
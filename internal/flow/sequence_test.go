@@ -0,0 +1,42 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package flow
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_insertAssignees(t *testing.T) {
+	tests := []struct {
+		name    string
+		current int
+		before  bool
+		want    []string
+	}{
+		{"before first", 0, true, []string{"x", "y", "a", "b", "c"}},
+		{"after first", 0, false, []string{"a", "x", "y", "b", "c"}},
+		{"before last", 2, true, []string{"a", "b", "x", "y", "c"}},
+		{"after last", 2, false, []string{"a", "b", "c", "x", "y"}},
+	}
+	for _, tt := range tests {
+		got := insertAssignees([]string{"a", "b", "c"}, tt.current, []string{"x", "y"}, tt.before)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: insertAssignees() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+	if got := splitAssignees(""); len(got) != 0 {
+		t.Errorf("splitAssignees(\"\") = %v", got)
+	}
+}
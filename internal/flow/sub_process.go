@@ -0,0 +1,332 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package flow
+
+import (
+	"context"
+	"errors"
+
+	"github.com/quanxiang-cloud/flow/internal/models"
+	"github.com/quanxiang-cloud/flow/internal/models/mysql"
+	"github.com/quanxiang-cloud/flow/internal/server/options"
+	"github.com/quanxiang-cloud/flow/pkg"
+	"github.com/quanxiang-cloud/flow/pkg/client"
+	"github.com/quanxiang-cloud/flow/pkg/config"
+	"github.com/quanxiang-cloud/flow/pkg/misc/logger"
+	"github.com/quanxiang-cloud/flow/pkg/utils"
+	"gorm.io/gorm"
+)
+
+const (
+	// stepSubProcess history step of a child instance started by the current instance
+	stepSubProcess = "SUB_PROCESS"
+	// stepParentProcess history step of the instance which started the current instance
+	stepParentProcess = "PARENT_PROCESS"
+
+	// maxSubProcessDepth max nesting depth of sub process instances
+	maxSubProcessDepth = 10
+)
+
+// SubProcess service of sub process node
+type SubProcess interface {
+	MapOutputs(ctx context.Context, rel *models.SubProcess, child *models.Instance, status string) error
+	Finish(ctx context.Context, child *models.Instance, status string) error
+	CheckStart(ctx context.Context, instance *models.Instance, subFlowID string) error
+	Steps(ctx context.Context, processInstanceID string) ([]*models.InstanceStep, error)
+}
+
+type subProcess struct {
+	db                    *gorm.DB
+	subProcessRepo        models.SubProcessRepo
+	instanceRepo          models.InstanceRepo
+	instanceVariablesRepo models.InstanceVariablesRepo
+	flow                  Flow
+	operationRecord       OperationRecord
+	formAPI               client.Form
+	processAPI            client.Process
+}
+
+// NewSubProcess init
+func NewSubProcess(conf *config.Configs, opts ...options.Options) (SubProcess, error) {
+	flow, err := NewFlow(conf, opts...)
+	if err != nil {
+		return nil, err
+	}
+	operationRecord, err := NewOperationRecord(conf, opts...)
+	if err != nil {
+		return nil, err
+	}
+	s := &subProcess{
+		subProcessRepo:        mysql.NewSubProcessRepo(),
+		instanceRepo:          mysql.NewInstanceRepo(),
+		instanceVariablesRepo: mysql.NewInstanceVariablesRepo(),
+		flow:                  flow,
+		operationRecord:       operationRecord,
+		formAPI:               client.NewForm(conf),
+		processAPI:            client.NewProcess(conf),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s, nil
+}
+
+// SetDB set db
+func (s *subProcess) SetDB(db *gorm.DB) {
+	s.db = db
+}
+
+// MapOutputs write the output mapping of the sub process node back into the parent instance variables
+// and mark the relation as finished
+func (s *subProcess) MapOutputs(ctx context.Context, rel *models.SubProcess, child *models.Instance, status string) error {
+	shape, err := s.flow.GetShapeByInstance(ctx, rel.ProcessID, rel.ProcessInstanceID, rel.NodeDefKey)
+	if err != nil {
+		return err
+	}
+	bd := shape.Data.BusinessData
+	outputs := make(map[string]interface{})
+	if rules, ok := bd["outputMapping"].([]interface{}); ok && len(rules) > 0 {
+		variables, err := s.flow.GetInstanceVariableValues(ctx, child)
+		if err != nil {
+			return err
+		}
+		var formData map[string]interface{}
+		for _, r := range rules {
+			rule, ok := r.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			variableName := utils.Strval(rule["variableName"])
+			if variableName == "" {
+				continue
+			}
+			switch utils.Strval(rule["valueFrom"]) {
+			case "processVariable":
+				outputs[variableName] = variables[utils.Strval(rule["valueOf"])]
+			case "currentFormValue":
+				if formData == nil {
+					formData, err = s.formAPI.GetFormData(ctx, client.FormDataConditionModel{
+						AppID:   child.AppID,
+						TableID: child.FormID,
+						DataID:  child.FormInstanceID,
+					})
+					if err != nil {
+						return err
+					}
+				}
+				outputs[variableName], _ = s.formAPI.GetValue(formData, utils.Strval(rule["valueOf"]), nil)
+			default:
+				outputs[variableName] = rule["valueOf"]
+			}
+		}
+	}
+	if v := utils.Strval(bd["statusVariable"]); v != "" {
+		outputs[v] = status
+	}
+	for code, value := range outputs {
+		fieldValue, fieldType := utils.StrvalAndType(value)
+		err = s.instanceVariablesRepo.UpdateTypeAndValue(s.db, rel.ProcessInstanceID, code, fieldType, fieldValue)
+		if err != nil {
+			return err
+		}
+	}
+
+	return s.subProcessRepo.Update(s.db, rel.ID, map[string]interface{}{
+		"status":      status,
+		"modifier_id": pkg.STDUserID(ctx),
+	})
+}
+
+// Finish called when an instance ends, the running child instances of it are abended,
+// and if it is a child instance, maps its outputs back and lets the parent node continue
+func (s *subProcess) Finish(ctx context.Context, child *models.Instance, status string) error {
+	if err := s.abendChildren(ctx, child); err != nil {
+		return err
+	}
+	rel, err := s.subProcessRepo.FindBySubProcessInstanceID(s.db, child.ProcessInstanceID)
+	if err != nil {
+		return err
+	}
+	if rel == nil || rel.Status != "" {
+		return nil
+	}
+	parent, err := s.instanceRepo.GetEntityByProcessInstanceID(s.db, rel.ProcessInstanceID)
+	if err != nil {
+		return err
+	}
+	if parent == nil {
+		return nil
+	}
+	err = s.MapOutputs(ctx, rel, child, status)
+	if err != nil {
+		return err
+	}
+	if !IsFlowOngoing(parent.Status) {
+		logger.Logger.Infof("parent instance %s is %s, sub process result ignored", parent.ID, parent.Status)
+		return nil
+	}
+
+	params, err := s.flow.GetInstanceVariableValues(ctx, parent)
+	if err != nil {
+		return err
+	}
+	return s.processAPI.CompleteNode(ctx, &client.CompleteNodeReq{
+		ProcessID:   rel.ProcessID,
+		InstanceID:  rel.ProcessInstanceID,
+		NodeDefKey:  rel.NodeDefKey,
+		ExecutionID: rel.ExecutionID,
+		Params:      s.flow.FormatFormValue(parent, params),
+		UserID:      pkg.STDUserID(ctx),
+	})
+}
+
+// abendChildren abend the child instances still running when the parent instance ends, recursively
+func (s *subProcess) abendChildren(ctx context.Context, parent *models.Instance) error {
+	rels, err := s.subProcessRepo.FindByProcessInstanceID(s.db, parent.ProcessInstanceID)
+	if err != nil {
+		return err
+	}
+	for _, rel := range rels {
+		if rel.Status != "" || rel.SubProcessInstanceID == "" {
+			continue
+		}
+		child, err := s.instanceRepo.GetEntityByProcessInstanceID(s.db, rel.SubProcessInstanceID)
+		if err != nil {
+			return err
+		}
+		if child == nil || !IsFlowOngoing(child.Status) {
+			continue
+		}
+		if err = s.processAPI.AbendInstance(ctx, child.ProcessInstanceID); err != nil {
+			return err
+		}
+		err = s.instanceRepo.Update(s.db, child.ID, map[string]interface{}{
+			"status":      opAbend,
+			"modifier_id": pkg.STDUserID(ctx),
+		})
+		if err != nil {
+			return err
+		}
+		err = s.subProcessRepo.Update(s.db, rel.ID, map[string]interface{}{
+			"status":      opAbend,
+			"modifier_id": pkg.STDUserID(ctx),
+		})
+		if err != nil {
+			return err
+		}
+		s.operationRecord.AddOperationRecord(ctx, child, nil, &models.HandleTaskModel{
+			HandleType: opAbend,
+			HandleDesc: "异常结束",
+			Remark:     "父流程已结束，子流程异常结束",
+		})
+		if err = s.abendChildren(ctx, child); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CheckStart the child flow must not be the flow of the instance or any of its ancestors,
+// and the nesting depth is limited
+func (s *subProcess) CheckStart(ctx context.Context, instance *models.Instance, subFlowID string) error {
+	cur := instance
+	for depth := 0; cur != nil; depth++ {
+		if depth >= maxSubProcessDepth {
+			return errors.New("sub process is nested too deep")
+		}
+		if cur.FlowID == subFlowID {
+			return errors.New("sub process flow is the flow itself or one of its parent flows")
+		}
+		rel, err := s.subProcessRepo.FindBySubProcessInstanceID(s.db, cur.ProcessInstanceID)
+		if err != nil {
+			return err
+		}
+		if rel == nil {
+			return nil
+		}
+		cur, err = s.instanceRepo.GetEntityByProcessInstanceID(s.db, rel.ProcessInstanceID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Steps history steps linking the instance with its child and parent instances
+func (s *subProcess) Steps(ctx context.Context, processInstanceID string) ([]*models.InstanceStep, error) {
+	steps := make([]*models.InstanceStep, 0)
+
+	children, err := s.subProcessRepo.FindByProcessInstanceID(s.db, processInstanceID)
+	if err != nil {
+		return nil, err
+	}
+	for _, rel := range children {
+		if rel.SubProcessInstanceID == "" {
+			continue
+		}
+		child, err := s.instanceRepo.GetEntityByProcessInstanceID(s.db, rel.SubProcessInstanceID)
+		if err != nil {
+			return nil, err
+		}
+		if child == nil {
+			continue
+		}
+		status := rel.Status
+		if status == "" {
+			status = child.Status
+		}
+		steps = append(steps, &models.InstanceStep{
+			ProcessInstanceID:    processInstanceID,
+			TaskType:             stepSubProcess,
+			TaskDefKey:           rel.NodeDefKey,
+			TaskName:             "子流程",
+			Status:               status,
+			FlowName:             child.Name,
+			RelProcessInstanceID: rel.SubProcessInstanceID,
+			BaseModel: models.BaseModel{
+				CreateTime:  rel.CreateTime,
+				ModifyTime:  rel.ModifyTime,
+				CreatorName: child.ApplyUserName,
+				CreatorID:   child.ApplyUserID,
+			},
+		})
+	}
+
+	rel, err := s.subProcessRepo.FindBySubProcessInstanceID(s.db, processInstanceID)
+	if err != nil {
+		return nil, err
+	}
+	if rel != nil {
+		parent, err := s.instanceRepo.GetEntityByProcessInstanceID(s.db, rel.ProcessInstanceID)
+		if err != nil {
+			return nil, err
+		}
+		if parent != nil {
+			steps = append(steps, &models.InstanceStep{
+				ProcessInstanceID:    processInstanceID,
+				TaskType:             stepParentProcess,
+				TaskDefKey:           rel.NodeDefKey,
+				TaskName:             "父流程",
+				Status:               parent.Status,
+				FlowName:             parent.Name,
+				RelProcessInstanceID: rel.ProcessInstanceID,
+				BaseModel: models.BaseModel{
+					CreateTime: rel.CreateTime,
+					ModifyTime: rel.ModifyTime,
+				},
+			})
+		}
+	}
+	return steps, nil
+}
@@ -0,0 +1,177 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package flow
+
+import (
+	"context"
+	"strings"
+
+	"github.com/quanxiang-cloud/flow/internal/models"
+	"github.com/quanxiang-cloud/flow/pkg"
+	"github.com/quanxiang-cloud/flow/pkg/client"
+	"github.com/quanxiang-cloud/flow/pkg/misc/error2"
+	"github.com/quanxiang-cloud/flow/pkg/misc/logger"
+	"github.com/quanxiang-cloud/flow/pkg/misc/time2"
+)
+
+// InstanceSuspendReq suspend or resume a flow instance
+type InstanceSuspendReq struct {
+	ProcessInstanceID string `json:"processInstanceId" binding:"required"`
+	Remark            string `json:"remark"`
+}
+
+// Suspend suspend an ongoing flow instance, its tasks are hidden and timers are paused
+func (i *instance) Suspend(ctx context.Context, req *InstanceSuspendReq) (bool, error) {
+	flowInstanceEntity, err := i.adminInstance(ctx, req.ProcessInstanceID)
+	if err != nil {
+		return false, err
+	}
+	if !IsFlowOngoing(flowInstanceEntity.Status) {
+		return false, error2.NewErrorWithString(error2.Internal, "Flow instance is not ongoing ")
+	}
+
+	updateMap := map[string]interface{}{
+		"status":             Suspended,
+		"pre_suspend_status": flowInstanceEntity.Status,
+		"suspend_time":       time2.Now(),
+		"modifier_id":        pkg.STDUserID(ctx),
+	}
+	if err = i.instanceRepo.Update(i.db, flowInstanceEntity.ID, updateMap); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Resume resume a suspended flow instance, pending deadlines are shifted by the suspended duration
+func (i *instance) Resume(ctx context.Context, req *InstanceSuspendReq) (bool, error) {
+	flowInstanceEntity, err := i.adminInstance(ctx, req.ProcessInstanceID)
+	if err != nil {
+		return false, err
+	}
+	if flowInstanceEntity.Status != Suspended {
+		return false, error2.NewErrorWithString(error2.Internal, "Flow instance is not suspended ")
+	}
+
+	suspendTime, err := time2.ISO8601ToUnix(flowInstanceEntity.SuspendTime)
+	if err != nil {
+		return false, err
+	}
+	duration := time2.NowUnix() - suspendTime
+	if duration < 0 {
+		duration = 0
+	}
+
+	status := flowInstanceEntity.PreSuspendStatus
+	if status == "" {
+		status = InReview
+	}
+	updateMap := map[string]interface{}{
+		"status":             status,
+		"pre_suspend_status": "",
+		"suspend_time":       "",
+		"modifier_id":        pkg.STDUserID(ctx),
+	}
+	if err = i.instanceRepo.Update(i.db, flowInstanceEntity.ID, updateMap); err != nil {
+		return false, err
+	}
+
+	if duration > 0 {
+		i.shiftCallbacks(ctx, flowInstanceEntity.ProcessInstanceID, suspendTime, duration)
+		i.shiftDueTimes(ctx, flowInstanceEntity.ProcessInstanceID, duration)
+	}
+	return true, nil
+}
+
+func (i *instance) adminInstance(ctx context.Context, processInstanceID string) (*models.Instance, error) {
+	flowInstanceEntity, err := i.instanceRepo.GetEntityByProcessInstanceID(i.db, processInstanceID)
+	if err != nil {
+		return nil, err
+	}
+	if flowInstanceEntity == nil {
+		return nil, error2.NewErrorWithString(error2.Internal, "Can not find flow instance data ")
+	}
+	if err = checkAppAdmin(ctx, i.appCenterAPI, flowInstanceEntity.AppID); err != nil {
+		return nil, err
+	}
+	return flowInstanceEntity, nil
+}
+
+// shiftCallbacks 顺延挂起期间未执行的催办、超时、延时回调
+func (i *instance) shiftCallbacks(ctx context.Context, processInstanceID string, suspendTime int64, duration int64) {
+	callbacks, err := i.dispatcherCallbackRepo.FindByProcessInstanceID(i.db, processInstanceID)
+	if err != nil {
+		logger.Logger.Error("find dispatcher callbacks err,", err)
+		return
+	}
+	for _, callback := range callbacks {
+		execTime, err := time2.ISO8601ToUnix(callback.ExecTime)
+		if err != nil {
+			continue
+		}
+		if execTime < suspendTime {
+			// 挂起前已执行
+			continue
+		}
+		newTime := time2.UnixToISO8601(execTime + duration)
+		err = i.dispatcherCallbackRepo.Update(i.db, callback.ID, map[string]interface{}{
+			"exec_time": newTime,
+		})
+		if err != nil {
+			logger.Logger.Error("update dispatcher callback err,", err)
+			continue
+		}
+		err = i.dispatcherAPI.UpdateState(ctx, client.UpdateTaskStateReq{
+			Code:    dispatcherCode(callback.ID),
+			State:   1,
+			TimeBar: newTime,
+		})
+		if err != nil {
+			logger.Logger.Error("update dispatcher state err,", err)
+		}
+	}
+}
+
+// shiftDueTimes 顺延当前任务的有效期
+func (i *instance) shiftDueTimes(ctx context.Context, processInstanceID string, duration int64) {
+	tasks, err := i.processAPI.GetTasksByInstanceID(ctx, processInstanceID)
+	if err != nil {
+		logger.Logger.Error("get instance tasks err,", err)
+		return
+	}
+	for _, task := range tasks {
+		dueTime, err := time2.ISO8601ToUnix(task.DueTime)
+		if err != nil {
+			continue
+		}
+		if err = i.processAPI.SetDueDate(ctx, task.ID, time2.UnixToISO8601(dueTime+duration)); err != nil {
+			logger.Logger.Error("set task due date err,", err)
+		}
+	}
+}
+
+// checkNotSuspended tasks of a suspended flow instance can not be handled
+func checkNotSuspended(flowInstanceEntity *models.Instance) error {
+	if flowInstanceEntity != nil && flowInstanceEntity.Status == Suspended {
+		return error2.NewErrorWithString(error2.Internal, "Flow instance is suspended ")
+	}
+	return nil
+}
+
+// dispatcherCode the dispatcher task code of a callback
+func dispatcherCode(callbackID string) string {
+	if strings.HasPrefix(callbackID, "flow:") {
+		return callbackID
+	}
+	return "flow:" + callbackID
+}
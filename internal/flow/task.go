@@ -18,6 +18,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/quanxiang-cloud/flow/internal/convert"
+	"github.com/quanxiang-cloud/flow/internal/flow/notifier"
 	"github.com/quanxiang-cloud/flow/internal/models"
 	"github.com/quanxiang-cloud/flow/internal/models/mysql"
 	"github.com/quanxiang-cloud/flow/internal/server/options"
@@ -37,6 +38,8 @@ import (
 type Task interface {
 	TaskInitHandle(ctx context.Context, flowEntity *models.Flow, flowInstanceEntity *models.Instance, task *client.ProcessTask, currentUserID string) error
 	noUserHandle(ctx context.Context, flowInstanceEntity *models.Instance, task *client.ProcessTask, taskBasicConfigModel *convert.TaskBasicConfigModel) error
+	sendHandleMessage(ctx context.Context, instance *models.Instance, task *client.ProcessTask, handleUserIDs []string) error
+	sendFinishedMessage(ctx context.Context, instance *models.Instance, status string) error
 	TaskUrging(ctx context.Context, rule convert.TaskTimeRuleModel, nodeID string, instance models.Instance, TaskID string) error
 
 	// TaskCheck(ctx context.Context, flowEntity *models.Flow, flowInstanceEntity *models.Instance, currentUserID string) error
@@ -60,6 +63,7 @@ const (
 const (
 	DEADLINE = "DEADLINE"
 	URGE     = "URGE"
+	ESCALATE = "ESCALATE"
 )
 
 // DispatcherCallOtherInfo struct
@@ -67,6 +71,7 @@ type DispatcherCallOtherInfo struct {
 	FlowInstanceID string `json:"flowInstanceId"`
 	TaskID         string `json:"TaskID"`
 	TaskDefKey     string `json:"taskDefKey"`
+	Level          int    `json:"level"` // 超时升级的级别
 }
 
 type task struct {
@@ -86,6 +91,10 @@ type task struct {
 	instanceVariablesRepo  models.InstanceVariablesRepo
 	instanceExecutionRepo  models.InstanceExecutionRepo
 	instanceRepo           models.InstanceRepo
+	subProcess             SubProcess
+	workCalendar           WorkCalendar
+	messageTemplate        MessageTemplate
+	notifier               notifier.Registry
 }
 
 // NewTask init
@@ -99,6 +108,22 @@ func NewTask(conf *config.Configs, opts ...options.Options) (Task, error) {
 	if err != nil {
 		return nil, err
 	}
+	subProcess, err := NewSubProcess(conf, opts...)
+	if err != nil {
+		return nil, err
+	}
+	workCalendar, err := NewWorkCalendar(conf, opts...)
+	if err != nil {
+		return nil, err
+	}
+	messageTemplate, err := NewMessageTemplate(conf, opts...)
+	if err != nil {
+		return nil, err
+	}
+	registry, err := notifier.NewRegistry(conf, opts...)
+	if err != nil {
+		return nil, err
+	}
 	t := &task{
 		serverConf:             conf,
 		operationRecordRepo:    mysql.NewOperationRecordRepo(),
@@ -115,6 +140,10 @@ func NewTask(conf *config.Configs, opts ...options.Options) (Task, error) {
 		instanceVariablesRepo:  mysql.NewInstanceVariablesRepo(),
 		instanceExecutionRepo:  mysql.NewInstanceExecutionRepo(),
 		instanceRepo:           mysql.NewInstanceRepo(),
+		subProcess:             subProcess,
+		workCalendar:           workCalendar,
+		messageTemplate:        messageTemplate,
+		notifier:               registry,
 	}
 
 	for _, opt := range opts {
@@ -140,13 +169,20 @@ func (t *task) TaskInitHandle(ctx context.Context, flowEntity *models.Flow, flow
 		return nil
 	}
 
-	handleUserIds, _ := t.flow.GetTaskHandleUserIDs(ctx, shape, flowInstanceEntity)
+	handleUserIds, delegations := t.flow.GetTaskHandleDelegations(ctx, shape, flowInstanceEntity)
+	if len(delegations) > 0 && task.Assignee != "" {
+		t.delegateTask(ctx, flowInstanceEntity, task, delegations)
+	}
+	sequential := taskBasicConfigModel.MultiplePersonWay == convert.Sequential
+	if sequential && len(handleUserIds) > 0 && task.Assignee != "" {
+		handleUserIds = []string{task.Assignee} // 依次处理只通知当前处理人
+	}
 	if len(handleUserIds) == 0 { // 无审批人时
 		logger.Logger.Info("事件：加载handleUserIds，handleUserIds=" + utils.ChangeStringArrayToString(handleUserIds))
 		t.noUserHandle(ctx, flowInstanceEntity, task, taskBasicConfigModel)
 	} else { // 有人审批时,审批人为发起人时origin，审批人与上一节点审批人相同时parent，审批人与前置节点（非上一节点审批人相同时）previous
 		autoRules := taskBasicConfigModel.AutoRules
-		if len(autoRules) > 0 { // 自动审批通过
+		if len(autoRules) > 0 && !sequential { // 自动审批通过，依次处理不自动审批以免跳过后续处理人
 			params, err := t.flow.GetInstanceVariableValues(ctx, flowInstanceEntity)
 			if err != nil {
 				logger.Logger.Error(err)
@@ -206,41 +242,130 @@ func (t *task) TaskInitHandle(ctx context.Context, flowEntity *models.Flow, flow
 		if err != nil {
 			logger.Logger.Error(err)
 		}
+		err = t.subProcess.Finish(ctx, flowInstanceEntity, opAgree)
+		if err != nil {
+			logger.Logger.Error(err)
+		}
+		go t.sendFinishedMessage(ctx, flowInstanceEntity, opAgree)
 	}
 
 	return nil
 }
 
+// delegateTask route the task of an absent user to the delegate, the delegation is recorded either way
+func (t *task) delegateTask(ctx context.Context, flowInstanceEntity *models.Instance, task *client.ProcessTask, delegations map[string]string) {
+	delegator, delegate := task.Assignee, delegations[task.Assignee]
+	if delegate != "" {
+		err := t.processAPI.SetAssignee(ctx, flowInstanceEntity.ProcessInstanceID, task.ID, delegate)
+		if err != nil {
+			logger.Logger.Error(err)
+			return
+		}
+		task.Assignee = delegate
+	} else {
+		// 动态处理人在分配任务时已替换为受托人
+		for k, v := range delegations {
+			if v == task.Assignee {
+				delegator, delegate = k, v
+				break
+			}
+		}
+		if delegate == "" {
+			return
+		}
+	}
+
+	model := &models.HandleTaskModel{
+		HandleType:    opDelegate,
+		HandleDesc:    "委托",
+		HandleUserIDs: []string{delegate},
+	}
+	records := t.operationRecord.ConvertOperationRecord(ctx, flowInstanceEntity, task, model)
+	for _, record := range records {
+		record.HandleUserID = delegator
+		record.CreatorID = delegator
+	}
+	err := t.operationRecord.AddOperationRecords(ctx, flowInstanceEntity, task, model, records)
+	if err != nil {
+		logger.Logger.Error(err)
+	}
+}
+
 // sendHandleMessage send handle message to assignee user
 func (t *task) sendHandleMessage(ctx context.Context, instance *models.Instance, task *client.ProcessTask, handleUserIDs []string) error {
+	messageTitle := "审批提醒"
 	messageContent := "您有新的" + instance.Name + "流程的审批，请点击查看：" + t.serverConf.APIHost.HomeHost + "approvals/" +
 		instance.ProcessInstanceID + "/" + task.ID + "/WAIT_HANDLE_PAGE"
+	// 应用或流程配置了待办提醒模板时使用模板
+	rendered, err := t.messageTemplate.RenderByType(ctx, models.MessageTemplateTaskAssigned, instance, task)
+	if err != nil {
+		logger.Logger.Error("render task assigned template err,", err)
+	}
+	if rendered != nil {
+		messageTitle, messageContent = rendered.Title, rendered.Content
+	}
 
 	handleUsers, err := t.identityAPI.FindUsersByIDs(ctx, handleUserIDs)
 	if err != nil {
 		return err
 	}
-	emailAddrs := make([]string, 0)
+	if len(handleUsers) == 0 {
+		return nil
+	}
+	receivers := make([]*client.UserInfoResp, 0, len(handleUsers))
 	for _, user := range handleUsers {
-		if len(user.Email) > 0 {
-			emailAddrs = append(emailAddrs, user.Email)
-		}
+		receivers = append(receivers, user)
+	}
+
+	flowEntity, err := t.flow.GetInstanceFlow(ctx, instance)
+	if err != nil || flowEntity == nil {
+		return err
 	}
+	t.notifier.Notify(ctx, notifier.Channels(flowEntity.NotifyChannels, notifier.EventTaskAssigned), &notifier.Message{
+		Event:             notifier.EventTaskAssigned,
+		ProcessInstanceID: instance.ProcessInstanceID,
+		TaskID:            task.ID,
+		Title:             messageTitle,
+		Content:           messageContent,
+		Receivers:         receivers,
+	})
+	return nil
+}
 
-	if len(emailAddrs) == 0 {
+// sendFinishedMessage send the result to the applicant when the instance is finished
+func (t *task) sendFinishedMessage(ctx context.Context, instance *models.Instance, status string) error {
+	flowEntity, err := t.flow.GetInstanceFlow(ctx, instance)
+	if err != nil || flowEntity == nil {
+		return err
+	}
+	channels := notifier.Channels(flowEntity.NotifyChannels, notifier.EventFinished)
+	if len(channels) == 0 {
 		return nil
 	}
+	applicant, err := t.identityAPI.FindUserByID(ctx, instance.ApplyUserID)
+	if err != nil || applicant == nil {
+		return err
+	}
 
-	msgReq := client.MsgReq{
-		Email: client.Email{
-			To: emailAddrs,
-			Contents: client.Contents{
-				Content: messageContent,
-			},
-			Title: "审批提醒",
-		},
+	finished := *instance
+	finished.Status = status
+	messageTitle := "审批结果通知"
+	messageContent := "您发起的" + instance.Name + "流程已结束，审批结果：" + GetStatusName(status)
+	rendered, err := t.messageTemplate.RenderByType(ctx, models.MessageTemplateFinished, &finished, nil)
+	if err != nil {
+		logger.Logger.Error("render finished template err,", err)
+	}
+	if rendered != nil {
+		messageTitle, messageContent = rendered.Title, rendered.Content
 	}
-	return t.messageCenterAPI.MessageCreate(ctx, msgReq)
+	t.notifier.Notify(ctx, channels, &notifier.Message{
+		Event:             notifier.EventFinished,
+		ProcessInstanceID: instance.ProcessInstanceID,
+		Title:             messageTitle,
+		Content:           messageContent,
+		Receivers:         []*client.UserInfoResp{applicant},
+	})
+	return nil
 }
 
 // noUserHandle no user handle
@@ -315,7 +440,7 @@ func (t *task) TaskUrging(ctx context.Context, rule convert.TaskTimeRuleModel, n
 
 	// eval deadline
 	now := time2.Now()
-	deadLineTime := utils.AddDaysHoursMinutes(now, deadLine.Day, deadLine.Hours, deadLine.Minutes)
+	deadLineTime := t.workCalendar.AddTime(ctx, instance.AppID, now, rule.TimeType, deadLine.DayHourMinuteModel)
 	otherInfo, _ := json.Marshal(&DispatcherCallOtherInfo{
 		FlowInstanceID: instance.ID,
 		TaskID:         TaskID,
@@ -326,8 +451,11 @@ func (t *task) TaskUrging(ctx context.Context, rule convert.TaskTimeRuleModel, n
 		BaseModel: models.BaseModel{
 			ID: id,
 		},
-		Type:      DEADLINE,
-		OtherInfo: string(otherInfo),
+		Type:              DEADLINE,
+		OtherInfo:         string(otherInfo),
+		ProcessInstanceID: instance.ProcessInstanceID,
+		TaskDefKey:        nodeID,
+		ExecTime:          deadLineTime,
 	}
 	err := t.dispatcherCallbackRepo.Create(t.db, &dc)
 	if err != nil {
@@ -348,14 +476,21 @@ func (t *task) TaskUrging(ctx context.Context, rule convert.TaskTimeRuleModel, n
 	if urgeInfo.Day == 0 && urgeInfo.Hours == 0 && urgeInfo.Minutes == 0 {
 		return nil
 	}
-	urgeTime := utils.AddDaysHoursMinutes(now, -urgeInfo.Day, -urgeInfo.Hours, -urgeInfo.Minutes)
+	urgeTime := t.workCalendar.AddTime(ctx, instance.AppID, now, rule.TimeType, convert.DayHourMinuteModel{
+		Day:     -urgeInfo.Day,
+		Hours:   -urgeInfo.Hours,
+		Minutes: -urgeInfo.Minutes,
+	})
 	id = id2.GenID()
 	dc = models.DispatcherCallback{
 		BaseModel: models.BaseModel{
 			ID: id,
 		},
-		Type:      URGE,
-		OtherInfo: string(otherInfo),
+		Type:              URGE,
+		OtherInfo:         string(otherInfo),
+		ProcessInstanceID: instance.ProcessInstanceID,
+		TaskDefKey:        nodeID,
+		ExecTime:          urgeTime,
 	}
 	err = t.dispatcherCallbackRepo.Create(t.db, &dc)
 	if err != nil {
@@ -581,7 +716,7 @@ func (t *task) setTimeRule(ctx context.Context, flowInstanceEntity *models.Insta
 	// entry进入该节点后,firstEntry首次进入该节点后,flowWorked工作流开始后
 	if len(timeRule.DeadLine.BreakPoint) > 0 {
 		if Entry == timeRule.DeadLine.BreakPoint { // 进入该节点后
-			dueDate := utils.AddDaysHoursMinutes(task.CreateTime, int(timeRule.DeadLine.Day), int(timeRule.DeadLine.Hours), int(timeRule.DeadLine.Minutes))
+			dueDate := t.workCalendar.AddTime(ctx, flowInstanceEntity.AppID, task.CreateTime, timeRule.TimeType, timeRule.DeadLine.DayHourMinuteModel)
 			t.processAPI.SetDueDate(ctx, task.ID, dueDate)
 		} else if FirstEntry == timeRule.DeadLine.BreakPoint { // 首次进入该节点后
 			taskCondition := client.GetTasksReq{
@@ -594,11 +729,11 @@ func (t *task) setTimeRule(ctx context.Context, flowInstanceEntity *models.Insta
 			}
 
 			if actRuTaskEntity != nil {
-				dueDate := utils.AddDaysHoursMinutes(actRuTaskEntity.CreateTime, int(timeRule.DeadLine.Day), int(timeRule.DeadLine.Hours), int(timeRule.DeadLine.Minutes))
+				dueDate := t.workCalendar.AddTime(ctx, flowInstanceEntity.AppID, actRuTaskEntity.CreateTime, timeRule.TimeType, timeRule.DeadLine.DayHourMinuteModel)
 				t.processAPI.SetDueDate(ctx, task.ID, dueDate)
 			}
 		} else if FlowWorked == timeRule.DeadLine.BreakPoint { // 工作流开始后
-			dueDate := utils.AddDaysHoursMinutes(flowInstanceEntity.CreateTime, int(timeRule.DeadLine.Day), int(timeRule.DeadLine.Hours), int(timeRule.DeadLine.Minutes))
+			dueDate := t.workCalendar.AddTime(ctx, flowInstanceEntity.AppID, flowInstanceEntity.CreateTime, timeRule.TimeType, timeRule.DeadLine.DayHourMinuteModel)
 			t.processAPI.SetDueDate(ctx, task.ID, dueDate)
 		}
 	}
@@ -0,0 +1,158 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package flow
+
+import (
+	"context"
+
+	"github.com/quanxiang-cloud/flow/internal/convert"
+	"github.com/quanxiang-cloud/flow/internal/models"
+	"github.com/quanxiang-cloud/flow/pkg/client"
+	"github.com/quanxiang-cloud/flow/pkg/misc/id2"
+	"github.com/quanxiang-cloud/flow/pkg/misc/time2"
+)
+
+// voteRule vote rule of the approve task, nil if the node is not a voting countersign
+func (i *instance) voteRule(flow *models.Flow, task *client.ProcessTask) *convert.VoteRuleModel {
+	shape, err := convert.GetShapeByTaskDefKey(flow.BpmnText, task.NodeDefKey)
+	if err != nil || shape == nil || shape.Type != convert.Approve {
+		return nil
+	}
+	basicConfig := convert.GetTaskBasicConfigModel(shape)
+	if basicConfig == nil || basicConfig.MultiplePersonWay != "and" || !basicConfig.VoteRule.Enabled() {
+		return nil
+	}
+	return &basicConfig.VoteRule
+}
+
+// voteStep step of the node being voted, nil before the first vote
+func (i *instance) voteStep(processInstanceID string, nodeDefKey string) (*models.InstanceStep, error) {
+	steps, err := i.stepRepo.FindInstanceSteps(i.db, &models.InstanceStep{
+		ProcessInstanceID: processInstanceID,
+		TaskDefKey:        nodeDefKey,
+		Status:            InReview,
+	})
+	if err != nil || len(steps) == 0 {
+		return nil, err
+	}
+	return steps[0], nil
+}
+
+// countVotes outcome of the node with the vote of the current task,
+// voters are those who have voted and those who still have an active task
+func (i *instance) countVotes(ctx context.Context, processInstanceID string, task *client.ProcessTask, rule convert.VoteRuleModel, handleType string) (bool, bool, error) {
+	var agreed, refused, total float64
+	step, err := i.voteStep(processInstanceID, task.NodeDefKey)
+	if err != nil {
+		return false, false, err
+	}
+	if step != nil {
+		records, err := i.recordRepo.FindRecords(i.db, processInstanceID, step.ID, []string{opAgree, opRefuse}, true)
+		if err != nil {
+			return false, false, err
+		}
+		for _, record := range records {
+			weight := rule.Weight(record.HandleUserID)
+			total += weight
+			if record.HandleType == opAgree {
+				agreed += weight
+			} else {
+				refused += weight
+			}
+		}
+	}
+
+	tasks, err := i.processAPI.GetTasks(ctx, client.GetTasksReq{
+		InstanceID: []string{processInstanceID},
+		NodeDefKey: task.NodeDefKey,
+		Desc:       []string{convert.ReviewTask},
+	})
+	if err != nil {
+		return false, false, err
+	}
+	for _, t := range tasks.Data {
+		weight := rule.Weight(t.Assignee)
+		total += weight
+		if t.ID != task.ID {
+			continue
+		}
+		if handleType == opAgree {
+			agreed += weight
+		} else {
+			refused += weight
+		}
+	}
+
+	pass, decided := rule.Decide(agreed, refused, total)
+	return pass, decided, nil
+}
+
+// skipVoters the outcome is decided, complete the tasks of those who have not voted
+func (i *instance) skipVoters(ctx context.Context, instance *models.Instance, task *client.ProcessTask, params map[string]interface{}) error {
+	step, err := i.voteStep(instance.ProcessInstanceID, task.NodeDefKey)
+	if err != nil {
+		return err
+	}
+	tasks, err := i.processAPI.GetTasks(ctx, client.GetTasksReq{
+		InstanceID: []string{instance.ProcessInstanceID},
+		NodeDefKey: task.NodeDefKey,
+		Desc:       []string{convert.ReviewTask},
+	})
+	if err != nil {
+		return err
+	}
+
+	comments := map[string]interface{}{
+		"reviewResult": OpAutoSkip,
+		"reviewRemark": "",
+	}
+	for _, t := range tasks.Data {
+		if t.ID == task.ID {
+			continue
+		}
+		if err := i.processAPI.CompleteTask(ctx, instance.ProcessInstanceID, t.ID, i.flow.FormatFormValue(instance, params), comments); err != nil {
+			return err
+		}
+		if step == nil {
+			continue
+		}
+		err := i.recordRepo.Create(i.db, &models.OperationRecord{
+			ProcessInstanceID: instance.ProcessInstanceID,
+			InstanceStepID:    step.ID,
+			HandleType:        OpAutoSkip,
+			HandleUserID:      t.Assignee,
+			HandleDesc:        "会签结果已确定，已自动跳过",
+			Status:            Completed,
+			TaskID:            t.ID,
+			TaskName:          t.Name,
+			TaskDefKey:        t.NodeDefKey,
+			BaseModel: models.BaseModel{
+				ID:         id2.GenID(),
+				CreatorID:  t.Assignee,
+				CreateTime: time2.Now(),
+				ModifyTime: time2.Now(),
+			},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if step == nil {
+		return nil
+	}
+	return i.stepRepo.Update(i.db, step.ID, map[string]interface{}{
+		"status":      opAgree,
+		"modify_time": time2.Now(),
+	})
+}
@@ -0,0 +1,370 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package flow
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/quanxiang-cloud/flow/internal/convert"
+	"github.com/quanxiang-cloud/flow/internal/models"
+	"github.com/quanxiang-cloud/flow/internal/models/mysql"
+	"github.com/quanxiang-cloud/flow/internal/server/options"
+	"github.com/quanxiang-cloud/flow/pkg/client"
+	"github.com/quanxiang-cloud/flow/pkg/config"
+	"github.com/quanxiang-cloud/flow/pkg/misc/error2"
+	"github.com/quanxiang-cloud/flow/pkg/misc/logger"
+	"github.com/quanxiang-cloud/flow/pkg/misc/time2"
+	"github.com/quanxiang-cloud/flow/pkg/utils"
+	"gorm.io/gorm"
+)
+
+const (
+	webhookTargetVariable  = "variable"
+	webhookTargetFormField = "formField"
+
+	// whenTimeout type of timeRule, noDealWith keeps waiting
+	timeoutAutoDealWith = "autoDealWith"
+	timeoutJump         = "jump"
+
+	webhookTokenBytes = 24
+	// webhookReadyRetry times of checking the node is paused before a callback is accepted, once per second
+	webhookReadyRetry = 10
+	// webhookCodeFmt dispatcher code of callback timeout: {address}:{callback_type}_{webhook_callback.id}
+	webhookCodeFmt = "flow:%s_%s"
+)
+
+// Webhook service, saving webhook results and waiting for callbacks of webhook nodes
+type Webhook interface {
+	SaveFlatten(ctx context.Context, processInstanceID string, nodeDefKey string, body map[string]interface{}) error
+	MapOutputs(ctx context.Context, instance *models.Instance, outputs []convert.WebHookOutput, body interface{}) error
+
+	Wait(ctx context.Context, req *WaitCallbackReq) (*WaitCallbackResp, error)
+	Ready(ctx context.Context, callbackID string) error
+	Abort(ctx context.Context, callbackID string) error
+	Callback(ctx context.Context, token string, payload map[string]interface{}) (bool, error)
+	CallbackTimeout(ctx context.Context, callbackID string) error
+}
+
+type webhook struct {
+	db                  *gorm.DB
+	conf                *config.Configs
+	webhookCallbackRepo models.WebhookCallbackRepo
+	instanceRepo        models.InstanceRepo
+	formAPI             client.Form
+	processAPI          client.Process
+	dispatcherAPI       client.Dispatcher
+	flow                Flow
+	workCalendar        WorkCalendar
+}
+
+// NewWebhook init
+func NewWebhook(conf *config.Configs, opts ...options.Options) (Webhook, error) {
+	flow, err := NewFlow(conf, opts...)
+	if err != nil {
+		return nil, err
+	}
+	workCalendar, err := NewWorkCalendar(conf, opts...)
+	if err != nil {
+		return nil, err
+	}
+	w := &webhook{
+		conf:                conf,
+		webhookCallbackRepo: mysql.NewWebhookCallbackRepo(),
+		instanceRepo:        mysql.NewInstanceRepo(),
+		formAPI:             client.NewForm(conf),
+		processAPI:          client.NewProcess(conf),
+		dispatcherAPI:       client.NewDispatcher(conf),
+		flow:                flow,
+		workCalendar:        workCalendar,
+	}
+
+	for _, opt := range opts {
+		opt(w)
+	}
+	return w, nil
+}
+
+// SetDB set db
+func (w *webhook) SetDB(db *gorm.DB) {
+	w.db = db
+}
+
+// SaveFlatten save every leaf of the body into the variable $nodeDefKey.a.b
+func (w *webhook) SaveFlatten(ctx context.Context, processInstanceID string, nodeDefKey string, body map[string]interface{}) error {
+	for k, v := range flatten(body) {
+		if err := w.flow.SaveInstanceVariable(ctx, processInstanceID, "$"+nodeDefKey+"."+k, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MapOutputs write selected fields of the body into process variables or form fields
+func (w *webhook) MapOutputs(ctx context.Context, instance *models.Instance, outputs []convert.WebHookOutput, body interface{}) error {
+	entity := make(map[string]interface{})
+	for _, output := range outputs {
+		if output.Name == "" {
+			continue
+		}
+		value := utils.SelectValue(body, output.Selector)
+		switch output.Target {
+		case webhookTargetVariable:
+			code := strings.TrimPrefix(output.Name, "$variable.")
+			if err := w.flow.SaveInstanceVariable(ctx, instance.ProcessInstanceID, code, value); err != nil {
+				return err
+			}
+		case webhookTargetFormField:
+			entity[output.Name] = value
+		}
+	}
+	if len(entity) == 0 || instance.FormInstanceID == "" {
+		return nil
+	}
+	return w.formAPI.UpdateData(ctx, instance.AppID, instance.FormID, instance.FormInstanceID, client.UpdateEntity{Entity: entity}, false)
+}
+
+// WaitCallbackReq wait callback req
+type WaitCallbackReq struct {
+	Instance    *models.Instance
+	ProcessID   string
+	NodeDefKey  string
+	ExecutionID string
+	Outputs     []convert.WebHookOutput
+	TimeRule    convert.TaskTimeRuleModel
+}
+
+// WaitCallbackResp wait callback resp
+type WaitCallbackResp struct {
+	ID          string
+	Token       string
+	CallbackURL string
+}
+
+// Wait create a one-time callback token, and a dispatcher job if the node has a time limit,
+// the token is accepted after the node calls Ready
+func (w *webhook) Wait(ctx context.Context, req *WaitCallbackReq) (*WaitCallbackResp, error) {
+	token, err := utils.RandomSecret(webhookTokenBytes)
+	if err != nil {
+		return nil, err
+	}
+	outputs, err := json.Marshal(req.Outputs)
+	if err != nil {
+		return nil, err
+	}
+	whenTimeout, err := json.Marshal(req.TimeRule.WhenTimeout)
+	if err != nil {
+		return nil, err
+	}
+	entity := &models.WebhookCallback{
+		Token:             token,
+		ProcessID:         req.ProcessID,
+		ProcessInstanceID: req.Instance.ProcessInstanceID,
+		NodeDefKey:        req.NodeDefKey,
+		ExecutionID:       req.ExecutionID,
+		Outputs:           string(outputs),
+		WhenTimeout:       string(whenTimeout),
+		Status:            models.WebhookCallbackPending,
+	}
+	if err := w.webhookCallbackRepo.Create(w.db, entity); err != nil {
+		return nil, err
+	}
+
+	deadLine := req.TimeRule.DeadLine
+	if req.TimeRule.Enabled && (deadLine.Day != 0 || deadLine.Hours != 0 || deadLine.Minutes != 0) {
+		begin := time2.Now()
+		if deadLine.BreakPoint == FlowWorked {
+			begin = req.Instance.CreateTime
+		}
+		err := w.dispatcherAPI.TakePost(ctx, client.TaskPostReq{
+			Code:       fmt.Sprintf(webhookCodeFmt, convert.CallbackOfWebhook, entity.ID),
+			Type:       1,
+			TimeBar:    w.workCalendar.AddTime(ctx, req.Instance.AppID, begin, req.TimeRule.TimeType, deadLine.DayHourMinuteModel),
+			State:      1,
+			Retry:      3,
+			RetryDelay: 60,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &WaitCallbackResp{
+		ID:          entity.ID,
+		Token:       token,
+		CallbackURL: w.conf.APIHost.HomeHost + "api/v1/flow/callback/" + token,
+	}, nil
+}
+
+// Ready the request is sent and the node is paused, the token is accepted from now on
+func (w *webhook) Ready(ctx context.Context, callbackID string) error {
+	_, err := w.webhookCallbackRepo.UpdateStatus(w.db, callbackID, models.WebhookCallbackPending, models.WebhookCallbackWaiting)
+	return err
+}
+
+// Abort the request could not be sent, the token is no longer valid
+func (w *webhook) Abort(ctx context.Context, callbackID string) error {
+	_, err := w.webhookCallbackRepo.UpdateStatus(w.db, callbackID, models.WebhookCallbackPending, models.WebhookCallbackFailed)
+	return err
+}
+
+// Callback result posted by the external system, mapped into variables before the node is completed
+func (w *webhook) Callback(ctx context.Context, token string, payload map[string]interface{}) (bool, error) {
+	entity, err := w.webhookCallbackRepo.FindByToken(w.db, token)
+	if err != nil {
+		return false, err
+	}
+	if entity == nil {
+		return false, error2.NewErrorWithString(error2.ErrParams, "invalid callback token")
+	}
+	// 回调可能早于节点挂起，等待节点挂起后再处理
+	for i := 0; entity.Status == models.WebhookCallbackPending && i < webhookReadyRetry; i++ {
+		time.Sleep(time.Second)
+		if entity, err = w.webhookCallbackRepo.FindByID(w.db, entity.ID); err != nil {
+			return false, err
+		}
+	}
+	if entity.Status == models.WebhookCallbackPending {
+		return false, error2.NewErrorWithString(error2.ErrParams, "webhook node is not paused yet, retry later")
+	}
+
+	instance, err := w.instanceRepo.GetEntityByProcessInstanceID(w.db, entity.ProcessInstanceID)
+	if err != nil {
+		return false, err
+	}
+	if instance == nil {
+		return false, error2.NewErrorWithString(error2.Internal, "instance of callback is nil")
+	}
+	if instance.Status == Suspended {
+		return false, error2.NewErrorWithString(error2.ErrParams, "flow instance is suspended, retry after it is resumed")
+	}
+	if !IsFlowOngoing(instance.Status) {
+		return false, error2.NewErrorWithString(error2.ErrParams, "flow instance is finished")
+	}
+
+	ok, err := w.webhookCallbackRepo.UpdateStatus(w.db, entity.ID, models.WebhookCallbackWaiting, models.WebhookCallbackDone)
+	if err != nil {
+		return false, err
+	}
+	if !ok {
+		return false, error2.NewErrorWithString(error2.ErrParams, "callback token has been used or expired")
+	}
+
+	var outputs []convert.WebHookOutput
+	if entity.Outputs != "" {
+		if err := json.Unmarshal([]byte(entity.Outputs), &outputs); err != nil {
+			return false, err
+		}
+	}
+	if err := w.saveSelected(ctx, entity.ProcessInstanceID, entity.NodeDefKey, outputs, payload); err != nil {
+		return false, err
+	}
+	if err := w.MapOutputs(ctx, instance, outputs, payload); err != nil {
+		return false, err
+	}
+	if err := w.completeNode(ctx, instance, entity, ""); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// saveSelected save the mapped fields of the payload into the variable $nodeDefKey.selector,
+// the payload comes from the external system, other fields are dropped
+func (w *webhook) saveSelected(ctx context.Context, processInstanceID string, nodeDefKey string,
+	outputs []convert.WebHookOutput, payload map[string]interface{}) error {
+	for _, output := range outputs {
+		path := strings.TrimPrefix(strings.TrimPrefix(strings.TrimSpace(output.Selector), "$"), ".")
+		if path == "" {
+			continue
+		}
+		value := utils.SelectValue(payload, output.Selector)
+		if err := w.flow.SaveInstanceVariable(ctx, processInstanceID, "$"+nodeDefKey+"."+path, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CallbackTimeout no callback in time, handled as whenTimeout of the node's timeRule
+func (w *webhook) CallbackTimeout(ctx context.Context, callbackID string) error {
+	entity, err := w.webhookCallbackRepo.FindByID(w.db, callbackID)
+	if err != nil || entity == nil {
+		return err
+	}
+	var whenTimeout convert.TypeAndValueModel
+	if entity.WhenTimeout != "" {
+		if err := json.Unmarshal([]byte(entity.WhenTimeout), &whenTimeout); err != nil {
+			return err
+		}
+	}
+	// 不处理：继续等待回调
+	if whenTimeout.Type != timeoutAutoDealWith && whenTimeout.Type != timeoutJump {
+		return nil
+	}
+	ok, err := w.webhookCallbackRepo.UpdateStatus(w.db, entity.ID, models.WebhookCallbackWaiting, models.WebhookCallbackTimeout)
+	if err != nil || !ok {
+		return err
+	}
+
+	instance, err := w.instanceRepo.GetEntityByProcessInstanceID(w.db, entity.ProcessInstanceID)
+	if err != nil || instance == nil {
+		return err
+	}
+	nextNodes := ""
+	if whenTimeout.Type == timeoutJump {
+		nextNodes = whenTimeout.Value
+	}
+	logger.Logger.Infow("webhook callback timeout", "processInstanceID", entity.ProcessInstanceID, "nodeDefKey", entity.NodeDefKey, "whenTimeout", whenTimeout.Type)
+	return w.completeNode(ctx, instance, entity, nextNodes)
+}
+
+func (w *webhook) completeNode(ctx context.Context, instance *models.Instance, entity *models.WebhookCallback, nextNodes string) error {
+	params, err := w.flow.GetInstanceVariableValues(ctx, instance)
+	if err != nil {
+		return err
+	}
+	return w.processAPI.CompleteNode(ctx, &client.CompleteNodeReq{
+		ProcessID:   entity.ProcessID,
+		InstanceID:  entity.ProcessInstanceID,
+		NodeDefKey:  entity.NodeDefKey,
+		ExecutionID: entity.ExecutionID,
+		NextNodes:   nextNodes,
+		Params:      w.flow.FormatFormValue(instance, params),
+		UserID:      instance.ApplyUserID,
+	})
+}
+
+// flatten nested maps into a.b keys
+func flatten(data map[string]interface{}) map[string]interface{} {
+	ret := make(map[string]interface{})
+	for k, v := range data {
+		if v == nil {
+			continue
+		}
+		if reflect.TypeOf(v).Kind() == reflect.Map {
+			if m, ok := v.(map[string]interface{}); ok {
+				for k1, v1 := range flatten(m) {
+					ret[k+"."+k1] = v1
+				}
+				continue
+			}
+		}
+		ret[k] = v
+	}
+	return ret
+}
@@ -0,0 +1,416 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package flow
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/quanxiang-cloud/flow/internal/convert"
+	"github.com/quanxiang-cloud/flow/internal/models"
+	"github.com/quanxiang-cloud/flow/internal/models/mysql"
+	"github.com/quanxiang-cloud/flow/internal/server/options"
+	"github.com/quanxiang-cloud/flow/pkg"
+	"github.com/quanxiang-cloud/flow/pkg/client"
+	"github.com/quanxiang-cloud/flow/pkg/config"
+	"github.com/quanxiang-cloud/flow/pkg/misc/error2"
+	"github.com/quanxiang-cloud/flow/pkg/misc/logger"
+	"github.com/quanxiang-cloud/flow/pkg/misc/time2"
+	"github.com/quanxiang-cloud/flow/pkg/utils"
+	"gorm.io/gorm"
+)
+
+const (
+	calendarDateFmt  = "2006-01-02"
+	calendarClockFmt = "15:04"
+
+	defaultWorkDays  = "1,2,3,4,5"
+	defaultWorkStart = "09:00"
+	defaultWorkEnd   = "18:00"
+	defaultTimeZone  = "Asia/Shanghai"
+)
+
+// WorkCalendar service, working days and hours used by deadlines and delays
+type WorkCalendar interface {
+	Save(ctx context.Context, req *SaveWorkCalendarReq) (*models.WorkCalendar, error)
+	Get(ctx context.Context, req *WorkCalendarReq) (*models.WorkCalendar, error)
+	Delete(ctx context.Context, req *WorkCalendarReq) (bool, error)
+
+	AddTime(ctx context.Context, appID string, begin string, timeType string, d convert.DayHourMinuteModel) string
+	Location(ctx context.Context, appID string) *time.Location
+}
+
+type workCalendar struct {
+	db               *gorm.DB
+	workCalendarRepo models.WorkCalendarRepo
+	appCenterAPI     client.AppCenter
+}
+
+// NewWorkCalendar init
+func NewWorkCalendar(conf *config.Configs, opts ...options.Options) (WorkCalendar, error) {
+	w := &workCalendar{
+		workCalendarRepo: mysql.NewWorkCalendarRepo(),
+		appCenterAPI:     client.NewAppCenter(conf),
+	}
+
+	for _, opt := range opts {
+		opt(w)
+	}
+	return w, nil
+}
+
+// SetDB set db
+func (w *workCalendar) SetDB(db *gorm.DB) {
+	w.db = db
+}
+
+// WorkCalendarReq work calendar req, empty app id means the default calendar of the tenant
+type WorkCalendarReq struct {
+	AppID string `json:"appID"`
+	Super bool   `json:"-"` // 平台管理员，由请求头的角色得到
+}
+
+// SaveWorkCalendarReq save work calendar req
+type SaveWorkCalendarReq struct {
+	AppID         string   `json:"appID"`
+	WorkDays      []int    `json:"workDays"`  // 0为周日
+	WorkStart     string   `json:"workStart"` // HH:mm
+	WorkEnd       string   `json:"workEnd"`   // HH:mm
+	TimeZone      string   `json:"timeZone"`
+	Holidays      []string `json:"holidays"`      // yyyy-MM-dd
+	ExtraWorkDays []string `json:"extraWorkDays"` // yyyy-MM-dd
+	Super         bool     `json:"-"`             // 平台管理员，由请求头的角色得到
+}
+
+// Save create or replace the calendar of the app
+func (w *workCalendar) Save(ctx context.Context, req *SaveWorkCalendarReq) (*models.WorkCalendar, error) {
+	if err := w.checkAdmin(ctx, req.AppID, req.Super); err != nil {
+		return nil, err
+	}
+	entity, err := req.toModel()
+	if err != nil {
+		return nil, err
+	}
+
+	userID := pkg.STDUserID(ctx)
+	old, err := w.workCalendarRepo.FindByAppID(w.db, req.AppID)
+	if err != nil {
+		return nil, err
+	}
+	if old == nil {
+		entity.CreatorID = userID
+		entity.ModifierID = userID
+		if err = w.workCalendarRepo.Create(w.db, entity); err != nil {
+			return nil, err
+		}
+		return entity, nil
+	}
+
+	err = w.workCalendarRepo.Update(w.db, old.ID, map[string]interface{}{
+		"work_days":       entity.WorkDays,
+		"work_start":      entity.WorkStart,
+		"work_end":        entity.WorkEnd,
+		"time_zone":       entity.TimeZone,
+		"holidays":        entity.Holidays,
+		"extra_work_days": entity.ExtraWorkDays,
+		"modifier_id":     userID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	entity.BaseModel = old.BaseModel
+	return entity, nil
+}
+
+// Get calendar used by the app, falls back to the default of the tenant and then the built-in one
+func (w *workCalendar) Get(ctx context.Context, req *WorkCalendarReq) (*models.WorkCalendar, error) {
+	return w.find(req.AppID)
+}
+
+// Delete delete the calendar of the app
+func (w *workCalendar) Delete(ctx context.Context, req *WorkCalendarReq) (bool, error) {
+	if err := w.checkAdmin(ctx, req.AppID, req.Super); err != nil {
+		return false, err
+	}
+	entity, err := w.workCalendarRepo.FindByAppID(w.db, req.AppID)
+	if err != nil {
+		return false, err
+	}
+	if entity == nil {
+		return false, error2.NewErrorWithString(error2.ErrParams, "work calendar is not exists")
+	}
+	if err = w.workCalendarRepo.Delete(w.db, entity.ID); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// AddTime begin + d, counted in working time if the time type is working
+func (w *workCalendar) AddTime(ctx context.Context, appID string, begin string, timeType string, d convert.DayHourMinuteModel) string {
+	if timeType != convert.TimeTypeWorking {
+		return utils.AddDaysHoursMinutes(begin, d.Day, d.Hours, d.Minutes)
+	}
+	t, err := time.Parse(time2.ISO8601, begin)
+	if err != nil {
+		return ""
+	}
+	entity, err := w.find(appID)
+	if err != nil {
+		logger.Logger.Error("find work calendar err,", err)
+		return utils.AddDaysHoursMinutes(begin, d.Day, d.Hours, d.Minutes)
+	}
+	c := newCalendar(entity)
+	minutes := time.Duration(d.Hours)*time.Hour + time.Duration(d.Minutes)*time.Minute
+	return time2.UnixToISO8601(c.add(t, d.Day, minutes).Unix())
+}
+
+// Location time zone of the calendar used by the app
+func (w *workCalendar) Location(ctx context.Context, appID string) *time.Location {
+	entity, err := w.find(appID)
+	if err != nil {
+		logger.Logger.Error("find work calendar err,", err)
+		return calendarLocation("")
+	}
+	return calendarLocation(entity.TimeZone)
+}
+
+func (w *workCalendar) find(appID string) (*models.WorkCalendar, error) {
+	if appID != "" {
+		entity, err := w.workCalendarRepo.FindByAppID(w.db, appID)
+		if err != nil || entity != nil {
+			return entity, err
+		}
+	}
+	entity, err := w.workCalendarRepo.FindByAppID(w.db, "")
+	if err != nil || entity != nil {
+		return entity, err
+	}
+	return &models.WorkCalendar{
+		WorkDays:  defaultWorkDays,
+		WorkStart: defaultWorkStart,
+		WorkEnd:   defaultWorkEnd,
+		TimeZone:  defaultTimeZone,
+	}, nil
+}
+
+// checkAdmin the default calendar of the tenant is changed by the platform admin, the calendar of an app by its admins
+func (w *workCalendar) checkAdmin(ctx context.Context, appID string, super bool) error {
+	if appID == "" {
+		if !super {
+			return error2.NewErrorWithString(error2.Internal, "No permission to change the default work calendar ")
+		}
+		return nil
+	}
+	return checkAppAdmin(ctx, w.appCenterAPI, appID)
+}
+
+func (req *SaveWorkCalendarReq) toModel() (*models.WorkCalendar, error) {
+	if len(req.WorkDays) == 0 {
+		return nil, error2.NewErrorWithString(error2.ErrParams, "work days is required")
+	}
+	workDays := make([]string, 0, len(req.WorkDays))
+	for _, day := range req.WorkDays {
+		if day < 0 || day > 6 {
+			return nil, error2.NewErrorWithString(error2.ErrParams, "work day must be 0-6")
+		}
+		workDays = append(workDays, strconv.Itoa(day))
+	}
+
+	start, err := time.Parse(calendarClockFmt, req.WorkStart)
+	if err != nil {
+		return nil, error2.NewErrorWithString(error2.ErrParams, "bad work start time")
+	}
+	end, err := time.Parse(calendarClockFmt, req.WorkEnd)
+	if err != nil {
+		return nil, error2.NewErrorWithString(error2.ErrParams, "bad work end time")
+	}
+	if !start.Before(end) {
+		return nil, error2.NewErrorWithString(error2.ErrParams, "work start time must be before end time")
+	}
+
+	if req.TimeZone == "" {
+		req.TimeZone = defaultTimeZone
+	}
+	if _, err = time.LoadLocation(req.TimeZone); err != nil {
+		return nil, error2.NewErrorWithString(error2.ErrParams, "bad time zone")
+	}
+
+	for _, dates := range [][]string{req.Holidays, req.ExtraWorkDays} {
+		for _, date := range dates {
+			if _, err = time.Parse(calendarDateFmt, date); err != nil {
+				return nil, error2.NewErrorWithString(error2.ErrParams, "bad date "+date)
+			}
+		}
+	}
+
+	return &models.WorkCalendar{
+		AppID:         req.AppID,
+		WorkDays:      strings.Join(workDays, ","),
+		WorkStart:     req.WorkStart,
+		WorkEnd:       req.WorkEnd,
+		TimeZone:      req.TimeZone,
+		Holidays:      strings.Join(req.Holidays, ","),
+		ExtraWorkDays: strings.Join(req.ExtraWorkDays, ","),
+	}, nil
+}
+
+// calendar working time calculator of a work calendar
+type calendar struct {
+	loc           *time.Location
+	workDays      map[time.Weekday]bool
+	start, end    time.Duration // 上下班时间距零点的时长
+	holidays      map[string]bool
+	extraWorkDays map[string]bool
+}
+
+func newCalendar(entity *models.WorkCalendar) *calendar {
+	c := &calendar{
+		workDays:      make(map[time.Weekday]bool),
+		holidays:      dateSet(entity.Holidays),
+		extraWorkDays: dateSet(entity.ExtraWorkDays),
+	}
+
+	c.loc = calendarLocation(entity.TimeZone)
+
+	for _, day := range strings.Split(entity.WorkDays, ",") {
+		if d, err := strconv.Atoi(strings.TrimSpace(day)); err == nil && d >= 0 && d <= 6 {
+			c.workDays[time.Weekday(d)] = true
+		}
+	}
+	if len(c.workDays) == 0 {
+		for _, d := range []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday} {
+			c.workDays[d] = true
+		}
+	}
+
+	c.start, c.end = clockOf(entity.WorkStart, defaultWorkStart), clockOf(entity.WorkEnd, defaultWorkEnd)
+	if c.start >= c.end {
+		c.start, c.end = clockOf(defaultWorkStart, ""), clockOf(defaultWorkEnd, "")
+	}
+	return c
+}
+
+func (c *calendar) isWorkDay(t time.Time) bool {
+	date := t.Format(calendarDateFmt)
+	if c.extraWorkDays[date] {
+		return true
+	}
+	if c.holidays[date] {
+		return false
+	}
+	return c.workDays[t.Weekday()]
+}
+
+// add t + days working days + d working hours, negative values count backward
+func (c *calendar) add(t time.Time, days int, d time.Duration) time.Time {
+	t = t.In(c.loc)
+	// 按工作日推移，保持时刻不变
+	for days > 0 {
+		t = t.AddDate(0, 0, 1)
+		if c.isWorkDay(t) {
+			days--
+		}
+	}
+	for days < 0 {
+		t = t.AddDate(0, 0, -1)
+		if c.isWorkDay(t) {
+			days++
+		}
+	}
+
+	// 只在上班时间内计时
+	for d > 0 {
+		dayStart, dayEnd := c.clock(t, c.start), c.clock(t, c.end)
+		if !c.isWorkDay(t) || !t.Before(dayEnd) {
+			t = c.clock(c.nextWorkDay(t, 1), c.start)
+			continue
+		}
+		if t.Before(dayStart) {
+			t = dayStart
+		}
+		if available := dayEnd.Sub(t); d > available {
+			d -= available
+			t = dayEnd
+			continue
+		}
+		t = t.Add(d)
+		d = 0
+	}
+	for d < 0 {
+		dayStart, dayEnd := c.clock(t, c.start), c.clock(t, c.end)
+		if !c.isWorkDay(t) || !t.After(dayStart) {
+			t = c.clock(c.nextWorkDay(t, -1), c.end)
+			continue
+		}
+		if t.After(dayEnd) {
+			t = dayEnd
+		}
+		if available := t.Sub(dayStart); -d > available {
+			d += available
+			t = dayStart
+			continue
+		}
+		t = t.Add(d)
+		d = 0
+	}
+	return t
+}
+
+// nextWorkDay the nearest working day after (step 1) or before (step -1) t
+func (c *calendar) nextWorkDay(t time.Time, step int) time.Time {
+	for {
+		t = t.AddDate(0, 0, step)
+		if c.isWorkDay(t) {
+			return t
+		}
+	}
+}
+
+// clock the time of the day of t
+func (c *calendar) clock(t time.Time, offset time.Duration) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, c.loc).Add(offset)
+}
+
+func calendarLocation(timeZone string) *time.Location {
+	loc, err := time.LoadLocation(timeZone)
+	if timeZone == "" || err != nil {
+		// 与ChangeBjTimeToISO8601保持一致，默认东八区
+		return time.FixedZone("CST", 8*60*60)
+	}
+	return loc
+}
+
+func clockOf(s string, def string) time.Duration {
+	t, err := time.Parse(calendarClockFmt, s)
+	if err != nil {
+		if def == "" {
+			return 0
+		}
+		return clockOf(def, "")
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+}
+
+func dateSet(s string) map[string]bool {
+	set := make(map[string]bool)
+	for _, date := range strings.Split(s, ",") {
+		if date = strings.TrimSpace(date); date != "" {
+			set[date] = true
+		}
+	}
+	return set
+}
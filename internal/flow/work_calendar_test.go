@@ -0,0 +1,63 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package flow
+
+import (
+	"testing"
+	"time"
+
+	"github.com/quanxiang-cloud/flow/internal/models"
+)
+
+func Test_calendar_add(t *testing.T) {
+	cst := time.FixedZone("CST", 8*60*60)
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2024, 1, day, hour, minute, 0, 0, cst)
+	}
+	weekly := &models.WorkCalendar{WorkDays: "1,2,3,4,5", WorkStart: "09:00", WorkEnd: "18:00"}
+	tests := []struct {
+		name     string
+		calendar *models.WorkCalendar
+		begin    time.Time
+		days     int
+		d        time.Duration
+		want     time.Time
+	}{
+		{"friday evening plus 2 days", weekly, at(5, 19, 0), 2, 0, at(9, 19, 0)},
+		{"hours over the weekend", weekly, at(5, 17, 0), 0, 2 * time.Hour, at(8, 10, 0)},
+		{"start on saturday", weekly, at(6, 12, 0), 0, time.Hour, at(8, 10, 0)},
+		{"exact end of day", weekly, at(5, 17, 0), 0, time.Hour, at(5, 18, 0)},
+		{"backward over the weekend", weekly, at(8, 10, 0), 0, -2 * time.Hour, at(5, 17, 0)},
+		{"backward days", weekly, at(8, 10, 0), -1, 0, at(5, 10, 0)},
+		{
+			"holiday is skipped",
+			&models.WorkCalendar{WorkDays: "1,2,3,4,5", WorkStart: "09:00", WorkEnd: "18:00", Holidays: "2024-01-08"},
+			at(5, 17, 0), 0, 2 * time.Hour, at(9, 10, 0),
+		},
+		{
+			"extra work day is counted",
+			&models.WorkCalendar{WorkDays: "1,2,3,4,5", WorkStart: "09:00", WorkEnd: "18:00", ExtraWorkDays: "2024-01-06"},
+			at(5, 19, 0), 1, 0, at(6, 19, 0),
+		},
+		{"days and hours", weekly, at(5, 16, 0), 1, 3 * time.Hour, at(9, 10, 0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := newCalendar(tt.calendar).add(tt.begin, tt.days, tt.d)
+			if !got.Equal(tt.want) {
+				t.Errorf("add() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -27,9 +27,13 @@ type AbnormalTask struct {
 	TaskID            string `json:"taskId"`
 	TaskName          string `json:"taskName"`
 	TaskDefKey        string `json:"taskDefKey"`
+	ExecutionID       string `json:"executionId"` // 挂起的自动节点执行id，没有任务
 	Reason            string `json:"reason"`
 	Remark            string `json:"remark"`
-	Status            int8   `json:"status"` // 0 unhandle，1 handled，2 autoHandled
+	Status            int8   `json:"status"`       // 0 unhandle，1 handled，2 autoHandled
+	JobID             string `json:"jobId"`        // 批量处理任务id
+	HandleResult      string `json:"handleResult"` // 批量处理结果：SUCCESS、FAIL
+	HandleMessage     string `json:"handleMessage"`
 }
 
 // AbnormalTaskRepo interface
@@ -43,6 +47,19 @@ type AbnormalTaskRepo interface {
 	Find(db *gorm.DB, condition map[string]interface{}) ([]*AbnormalTask, error)
 	Page(db *gorm.DB, req *AbnormalTaskReq) ([]*AbnormalTaskVo, int64, error)
 	DeleteByInstanceIDs(db *gorm.DB, InstanceIDs []string) error
+	FindUnhandled(db *gorm.DB, filter *AbnormalTaskFilter) ([]*AbnormalTask, error)
+}
+
+// AbnormalTaskFilter unhandled abnormal tasks to handle in batch, by IDs or by conditions
+type AbnormalTaskFilter struct {
+	IDs         []string `json:"ids"`
+	AppID       string   `json:"appId"`
+	FlowID      string   `json:"flowId"`
+	Reason      string   `json:"reason"`
+	StartTime   string   `json:"startTime"` // 异常任务创建时间范围
+	EndTime     string   `json:"endTime"`
+	AdminAppIDs []string `json:"-"`
+	Limit       int      `json:"-"`
 }
 
 // AbnormalTaskReq AbnormalTaskReq
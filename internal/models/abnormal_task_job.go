@@ -0,0 +1,43 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package models
+
+import "gorm.io/gorm"
+
+const (
+	// AbnormalTaskJobRunning job is handling the tasks
+	AbnormalTaskJobRunning = "RUNNING"
+	// AbnormalTaskJobFinished all tasks of the job are handled
+	AbnormalTaskJobFinished = "FINISHED"
+)
+
+// AbnormalTaskJob batch operation on abnormal tasks, outcome of each task is recorded on the abnormal task
+type AbnormalTaskJob struct {
+	BaseModel
+
+	Operation    string `json:"operation"` // STEP_BACK、SEND_BACK、ABANDON、DELIVER
+	Params       string `json:"params"`
+	Status       string `json:"status"`
+	Total        int    `json:"total"`
+	Processed    int    `json:"processed"`
+	SuccessCount int    `json:"successCount"`
+	FailCount    int    `json:"failCount"`
+}
+
+// AbnormalTaskJobRepo interface
+type AbnormalTaskJobRepo interface {
+	Create(db *gorm.DB, model *AbnormalTaskJob) error
+	Update(db *gorm.DB, ID string, updateMap map[string]interface{}) error
+	FindByID(db *gorm.DB, ID string) (*AbnormalTaskJob, error)
+}
@@ -0,0 +1,39 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package models
+
+import "gorm.io/gorm"
+
+const (
+	// AppSecretActive secret used to sign outbound webhooks
+	AppSecretActive = "ACTIVE"
+	// AppSecretRevoked secret no longer used
+	AppSecretRevoked = "REVOKED"
+)
+
+// AppSecret signing secret of an app's outbound webhooks
+type AppSecret struct {
+	BaseModel
+
+	AppID  string `json:"appId"`
+	Secret string `json:"-"` // 不参与序列化，避免随导出、列表等接口泄露
+	Status string `json:"status"`
+}
+
+// AppSecretRepo interface
+type AppSecretRepo interface {
+	Create(db *gorm.DB, model *AppSecret) error
+	FindActiveByAppID(db *gorm.DB, appID string) (*AppSecret, error)
+	RevokeByAppID(db *gorm.DB, appID string, modifierID string) error
+}
@@ -0,0 +1,33 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package models
+
+import "gorm.io/gorm"
+
+// ApproveSequence assignees of a sequential approve node, processed one after another
+type ApproveSequence struct {
+	BaseModel
+
+	ProcessInstanceID string `json:"processInstanceId"`
+	NodeDefKey        string `json:"nodeDefKey"`
+	Assignees         string `json:"assignees"` // 按处理顺序，逗号分隔
+	Current           int    `json:"current"`   // 当前处理人下标
+}
+
+// ApproveSequenceRepo interface
+type ApproveSequenceRepo interface {
+	Create(db *gorm.DB, model *ApproveSequence) error
+	Update(db *gorm.DB, ID string, updateMap map[string]interface{}) error
+	FindByNodeDefKey(db *gorm.DB, processInstanceID string, nodeDefKey string) (*ApproveSequence, error)
+}
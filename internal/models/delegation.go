@@ -0,0 +1,64 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package models
+
+import (
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// DelegationActive delegation in use
+	DelegationActive = "ACTIVE"
+	// DelegationCanceled delegation canceled by the delegator
+	DelegationCanceled = "CANCELED"
+)
+
+// Delegation tasks of the user are routed to the delegate during the period
+type Delegation struct {
+	BaseModel
+
+	UserID     string `json:"userID"`     // 委托人
+	DelegateID string `json:"delegateID"` // 受托人
+	AppIDs     string `json:"appIDs"`     // 委托的应用，逗号分隔，为空时委托所有应用
+	StartTime  string `json:"startTime"`
+	EndTime    string `json:"endTime"`
+	Status     string `json:"status"`
+
+	UserName     string `gorm:"-" json:"userName"`
+	DelegateName string `gorm:"-" json:"delegateName"`
+}
+
+// Covers whether the tasks of the app are delegated
+func (d *Delegation) Covers(appID string) bool {
+	if d.AppIDs == "" {
+		return true
+	}
+	for _, id := range strings.Split(d.AppIDs, ",") {
+		if id == appID {
+			return true
+		}
+	}
+	return false
+}
+
+// DelegationRepo interface
+type DelegationRepo interface {
+	Create(db *gorm.DB, model *Delegation) error
+	Update(db *gorm.DB, ID string, updateMap map[string]interface{}) error
+	FindByID(db *gorm.DB, ID string) (*Delegation, error)
+	FindByUserID(db *gorm.DB, userID string) ([]*Delegation, error)
+	FindActive(db *gorm.DB, startTime string, endTime string) ([]*Delegation, error)
+}
@@ -23,6 +23,7 @@ type DispatcherCallback struct {
 	OtherInfo         string `json:"otherInfo"`
 	ProcessInstanceID string `json:"processInstanceId"`
 	TaskDefKey        string `json:"taskDefKey"`
+	ExecTime          string `json:"execTime"` // 回调执行时间，实例恢复时顺延
 }
 
 // DispatcherCallbackRepo interface
@@ -31,4 +32,5 @@ type DispatcherCallbackRepo interface {
 	Update(db *gorm.DB, ID string, updateMap map[string]interface{}) error
 	Delete(db *gorm.DB, ID string) error
 	FindByID(db *gorm.DB, ID string) (*DispatcherCallback, error)
+	FindByProcessInstanceID(db *gorm.DB, processInstanceID string) ([]*DispatcherCallback, error)
 }
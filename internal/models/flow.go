@@ -38,6 +38,8 @@ type Flow struct {
 	CanCancelType    int8   `json:"canCancelType"`
 	CanCancelNodes   string `json:"canCancelNodes"` // taskDefKey array
 	CanUrge          int8   `json:"canUrge"`
+	UrgePolicy       string `json:"urgePolicy"`     // 催办策略，json格式
+	NotifyChannels   string `json:"notifyChannels"` // 各事件的通知渠道，json格式，如{"TASK_ASSIGNED":["email","chat"]}
 	CanViewStatusMsg int8   `json:"canViewStatusMsg"`
 	CanMsg           int8   `json:"canMsg"`
 	InstanceName     string `json:"instanceName"` // Instance name template
@@ -47,6 +49,15 @@ type Flow struct {
 	Variables []*Variables `json:"variables" gorm:"-"`
 }
 
+// UrgePolicy urge policy of a flow
+type UrgePolicy struct {
+	MinInterval int      `json:"minInterval"` // 两次催办的最小间隔，分钟
+	MaxTimes    int      `json:"maxTimes"`    // 每个任务最多催办次数，0为不限
+	QuietStart  string   `json:"quietStart"`  // 免打扰开始时间，HH:mm，应用工作日历的时区
+	QuietEnd    string   `json:"quietEnd"`    // 免打扰结束时间，HH:mm，应用工作日历的时区
+	Channels    []string `json:"channels"`    // 通知渠道，为空时使用流程的催办通知渠道
+}
+
 const (
 	// ENABLE status
 	ENABLE = "ENABLE"
@@ -0,0 +1,43 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package models
+
+import "gorm.io/gorm"
+
+// FlowVersion immutable snapshot of a published flow
+type FlowVersion struct {
+	BaseModel
+
+	FlowID        string `json:"flowId"`
+	Version       int    `json:"version"`
+	Name          string `json:"name"`
+	BpmnText      string `json:"bpmnText"`  // flow model json
+	ProcessID     string `json:"processID"` // Process id deployed for this version
+	FormID        string `json:"formId"`
+	PublisherID   string `json:"publisherId"`
+	PublisherName string `json:"publisherName"`
+	PublishTime   string `json:"publishTime"`
+	DiffSummary   string `json:"diffSummary"` // changes compared with the previous version
+	Remark        string `json:"remark"`
+}
+
+// FlowVersionRepo interface
+type FlowVersionRepo interface {
+	Create(db *gorm.DB, model *FlowVersion) error
+	FindByID(db *gorm.DB, ID string) (*FlowVersion, error)
+	FindByProcessID(db *gorm.DB, processID string) (*FlowVersion, error)
+	FindLatest(db *gorm.DB, flowID string) (*FlowVersion, error)
+	FindPageByFlowID(db *gorm.DB, flowID string, page, size int) ([]*FlowVersion, int64, error)
+	DeleteByFlowIDs(db *gorm.DB, flowIDs []string) error
+}
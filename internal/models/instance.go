@@ -26,6 +26,8 @@ type Instance struct {
 	AppStatus         string          `json:"appStatus"`
 	AppName           string          `json:"appName"`
 	FlowID            string          `json:"flowId"`
+	FlowVersionID     string          `json:"flowVersionId"` // version pinned when the instance started
+	FlowVersion       int             `json:"flowVersion"`
 	ProcessInstanceID string          `json:"processInstanceId"`
 	FormID            string          `json:"formId"`
 	FormInstanceID    string          `json:"formInstanceId"`
@@ -39,6 +41,8 @@ type Instance struct {
 	Tasks             []ActTaskEntity `gorm:"-" json:"tasks"`
 	Nodes             []NodeModel     `gorm:"-" json:"nodes"`
 	RequestID         string          `json:"requestID"`
+	PreSuspendStatus  string          `json:"preSuspendStatus"` // 挂起前的状态
+	SuspendTime       string          `json:"suspendTime"`
 }
 
 // InstanceRepo interface
@@ -69,4 +73,5 @@ type PageInstancesReq struct {
 	Keyword         string
 	AppID           string
 	FlowIDs         []string
+	Statuses        []string
 }
@@ -19,17 +19,19 @@ import "gorm.io/gorm"
 type InstanceStep struct {
 	BaseModel
 
-	ProcessInstanceID string             `json:"processInstanceId"`
-	TaskID            string             `json:"taskId"`
-	TaskType          string             `json:"taskType"` // 节点类型：或签、会签、任填、全填、开始、结束
-	TaskDefKey        string             `json:"taskDefKey"`
-	TaskName          string             `json:"taskName"`
-	HandleUserIDs     string             `json:"handleUserIds"`
-	Status            string             `json:"status"` // 步骤处理结果，通过、拒绝、完成填写、已回退、打回重填、自动跳过、自动交给管理员
-	NodeInstanceID    string             `json:"nodeInstanceId"`
-	OperationRecords  []*OperationRecord `gorm:"-" json:"operationRecords"`
-	FlowName          string             `gorm:"-" json:"flowName"`
-	Reason            string             `gorm:"-" json:"reason"`
+	ProcessInstanceID    string             `json:"processInstanceId"`
+	TaskID               string             `json:"taskId"`
+	TaskType             string             `json:"taskType"` // 节点类型：或签、会签、任填、全填、开始、结束
+	TaskDefKey           string             `json:"taskDefKey"`
+	TaskName             string             `json:"taskName"`
+	HandleUserIDs        string             `json:"handleUserIds"`
+	Status               string             `json:"status"` // 步骤处理结果，通过、拒绝、完成填写、已回退、打回重填、自动跳过、自动交给管理员
+	NodeInstanceID       string             `json:"nodeInstanceId"`
+	OperationRecords     []*OperationRecord `gorm:"-" json:"operationRecords"`
+	FlowName             string             `gorm:"-" json:"flowName"`
+	Reason               string             `gorm:"-" json:"reason"`
+	RelProcessInstanceID string             `gorm:"-" json:"relProcessInstanceId"` // 子流程步骤关联的子（父）流程实例
+	WebhookAttempts      []*WebhookAttempt  `gorm:"-" json:"webhookAttempts"`      // webhook步骤的调用记录
 }
 
 // InstanceStepRepo interface
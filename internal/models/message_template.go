@@ -0,0 +1,51 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package models
+
+import "gorm.io/gorm"
+
+const (
+	// MessageTemplateCustom template used by letter and email nodes
+	MessageTemplateCustom = "CUSTOM"
+	// MessageTemplateTaskAssigned message to the assignee of a new task
+	MessageTemplateTaskAssigned = "TASK_ASSIGNED"
+	// MessageTemplateUrge message to the assignee of an urged task
+	MessageTemplateUrge = "URGE"
+	// MessageTemplateTimeout message sent when a task times out
+	MessageTemplateTimeout = "TIMEOUT"
+	// MessageTemplateFinished message to the applicant when the instance is finished
+	MessageTemplateFinished = "FINISHED"
+)
+
+// MessageTemplate message template of an app, the one with flow id overrides the app's for that flow
+type MessageTemplate struct {
+	BaseModel
+
+	AppID   string `json:"appId"`
+	FlowID  string `json:"flowId"` // 为空时为应用级模板
+	Type    string `json:"type"`   // CUSTOM、TASK_ASSIGNED、URGE、TIMEOUT、FINISHED
+	Name    string `json:"name"`
+	Title   string `json:"title"`
+	Content string `json:"content"` // 支持${form.field}、${variable.code}、${instance.applyNo}、${task.link}等变量
+}
+
+// MessageTemplateRepo interface
+type MessageTemplateRepo interface {
+	Create(db *gorm.DB, model *MessageTemplate) error
+	Update(db *gorm.DB, ID string, updateMap map[string]interface{}) error
+	Delete(db *gorm.DB, ID string) error
+	FindByID(db *gorm.DB, ID string) (*MessageTemplate, error)
+	FindByAppID(db *gorm.DB, appID string, flowID string) ([]*MessageTemplate, error)
+	FindByType(db *gorm.DB, appID string, flowID string, templateType string) (*MessageTemplate, error)
+}
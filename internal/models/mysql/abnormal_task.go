@@ -141,3 +141,42 @@ func (r *abnormalTaskRepo) DeleteByInstanceIDs(db *gorm.DB, InstanceIDs []string
 	err := db.Table(r.TableName()).Where("flow_instance_id in (?)", InstanceIDs).Delete(&models.AbnormalTask{}).Error
 	return err
 }
+
+// FindUnhandled unhandled abnormal tasks matching the filter
+func (r *abnormalTaskRepo) FindUnhandled(db *gorm.DB, filter *models.AbnormalTaskFilter) ([]*models.AbnormalTask, error) {
+	rs := make([]*models.AbnormalTask, 0)
+	tx := db.Table(r.TableName()).Select("flow_abnormal_task.*")
+	tx = tx.Joins("left join flow_instance on flow_abnormal_task.flow_instance_id = flow_instance.id")
+	tx = tx.Where("flow_abnormal_task.status = ?", 0)
+
+	if len(filter.IDs) > 0 {
+		tx = tx.Where("flow_abnormal_task.id in (?)", filter.IDs)
+	}
+	if len(filter.AppID) > 0 {
+		tx = tx.Where("flow_instance.app_id = ?", filter.AppID)
+	}
+	if len(filter.FlowID) > 0 {
+		tx = tx.Where("flow_instance.flow_id = ?", filter.FlowID)
+	}
+	if len(filter.Reason) > 0 {
+		tx = tx.Where("flow_abnormal_task.reason = ?", filter.Reason)
+	}
+	if len(filter.StartTime) > 0 {
+		tx = tx.Where("flow_abnormal_task.create_time >= ?", filter.StartTime)
+	}
+	if len(filter.EndTime) > 0 {
+		tx = tx.Where("flow_abnormal_task.create_time <= ?", filter.EndTime)
+	}
+	if len(filter.AdminAppIDs) > 0 {
+		tx = tx.Where("flow_instance.app_id in (?)", filter.AdminAppIDs)
+	}
+	if filter.Limit > 0 {
+		tx = tx.Limit(filter.Limit)
+	}
+
+	err := tx.Order("flow_abnormal_task.create_time asc").Find(&rs).Error
+	if err != nil {
+		return nil, err
+	}
+	return rs, nil
+}
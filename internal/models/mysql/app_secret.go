@@ -0,0 +1,75 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mysql
+
+import (
+	"github.com/quanxiang-cloud/flow/internal/models"
+	"github.com/quanxiang-cloud/flow/pkg/misc/id2"
+	"github.com/quanxiang-cloud/flow/pkg/misc/time2"
+	"gorm.io/gorm"
+)
+
+type appSecretRepo struct{}
+
+// NewAppSecretRepo new repo
+func NewAppSecretRepo() models.AppSecretRepo {
+	return &appSecretRepo{}
+}
+
+// TableName db table name
+func (r *appSecretRepo) TableName() string {
+	return "flow_app_secret"
+}
+
+// Create create model
+func (r *appSecretRepo) Create(db *gorm.DB, entity *models.AppSecret) error {
+	entity.ID = id2.GenID()
+	entity.CreateTime = time2.Now()
+	entity.ModifyTime = entity.CreateTime
+	err := db.Table(r.TableName()).
+		Create(entity).
+		Error
+	return err
+}
+
+// FindActiveByAppID find the active secret of the app
+func (r *appSecretRepo) FindActiveByAppID(db *gorm.DB, appID string) (*models.AppSecret, error) {
+	entity := new(models.AppSecret)
+	err := db.Table(r.TableName()).
+		Where("app_id = ? and status = ?", appID, models.AppSecretActive).
+		Order("create_time desc").
+		Limit(1).
+		Find(entity).
+		Error
+	if err != nil {
+		return nil, err
+	}
+	if entity.ID == "" {
+		return nil, nil
+	}
+	return entity, nil
+}
+
+// RevokeByAppID revoke all active secrets of the app
+func (r *appSecretRepo) RevokeByAppID(db *gorm.DB, appID string, modifierID string) error {
+	err := db.Table(r.TableName()).
+		Where("app_id = ? and status = ?", appID, models.AppSecretActive).
+		Updates(map[string]interface{}{
+			"status":      models.AppSecretRevoked,
+			"modifier_id": modifierID,
+			"modify_time": time2.Now(),
+		}).
+		Error
+	return err
+}
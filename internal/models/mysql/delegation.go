@@ -0,0 +1,97 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mysql
+
+import (
+	"github.com/quanxiang-cloud/flow/internal/models"
+	"github.com/quanxiang-cloud/flow/pkg/misc/id2"
+	"github.com/quanxiang-cloud/flow/pkg/misc/time2"
+	"gorm.io/gorm"
+)
+
+type delegationRepo struct{}
+
+// NewDelegationRepo new repo
+func NewDelegationRepo() models.DelegationRepo {
+	return &delegationRepo{}
+}
+
+// TableName db table name
+func (r *delegationRepo) TableName() string {
+	return "flow_delegation"
+}
+
+// Create create model
+func (r *delegationRepo) Create(db *gorm.DB, entity *models.Delegation) error {
+	entity.ID = id2.GenID()
+	entity.CreateTime = time2.Now()
+	entity.ModifyTime = entity.CreateTime
+	err := db.Table(r.TableName()).
+		Create(entity).
+		Error
+	return err
+}
+
+// Update update model
+func (r *delegationRepo) Update(db *gorm.DB, ID string, updateMap map[string]interface{}) error {
+	updateMap["modify_time"] = time2.Now()
+	err := db.Table(r.TableName()).
+		Where("id = ?", ID).
+		Updates(updateMap).
+		Error
+	return err
+}
+
+// FindByID find by id
+func (r *delegationRepo) FindByID(db *gorm.DB, ID string) (*models.Delegation, error) {
+	entity := new(models.Delegation)
+	err := db.Table(r.TableName()).
+		Where("id = ?", ID).
+		Find(entity).
+		Error
+	if err != nil {
+		return nil, err
+	}
+	if entity.ID == "" {
+		return nil, nil
+	}
+	return entity, nil
+}
+
+// FindByUserID delegations of the delegator
+func (r *delegationRepo) FindByUserID(db *gorm.DB, userID string) ([]*models.Delegation, error) {
+	entities := make([]*models.Delegation, 0)
+	err := db.Table(r.TableName()).
+		Where("user_id = ?", userID).
+		Order("create_time desc").
+		Find(&entities).
+		Error
+	if err != nil {
+		return nil, err
+	}
+	return entities, nil
+}
+
+// FindActive active delegations overlapping the period
+func (r *delegationRepo) FindActive(db *gorm.DB, startTime string, endTime string) ([]*models.Delegation, error) {
+	entities := make([]*models.Delegation, 0)
+	err := db.Table(r.TableName()).
+		Where("status = ? and start_time <= ? and end_time >= ?", models.DelegationActive, endTime, startTime).
+		Find(&entities).
+		Error
+	if err != nil {
+		return nil, err
+	}
+	return entities, nil
+}
@@ -72,3 +72,16 @@ func (r *dispatcherCallbackRepo) FindByID(db *gorm.DB, ID string) (*models.Dispa
 	}
 	return entity, nil
 }
+
+// FindByProcessInstanceID callbacks of the process instance
+func (r *dispatcherCallbackRepo) FindByProcessInstanceID(db *gorm.DB, processInstanceID string) ([]*models.DispatcherCallback, error) {
+	entities := make([]*models.DispatcherCallback, 0)
+	err := db.Table(r.TableName()).
+		Where("process_instance_id = ?", processInstanceID).
+		Find(&entities).
+		Error
+	if err != nil {
+		return nil, err
+	}
+	return entities, nil
+}
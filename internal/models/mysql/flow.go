@@ -94,6 +94,8 @@ func (r *flowRepo) UpdateFlow(db *gorm.DB, model *models.Flow) error {
 	m["status"] = model.Status
 	m["can_cancel"] = model.CanCancel
 	m["can_urge"] = model.CanUrge
+	m["urge_policy"] = model.UrgePolicy
+	m["notify_channels"] = model.NotifyChannels
 	m["can_view_status_msg"] = model.CanViewStatusMsg
 	m["can_msg"] = model.CanMsg
 	m["can_cancel_type"] = model.CanCancelType
@@ -0,0 +1,121 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mysql
+
+import (
+	"github.com/quanxiang-cloud/flow/internal/models"
+	"github.com/quanxiang-cloud/flow/pkg/misc/id2"
+	"github.com/quanxiang-cloud/flow/pkg/misc/time2"
+	"gorm.io/gorm"
+)
+
+type flowVersionRepo struct{}
+
+// NewFlowVersionRepo new repo
+func NewFlowVersionRepo() models.FlowVersionRepo {
+	return &flowVersionRepo{}
+}
+
+// TableName db table name
+func (r *flowVersionRepo) TableName() string {
+	return "flow_version"
+}
+
+// Create create model
+func (r *flowVersionRepo) Create(db *gorm.DB, entity *models.FlowVersion) error {
+	entity.ID = id2.GenID()
+	entity.CreateTime = time2.Now()
+	err := db.Table(r.TableName()).
+		Create(entity).
+		Error
+	return err
+}
+
+// FindByID find model by ID
+func (r *flowVersionRepo) FindByID(db *gorm.DB, ID string) (*models.FlowVersion, error) {
+	entity := new(models.FlowVersion)
+	err := db.Table(r.TableName()).
+		Where("id = ?", ID).
+		Find(entity).
+		Error
+	if err != nil {
+		return nil, err
+	}
+	if entity.ID == "" {
+		return nil, nil
+	}
+	return entity, nil
+}
+
+// FindByProcessID find model by processID
+func (r *flowVersionRepo) FindByProcessID(db *gorm.DB, processID string) (*models.FlowVersion, error) {
+	entity := new(models.FlowVersion)
+	err := db.Table(r.TableName()).
+		Where("process_id = ?", processID).
+		Find(entity).
+		Error
+	if err != nil {
+		return nil, err
+	}
+	if entity.ID == "" {
+		return nil, nil
+	}
+	return entity, nil
+}
+
+// FindLatest find the latest published version of flow
+func (r *flowVersionRepo) FindLatest(db *gorm.DB, flowID string) (*models.FlowVersion, error) {
+	entity := new(models.FlowVersion)
+	err := db.Table(r.TableName()).
+		Where("flow_id = ?", flowID).
+		Order("version desc").
+		Limit(1).
+		Find(entity).
+		Error
+	if err != nil {
+		return nil, err
+	}
+	if entity.ID == "" {
+		return nil, nil
+	}
+	return entity, nil
+}
+
+// FindPageByFlowID page versions of flow, newest first
+func (r *flowVersionRepo) FindPageByFlowID(db *gorm.DB, flowID string, page, size int) ([]*models.FlowVersion, int64, error) {
+	versions := make([]*models.FlowVersion, 0)
+	var num int64
+	db = db.Table(r.TableName()).Where("flow_id = ?", flowID)
+	err := db.Count(&num).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	if page > 0 && size > 0 {
+		db = db.Limit(size).Offset((page - 1) * size)
+	}
+	err = db.Select([]string{"id", "flow_id", "version", "name", "process_id", "form_id", "publisher_id", "publisher_name", "publish_time", "diff_summary", "remark", "create_time"}).
+		Order("version desc").
+		Find(&versions).
+		Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return versions, num, nil
+}
+
+// DeleteByFlowIDs delete versions by flow ids
+func (r *flowVersionRepo) DeleteByFlowIDs(db *gorm.DB, flowIDs []string) error {
+	err := db.Table(r.TableName()).Where("flow_id in (?)", flowIDs).Delete(&models.FlowVersion{}).Error
+	return err
+}
@@ -172,6 +172,9 @@ func (r *instanceRepo) PageInstances(db *gorm.DB, req *models.PageInstancesReq)
 	if len(req.Status) > 0 {
 		tx = tx.Where("status=?", req.Status)
 	}
+	if len(req.Statuses) > 0 {
+		tx = tx.Where("status in (?)", req.Statuses)
+	}
 	if len(req.CreateTimeBegin) > 0 {
 		tx = tx.Where("create_time >= ?", utils.ChangeBjTimeToISO8601(req.CreateTimeBegin+" 00:00:00"))
 	}
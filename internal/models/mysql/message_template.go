@@ -0,0 +1,111 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mysql
+
+import (
+	"github.com/quanxiang-cloud/flow/internal/models"
+	"github.com/quanxiang-cloud/flow/pkg/misc/id2"
+	"github.com/quanxiang-cloud/flow/pkg/misc/time2"
+	"gorm.io/gorm"
+)
+
+type messageTemplateRepo struct{}
+
+// NewMessageTemplateRepo new repo
+func NewMessageTemplateRepo() models.MessageTemplateRepo {
+	return &messageTemplateRepo{}
+}
+
+// TableName db table name
+func (r *messageTemplateRepo) TableName() string {
+	return "flow_message_template"
+}
+
+// Create create model
+func (r *messageTemplateRepo) Create(db *gorm.DB, entity *models.MessageTemplate) error {
+	entity.ID = id2.GenID()
+	entity.CreateTime = time2.Now()
+	entity.ModifyTime = entity.CreateTime
+	err := db.Table(r.TableName()).
+		Create(entity).
+		Error
+	return err
+}
+
+// Update update model
+func (r *messageTemplateRepo) Update(db *gorm.DB, ID string, updateMap map[string]interface{}) error {
+	updateMap["modify_time"] = time2.Now()
+	err := db.Table(r.TableName()).
+		Where("id = ?", ID).
+		Updates(updateMap).
+		Error
+	return err
+}
+
+// Delete delete model
+func (r *messageTemplateRepo) Delete(db *gorm.DB, ID string) error {
+	err := db.Table(r.TableName()).
+		Where("id = ?", ID).
+		Delete(&models.MessageTemplate{}).
+		Error
+	return err
+}
+
+// FindByID find model by ID
+func (r *messageTemplateRepo) FindByID(db *gorm.DB, ID string) (*models.MessageTemplate, error) {
+	entity := new(models.MessageTemplate)
+	err := db.Table(r.TableName()).
+		Where("id = ?", ID).
+		Find(entity).
+		Error
+	if err != nil {
+		return nil, err
+	}
+	if entity.ID == "" {
+		return nil, nil
+	}
+	return entity, nil
+}
+
+// FindByAppID find templates of the app, only the flow's if flow id is not empty
+func (r *messageTemplateRepo) FindByAppID(db *gorm.DB, appID string, flowID string) ([]*models.MessageTemplate, error) {
+	entities := make([]*models.MessageTemplate, 0)
+	tx := db.Table(r.TableName()).
+		Where("app_id = ?", appID)
+	if flowID != "" {
+		tx = tx.Where("flow_id = ?", flowID)
+	}
+	err := tx.Order("create_time desc").
+		Find(&entities).
+		Error
+	return entities, err
+}
+
+// FindByType find the template of the type, the flow's one is preferred
+func (r *messageTemplateRepo) FindByType(db *gorm.DB, appID string, flowID string, templateType string) (*models.MessageTemplate, error) {
+	entity := new(models.MessageTemplate)
+	err := db.Table(r.TableName()).
+		Where("app_id = ? and type = ? and flow_id in (?)", appID, templateType, []string{flowID, ""}).
+		Order("flow_id desc").
+		Limit(1).
+		Find(entity).
+		Error
+	if err != nil {
+		return nil, err
+	}
+	if entity.ID == "" {
+		return nil, nil
+	}
+	return entity, nil
+}
@@ -0,0 +1,81 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mysql
+
+import (
+	"github.com/quanxiang-cloud/flow/internal/models"
+	"github.com/quanxiang-cloud/flow/pkg/misc/id2"
+	"github.com/quanxiang-cloud/flow/pkg/misc/time2"
+	"gorm.io/gorm"
+)
+
+type subProcessRepo struct{}
+
+// NewSubProcessRepo new repo
+func NewSubProcessRepo() models.SubProcessRepo {
+	return &subProcessRepo{}
+}
+
+// TableName db table name
+func (r *subProcessRepo) TableName() string {
+	return "flow_sub_process"
+}
+
+// Create create model
+func (r *subProcessRepo) Create(db *gorm.DB, entity *models.SubProcess) error {
+	entity.ID = id2.GenID()
+	entity.CreateTime = time2.Now()
+	entity.ModifyTime = entity.CreateTime
+	err := db.Table(r.TableName()).
+		Create(entity).
+		Error
+	return err
+}
+
+// Update update model
+func (r *subProcessRepo) Update(db *gorm.DB, ID string, updateMap map[string]interface{}) error {
+	updateMap["modify_time"] = time2.Now()
+	err := db.Table(r.TableName()).
+		Where("id = ?", ID).
+		Updates(updateMap).
+		Error
+	return err
+}
+
+// FindBySubProcessInstanceID find relation by the child process instance id
+func (r *subProcessRepo) FindBySubProcessInstanceID(db *gorm.DB, subProcessInstanceID string) (*models.SubProcess, error) {
+	entity := new(models.SubProcess)
+	err := db.Table(r.TableName()).
+		Where("sub_process_instance_id = ?", subProcessInstanceID).
+		Find(entity).
+		Error
+	if err != nil {
+		return nil, err
+	}
+	if entity.ID == "" {
+		return nil, nil
+	}
+	return entity, nil
+}
+
+// FindByProcessInstanceID find relations started by the parent process instance
+func (r *subProcessRepo) FindByProcessInstanceID(db *gorm.DB, processInstanceID string) ([]*models.SubProcess, error) {
+	entities := make([]*models.SubProcess, 0)
+	err := db.Table(r.TableName()).
+		Where("process_instance_id = ?", processInstanceID).
+		Order("create_time").
+		Find(&entities).
+		Error
+	return entities, err
+}
@@ -0,0 +1,55 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mysql
+
+import (
+	"github.com/quanxiang-cloud/flow/internal/models"
+	"github.com/quanxiang-cloud/flow/pkg/misc/id2"
+	"github.com/quanxiang-cloud/flow/pkg/misc/time2"
+	"gorm.io/gorm"
+)
+
+type webhookAttemptRepo struct{}
+
+// NewWebhookAttemptRepo new repo
+func NewWebhookAttemptRepo() models.WebhookAttemptRepo {
+	return &webhookAttemptRepo{}
+}
+
+// TableName db table name
+func (r *webhookAttemptRepo) TableName() string {
+	return "flow_webhook_attempt"
+}
+
+// Create create model
+func (r *webhookAttemptRepo) Create(db *gorm.DB, entity *models.WebhookAttempt) error {
+	entity.ID = id2.GenID()
+	entity.CreateTime = time2.Now()
+	entity.ModifyTime = entity.CreateTime
+	err := db.Table(r.TableName()).
+		Create(entity).
+		Error
+	return err
+}
+
+// FindByProcessInstanceID find attempts of the process instance
+func (r *webhookAttemptRepo) FindByProcessInstanceID(db *gorm.DB, processInstanceID string) ([]*models.WebhookAttempt, error) {
+	entities := make([]*models.WebhookAttempt, 0)
+	err := db.Table(r.TableName()).
+		Where("process_instance_id = ?", processInstanceID).
+		Order("create_time, attempt").
+		Find(&entities).
+		Error
+	return entities, err
+}
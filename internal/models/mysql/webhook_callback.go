@@ -0,0 +1,90 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mysql
+
+import (
+	"github.com/quanxiang-cloud/flow/internal/models"
+	"github.com/quanxiang-cloud/flow/pkg/misc/id2"
+	"github.com/quanxiang-cloud/flow/pkg/misc/time2"
+	"gorm.io/gorm"
+)
+
+type webhookCallbackRepo struct{}
+
+// NewWebhookCallbackRepo new repo
+func NewWebhookCallbackRepo() models.WebhookCallbackRepo {
+	return &webhookCallbackRepo{}
+}
+
+// TableName db table name
+func (r *webhookCallbackRepo) TableName() string {
+	return "flow_webhook_callback"
+}
+
+// Create create model
+func (r *webhookCallbackRepo) Create(db *gorm.DB, entity *models.WebhookCallback) error {
+	entity.ID = id2.GenID()
+	entity.CreateTime = time2.Now()
+	entity.ModifyTime = entity.CreateTime
+	err := db.Table(r.TableName()).
+		Create(entity).
+		Error
+	return err
+}
+
+// FindByID find by id
+func (r *webhookCallbackRepo) FindByID(db *gorm.DB, ID string) (*models.WebhookCallback, error) {
+	entity := new(models.WebhookCallback)
+	err := db.Table(r.TableName()).
+		Where("id = ?", ID).
+		Find(entity).
+		Error
+	if err != nil {
+		return nil, err
+	}
+	if entity.ID == "" {
+		return nil, nil
+	}
+	return entity, nil
+}
+
+// FindByToken find by callback token
+func (r *webhookCallbackRepo) FindByToken(db *gorm.DB, token string) (*models.WebhookCallback, error) {
+	entity := new(models.WebhookCallback)
+	err := db.Table(r.TableName()).
+		Where("token = ?", token).
+		Find(entity).
+		Error
+	if err != nil {
+		return nil, err
+	}
+	if entity.ID == "" {
+		return nil, nil
+	}
+	return entity, nil
+}
+
+// UpdateStatus change status only if it is still from
+func (r *webhookCallbackRepo) UpdateStatus(db *gorm.DB, ID string, from string, to string) (bool, error) {
+	result := db.Table(r.TableName()).
+		Where("id = ? and status = ?", ID, from).
+		Updates(map[string]interface{}{
+			"status":      to,
+			"modify_time": time2.Now(),
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
@@ -0,0 +1,79 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mysql
+
+import (
+	"github.com/quanxiang-cloud/flow/internal/models"
+	"github.com/quanxiang-cloud/flow/pkg/misc/id2"
+	"github.com/quanxiang-cloud/flow/pkg/misc/time2"
+	"gorm.io/gorm"
+)
+
+type workCalendarRepo struct{}
+
+// NewWorkCalendarRepo new repo
+func NewWorkCalendarRepo() models.WorkCalendarRepo {
+	return &workCalendarRepo{}
+}
+
+// TableName db table name
+func (r *workCalendarRepo) TableName() string {
+	return "flow_work_calendar"
+}
+
+// Create create model
+func (r *workCalendarRepo) Create(db *gorm.DB, entity *models.WorkCalendar) error {
+	entity.ID = id2.GenID()
+	entity.CreateTime = time2.Now()
+	entity.ModifyTime = entity.CreateTime
+	err := db.Table(r.TableName()).
+		Create(entity).
+		Error
+	return err
+}
+
+// Update update model
+func (r *workCalendarRepo) Update(db *gorm.DB, ID string, updateMap map[string]interface{}) error {
+	updateMap["modify_time"] = time2.Now()
+	err := db.Table(r.TableName()).
+		Where("id = ?", ID).
+		Updates(updateMap).
+		Error
+	return err
+}
+
+// Delete delete model
+func (r *workCalendarRepo) Delete(db *gorm.DB, ID string) error {
+	err := db.Table(r.TableName()).
+		Where("id = ?", ID).
+		Delete(&models.WorkCalendar{}).
+		Error
+	return err
+}
+
+// FindByAppID find the calendar of the app
+func (r *workCalendarRepo) FindByAppID(db *gorm.DB, appID string) (*models.WorkCalendar, error) {
+	entity := new(models.WorkCalendar)
+	err := db.Table(r.TableName()).
+		Where("app_id = ?", appID).
+		Find(entity).
+		Error
+	if err != nil {
+		return nil, err
+	}
+	if entity.ID == "" {
+		return nil, nil
+	}
+	return entity, nil
+}
@@ -0,0 +1,45 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package models
+
+import "gorm.io/gorm"
+
+const (
+	// NotifySuccess delivered
+	NotifySuccess = "SUCCESS"
+	// NotifyFailed delivery failed
+	NotifyFailed = "FAILED"
+	// NotifySkipped no receiver address for the channel
+	NotifySkipped = "SKIPPED"
+)
+
+// NotifyRecord delivery result of a notification on one channel
+type NotifyRecord struct {
+	BaseModel
+
+	ProcessInstanceID string `json:"processInstanceID"`
+	TaskID            string `json:"taskID"`
+	Event             string `json:"event"`     // TASK_ASSIGNED、URGE、TIMEOUT、FINISHED
+	Channel           string `json:"channel"`   // letter、email、chat、sms
+	Receivers         string `json:"receivers"` // 接收人id，逗号分隔
+	Title             string `json:"title"`
+	Status            string `json:"status"`
+	Error             string `json:"error"`
+}
+
+// NotifyRecordRepo interface
+type NotifyRecordRepo interface {
+	Create(db *gorm.DB, model *NotifyRecord) error
+	FindByProcessInstanceID(db *gorm.DB, processInstanceID string) ([]*NotifyRecord, error)
+}
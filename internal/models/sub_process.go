@@ -0,0 +1,38 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package models
+
+import "gorm.io/gorm"
+
+// SubProcess relation between a sub process node and the child instance it started
+type SubProcess struct {
+	BaseModel
+
+	ProcessID            string `json:"processId"`         // parent process id
+	ProcessInstanceID    string `json:"processInstanceId"` // parent process instance id
+	NodeDefKey           string `json:"nodeDefKey"`
+	ExecutionID          string `json:"executionId"`
+	SubFlowID            string `json:"subFlowId"`
+	SubInstanceID        string `json:"subInstanceId"`
+	SubProcessInstanceID string `json:"subProcessInstanceId"`
+	Status               string `json:"status"` // child instance status when it finished, empty while running
+}
+
+// SubProcessRepo interface
+type SubProcessRepo interface {
+	Create(db *gorm.DB, model *SubProcess) error
+	Update(db *gorm.DB, ID string, updateMap map[string]interface{}) error
+	FindBySubProcessInstanceID(db *gorm.DB, subProcessInstanceID string) (*SubProcess, error)
+	FindByProcessInstanceID(db *gorm.DB, processInstanceID string) ([]*SubProcess, error)
+}
@@ -30,6 +30,8 @@ type ActTaskEntity struct {
 	FlowInstanceEntity interface{} `json:"flowInstanceEntity"` // Instance
 	UrgeNum            int64       `json:"urgeNum"`
 	Handled            string      `json:"handled"`
+	DelegatorID        string      `json:"delegatorId"` // 委托人，任务经委托分配时
+	DelegatorName      string      `json:"delegatorName"`
 }
 
 // NodeModel event model
@@ -0,0 +1,39 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package models
+
+import "gorm.io/gorm"
+
+// WebhookAttempt one outbound call of a webhook node
+type WebhookAttempt struct {
+	BaseModel
+
+	ProcessInstanceID string `json:"processInstanceId"`
+	NodeDefKey        string `json:"nodeDefKey"`
+	NodeName          string `json:"nodeName"`
+	ExecutionID       string `json:"executionId"`
+	Attempt           int    `json:"attempt"` // 第几次调用，从1开始
+	URL               string `json:"url"`
+	Method            string `json:"method"`
+	StatusCode        int    `json:"statusCode"` // 0 请求未得到响应
+	Latency           int64  `json:"latency"`    // 耗时，毫秒
+	Error             string `json:"error"`
+	ResponseBody      string `json:"responseBody"` // 截断后的响应内容
+}
+
+// WebhookAttemptRepo interface
+type WebhookAttemptRepo interface {
+	Create(db *gorm.DB, model *WebhookAttempt) error
+	FindByProcessInstanceID(db *gorm.DB, processInstanceID string) ([]*WebhookAttempt, error)
+}
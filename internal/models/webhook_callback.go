@@ -0,0 +1,52 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package models
+
+import "gorm.io/gorm"
+
+const (
+	// WebhookCallbackPending the request is being sent, the node is not paused yet
+	WebhookCallbackPending = "PENDING"
+	// WebhookCallbackWaiting waiting for the callback
+	WebhookCallbackWaiting = "WAITING"
+	// WebhookCallbackDone the callback is received
+	WebhookCallbackDone = "DONE"
+	// WebhookCallbackTimeout handled when timeout
+	WebhookCallbackTimeout = "TIMEOUT"
+	// WebhookCallbackFailed the request is not sent
+	WebhookCallbackFailed = "FAILED"
+)
+
+// WebhookCallback webhook node parked until an external system posts the result
+type WebhookCallback struct {
+	BaseModel
+
+	Token             string `json:"-"` // 一次性回调token
+	ProcessID         string `json:"processId"`
+	ProcessInstanceID string `json:"processInstanceId"`
+	NodeDefKey        string `json:"nodeDefKey"`
+	ExecutionID       string `json:"executionId"`
+	Outputs           string `json:"outputs"`     // 回调内容映射，json
+	WhenTimeout       string `json:"whenTimeout"` // 超时处理，json
+	Status            string `json:"status"`
+}
+
+// WebhookCallbackRepo interface
+type WebhookCallbackRepo interface {
+	Create(db *gorm.DB, model *WebhookCallback) error
+	FindByID(db *gorm.DB, ID string) (*WebhookCallback, error)
+	FindByToken(db *gorm.DB, token string) (*WebhookCallback, error)
+	// UpdateStatus change status only if it is still from, returns false if someone else changed it
+	UpdateStatus(db *gorm.DB, ID string, from string, to string) (bool, error)
+}
@@ -0,0 +1,37 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package models
+
+import "gorm.io/gorm"
+
+// WorkCalendar working time of an app, the one with empty app id is the default of the tenant
+type WorkCalendar struct {
+	BaseModel
+
+	AppID         string `json:"appId"`
+	WorkDays      string `json:"workDays"`  // 工作日，逗号分隔，0为周日
+	WorkStart     string `json:"workStart"` // 上班时间，HH:mm
+	WorkEnd       string `json:"workEnd"`   // 下班时间，HH:mm
+	TimeZone      string `json:"timeZone"`
+	Holidays      string `json:"holidays"`      // 节假日，逗号分隔，yyyy-MM-dd
+	ExtraWorkDays string `json:"extraWorkDays"` // 调休上班日，逗号分隔，yyyy-MM-dd
+}
+
+// WorkCalendarRepo interface
+type WorkCalendarRepo interface {
+	Create(db *gorm.DB, model *WorkCalendar) error
+	Update(db *gorm.DB, ID string, updateMap map[string]interface{}) error
+	Delete(db *gorm.DB, ID string) error
+	FindByAppID(db *gorm.DB, appID string) (*WorkCalendar, error)
+}
@@ -36,6 +36,7 @@ type Form interface {
 	DeleteData(c context.Context, appID string, tableID string, dataIDs []string) error
 	FindOneData(c context.Context, appID string, tableID string, dataID string, ref interface{}) (interface{}, error)
 	SearchData(c context.Context, appID string, tableID string, req SearchReq) ([]map[string]interface{}, error)
+	Search(c context.Context, appID string, tableID string, req SearchReq) (*SearchResp, error)
 	GetIDs(c context.Context, appID string, tableID string, query map[string]interface{}) ([]string, error)
 
 	BatchGetFormSchema(c context.Context, req []FormSchemaConditionModel) (map[string]interface{}, error)
@@ -145,6 +146,15 @@ func (f *form) FindOneData(c context.Context, appID string, tableID string, data
 }
 
 func (f *form) SearchData(c context.Context, appID string, tableID string, req SearchReq) ([]map[string]interface{}, error) {
+	resp, err := f.Search(c, appID, tableID, req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Entities, nil
+}
+
+// Search search data, the resp contains the total of matched data
+func (f *form) Search(c context.Context, appID string, tableID string, req SearchReq) (*SearchResp, error) {
 	var resp SearchResp
 	url := fmt.Sprintf("%s%s%s%s%s%s", f.conf.APIHost.FormHost, "api/v1/form/", appID, "/internal/form/", tableID, "/search")
 	err := POST(c, &f.client, url, req, &resp)
@@ -152,7 +162,7 @@ func (f *form) SearchData(c context.Context, appID string, tableID string, req S
 		logger.Logger.Error("Failed to get form data ", err)
 		return nil, err
 	}
-	return resp.Entities, nil
+	return &resp, nil
 }
 
 func (f *form) GetIDs(c context.Context, appID string, tableID string, query map[string]interface{}) ([]string, error) {
@@ -325,6 +335,7 @@ type SearchReq struct {
 	Page  int         `json:"page"`
 	Size  int         `json:"size"`
 	Query interface{} `json:"query"`
+	Sort  []string    `json:"sort,omitempty"` // fieldName asc, -fieldName desc
 }
 
 // SearchResp search resp
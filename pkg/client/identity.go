@@ -299,6 +299,7 @@ type UserInfoResp struct {
 	UserName  string            `json:"name"`
 	Avatar    string            `json:"avatar"`
 	Email     string            `json:"email"`
+	Phone     string            `json:"phone"`
 	UseStatus int8              `json:"useStatus"` // 1:正常，-2禁用,-1真删除
 	Deps      [][]*Dep          `json:"deps"`
 	Leaders   [][]*UserInfoResp `json:"leaders"`
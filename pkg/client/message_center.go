@@ -71,6 +71,8 @@ type SendMsgModel struct {
 // Email 邮件节点
 type Email struct {
 	To          []string                 `json:"to"`
+	Cc          []string                 `json:"cc"`
+	Bcc         []string                 `json:"bcc"`
 	Contents    Contents                 `json:"contents"`
 	Title       string                   `json:"title"`
 	ContentType string                   `json:"content_type"`
@@ -24,6 +24,8 @@ import (
 type PolyAPI interface {
 	InnerRequest(c context.Context, apiPath string, req map[string]interface{}, header map[string]string, method string) (map[string]interface{}, error)
 	SendRequest(c context.Context, url string, req map[string]interface{}, header map[string]string, method string) (map[string]interface{}, error)
+	InnerRequestRaw(c context.Context, apiPath string, req map[string]interface{}, header map[string]string, method string) (*RawResponse, error)
+	SendRequestRaw(c context.Context, url string, req map[string]interface{}, header map[string]string, method string) (*RawResponse, error)
 }
 
 type polyAPI struct {
@@ -51,3 +53,12 @@ func (p *polyAPI) SendRequest(c context.Context, url string, req map[string]inte
 	err := Request(c, &p.client, url, req, resp, header, method)
 	return resp, err
 }
+
+func (p *polyAPI) InnerRequestRaw(c context.Context, apiPath string, req map[string]interface{}, header map[string]string, method string) (*RawResponse, error) {
+	url := fmt.Sprintf("%s%s%s", p.conf.APIHost.PolyAPIHost, "api/v1/polyapi/inner/request", apiPath)
+	return RequestRaw(c, &p.client, url, req, header, method)
+}
+
+func (p *polyAPI) SendRequestRaw(c context.Context, url string, req map[string]interface{}, header map[string]string, method string) (*RawResponse, error) {
+	return RequestRaw(c, &p.client, url, req, header, method)
+}
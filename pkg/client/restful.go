@@ -24,6 +24,7 @@ import (
 	"math/rand"
 	"net"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/quanxiang-cloud/flow/pkg/misc/logger"
@@ -178,6 +179,48 @@ func Request(ctx context.Context, client *http.Client, uri string, params interf
 	return nil
 }
 
+// RawResponse status code and body of a http response
+type RawResponse struct {
+	StatusCode int
+	Body       []byte
+}
+
+// RequestRaw http request, the response is returned whatever the status code is
+func RequestRaw(ctx context.Context, client *http.Client, uri string, params interface{}, headers map[string]string, method string) (*RawResponse, error) {
+	paramByte, err := json.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Logger.Info("Request-Id:" + pkg.STDRequestID(ctx).String + " http request uri:" + uri + "      http request params:" + string(paramByte))
+	req, err := http.NewRequestWithContext(ctx, method, uri, bytes.NewReader(paramByte))
+	if err != nil {
+		logger.Logger.Errorw(err.Error(), pkg.STDRequestID(ctx))
+		return nil, err
+	}
+	for k, v := range headers {
+		req.Header.Add(k, v)
+	}
+
+	response, err := client.Do(req)
+	if err != nil {
+		logger.Logger.Errorw(err.Error(), pkg.STDRequestID(ctx))
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		logger.Logger.Errorw(err.Error(), pkg.STDRequestID(ctx))
+		return nil, err
+	}
+	logger.Logger.Info("Request-Id:" + pkg.STDRequestID(ctx).String + " status:" + strconv.Itoa(response.StatusCode) + " resp:" + string(body))
+	return &RawResponse{
+		StatusCode: response.StatusCode,
+		Body:       body,
+	}, nil
+}
+
 func HttpRetry(ctx context.Context, client *http.Client, uri string, params interface{}, entity interface{}, headers map[string]string, C *HttpRetryConf) error {
 	err := C.Func(ctx, client, uri, params, entity, headers)
 	if err != nil {
@@ -53,6 +53,12 @@ const (
 
 	// CannotRepeatUrge can not repeat urge
 	CannotRepeatUrge = 70030020001
+	// UrgeTooFrequent urge again within the min interval
+	UrgeTooFrequent = 70030020002
+	// UrgeExceedMaxTimes urge times of the task reach the limit
+	UrgeExceedMaxTimes = 70030020003
+	// UrgeInQuietHours urge in the quiet hours
+	UrgeInQuietHours = 70030020004
 
 	// Task handle error begin------------------
 	TaskCannotFind = 70040020001
@@ -71,6 +77,9 @@ var CodeTable = map[int64]string{
 	CannotInviteReadToSelf: "不能邀请自己阅示",
 	CannotRepeatInviteRead: "不能重复邀请",
 	CannotRepeatUrge:       "已经催办过了",
+	UrgeTooFrequent:        "催办过于频繁，请稍后再试",
+	UrgeExceedMaxTimes:     "催办次数已达上限",
+	UrgeInQuietHours:       "当前为免打扰时段，不能催办",
 
 	TaskCannotFind: "任务已被处理或者不存在，请刷新重试",
 }
@@ -38,6 +38,7 @@ type Configs struct {
 	Redis       redis2.Config `yaml:"redis"`
 	Kafka       kafka.Config  `yaml:"kafka"`
 	APIHost     APIHost       `yaml:"api"`
+	Notifier    Notifier      `yaml:"notifier"`
 
 	Name     string `yaml:"Name"`
 	ListenOn string `yaml:"ListenOn"`
@@ -64,6 +65,24 @@ type APIHost struct {
 	HomeHost          string `yaml:"homeHost" validate:"required"`
 }
 
+// Notifier 消息中心之外的通知渠道，未配置的渠道不启用
+type Notifier struct {
+	ChatWebhook ChatWebhook `yaml:"chatWebhook"`
+	SMS         SMS         `yaml:"sms"`
+}
+
+// ChatWebhook 群机器人
+type ChatWebhook struct {
+	Type string `yaml:"type"` // slack、dingtalk、feishu
+	URL  string `yaml:"url"`
+}
+
+// SMS 短信网关
+type SMS struct {
+	Host string `yaml:"host"`
+	Sign string `yaml:"sign"`
+}
+
 // Init 初始化
 func Init(configPath string) error {
 	if configPath == "" {
@@ -77,11 +77,20 @@ func RecursionGetValue(data interface{}, fields []string) interface{} {
 			}
 
 			cvs := reflect.ValueOf(data)
+			if cvs.Kind() != reflect.Slice && cvs.Kind() != reflect.Array {
+				return nil
+			}
+			if arrIndex < 0 || arrIndex >= cvs.Len() {
+				return nil
+			}
 			v := cvs.Index(arrIndex).Interface()
 			return RecursionGetValue(v, fields[index+1:])
 		} else if strings.HasPrefix(field, "[") && strings.HasSuffix(field, "]") && len(field) == 2 {
 			cValues := make([]interface{}, 0)
 			cvs := reflect.ValueOf(data)
+			if cvs.Kind() != reflect.Slice && cvs.Kind() != reflect.Array {
+				return nil
+			}
 			for i := 0; i < cvs.Len(); i++ {
 				v := cvs.Index(i).Interface()
 				cValues = append(cValues, RecursionGetValue(v, fields[index+1:]))
@@ -98,3 +107,27 @@ func RecursionGetValue(data interface{}, fields []string) interface{} {
 
 	return nil
 }
+
+// SelectValue get value by a JSONPath-like selector
+// $.data.list[0].id, data.list.[0].id, data.list[*].id
+func SelectValue(data interface{}, selector string) interface{} {
+	selector = strings.TrimSpace(selector)
+	selector = strings.TrimPrefix(selector, "$")
+	selector = strings.TrimPrefix(selector, ".")
+	if selector == "" {
+		return data
+	}
+
+	selector = strings.ReplaceAll(selector, "[*]", "[]")
+	selector = strings.ReplaceAll(selector, ".[", "[")
+	selector = strings.ReplaceAll(selector, "[", ".[")
+	selector = strings.TrimPrefix(selector, ".")
+
+	fields := strings.Split(selector, ".")
+	for _, field := range fields {
+		if field == "" {
+			return nil
+		}
+	}
+	return RecursionGetValue(data, fields)
+}
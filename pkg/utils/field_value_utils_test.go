@@ -15,6 +15,7 @@ package utils
 
 import (
 	"fmt"
+	"reflect"
 	"testing"
 )
 
@@ -44,3 +45,34 @@ func Test_GetFieldValue(t *testing.T) {
 	fmt.Println(result)
 
 }
+
+func Test_SelectValue(t *testing.T) {
+	data := map[string]interface{}{
+		"code": float64(0),
+		"data": map[string]interface{}{
+			"list": []interface{}{
+				map[string]interface{}{"id": "a"},
+				map[string]interface{}{"id": "b"},
+			},
+		},
+	}
+
+	tests := []struct {
+		selector string
+		want     interface{}
+	}{
+		{"$.code", float64(0)},
+		{"code", float64(0)},
+		{"$.data.list[1].id", "b"},
+		{"data.list.[0].id", "a"},
+		{"$.data.list[*].id", []interface{}{"a", "b"}},
+		{"$.data.list[5].id", nil},
+		{"$.code[0]", nil},
+		{"$.missing.id", nil},
+	}
+	for _, tt := range tests {
+		if got := SelectValue(data, tt.selector); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("SelectValue(%q) = %v, want %v", tt.selector, got, tt.want)
+		}
+	}
+}
@@ -35,12 +35,12 @@ func FormatValue(value string, valueType string) interface{} {
 			t, _ := time.Parse("2006-01-02", value)
 			return t
 		}
-	case "boolean":
+	case "boolean", "bool":
 		{
 			t, _ := strconv.ParseBool(value)
 			return t
 		}
-	case "number":
+	case "number", "float64", "float32", "int", "int8", "int16", "int32", "int64", "uint", "uint8", "uint16", "uint32", "uint64": // number and types of StrvalAndType
 		{
 			t, _ := strconv.ParseFloat(value, 64)
 			return t
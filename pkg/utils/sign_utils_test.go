@@ -0,0 +1,35 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import "testing"
+
+func Test_HMACSign(t *testing.T) {
+	body := []byte(`{"a":1}`)
+	// echo -n '1650000000.{"a":1}' | openssl dgst -sha256 -hmac secret
+	want := "sha256=8d48f705a5b1a6589308026536e4594ba66a37f6550cac938b3b54700ff95ca2"
+	got := HMACSign("secret", 1650000000, body)
+	if got != want {
+		t.Errorf("HMACSign() = %s, want %s", got, want)
+	}
+	if !HMACVerify("secret", 1650000000, body, got) {
+		t.Error("HMACVerify() = false, want true")
+	}
+	if HMACVerify("secret", 1650000001, body, got) {
+		t.Error("HMACVerify() with another timestamp = true, want false")
+	}
+	if HMACVerify("other", 1650000000, body, got) {
+		t.Error("HMACVerify() with another secret = true, want false")
+	}
+}
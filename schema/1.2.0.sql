@@ -0,0 +1,219 @@
+CREATE TABLE `flow_version`
+(
+    `id`             varchar(40) NOT NULL DEFAULT '' COMMENT 'id',
+    `flow_id`        varchar(40) NOT NULL DEFAULT '' COMMENT 'flowID',
+    `version`        int(11)     NOT NULL DEFAULT 0 COMMENT '版本号',
+    `name`           varchar(80) NOT NULL DEFAULT '' COMMENT '流程名称',
+    `bpmn_text`      longtext    NOT NULL COMMENT '流程json文件内容',
+    `process_id`     varchar(40) NOT NULL DEFAULT '' COMMENT 'process中流程的id',
+    `form_id`        varchar(40) NOT NULL DEFAULT '' COMMENT '表单id',
+    `publisher_id`   varchar(40) NOT NULL DEFAULT '' COMMENT '发布人',
+    `publisher_name` varchar(64) NOT NULL DEFAULT '' COMMENT '发布人名称',
+    `publish_time`   varchar(40)          DEFAULT NULL COMMENT '发布时间',
+    `diff_summary`   text COMMENT '与上一版本的差异',
+    `remark`         varchar(255) NOT NULL DEFAULT '' COMMENT '发布备注',
+    `creator_id`     varchar(40) NOT NULL DEFAULT '' COMMENT '创建人',
+    `create_time`    varchar(40)          DEFAULT NULL COMMENT '创建时间',
+    `modifier_id`    varchar(40) NOT NULL DEFAULT '' COMMENT '更新人',
+    `modify_time`    varchar(40)          DEFAULT NULL COMMENT '更新时间',
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `uk_flow_version` (`flow_id`, `version`),
+    KEY `idx_process_id` (`process_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COMMENT='流程版本表';
+
+ALTER TABLE flow_instance  ADD  flow_version_id varchar(40) NOT NULL DEFAULT '' COMMENT '流程版本id' after flow_id;
+ALTER TABLE flow_instance  ADD  flow_version int(11) NOT NULL DEFAULT 0 COMMENT '流程版本号' after flow_version_id;
+
+CREATE TABLE `flow_sub_process`
+(
+    `id`                      varchar(40) NOT NULL DEFAULT '' COMMENT 'id',
+    `process_id`              varchar(40) NOT NULL DEFAULT '' COMMENT '父流程process id',
+    `process_instance_id`     varchar(40) NOT NULL DEFAULT '' COMMENT '父流程实例id',
+    `node_def_key`            varchar(40) NOT NULL DEFAULT '' COMMENT '子流程节点key',
+    `execution_id`            varchar(40) NOT NULL DEFAULT '' COMMENT '子流程节点执行id',
+    `sub_flow_id`             varchar(40) NOT NULL DEFAULT '' COMMENT '子流程flowID',
+    `sub_instance_id`         varchar(40) NOT NULL DEFAULT '' COMMENT '子流程实例id',
+    `sub_process_instance_id` varchar(40) NOT NULL DEFAULT '' COMMENT '子流程process实例id',
+    `status`                  varchar(40) NOT NULL DEFAULT '' COMMENT '子流程结束时的状态',
+    `creator_id`              varchar(40) NOT NULL DEFAULT '' COMMENT '创建人',
+    `create_time`             varchar(40)          DEFAULT NULL COMMENT '创建时间',
+    `modifier_id`             varchar(40) NOT NULL DEFAULT '' COMMENT '更新人',
+    `modify_time`             varchar(40)          DEFAULT NULL COMMENT '更新时间',
+    PRIMARY KEY (`id`),
+    KEY `idx_process_instance_id` (`process_instance_id`),
+    KEY `idx_sub_process_instance_id` (`sub_process_instance_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COMMENT='子流程关系表';
+
+CREATE TABLE `flow_webhook_attempt`
+(
+    `id`                  varchar(40)   NOT NULL DEFAULT '' COMMENT 'id',
+    `process_instance_id` varchar(40)   NOT NULL DEFAULT '' COMMENT '流程实例id',
+    `node_def_key`        varchar(40)   NOT NULL DEFAULT '' COMMENT 'webhook节点key',
+    `node_name`           varchar(200)  NOT NULL DEFAULT '' COMMENT 'webhook节点名称',
+    `execution_id`        varchar(40)   NOT NULL DEFAULT '' COMMENT '节点执行id',
+    `attempt`             int(11)       NOT NULL DEFAULT 0 COMMENT '第几次调用',
+    `url`                 varchar(1000) NOT NULL DEFAULT '' COMMENT '请求地址',
+    `method`              varchar(20)   NOT NULL DEFAULT '' COMMENT '请求方法',
+    `status_code`         int(11)       NOT NULL DEFAULT 0 COMMENT '响应状态码',
+    `latency`             bigint(20)    NOT NULL DEFAULT 0 COMMENT '耗时（毫秒）',
+    `error`               varchar(1000) NOT NULL DEFAULT '' COMMENT '错误信息',
+    `response_body`       text COMMENT '响应内容（截断）',
+    `creator_id`          varchar(40)   NOT NULL DEFAULT '' COMMENT '创建人',
+    `create_time`         varchar(40)            DEFAULT NULL COMMENT '创建时间',
+    `modifier_id`         varchar(40)   NOT NULL DEFAULT '' COMMENT '更新人',
+    `modify_time`         varchar(40)            DEFAULT NULL COMMENT '更新时间',
+    PRIMARY KEY (`id`),
+    KEY `idx_process_instance_id` (`process_instance_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COMMENT='webhook调用记录表';
+
+CREATE TABLE `flow_app_secret`
+(
+    `id`          varchar(40)  NOT NULL DEFAULT '' COMMENT 'id',
+    `app_id`      varchar(40)  NOT NULL DEFAULT '' COMMENT '应用id',
+    `secret`      varchar(200) NOT NULL DEFAULT '' COMMENT 'webhook签名密钥，明文存储，签名时需要原文',
+    `status`      varchar(20)  NOT NULL DEFAULT '' COMMENT '状态：ACTIVE、REVOKED',
+    `creator_id`  varchar(40)  NOT NULL DEFAULT '' COMMENT '创建人',
+    `create_time` varchar(40)           DEFAULT NULL COMMENT '创建时间',
+    `modifier_id` varchar(40)  NOT NULL DEFAULT '' COMMENT '更新人',
+    `modify_time` varchar(40)           DEFAULT NULL COMMENT '更新时间',
+    PRIMARY KEY (`id`),
+    KEY `idx_app_id` (`app_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COMMENT='应用webhook签名密钥表';
+
+CREATE TABLE `flow_webhook_callback`
+(
+    `id`                  varchar(40)  NOT NULL DEFAULT '' COMMENT 'id',
+    `token`               varchar(80)  NOT NULL DEFAULT '' COMMENT '一次性回调token',
+    `process_id`          varchar(40)  NOT NULL DEFAULT '' COMMENT '流程process id',
+    `process_instance_id` varchar(40)  NOT NULL DEFAULT '' COMMENT '流程实例id',
+    `node_def_key`        varchar(40)  NOT NULL DEFAULT '' COMMENT 'webhook节点key',
+    `execution_id`        varchar(40)  NOT NULL DEFAULT '' COMMENT '节点执行id',
+    `outputs`             text COMMENT '回调内容映射',
+    `when_timeout`        varchar(500) NOT NULL DEFAULT '' COMMENT '超时处理',
+    `status`              varchar(20)  NOT NULL DEFAULT '' COMMENT '状态：PENDING、WAITING、DONE、TIMEOUT、FAILED',
+    `creator_id`          varchar(40)  NOT NULL DEFAULT '' COMMENT '创建人',
+    `create_time`         varchar(40)           DEFAULT NULL COMMENT '创建时间',
+    `modifier_id`         varchar(40)  NOT NULL DEFAULT '' COMMENT '更新人',
+    `modify_time`         varchar(40)           DEFAULT NULL COMMENT '更新时间',
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `uk_token` (`token`),
+    KEY `idx_process_instance_id` (`process_instance_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COMMENT='webhook回调等待表';
+
+CREATE TABLE `flow_approve_sequence`
+(
+    `id`                  varchar(40)   NOT NULL DEFAULT '' COMMENT 'id',
+    `process_instance_id` varchar(40)   NOT NULL DEFAULT '' COMMENT '流程实例id',
+    `node_def_key`        varchar(40)   NOT NULL DEFAULT '' COMMENT '依次审批节点key',
+    `assignees`           varchar(2000) NOT NULL DEFAULT '' COMMENT '按处理顺序的处理人，逗号分隔',
+    `current`             int(11)       NOT NULL DEFAULT 0 COMMENT '当前处理人下标',
+    `creator_id`          varchar(40)   NOT NULL DEFAULT '' COMMENT '创建人',
+    `create_time`         varchar(40)            DEFAULT NULL COMMENT '创建时间',
+    `modifier_id`         varchar(40)   NOT NULL DEFAULT '' COMMENT '更新人',
+    `modify_time`         varchar(40)            DEFAULT NULL COMMENT '更新时间',
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `uk_instance_node` (`process_instance_id`, `node_def_key`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COMMENT='依次审批处理顺序表';
+
+CREATE TABLE `flow_delegation`
+(
+    `id`          varchar(40)   NOT NULL DEFAULT '' COMMENT 'id',
+    `user_id`     varchar(40)   NOT NULL DEFAULT '' COMMENT '委托人',
+    `delegate_id` varchar(40)   NOT NULL DEFAULT '' COMMENT '受托人',
+    `app_ids`     varchar(2000) NOT NULL DEFAULT '' COMMENT '委托的应用，逗号分隔，为空时为所有应用',
+    `start_time`  varchar(40)   NOT NULL DEFAULT '' COMMENT '开始时间',
+    `end_time`    varchar(40)   NOT NULL DEFAULT '' COMMENT '结束时间',
+    `status`      varchar(20)   NOT NULL DEFAULT '' COMMENT '状态：ACTIVE、CANCELED',
+    `creator_id`  varchar(40)   NOT NULL DEFAULT '' COMMENT '创建人',
+    `create_time` varchar(40)            DEFAULT NULL COMMENT '创建时间',
+    `modifier_id` varchar(40)   NOT NULL DEFAULT '' COMMENT '更新人',
+    `modify_time` varchar(40)            DEFAULT NULL COMMENT '更新时间',
+    PRIMARY KEY (`id`),
+    KEY `idx_user_id` (`user_id`),
+    KEY `idx_status_time` (`status`, `start_time`, `end_time`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COMMENT='审批委托表';
+
+ALTER TABLE flow_abnormal_task  ADD  job_id varchar(40) NOT NULL DEFAULT '' COMMENT '批量处理任务id' after status;
+ALTER TABLE flow_abnormal_task  ADD  handle_result varchar(20) NOT NULL DEFAULT '' COMMENT '批量处理结果' after job_id;
+ALTER TABLE flow_abnormal_task  ADD  handle_message varchar(500) NOT NULL DEFAULT '' COMMENT '批量处理失败原因' after handle_result;
+
+CREATE TABLE `flow_abnormal_task_job`
+(
+    `id`            varchar(40)   NOT NULL DEFAULT '' COMMENT 'id',
+    `operation`     varchar(20)   NOT NULL DEFAULT '' COMMENT '操作：STEP_BACK、SEND_BACK、ABANDON、DELIVER',
+    `params`        text COMMENT '操作参数',
+    `status`        varchar(20)   NOT NULL DEFAULT '' COMMENT '状态：RUNNING、FINISHED',
+    `total`         int(11)       NOT NULL DEFAULT 0 COMMENT '异常任务数',
+    `processed`     int(11)       NOT NULL DEFAULT 0 COMMENT '已处理数',
+    `success_count` int(11)       NOT NULL DEFAULT 0 COMMENT '成功数',
+    `fail_count`    int(11)       NOT NULL DEFAULT 0 COMMENT '失败数',
+    `creator_id`    varchar(40)   NOT NULL DEFAULT '' COMMENT '创建人',
+    `create_time`   varchar(40)            DEFAULT NULL COMMENT '创建时间',
+    `modifier_id`   varchar(40)   NOT NULL DEFAULT '' COMMENT '更新人',
+    `modify_time`   varchar(40)            DEFAULT NULL COMMENT '更新时间',
+    PRIMARY KEY (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COMMENT='异常任务批量处理表';
+
+ALTER TABLE flow_instance  ADD  pre_suspend_status varchar(20) NOT NULL DEFAULT '' COMMENT '挂起前的状态' after status;
+ALTER TABLE flow_instance  ADD  suspend_time varchar(40) NOT NULL DEFAULT '' COMMENT '挂起时间' after pre_suspend_status;
+ALTER TABLE dispatcher_callback  ADD  exec_time varchar(40) NOT NULL DEFAULT '' COMMENT '回调执行时间';
+
+CREATE TABLE `flow_work_calendar`
+(
+    `id`              varchar(40)   NOT NULL DEFAULT '' COMMENT 'id',
+    `app_id`          varchar(40)   NOT NULL DEFAULT '' COMMENT '应用id，为空时为租户默认日历',
+    `work_days`       varchar(20)   NOT NULL DEFAULT '' COMMENT '工作日，逗号分隔，0为周日',
+    `work_start`      varchar(10)   NOT NULL DEFAULT '' COMMENT '上班时间',
+    `work_end`        varchar(10)   NOT NULL DEFAULT '' COMMENT '下班时间',
+    `time_zone`       varchar(64)   NOT NULL DEFAULT '' COMMENT '时区',
+    `holidays`        varchar(4000) NOT NULL DEFAULT '' COMMENT '节假日，逗号分隔',
+    `extra_work_days` varchar(2000) NOT NULL DEFAULT '' COMMENT '调休上班日，逗号分隔',
+    `creator_id`      varchar(40)   NOT NULL DEFAULT '' COMMENT '创建人',
+    `create_time`     varchar(40)            DEFAULT NULL COMMENT '创建时间',
+    `modifier_id`     varchar(40)   NOT NULL DEFAULT '' COMMENT '更新人',
+    `modify_time`     varchar(40)            DEFAULT NULL COMMENT '更新时间',
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `uk_app_id` (`app_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COMMENT='工作日历表';
+
+ALTER TABLE flow  ADD  urge_policy varchar(500) NOT NULL DEFAULT '' COMMENT '催办策略' after can_urge;
+
+CREATE TABLE `flow_message_template`
+(
+    `id`          varchar(40)   NOT NULL DEFAULT '' COMMENT 'id',
+    `app_id`      varchar(40)   NOT NULL DEFAULT '' COMMENT '应用id',
+    `flow_id`     varchar(40)   NOT NULL DEFAULT '' COMMENT '流程id，为空时为应用级模板',
+    `type`        varchar(20)   NOT NULL DEFAULT '' COMMENT '类型：CUSTOM、TASK_ASSIGNED、URGE、TIMEOUT、FINISHED',
+    `name`        varchar(100)  NOT NULL DEFAULT '' COMMENT '名称',
+    `title`       varchar(200)  NOT NULL DEFAULT '' COMMENT '标题',
+    `content`     text COMMENT '内容',
+    `creator_id`  varchar(40)   NOT NULL DEFAULT '' COMMENT '创建人',
+    `create_time` varchar(40)            DEFAULT NULL COMMENT '创建时间',
+    `modifier_id` varchar(40)   NOT NULL DEFAULT '' COMMENT '更新人',
+    `modify_time` varchar(40)            DEFAULT NULL COMMENT '更新时间',
+    PRIMARY KEY (`id`),
+    KEY `idx_app_type` (`app_id`, `type`, `flow_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COMMENT='消息模板表';
+
+ALTER TABLE flow  ADD  notify_channels varchar(500) NOT NULL DEFAULT '' COMMENT '各事件通知渠道' after urge_policy;
+
+CREATE TABLE `flow_notify_record`
+(
+    `id`                  varchar(40)   NOT NULL DEFAULT '' COMMENT 'id',
+    `process_instance_id` varchar(40)   NOT NULL DEFAULT '' COMMENT '流程实例id',
+    `task_id`             varchar(40)   NOT NULL DEFAULT '' COMMENT '任务id',
+    `event`               varchar(20)   NOT NULL DEFAULT '' COMMENT '事件：TASK_ASSIGNED、URGE、TIMEOUT、FINISHED',
+    `channel`             varchar(20)   NOT NULL DEFAULT '' COMMENT '渠道：letter、email、chat、sms',
+    `receivers`           varchar(2000) NOT NULL DEFAULT '' COMMENT '接收人id',
+    `title`               varchar(200)  NOT NULL DEFAULT '' COMMENT '标题',
+    `status`              varchar(20)   NOT NULL DEFAULT '' COMMENT '状态：SUCCESS、FAILED、SKIPPED',
+    `error`               varchar(500)  NOT NULL DEFAULT '' COMMENT '失败原因',
+    `creator_id`          varchar(40)   NOT NULL DEFAULT '' COMMENT '创建人',
+    `create_time`         varchar(40)            DEFAULT NULL COMMENT '创建时间',
+    `modifier_id`         varchar(40)   NOT NULL DEFAULT '' COMMENT '更新人',
+    `modify_time`         varchar(40)            DEFAULT NULL COMMENT '更新时间',
+    PRIMARY KEY (`id`),
+    KEY `idx_process_instance_id` (`process_instance_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COMMENT='通知发送记录表';
+
+ALTER TABLE flow_abnormal_task  ADD  execution_id varchar(100) NOT NULL DEFAULT '' COMMENT '挂起的自动节点执行id' after task_def_key;